@@ -0,0 +1,265 @@
+// Package archive moves logs past their hot retention out of analytics_logs
+// into gzip compressed Parquet files, one per UTC day and event type, kept in
+// a blob store and listed in the archive_manifest table.
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log-ingestion-server/blobstore"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// day is the range covered by an archived file
+const day = 24 * time.Hour
+
+// Options configures the archiver
+type Options struct {
+	// After is how old a log's whole UTC day must be before it is archived
+	After time.Duration
+	// Interval between archive rounds
+	Interval time.Duration
+	// MaxRowsPerFile bounds the logs written to one file, and held in memory
+	MaxRowsPerFile int
+	// Prefix is prepended to the key of every file
+	Prefix string
+}
+
+// Archiver periodically archives the logs of closed days. A round holds a
+// PostgreSQL advisory lock, so when several replicas run the archiver only
+// one of them works at a time.
+type Archiver struct {
+	db      *database.DB
+	store   blobstore.Store
+	opts    Options
+	metrics *Metrics
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the archiver
+type Metrics struct {
+	LogsArchived prometheus.Counter
+	FilesWritten prometheus.Counter
+	BytesWritten prometheus.Counter
+	Errors       prometheus.Counter
+	LastSuccess  prometheus.Gauge
+}
+
+// NewArchiver creates an archiver writing to store
+func NewArchiver(db *database.DB, store blobstore.Store, opts Options) *Archiver {
+	metrics := &Metrics{
+		LogsArchived: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archive_logs_archived_total",
+				Help: "Total number of logs moved to the archive by this instance",
+			},
+		),
+		FilesWritten: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archive_files_written_total",
+				Help: "Total number of Parquet files written to the archive",
+			},
+		),
+		BytesWritten: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archive_bytes_written_total",
+				Help: "Total size of the Parquet files written to the archive",
+			},
+		),
+		Errors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archive_errors_total",
+				Help: "Total number of failed archive rounds",
+			},
+		),
+		LastSuccess: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "archive_last_success_timestamp_seconds",
+				Help: "Unix time of the last complete archive round on this instance",
+			},
+		),
+	}
+
+	prometheus.MustRegister(
+		metrics.LogsArchived,
+		metrics.FilesWritten,
+		metrics.BytesWritten,
+		metrics.Errors,
+		metrics.LastSuccess,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Archiver{
+		db:      db,
+		store:   store,
+		opts:    opts,
+		metrics: metrics,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs archive rounds in the background until Stop is called
+func (a *Archiver) Start() {
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			a.runRound()
+
+			select {
+			case <-ticker.C:
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	logrus.Infof("Archiver started (logs older than %s to %s, every %s)", a.opts.After, a.store, a.opts.Interval)
+}
+
+// Stop interrupts the current round between files and stops the archiver
+func (a *Archiver) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// runRound archives every day that closed more than After ago, oldest first
+func (a *Archiver) runRound() {
+	release, err := a.db.TryArchiveLock(a.ctx)
+	if err != nil {
+		if a.ctx.Err() == nil {
+			a.fail("Failed to take the archive lock: %v", err)
+		}
+		return
+	}
+	if release == nil {
+		logrus.Debug("Archive round skipped: another instance holds the lock")
+		return
+	}
+	defer release()
+
+	cutoff := time.Now().UTC().Add(-a.opts.After).Truncate(day)
+	for a.ctx.Err() == nil {
+		oldest, err := a.db.OldestLogTimestamp(a.ctx)
+		if err != nil {
+			a.fail("Failed to find logs to archive: %v", err)
+			return
+		}
+		if oldest == nil || !oldest.Before(cutoff) {
+			a.metrics.LastSuccess.SetToCurrentTime()
+			return
+		}
+
+		from := oldest.UTC().Truncate(day)
+		archived, err := a.archiveDay(from)
+		if err != nil {
+			if a.ctx.Err() == nil {
+				a.fail("Failed to archive logs of %s: %v", from.Format("2006-01-02"), err)
+			}
+			return
+		}
+		if archived == 0 {
+			// Only possible if the day's logs were deleted meanwhile
+			return
+		}
+
+		logrus.Infof("Archived %d logs of %s", archived, from.Format("2006-01-02"))
+	}
+}
+
+// archiveDay archives the logs of the UTC day starting at from, writing at
+// least one file per event type
+func (a *Archiver) archiveDay(from time.Time) (int, error) {
+	to := from.Add(day)
+	eventTypes, err := a.db.LogEventTypesBetween(a.ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, eventType := range eventTypes {
+		for {
+			if err := a.ctx.Err(); err != nil {
+				return total, err
+			}
+
+			archived, err := a.db.ArchiveLogs(a.ctx, eventType, from, to, a.opts.MaxRowsPerFile,
+				func(logs []models.AnalyticsLog) (*models.ArchiveFile, error) {
+					return a.export(from, eventType, logs)
+				})
+			if err != nil {
+				return total, err
+			}
+			total += archived
+			a.metrics.LogsArchived.Add(float64(archived))
+
+			if archived < a.opts.MaxRowsPerFile {
+				break
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// export writes logs to a new file in the store
+func (a *Archiver) export(date time.Time, eventType string, logs []models.AnalyticsLog) (*models.ArchiveFile, error) {
+	data, err := Encode(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hive-style directories let query engines prune by date and event type
+	key := fmt.Sprintf("%s/date=%s/event_type=%s/part-%s.parquet",
+		a.opts.Prefix, date.Format("2006-01-02"), url.PathEscape(eventType),
+		time.Now().UTC().Format("20060102T150405.000000000"))
+
+	if err := a.store.Put(a.ctx, key, data); err != nil {
+		return nil, fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	a.metrics.FilesWritten.Inc()
+	a.metrics.BytesWritten.Add(float64(len(data)))
+
+	sum := sha256.Sum256(data)
+	file := &models.ArchiveFile{
+		ObjectKey:    key,
+		LogDate:      date,
+		EventType:    eventType,
+		RowCount:     int64(len(logs)),
+		SizeBytes:    int64(len(data)),
+		SHA256:       hex.EncodeToString(sum[:]),
+		MinTimestamp: logs[0].Timestamp,
+		MaxTimestamp: logs[0].Timestamp,
+	}
+	for _, log := range logs[1:] {
+		if log.Timestamp.Before(file.MinTimestamp) {
+			file.MinTimestamp = log.Timestamp
+		}
+		if log.Timestamp.After(file.MaxTimestamp) {
+			file.MaxTimestamp = log.Timestamp
+		}
+	}
+
+	return file, nil
+}
+
+func (a *Archiver) fail(format string, args ...interface{}) {
+	a.metrics.Errors.Inc()
+	logrus.Errorf(format, args...)
+}
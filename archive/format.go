@@ -0,0 +1,225 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// row is the Parquet schema of archived logs, mirroring analytics_logs.
+// Timestamps are stored in microseconds, the precision of Postgres
+type row struct {
+	ID                 int64   `parquet:"name=id, type=INT64"`
+	EventID            string  `parquet:"name=event_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Timestamp          int64   `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+	EventType          string  `parquet:"name=event_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EventName          string  `parquet:"name=event_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Properties         *string `parquet:"name=properties, type=BYTE_ARRAY, convertedtype=JSON, repetitiontype=OPTIONAL"`
+	UserID             *string `parquet:"name=user_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	SessionID          *string `parquet:"name=session_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	AppVersion         *string `parquet:"name=app_version, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	DeviceInfo         *string `parquet:"name=device_info, type=BYTE_ARRAY, convertedtype=JSON, repetitiontype=OPTIONAL"`
+	SequenceNumber     *int32  `parquet:"name=sequence_number, type=INT32, repetitiontype=OPTIONAL"`
+	Priority           string  `parquet:"name=priority, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreatedAt          int64   `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+	ProcessedAt        *int64  `parquet:"name=processed_at, type=INT64, convertedtype=TIMESTAMP_MICROS, repetitiontype=OPTIONAL"`
+	CorrectedTimestamp *int64  `parquet:"name=corrected_timestamp, type=INT64, convertedtype=TIMESTAMP_MICROS, repetitiontype=OPTIONAL"`
+	ClockSkewMs        *int64  `parquet:"name=clock_skew_ms, type=INT64, repetitiontype=OPTIONAL"`
+	ClockSkewFlagged   bool    `parquet:"name=clock_skew_flagged, type=BOOLEAN"`
+	SampleRate         float64 `parquet:"name=sample_rate, type=DOUBLE"`
+}
+
+// Encode writes logs as a Parquet file
+func Encode(logs []models.AnalyticsLog) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := writer.NewParquetWriterFromWriter(&buf, new(row), 1)
+	if err != nil {
+		return nil, err
+	}
+	w.CompressionType = parquet.CompressionCodec_GZIP
+
+	for i := range logs {
+		log := &logs[i]
+
+		properties, err := jsonValue(log.Properties)
+		if err != nil {
+			return nil, err
+		}
+		deviceInfo, err := jsonValue(log.DeviceInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		r := row{
+			ID:                 log.ID,
+			EventID:            log.EventID,
+			Timestamp:          log.Timestamp.UnixMicro(),
+			EventType:          log.EventType,
+			EventName:          log.EventName,
+			Properties:         properties,
+			UserID:             log.UserID,
+			SessionID:          log.SessionID,
+			AppVersion:         log.AppVersion,
+			DeviceInfo:         deviceInfo,
+			Priority:           log.Priority,
+			CreatedAt:          log.CreatedAt.UnixMicro(),
+			ProcessedAt:        timeValue(log.ProcessedAt),
+			CorrectedTimestamp: timeValue(log.CorrectedTimestamp),
+			ClockSkewMs:        log.ClockSkewMs,
+			ClockSkewFlagged:   log.ClockSkewFlagged,
+			SampleRate:         log.SampleRate,
+		}
+		if log.SequenceNumber != nil {
+			seq := int32(*log.SequenceNumber)
+			r.SequenceNumber = &seq
+		}
+
+		if err := w.Write(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.WriteStop(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads the logs of a file written by Encode
+func Decode(data []byte) (logs []models.AnalyticsLog, err error) {
+	// parquet-go panics on some malformed files rather than returning an error
+	defer func() {
+		if p := recover(); p != nil {
+			logs, err = nil, fmt.Errorf("invalid archive file: %v", p)
+		}
+	}()
+
+	if err := checkSchema(data); err != nil {
+		return nil, err
+	}
+
+	r, err := reader.NewParquetReader(buffer.NewBufferFileFromBytes(data), new(row), 1)
+	if err != nil {
+		return nil, err
+	}
+	defer r.ReadStop()
+
+	rows := make([]row, r.GetNumRows())
+	if err := r.Read(&rows); err != nil {
+		return nil, err
+	}
+
+	logs = make([]models.AnalyticsLog, len(rows))
+	for n := range rows {
+		row := &rows[n]
+		log := &logs[n]
+
+		log.ID = row.ID
+		log.EventID = row.EventID
+		log.Timestamp = time.UnixMicro(row.Timestamp).UTC()
+		log.EventType = row.EventType
+		log.EventName = row.EventName
+		log.UserID = row.UserID
+		log.SessionID = row.SessionID
+		log.AppVersion = row.AppVersion
+		log.Priority = row.Priority
+		log.CreatedAt = time.UnixMicro(row.CreatedAt).UTC()
+		log.ProcessedAt = timePointer(row.ProcessedAt)
+		log.CorrectedTimestamp = timePointer(row.CorrectedTimestamp)
+		log.ClockSkewMs = row.ClockSkewMs
+		log.ClockSkewFlagged = row.ClockSkewFlagged
+		log.SampleRate = row.SampleRate
+
+		if row.SequenceNumber != nil {
+			seq := int(*row.SequenceNumber)
+			log.SequenceNumber = &seq
+		}
+
+		if log.Properties, err = jsonMap(row.Properties); err != nil {
+			return nil, fmt.Errorf("invalid properties of log %s: %w", log.EventID, err)
+		}
+		if log.DeviceInfo, err = jsonMap(row.DeviceInfo); err != nil {
+			return nil, fmt.Errorf("invalid device_info of log %s: %w", log.EventID, err)
+		}
+	}
+
+	return logs, nil
+}
+
+// checkSchema rejects files whose columns differ from row's, which
+// parquet-go would otherwise read as zero values or panic on
+func checkSchema(data []byte) error {
+	r, err := reader.NewParquetReader(buffer.NewBufferFileFromBytes(data), nil, 1)
+	if err != nil {
+		return fmt.Errorf("invalid archive file: %w", err)
+	}
+	defer r.ReadStop()
+
+	expected, err := schema.NewSchemaHandlerFromStruct(new(row))
+	if err != nil {
+		return err
+	}
+
+	// The reader renames the footer's columns, so compare external names
+	got := r.SchemaHandler
+	if len(got.SchemaElements) != len(expected.SchemaElements) {
+		return fmt.Errorf("archive file has %d columns, want %d",
+			len(got.SchemaElements)-1, len(expected.SchemaElements)-1)
+	}
+	for i := 1; i < len(expected.SchemaElements); i++ {
+		column, want := got.SchemaElements[i], expected.SchemaElements[i]
+		name := expected.Infos[i].ExName
+		if got.Infos[i].ExName != name || column.GetType() != want.GetType() ||
+			column.IsSetConvertedType() != want.IsSetConvertedType() ||
+			column.GetConvertedType() != want.GetConvertedType() ||
+			column.GetRepetitionType() != want.GetRepetitionType() {
+			return fmt.Errorf("archive file has no %s column of the expected type", name)
+		}
+	}
+	return nil
+}
+
+func jsonValue(m models.JSONB) (*string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
+
+func jsonMap(s *string) (models.JSONB, error) {
+	if s == nil {
+		return nil, nil
+	}
+	var m models.JSONB
+	err := json.Unmarshal([]byte(*s), &m)
+	return m, err
+}
+
+func timeValue(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	micros := t.UnixMicro()
+	return &micros
+}
+
+func timePointer(micros *int64) *time.Time {
+	if micros == nil {
+		return nil
+	}
+	t := time.UnixMicro(*micros).UTC()
+	return &t
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"log-ingestion-server/models"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 123456000, time.UTC)
+	userID, sessionID, appVersion := "user-1", "session-1", "2.4.0"
+	sequence := 7
+	skew := int64(-1500)
+	corrected := at.Add(1500 * time.Millisecond)
+	processed := at.Add(time.Second)
+
+	logs := []models.AnalyticsLog{
+		{
+			ID:                 42,
+			EventID:            "evt-full",
+			Timestamp:          at,
+			EventType:          "behavioral",
+			EventName:          "habit_completed",
+			Properties:         models.JSONB{"habit": "run", "streak": 3.0, "tags": []interface{}{"a", "b"}},
+			UserID:             &userID,
+			SessionID:          &sessionID,
+			AppVersion:         &appVersion,
+			DeviceInfo:         models.JSONB{"os": "ios"},
+			SequenceNumber:     &sequence,
+			Priority:           "high",
+			CreatedAt:          at.Add(2 * time.Second),
+			ProcessedAt:        &processed,
+			CorrectedTimestamp: &corrected,
+			ClockSkewMs:        &skew,
+			ClockSkewFlagged:   true,
+			SampleRate:         0.25,
+		},
+		{
+			// Every optional column null
+			ID:         43,
+			EventID:    "evt-minimal",
+			Timestamp:  at,
+			EventType:  "system",
+			EventName:  "app_open",
+			Priority:   "normal",
+			CreatedAt:  at,
+			SampleRate: 1,
+		},
+	}
+
+	data, err := Encode(logs)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if len(decoded) != len(logs) {
+		t.Fatalf("decoded %d logs, want %d", len(decoded), len(logs))
+	}
+	for i := range logs {
+		want, _ := json.Marshal(&logs[i])
+		got, _ := json.Marshal(&decoded[i])
+		if string(got) != string(want) {
+			t.Errorf("log %d decoded as\n%s\nwant\n%s", i, got, want)
+		}
+	}
+}
+
+func TestDecodeRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not Parquet", data: []byte("not a parquet file")},
+		{name: "truncated", data: mustEncode(t)[:40]},
+		{name: "fewer columns", data: otherSchema(t, func(columns []*parquet.SchemaElement) []*parquet.SchemaElement {
+			children := int32(2)
+			columns[0].NumChildren = &children
+			return columns[:3]
+		})},
+		{name: "column of another type", data: otherSchema(t, func(columns []*parquet.SchemaElement) []*parquet.SchemaElement {
+			columns[2].Type = parquet.TypePtr(parquet.Type_INT64)
+			columns[2].ConvertedType = nil
+			return columns
+		})},
+		{name: "renamed column", data: otherSchema(t, func(columns []*parquet.SchemaElement) []*parquet.SchemaElement {
+			columns[2].Name = "event_uuid"
+			return columns
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil {
+				t.Error("Decode accepted the file")
+			}
+		})
+	}
+}
+
+func mustEncode(t *testing.T) []byte {
+	t.Helper()
+	data, err := Encode([]models.AnalyticsLog{{EventID: "evt-1", Priority: "normal"}})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return data
+}
+
+// otherSchema returns an empty Parquet file with the archive schema as
+// changed by change
+func otherSchema(t *testing.T, change func([]*parquet.SchemaElement) []*parquet.SchemaElement) []byte {
+	t.Helper()
+	handler, err := schema.NewSchemaHandlerFromStruct(new(row))
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := make([]*parquet.SchemaElement, len(handler.SchemaElements))
+	for i, column := range handler.SchemaElements {
+		copied := *column
+		copied.Name = handler.Infos[i].ExName
+		columns[i] = &copied
+	}
+
+	var buf bytes.Buffer
+	w, err := writer.NewParquetWriterFromWriter(&buf, change(columns), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteStop(); err != nil {
+		t.Fatalf("WriteStop: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -15,13 +15,13 @@ import (
 
 // AuthService handles API key authentication
 type AuthService struct {
-	db       *database.DB
+	db       database.Store
 	apiKeys  map[string]bool // In-memory cache for API keys
 	lastSync time.Time
 }
 
 // NewAuthService creates a new authentication service
-func NewAuthService(db *database.DB) *AuthService {
+func NewAuthService(db database.Store) *AuthService {
 	return &AuthService{
 		db:      db,
 		apiKeys: make(map[string]bool),
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// erroringStore is a memory store whose API key lookups fail
+type erroringStore struct {
+	*database.MemoryStore
+}
+
+func (erroringStore) GetAPIKey(keyHash string) (*models.APIKey, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestInitializeAPIKeys(t *testing.T) {
+	store := database.NewMemoryStore()
+	as := NewAuthService(store)
+
+	if err := as.InitializeAPIKeys([]string{"key-one", "key-two"}); err != nil {
+		t.Fatalf("InitializeAPIKeys: %v", err)
+	}
+	// Keys already stored are reused rather than inserted again
+	if err := NewAuthService(store).InitializeAPIKeys([]string{"key-one"}); err != nil {
+		t.Fatalf("InitializeAPIKeys with a stored key: %v", err)
+	}
+
+	for _, key := range []string{"key-one", "key-two"} {
+		apiKey, err := store.GetAPIKey(as.hashAPIKey(key))
+		if err != nil || apiKey == nil {
+			t.Errorf("%s not stored: %v", key, err)
+		}
+	}
+}
+
+func TestInitializeAPIKeysStoreError(t *testing.T) {
+	as := NewAuthService(erroringStore{database.NewMemoryStore()})
+
+	if err := as.InitializeAPIKeys([]string{"key-one"}); err == nil {
+		t.Error("InitializeAPIKeys succeeded with a failing store")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		stored   *models.APIKey
+		header   string
+		value    string
+		failing  bool
+		wantCode int
+	}{
+		{name: "configured key in X-API-Key", header: "X-API-Key", value: "configured-key", wantCode: http.StatusOK},
+		{name: "configured key as bearer token", header: "Authorization", value: "Bearer configured-key", wantCode: http.StatusOK},
+		{name: "missing key", wantCode: http.StatusUnauthorized},
+		{name: "unknown key", header: "X-API-Key", value: "unknown-key", wantCode: http.StatusUnauthorized},
+		{
+			name:     "stored key not yet cached",
+			stored:   &models.APIKey{Name: "stored", IsActive: true},
+			header:   "X-API-Key",
+			value:    "stored-key",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "inactive key",
+			stored:   &models.APIKey{Name: "inactive", IsActive: false},
+			header:   "X-API-Key",
+			value:    "stored-key",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "expired key",
+			stored:   &models.APIKey{Name: "expired", IsActive: true, ExpiresAt: &expired},
+			header:   "X-API-Key",
+			value:    "stored-key",
+			wantCode: http.StatusUnauthorized,
+		},
+		{name: "store error", header: "X-API-Key", value: "unknown-key", failing: true, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := database.NewMemoryStore()
+			as := NewAuthService(memory)
+			if err := as.InitializeAPIKeys([]string{"configured-key"}); err != nil {
+				t.Fatalf("InitializeAPIKeys: %v", err)
+			}
+			if tt.stored != nil {
+				tt.stored.KeyHash = as.hashAPIKey("stored-key")
+				if err := memory.InsertAPIKey(tt.stored); err != nil {
+					t.Fatalf("InsertAPIKey: %v", err)
+				}
+			}
+			if tt.failing {
+				as.db = erroringStore{memory}
+			}
+
+			router := gin.New()
+			router.GET("/", as.AuthMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package blobstore stores archive files under slash-separated keys, on the
+// local filesystem or in an S3-compatible object store.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log-ingestion-server/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a missing key
+var ErrNotFound = errors.New("blob not found")
+
+// Store holds blobs under slash-separated keys
+type Store interface {
+	// Put stores data under key, replacing any existing blob
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the blob stored under key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// String describes the store for logs
+	String() string
+}
+
+// FromConfig opens the store selected by ARCHIVE_STORE
+func FromConfig(cfg config.ArchiveConfig) (Store, error) {
+	if cfg.Store == "s3" {
+		return NewS3(S3Options{
+			Endpoint:     cfg.S3.Endpoint,
+			Region:       cfg.S3.Region,
+			Bucket:       cfg.S3.Bucket,
+			AccessKey:    cfg.S3.AccessKey,
+			SecretKey:    cfg.S3.SecretKey,
+			SessionToken: cfg.S3.SessionToken,
+			PathStyle:    cfg.S3.PathStyle,
+		})
+	}
+	return NewLocal(cfg.LocalPath)
+}
+
+// Local stores blobs as files below a root directory
+type Local struct {
+	root string
+}
+
+// NewLocal returns a store writing below root, creating it if needed
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &Local{root: root}, nil
+}
+
+// Put writes the blob to a temporary file, syncs it and renames it into
+// place, so a crash never leaves a partial file under key
+func (l *Local) Put(ctx context.Context, key string, data []byte) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads the file of key
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) String() string {
+	return "local directory " + l.root
+}
+
+// path maps a key below the root, rejecting keys that would escape it
+func (l *Local) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
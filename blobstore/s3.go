@@ -0,0 +1,112 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3RequestTimeout bounds a single object request
+const s3RequestTimeout = 5 * time.Minute
+
+// defaultS3Endpoint is used when no endpoint is configured
+const defaultS3Endpoint = "https://s3.amazonaws.com"
+
+// S3Options configures an S3-compatible store
+type S3Options struct {
+	// Endpoint is the base URL of the service, such as
+	// https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO.
+	// Empty means AWS
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// SessionToken accompanies temporary credentials, such as those of an
+	// assumed IAM role
+	SessionToken string
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// as MinIO and most self-hosted services expect
+	PathStyle bool
+}
+
+// S3 stores blobs as objects of a bucket
+type S3 struct {
+	opts   S3Options
+	client *minio.Client
+}
+
+// NewS3 returns a store writing to the bucket described by opts
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Endpoint == "" {
+		opts.Endpoint = defaultS3Endpoint
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" || endpoint.Path != "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket must be provided")
+	}
+
+	lookup := minio.BucketLookupDNS
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, opts.SessionToken),
+		Secure:       endpoint.Scheme == "https",
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 configuration: %w", err)
+	}
+
+	return &S3{opts: opts, client: client}, nil
+}
+
+// Put uploads an object
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+
+	_, err := s.client.PutObject(ctx, s.opts.Bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		return fmt.Errorf("S3 PUT %s failed: %w", key, err)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+
+	object, err := s.client.GetObject(ctx, s.opts.Bucket, key, minio.GetObjectOptions{})
+	if err == nil {
+		defer object.Close()
+		var data []byte
+		if data, err = io.ReadAll(object); err == nil {
+			return data, nil
+		}
+	}
+
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("S3 GET %s failed: %w", key, err)
+}
+
+func (s *S3) String() string {
+	return fmt.Sprintf("S3 bucket %s at %s", s.opts.Bucket, s.client.EndpointURL().Host)
+}
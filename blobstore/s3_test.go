@@ -0,0 +1,220 @@
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory S3 service for one bucket with path-style URLs
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests []*http.Request
+	// status, when set, fails every request with that status
+	status int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	if f.status != 0 {
+		writeS3Error(w, f.status, "AccessDenied")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err == nil && strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body, err = decodeChunked(body)
+		}
+		if err != nil {
+			f.t.Errorf("reading upload: %v", err)
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Fri, 16 Oct 2026 09:30:00 GMT")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// decodeChunked returns the payload of a body sent with the aws-chunked
+// encoding of streaming signatures
+func decodeChunked(body []byte) ([]byte, error) {
+	var payload []byte
+	r := bufio.NewReader(bytes.NewReader(body))
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+		if n == 0 {
+			return payload, nil
+		}
+		chunk := make([]byte, n+2)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		payload = append(payload, chunk[:n]...)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>`+code+`</Code><Message>`+code+`</Message></Error>`)
+}
+
+func newFakeS3(t *testing.T, sessionToken string) (*S3, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{t: t, bucket: "archive", objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3(S3Options{
+		Endpoint:     server.URL,
+		Region:       "eu-west-1",
+		Bucket:       "archive",
+		AccessKey:    "AKIDEXAMPLE",
+		SecretKey:    "secret",
+		SessionToken: sessionToken,
+		PathStyle:    true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return store, fake
+}
+
+func TestS3PutGet(t *testing.T) {
+	store, fake := newFakeS3(t, "")
+	ctx := context.Background()
+
+	key := "analytics_logs/date=2026-10-16/event_type=behavioral/part 1.parquet"
+	data := []byte("PAR1 archived logs PAR1")
+	if err := store.Put(ctx, key, data); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := string(fake.objects[key]); got != string(data) {
+		t.Errorf("stored %q, want %q", got, data)
+	}
+
+	got, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	if _, err := store.Get(ctx, "analytics_logs/missing.parquet"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3SignsRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		sessionToken string
+	}{
+		{name: "long-term credentials"},
+		{name: "temporary credentials", sessionToken: "session-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, fake := newFakeS3(t, tt.sessionToken)
+			if err := store.Put(context.Background(), "key", []byte("data")); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			for _, r := range fake.requests {
+				auth := r.Header.Get("Authorization")
+				if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+					!strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+					t.Errorf("%s %s has Authorization %q", r.Method, r.URL.Path, auth)
+				}
+				if got := r.Header.Get("X-Amz-Security-Token"); got != tt.sessionToken {
+					t.Errorf("%s %s has session token %q, want %q", r.Method, r.URL.Path, got, tt.sessionToken)
+				}
+			}
+		})
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	store, fake := newFakeS3(t, "")
+	fake.status = http.StatusForbidden
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "key", []byte("data")); err == nil {
+		t.Error("Put succeeded although the service refused it")
+	}
+	if _, err := store.Get(ctx, "key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want an error other than ErrNotFound", err)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     S3Options
+		wantErr  bool
+		wantHost string
+	}{
+		{name: "AWS by default", opts: S3Options{Bucket: "b", Region: "us-east-1"}, wantHost: "s3.amazonaws.com"},
+		{name: "MinIO", opts: S3Options{Endpoint: "http://minio:9000/", Bucket: "b", PathStyle: true}, wantHost: "minio:9000"},
+		{name: "no bucket", opts: S3Options{Endpoint: "http://minio:9000"}, wantErr: true},
+		{name: "no scheme", opts: S3Options{Endpoint: "minio:9000", Bucket: "b"}, wantErr: true},
+		{name: "path in endpoint", opts: S3Options{Endpoint: "http://minio:9000/s3", Bucket: "b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewS3 error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && !strings.Contains(store.String(), tt.wantHost) {
+				t.Errorf("store %s, want host %s", store, tt.wantHost)
+			}
+		})
+	}
+}
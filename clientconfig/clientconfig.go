@@ -0,0 +1,161 @@
+// Package clientconfig builds the configuration document served to client
+// SDKs from server defaults and the overrides stored in the
+// client_config_overrides table, so batching, flushing and client-side
+// sampling can be tuned per release or platform without an app update.
+package clientconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Store loads the client config overrides
+type Store interface {
+	ListClientConfigOverrides() ([]models.ClientConfigOverride, error)
+}
+
+// Defaults is the configuration served to clients no override targets
+type Defaults struct {
+	// MaxBatchSize is the server's batch limit; overrides may only lower it
+	MaxBatchSize  int
+	FlushInterval time.Duration
+	// RefreshInterval tells clients how long to cache the document
+	RefreshInterval time.Duration
+}
+
+// Registry caches the active overrides in application order
+type Registry struct {
+	store    Store
+	defaults Defaults
+
+	mu        sync.RWMutex
+	overrides []models.ClientConfigOverride
+}
+
+// NewRegistry creates an empty registry backed by store
+func NewRegistry(store Store, defaults Defaults) *Registry {
+	return &Registry{store: store, defaults: defaults}
+}
+
+// Load replaces the cache with the active overrides from the store.
+// Overrides with an invalid app version pattern are skipped and logged.
+func (r *Registry) Load() error {
+	stored, err := r.store.ListClientConfigOverrides()
+	if err != nil {
+		return err
+	}
+
+	overrides := make([]models.ClientConfigOverride, 0, len(stored))
+	for _, o := range stored {
+		if !o.IsActive {
+			continue
+		}
+		if err := Validate(o); err != nil {
+			logrus.Errorf("Skipping client config override %s: %v", o.Name, err)
+			continue
+		}
+		overrides = append(overrides, o)
+	}
+
+	r.mu.Lock()
+	r.overrides = overrides
+	r.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh periodically reloads the cache so override changes made
+// through other server instances take effect
+func (r *Registry) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Load(); err != nil {
+				logrus.Errorf("Failed to refresh client config overrides: %v", err)
+			}
+		}
+	}()
+}
+
+// Validate checks an override's app version pattern
+func Validate(o models.ClientConfigOverride) error {
+	if _, err := path.Match(o.AppVersion, ""); err != nil {
+		return fmt.Errorf("invalid app_version pattern %q", o.AppVersion)
+	}
+	return nil
+}
+
+// Resolve builds the document for a client. Every matching override is
+// applied in order: sampling rates are merged by event name, disabled events
+// accumulate and the last override setting a limit wins.
+func (r *Registry) Resolve(appVersion, platform string) models.ClientConfig {
+	doc := models.ClientConfig{
+		SamplingRates:          make(map[string]float64),
+		DisabledEvents:         []string{},
+		MaxBatchSize:           r.defaults.MaxBatchSize,
+		FlushIntervalSeconds:   int(r.defaults.FlushInterval / time.Second),
+		RefreshIntervalSeconds: int(r.defaults.RefreshInterval / time.Second),
+	}
+	disabled := make(map[string]bool)
+
+	r.mu.RLock()
+	for _, o := range r.overrides {
+		if !matches(o, appVersion, platform) {
+			continue
+		}
+		for eventName, rate := range o.SamplingRates {
+			doc.SamplingRates[eventName] = rate
+		}
+		for _, eventName := range o.DisabledEvents {
+			disabled[eventName] = true
+		}
+		if o.MaxBatchSize != nil {
+			doc.MaxBatchSize = min(*o.MaxBatchSize, r.defaults.MaxBatchSize)
+		}
+		if o.FlushIntervalSeconds != nil {
+			doc.FlushIntervalSeconds = *o.FlushIntervalSeconds
+		}
+	}
+	r.mu.RUnlock()
+
+	for eventName := range disabled {
+		doc.DisabledEvents = append(doc.DisabledEvents, eventName)
+	}
+	sort.Strings(doc.DisabledEvents)
+
+	doc.Version = version(doc)
+	return doc
+}
+
+func matches(o models.ClientConfigOverride, appVersion, platform string) bool {
+	if o.AppVersion != "" {
+		if ok, _ := path.Match(o.AppVersion, appVersion); !ok || appVersion == "" {
+			return false
+		}
+	}
+	if o.Platform != "" && !strings.EqualFold(o.Platform, platform) {
+		return false
+	}
+	return true
+}
+
+// version hashes the document's content, so every server instance serves the
+// same version and ETag for the same configuration
+func version(doc models.ClientConfig) string {
+	doc.Version = ""
+	content, _ := json.Marshal(doc)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
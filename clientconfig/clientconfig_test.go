@@ -0,0 +1,202 @@
+package clientconfig
+
+import (
+	"log-ingestion-server/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	overrides []models.ClientConfigOverride
+}
+
+func (s *fakeStore) ListClientConfigOverrides() ([]models.ClientConfigOverride, error) {
+	return s.overrides, nil
+}
+
+var testDefaults = Defaults{MaxBatchSize: 100, FlushInterval: 30 * time.Second, RefreshInterval: 5 * time.Minute}
+
+func newTestRegistry(t *testing.T, overrides ...models.ClientConfigOverride) (*Registry, *fakeStore) {
+	t.Helper()
+	for i := range overrides {
+		overrides[i].IsActive = true
+	}
+	store := &fakeStore{overrides: overrides}
+	r := NewRegistry(store, testDefaults)
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return r, store
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestResolve(t *testing.T) {
+	r, _ := newTestRegistry(t,
+		models.ClientConfigOverride{
+			Name:          "everyone",
+			SamplingRates: map[string]float64{"scroll": 0.5, "frame": 0.1},
+		},
+		models.ClientConfigOverride{
+			Name:           "ios",
+			Platform:       "iOS",
+			DisabledEvents: []string{"legacy_ping"},
+			MaxBatchSize:   intPtr(50),
+		},
+		models.ClientConfigOverride{
+			Name:                 "2.3 releases",
+			AppVersion:           "2.3.*",
+			SamplingRates:        map[string]float64{"scroll": 0.2},
+			DisabledEvents:       []string{"debug_trace", "legacy_ping"},
+			MaxBatchSize:         intPtr(500),
+			FlushIntervalSeconds: intPtr(10),
+		},
+	)
+
+	tests := []struct {
+		name       string
+		appVersion string
+		platform   string
+		want       models.ClientConfig
+	}{
+		{
+			name:     "only the catch-all",
+			platform: "Android",
+			want: models.ClientConfig{
+				SamplingRates: map[string]float64{"scroll": 0.5, "frame": 0.1}, DisabledEvents: []string{},
+				MaxBatchSize: 100, FlushIntervalSeconds: 30, RefreshIntervalSeconds: 300,
+			},
+		},
+		{
+			name:     "platform compared without case",
+			platform: "ios",
+			want: models.ClientConfig{
+				SamplingRates: map[string]float64{"scroll": 0.5, "frame": 0.1}, DisabledEvents: []string{"legacy_ping"},
+				MaxBatchSize: 50, FlushIntervalSeconds: 30, RefreshIntervalSeconds: 300,
+			},
+		},
+		{
+			// Later overrides win, and a batch size above the server's is capped
+			name:       "every override in order",
+			appVersion: "2.3.1",
+			platform:   "iOS",
+			want: models.ClientConfig{
+				SamplingRates: map[string]float64{"scroll": 0.2, "frame": 0.1}, DisabledEvents: []string{"debug_trace", "legacy_ping"},
+				MaxBatchSize: 100, FlushIntervalSeconds: 10, RefreshIntervalSeconds: 300,
+			},
+		},
+		{
+			name:       "version pattern does not match",
+			appVersion: "2.4.0",
+			platform:   "Android",
+			want: models.ClientConfig{
+				SamplingRates: map[string]float64{"scroll": 0.5, "frame": 0.1}, DisabledEvents: []string{},
+				MaxBatchSize: 100, FlushIntervalSeconds: 30, RefreshIntervalSeconds: 300,
+			},
+		},
+		{
+			name: "no app version skips version overrides",
+			want: models.ClientConfig{
+				SamplingRates: map[string]float64{"scroll": 0.5, "frame": 0.1}, DisabledEvents: []string{},
+				MaxBatchSize: 100, FlushIntervalSeconds: 30, RefreshIntervalSeconds: 300,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Resolve(tt.appVersion, tt.platform)
+			if got.Version == "" {
+				t.Error("document has no version")
+			}
+			got.Version = ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWithoutOverrides(t *testing.T) {
+	r, _ := newTestRegistry(t)
+	doc := r.Resolve("1.0.0", "Android")
+	if doc.SamplingRates == nil || doc.DisabledEvents == nil {
+		t.Error("empty collections are nil, want them served as {} and []")
+	}
+	if doc.MaxBatchSize != 100 || doc.FlushIntervalSeconds != 30 || doc.RefreshIntervalSeconds != 300 {
+		t.Errorf("Resolve = %+v, want the defaults", doc)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	r, store := newTestRegistry(t,
+		models.ClientConfigOverride{Name: "ios", Platform: "iOS", SamplingRates: map[string]float64{"a": 0.1, "b": 0.2, "c": 0.3}},
+	)
+
+	ios := r.Resolve("1.0", "iOS")
+	android := r.Resolve("1.0", "Android")
+
+	// The same content always gets the same version, however the maps are
+	// iterated, and a different version otherwise
+	for i := 0; i < 20; i++ {
+		if again := r.Resolve("1.0", "iOS"); again.Version != ios.Version {
+			t.Fatalf("version changed between resolutions: %s, %s", ios.Version, again.Version)
+		}
+	}
+	if ios.Version == android.Version {
+		t.Error("different documents share a version")
+	}
+	if other := r.Resolve("2.0", "iOS"); other.Version != ios.Version {
+		t.Error("the same document for another app version has a different version")
+	}
+
+	// A registry on another instance serves the same version
+	other := NewRegistry(store, testDefaults)
+	if err := other.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if doc := other.Resolve("1.0", "iOS"); doc.Version != ios.Version {
+		t.Errorf("another instance serves version %s, want %s", doc.Version, ios.Version)
+	}
+
+	// Changing an override changes the version
+	store.overrides[0].SamplingRates = map[string]float64{"a": 0.1, "b": 0.2, "c": 0.4}
+	if err := r.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if doc := r.Resolve("1.0", "iOS"); doc.Version == ios.Version {
+		t.Error("version unchanged after the override changed")
+	}
+}
+
+func TestLoadSkipsInactiveAndInvalidOverrides(t *testing.T) {
+	r := NewRegistry(&fakeStore{overrides: []models.ClientConfigOverride{
+		{Name: "inactive", MaxBatchSize: intPtr(1)},
+		{Name: "invalid", AppVersion: "2.[", MaxBatchSize: intPtr(2), IsActive: true},
+		{Name: "active", MaxBatchSize: intPtr(3), IsActive: true},
+	}}, testDefaults)
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if doc := r.Resolve("2.0", ""); doc.MaxBatchSize != 3 {
+		t.Errorf("max batch size = %d, want 3 from the only valid active override", doc.MaxBatchSize)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for pattern, wantErr := range map[string]bool{
+		"":        false,
+		"2.3.1":   false,
+		"2.3.*":   false,
+		"2.[0-3]": false,
+		"2.[":     true,
+		`2.3\`:    true,
+	} {
+		if err := Validate(models.ClientConfigOverride{AppVersion: pattern}); (err != nil) != wantErr {
+			t.Errorf("Validate(%q) = %v, want error %t", pattern, err, wantErr)
+		}
+	}
+}
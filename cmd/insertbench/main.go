@@ -0,0 +1,159 @@
+// Command insertbench compares the throughput of the batch insert strategies
+// in the database package against a real PostgreSQL instance.
+//
+// It reads the same environment configuration as the server, inserts
+// synthetic logs tagged with a unique run ID and deletes them afterwards:
+//
+//	go run ./cmd/insertbench -batches 20 -batch-size 1000
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// strategy is one batch insert implementation under test
+type strategy struct {
+	name   string
+	insert func(db *database.DB, logs []models.AnalyticsLog) error
+}
+
+var strategies = []strategy{
+	{
+		name: "prepared (row by row)",
+		insert: func(db *database.DB, logs []models.AnalyticsLog) error {
+			return db.InsertLogsBatchPrepared(logs)
+		},
+	},
+	{
+		name: "copy",
+		insert: func(db *database.DB, logs []models.AnalyticsLog) error {
+			return db.InsertLogsBatch(logs)
+		},
+	},
+	{
+		name: "copy + staging dedup",
+		insert: func(db *database.DB, logs []models.AnalyticsLog) error {
+			_, err := db.InsertLogsBatchSkipDuplicates(logs)
+			return err
+		},
+	},
+}
+
+func main() {
+	batches := flag.Int("batches", 20, "number of batches per strategy")
+	batchSize := flag.Int("batch-size", 1000, "number of logs per batch")
+	keep := flag.Bool("keep", false, "keep inserted rows instead of deleting them")
+	flag.Parse()
+
+	logrus.SetLevel(logrus.WarnLevel)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logrus.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := database.NewDB(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	runID := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	if !*keep {
+		defer cleanup(cfg, runID)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(out, "strategy\tbatches\tlogs\tduration\tlogs/sec\tms/batch\t\n")
+
+	for s, strat := range strategies {
+		var elapsed time.Duration
+		for b := 0; b < *batches; b++ {
+			logs := syntheticLogs(fmt.Sprintf("%s-%d-%d", runID, s, b), *batchSize)
+
+			start := time.Now()
+			if err := strat.insert(db, logs); err != nil {
+				logrus.Fatalf("%s: batch %d failed: %v", strat.name, b, err)
+			}
+			elapsed += time.Since(start)
+		}
+
+		total := *batches * *batchSize
+		fmt.Fprintf(out, "%s\t%d\t%d\t%s\t%.0f\t%.2f\t\n",
+			strat.name,
+			*batches,
+			total,
+			elapsed.Round(time.Millisecond),
+			float64(total)/elapsed.Seconds(),
+			float64(elapsed.Milliseconds())/float64(*batches),
+		)
+	}
+
+	out.Flush()
+}
+
+// syntheticLogs builds a batch resembling a flushed mobile offline buffer
+func syntheticLogs(prefix string, n int) []models.AnalyticsLog {
+	userID := "bench-user"
+	sessionID := prefix
+	appVersion := "1.0.0"
+
+	logs := make([]models.AnalyticsLog, n)
+	for i := range logs {
+		seq := i
+		logs[i] = models.AnalyticsLog{
+			EventID:   fmt.Sprintf("%s-%d", prefix, i),
+			Timestamp: time.Now(),
+			EventType: "behavioral",
+			EventName: "habit_completed",
+			Properties: models.JSONB{
+				"habit_id": fmt.Sprintf("habit-%d", i%50),
+				"streak":   i % 30,
+				"tags":     map[string]interface{}{"provider": "benchmark"},
+			},
+			UserID:         &userID,
+			SessionID:      &sessionID,
+			AppVersion:     &appVersion,
+			DeviceInfo:     models.JSONB{"platform": "android", "model": "Pixel 8"},
+			SequenceNumber: &seq,
+			Priority:       "normal",
+		}
+	}
+	return logs
+}
+
+// cleanup deletes every row inserted by this run
+func cleanup(cfg *config.Config, runID string) {
+	conn, err := sql.Open("postgres", cfg.GetDatabaseURL())
+	if err != nil {
+		logrus.Errorf("Failed to connect for cleanup: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	result, err := conn.Exec("DELETE FROM analytics_logs WHERE event_id LIKE $1", runID+"-%")
+	if err != nil {
+		logrus.Errorf("Failed to delete benchmark rows: %v", err)
+		return
+	}
+
+	if _, err := conn.Exec("DELETE FROM analytics_log_event_ids WHERE event_id LIKE $1", runID+"-%"); err != nil {
+		logrus.Errorf("Failed to delete benchmark event IDs: %v", err)
+		return
+	}
+
+	if n, err := result.RowsAffected(); err == nil {
+		fmt.Printf("Deleted %d benchmark rows\n", n)
+	}
+}
@@ -0,0 +1,114 @@
+// Command restore re-hydrates archived logs into the analytics_logs_restored
+// table of PostgreSQL.
+//
+// It reads the same environment configuration as the server, looks up the
+// files of the requested UTC days in archive_manifest, verifies their
+// checksums and loads them, skipping logs already restored:
+//
+//	go run ./cmd/restore -from 2024-01-01 -to 2024-01-31 -event-type behavioral
+//
+// With -list it only prints the matching files.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log-ingestion-server/archive"
+	"log-ingestion-server/blobstore"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	fromFlag := flag.String("from", "", "first UTC day to restore (YYYY-MM-DD)")
+	toFlag := flag.String("to", "", "last UTC day to restore (YYYY-MM-DD), defaults to -from")
+	eventType := flag.String("event-type", "", "restore only this event type")
+	list := flag.Bool("list", false, "list the archived files instead of restoring them")
+	flag.Parse()
+
+	logrus.SetLevel(logrus.WarnLevel)
+
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		logrus.Fatalf("-from must be a date such as 2024-01-31")
+	}
+	to := from
+	if *toFlag != "" {
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			logrus.Fatalf("-to must be a date such as 2024-01-31")
+		}
+	}
+	if to.Before(from) {
+		logrus.Fatalf("-to must not be before -from")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logrus.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := database.NewDB(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	files, err := db.ListArchiveFiles(from, to, *eventType)
+	if err != nil {
+		logrus.Fatalf("Failed to list archived files: %v", err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	if *list {
+		fmt.Fprintf(out, "date\tevent_type\tlogs\tbytes\tkey\t\n")
+		for _, f := range files {
+			fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%s\t\n", f.LogDate.Format("2006-01-02"), f.EventType, f.RowCount, f.SizeBytes, f.ObjectKey)
+		}
+		return
+	}
+
+	store, err := blobstore.FromConfig(cfg.Archive)
+	if err != nil {
+		logrus.Fatalf("Failed to open archive store: %v", err)
+	}
+
+	ctx := context.Background()
+	var total int64
+	fmt.Fprintf(out, "key\tlogs\trestored\t\n")
+	for _, f := range files {
+		data, err := store.Get(ctx, f.ObjectKey)
+		if err != nil {
+			logrus.Fatalf("Failed to read %s: %v", f.ObjectKey, err)
+		}
+
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			logrus.Fatalf("Checksum mismatch for %s; the file is corrupt", f.ObjectKey)
+		}
+
+		logs, err := archive.Decode(data)
+		if err != nil {
+			logrus.Fatalf("Failed to decode %s: %v", f.ObjectKey, err)
+		}
+
+		restored, err := db.RestoreLogs(logs)
+		if err != nil {
+			logrus.Fatalf("Failed to restore %s: %v", f.ObjectKey, err)
+		}
+		total += restored
+		fmt.Fprintf(out, "%s\t%d\t%d\t\n", f.ObjectKey, len(logs), restored)
+	}
+
+	fmt.Fprintf(out, "\t\t\t\n%d files\t\t%d\t\n", len(files), total)
+}
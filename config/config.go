@@ -22,6 +22,9 @@ type Config struct {
 	// Database Configuration
 	Database DatabaseConfig
 
+	// Storage Backend
+	Storage StorageConfig
+
 	// API Security
 	APIKeys                    []string
 	EnableAPIKeyRotation       bool
@@ -35,6 +38,48 @@ type Config struct {
 	MaxBatchSize     int
 	BatchTimeout     time.Duration
 	WorkerPoolSize   int
+	IngestQueueSize  int
+
+	// Streaming Ingestion
+	StreamMaxRequestSizeMB int
+	StreamTimeout          time.Duration
+
+	// Write-Ahead Log
+	WAL WALConfig
+
+	// Idempotency
+	IdempotencyTTL   time.Duration
+	IdempotencyLease time.Duration
+
+	// Syslog Listener
+	Syslog SyslogConfig
+
+	// PII Redaction
+	Redaction RedactionConfig
+
+	// Enrichment
+	Enrichment EnrichmentConfig
+
+	// Clock Skew
+	ClockSkewThreshold time.Duration
+
+	// Session Integrity
+	Integrity IntegrityConfig
+
+	// Client SDK Configuration
+	SDK SDKConfig
+
+	// Partitioning
+	Partition PartitionConfig
+
+	// Retention
+	Retention RetentionConfig
+
+	// Rollups
+	Rollup RollupConfig
+
+	// Cold archival
+	Archive ArchiveConfig
 
 	// Monitoring
 	EnableMetrics     bool
@@ -59,6 +104,125 @@ type DatabaseConfig struct {
 	MaxConnections     int
 	MaxIdleConnections int
 	MaxLifetime        time.Duration
+	// AutoMigrate applies pending migrations at startup; turn it off when
+	// deploys run `migrate up` as a separate step
+	AutoMigrate bool
+}
+
+// StorageConfig selects where logs and API keys are kept. Backends other
+// than postgres are for tests and single-binary development.
+type StorageConfig struct {
+	Backend    string
+	SQLitePath string
+}
+
+// WALConfig holds write-ahead log configuration
+type WALConfig struct {
+	Enabled        bool
+	Dir            string
+	SegmentSizeMB  int
+	MaxSizeMB      int
+	FsyncPolicy    string
+	FsyncInterval  time.Duration
+	ReplayInterval time.Duration
+	// DeadLetterDir receives logs the database refuses to store; empty
+	// disables it
+	DeadLetterDir string
+}
+
+// SyslogConfig holds syslog listener configuration
+type SyslogConfig struct {
+	Enabled          bool
+	UDPAddr          string
+	TCPAddr          string
+	MaxMessageSizeKB int
+}
+
+// RedactionConfig holds PII redaction configuration
+type RedactionConfig struct {
+	Enabled       bool
+	Detectors     []string
+	DefaultAction string
+	RulesFile     string
+	HashKey       string
+}
+
+// EnrichmentConfig holds user-agent and GeoIP enrichment configuration
+type EnrichmentConfig struct {
+	Enabled   bool
+	GeoIPPath string
+	DropIP    bool
+}
+
+// IntegrityConfig holds session integrity analyzer configuration
+type IntegrityConfig struct {
+	Enabled     bool
+	Interval    time.Duration
+	SessionIdle time.Duration
+	Window      time.Duration
+}
+
+// SDKConfig holds the defaults of the configuration served to client SDKs
+type SDKConfig struct {
+	FlushInterval   time.Duration
+	RefreshInterval time.Duration
+}
+
+// PartitionConfig holds analytics_logs partition maintenance configuration
+type PartitionConfig struct {
+	Enabled       bool
+	Interval      string
+	Premake       int
+	DetachAfter   time.Duration
+	CheckInterval time.Duration
+}
+
+// RetentionConfig holds retention job configuration
+type RetentionConfig struct {
+	Enabled                bool
+	Interval               time.Duration
+	BatchSize              int
+	ServerMetricsRetention time.Duration
+}
+
+// RollupConfig holds log rollup aggregator configuration
+type RollupConfig struct {
+	Enabled         bool
+	Interval        time.Duration
+	MinuteRetention time.Duration
+	HourRetention   time.Duration
+}
+
+// ArchiveConfig holds cold archival configuration
+type ArchiveConfig struct {
+	Enabled        bool
+	After          time.Duration
+	Interval       time.Duration
+	MaxRowsPerFile int
+	Prefix         string
+	Store          string
+	LocalPath      string
+	S3             S3Config
+}
+
+// S3Config holds the location and credentials of an S3-compatible bucket
+type S3Config struct {
+	Endpoint     string
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	PathStyle    bool
+}
+
+// HealthCheckURL returns the URL of the health endpoint of a server running
+// locally with this environment. It reads only PORT and HEALTH_CHECK_PATH,
+// so the container health check does not depend on the rest of the
+// configuration being valid.
+func HealthCheckURL() string {
+	_ = godotenv.Load()
+	return "http://127.0.0.1:" + getEnv("PORT", "8080") + getEnv("HEALTH_CHECK_PATH", "/health")
 }
 
 // LoadConfig loads configuration from environment variables
@@ -85,6 +249,12 @@ func LoadConfig() (*Config, error) {
 			MaxConnections:     getEnvAsInt("DB_MAX_CONNECTIONS", 100),
 			MaxIdleConnections: getEnvAsInt("DB_MAX_IDLE_CONNECTIONS", 10),
 			MaxLifetime:        time.Duration(getEnvAsInt("DB_MAX_LIFETIME_MINUTES", 60)) * time.Minute,
+			AutoMigrate:        getEnvAsBool("DB_AUTO_MIGRATE", true),
+		},
+
+		Storage: StorageConfig{
+			Backend:    getEnv("STORAGE_BACKEND", "postgres"),
+			SQLitePath: getEnv("SQLITE_PATH", "data/dev.db"),
 		},
 
 		APIKeys:                    getEnvAsSlice("API_KEYS", ","),
@@ -97,6 +267,100 @@ func LoadConfig() (*Config, error) {
 		MaxBatchSize:     getEnvAsInt("MAX_BATCH_SIZE", 1000),
 		BatchTimeout:     time.Duration(getEnvAsInt("BATCH_TIMEOUT_SECONDS", 30)) * time.Second,
 		WorkerPoolSize:   getEnvAsInt("WORKER_POOL_SIZE", 10),
+		IngestQueueSize:  getEnvAsInt("INGEST_QUEUE_SIZE", 10000),
+
+		StreamMaxRequestSizeMB: getEnvAsInt("STREAM_MAX_REQUEST_SIZE_MB", 512),
+		StreamTimeout:          time.Duration(getEnvAsInt("STREAM_TIMEOUT_SECONDS", 600)) * time.Second,
+
+		WAL: WALConfig{
+			Enabled:        getEnvAsBool("WAL_ENABLED", true),
+			Dir:            getEnv("WAL_DIR", "data/wal"),
+			SegmentSizeMB:  getEnvAsInt("WAL_SEGMENT_SIZE_MB", 64),
+			MaxSizeMB:      getEnvAsInt("WAL_MAX_SIZE_MB", 1024),
+			FsyncPolicy:    getEnv("WAL_FSYNC_POLICY", "interval"),
+			FsyncInterval:  time.Duration(getEnvAsInt("WAL_FSYNC_INTERVAL_MS", 200)) * time.Millisecond,
+			ReplayInterval: time.Duration(getEnvAsInt("WAL_REPLAY_INTERVAL_SECONDS", 10)) * time.Second,
+			DeadLetterDir:  getEnv("DEAD_LETTER_DIR", "data/deadletter"),
+		},
+
+		IdempotencyTTL:   time.Duration(getEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour,
+		IdempotencyLease: time.Duration(getEnvAsInt("IDEMPOTENCY_LEASE_SECONDS", 60)) * time.Second,
+
+		Syslog: SyslogConfig{
+			Enabled:          getEnvAsBool("SYSLOG_ENABLED", false),
+			UDPAddr:          getEnv("SYSLOG_UDP_ADDR", ":5514"),
+			TCPAddr:          getEnv("SYSLOG_TCP_ADDR", ":5514"),
+			MaxMessageSizeKB: getEnvAsInt("SYSLOG_MAX_MESSAGE_SIZE_KB", 64),
+		},
+
+		Redaction: RedactionConfig{
+			Enabled:       getEnvAsBool("REDACTION_ENABLED", false),
+			Detectors:     getEnvAsSlice("REDACTION_DETECTORS", ","),
+			DefaultAction: getEnv("REDACTION_DEFAULT_ACTION", "mask"),
+			RulesFile:     getEnv("REDACTION_RULES_FILE", ""),
+			HashKey:       getEnv("REDACTION_HASH_KEY", ""),
+		},
+
+		Enrichment: EnrichmentConfig{
+			Enabled:   getEnvAsBool("ENRICHMENT_ENABLED", false),
+			GeoIPPath: getEnv("GEOIP_DB_PATH", ""),
+			DropIP:    getEnvAsBool("ENRICHMENT_DROP_IP", false),
+		},
+
+		ClockSkewThreshold: time.Duration(getEnvAsInt("CLOCK_SKEW_THRESHOLD_SECONDS", 300)) * time.Second,
+
+		Integrity: IntegrityConfig{
+			Enabled:     getEnvAsBool("INTEGRITY_ANALYZER_ENABLED", true),
+			Interval:    time.Duration(getEnvAsInt("INTEGRITY_INTERVAL_MINUTES", 5)) * time.Minute,
+			SessionIdle: time.Duration(getEnvAsInt("INTEGRITY_SESSION_IDLE_MINUTES", 30)) * time.Minute,
+			Window:      time.Duration(getEnvAsInt("INTEGRITY_WINDOW_HOURS", 24)) * time.Hour,
+		},
+
+		SDK: SDKConfig{
+			FlushInterval:   time.Duration(getEnvAsInt("SDK_FLUSH_INTERVAL_SECONDS", 30)) * time.Second,
+			RefreshInterval: time.Duration(getEnvAsInt("SDK_CONFIG_REFRESH_SECONDS", 300)) * time.Second,
+		},
+
+		Partition: PartitionConfig{
+			Enabled:       getEnvAsBool("PARTITION_MAINTENANCE_ENABLED", true),
+			Interval:      getEnv("PARTITION_INTERVAL", "daily"),
+			Premake:       getEnvAsInt("PARTITION_PREMAKE", 7),
+			DetachAfter:   time.Duration(getEnvAsInt("PARTITION_DETACH_AFTER_DAYS", 0)) * 24 * time.Hour,
+			CheckInterval: time.Duration(getEnvAsInt("PARTITION_CHECK_INTERVAL_MINUTES", 60)) * time.Minute,
+		},
+
+		Retention: RetentionConfig{
+			Enabled:                getEnvAsBool("RETENTION_ENABLED", true),
+			Interval:               time.Duration(getEnvAsInt("RETENTION_INTERVAL_MINUTES", 60)) * time.Minute,
+			BatchSize:              getEnvAsInt("RETENTION_BATCH_SIZE", 5000),
+			ServerMetricsRetention: time.Duration(getEnvAsInt("RETENTION_SERVER_METRICS_DAYS", 30)) * 24 * time.Hour,
+		},
+
+		Rollup: RollupConfig{
+			Enabled:         getEnvAsBool("ROLLUP_ENABLED", true),
+			Interval:        time.Duration(getEnvAsInt("ROLLUP_INTERVAL_SECONDS", 60)) * time.Second,
+			MinuteRetention: time.Duration(getEnvAsInt("ROLLUP_MINUTE_RETENTION_DAYS", 7)) * 24 * time.Hour,
+			HourRetention:   time.Duration(getEnvAsInt("ROLLUP_HOUR_RETENTION_DAYS", 180)) * 24 * time.Hour,
+		},
+
+		Archive: ArchiveConfig{
+			Enabled:        getEnvAsBool("ARCHIVE_ENABLED", false),
+			After:          time.Duration(getEnvAsInt("ARCHIVE_AFTER_DAYS", 90)) * 24 * time.Hour,
+			Interval:       time.Duration(getEnvAsInt("ARCHIVE_INTERVAL_MINUTES", 60)) * time.Minute,
+			MaxRowsPerFile: getEnvAsInt("ARCHIVE_MAX_ROWS_PER_FILE", 100000),
+			Prefix:         getEnv("ARCHIVE_PREFIX", "analytics_logs"),
+			Store:          getEnv("ARCHIVE_STORE", "local"),
+			LocalPath:      getEnv("ARCHIVE_LOCAL_PATH", "data/archive"),
+			S3: S3Config{
+				Endpoint:     getEnv("ARCHIVE_S3_ENDPOINT", ""),
+				Region:       getEnv("ARCHIVE_S3_REGION", "us-east-1"),
+				Bucket:       getEnv("ARCHIVE_S3_BUCKET", ""),
+				AccessKey:    getEnv("ARCHIVE_S3_ACCESS_KEY", ""),
+				SecretKey:    getEnv("ARCHIVE_S3_SECRET_KEY", ""),
+				SessionToken: getEnv("ARCHIVE_S3_SESSION_TOKEN", ""),
+				PathStyle:    getEnvAsBool("ARCHIVE_S3_PATH_STYLE", false),
+			},
+		},
 
 		EnableMetrics:     getEnvAsBool("ENABLE_METRICS", true),
 		MetricsPath:       getEnv("METRICS_PATH", "/metrics"),
@@ -113,10 +377,78 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("API_KEYS must be provided")
 	}
 
-	if config.Database.Password == "" {
+	switch config.Storage.Backend {
+	case "postgres", "memory", "sqlite":
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND must be postgres, memory or sqlite")
+	}
+
+	if config.Storage.Backend == "postgres" && config.Database.Password == "" {
 		return nil, fmt.Errorf("DB_PASSWORD must be provided")
 	}
 
+	if config.WorkerPoolSize <= 0 {
+		return nil, fmt.Errorf("WORKER_POOL_SIZE must be positive")
+	}
+
+	if config.BatchTimeout <= 0 {
+		return nil, fmt.Errorf("BATCH_TIMEOUT_SECONDS must be positive")
+	}
+
+	if config.IngestQueueSize < config.MaxBatchSize {
+		return nil, fmt.Errorf("INGEST_QUEUE_SIZE must be at least MAX_BATCH_SIZE (%d)", config.MaxBatchSize)
+	}
+
+	if config.WAL.Enabled && (config.WAL.SegmentSizeMB <= 0 || config.WAL.FsyncInterval <= 0 || config.WAL.ReplayInterval <= 0) {
+		return nil, fmt.Errorf("WAL_SEGMENT_SIZE_MB, WAL_FSYNC_INTERVAL_MS and WAL_REPLAY_INTERVAL_SECONDS must be positive")
+	}
+
+	if config.IdempotencyLease < config.RequestTimeout {
+		return nil, fmt.Errorf("IDEMPOTENCY_LEASE_SECONDS must be at least REQUEST_TIMEOUT_SECONDS")
+	}
+
+	if config.Integrity.Enabled && (config.Integrity.Interval <= 0 || config.Integrity.Window <= 0) {
+		return nil, fmt.Errorf("INTEGRITY_INTERVAL_MINUTES and INTEGRITY_WINDOW_HOURS must be positive")
+	}
+
+	if config.SDK.FlushInterval <= 0 || config.SDK.RefreshInterval <= 0 {
+		return nil, fmt.Errorf("SDK_FLUSH_INTERVAL_SECONDS and SDK_CONFIG_REFRESH_SECONDS must be positive")
+	}
+
+	if config.Partition.Enabled {
+		if config.Partition.Interval != "daily" && config.Partition.Interval != "monthly" {
+			return nil, fmt.Errorf("PARTITION_INTERVAL must be daily or monthly")
+		}
+		if config.Partition.Premake < 0 || config.Partition.DetachAfter < 0 || config.Partition.CheckInterval <= 0 {
+			return nil, fmt.Errorf("PARTITION_PREMAKE and PARTITION_DETACH_AFTER_DAYS must not be negative and PARTITION_CHECK_INTERVAL_MINUTES must be positive")
+		}
+	}
+
+	if config.Retention.Enabled && (config.Retention.Interval <= 0 || config.Retention.BatchSize <= 0 || config.Retention.ServerMetricsRetention < 0) {
+		return nil, fmt.Errorf("RETENTION_INTERVAL_MINUTES and RETENTION_BATCH_SIZE must be positive and RETENTION_SERVER_METRICS_DAYS must not be negative")
+	}
+
+	if config.Rollup.Enabled && (config.Rollup.Interval <= 0 || config.Rollup.MinuteRetention <= 0 || config.Rollup.HourRetention < 0) {
+		return nil, fmt.Errorf("ROLLUP_INTERVAL_SECONDS and ROLLUP_MINUTE_RETENTION_DAYS must be positive and ROLLUP_HOUR_RETENTION_DAYS must not be negative")
+	}
+
+	if config.Archive.Enabled {
+		if config.Archive.After <= 0 || config.Archive.Interval <= 0 || config.Archive.MaxRowsPerFile <= 0 {
+			return nil, fmt.Errorf("ARCHIVE_AFTER_DAYS, ARCHIVE_INTERVAL_MINUTES and ARCHIVE_MAX_ROWS_PER_FILE must be positive")
+		}
+		if config.Partition.Enabled && config.Partition.DetachAfter > 0 && config.Archive.After >= config.Partition.DetachAfter {
+			return nil, fmt.Errorf("ARCHIVE_AFTER_DAYS must be below PARTITION_DETACH_AFTER_DAYS, or detached logs are never archived")
+		}
+	}
+
+	if config.Archive.Store != "local" && config.Archive.Store != "s3" {
+		return nil, fmt.Errorf("ARCHIVE_STORE must be local or s3")
+	}
+
+	if config.Syslog.Enabled && config.Syslog.MaxMessageSizeKB <= 0 {
+		return nil, fmt.Errorf("SYSLOG_MAX_MESSAGE_SIZE_KB must be positive")
+	}
+
 	return config, nil
 }
 
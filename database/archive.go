@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log-ingestion-server/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// archiveLockKey is the advisory lock held for a whole archive round, so only
+// one replica archives at a time
+const archiveLockKey = 0x61726368697665 // "archive"
+
+// restoreStagingTable is a per-transaction temporary table used to load
+// restored logs before merging them into analytics_logs_restored
+const restoreStagingTable = "analytics_logs_restore_staging"
+
+// restoreColumns lists the analytics_logs_restored columns written by
+// RestoreLogs: the original ID and creation times are kept
+var restoreColumns = append([]string{"id", "created_at", "processed_at"}, logColumns...)
+
+// archiveDate formats the UTC day of an archived file for the DATE column
+const archiveDate = "2006-01-02"
+
+// archivedCondition matches the analytics_logs rows whose UTC day and event
+// type have at least one archived file
+const archivedCondition = `EXISTS (
+		SELECT 1 FROM archive_manifest m
+		WHERE m.log_date = (analytics_logs.timestamp AT TIME ZONE 'UTC')::date
+		AND m.event_type = analytics_logs.event_type)`
+
+// TryArchiveLock takes the archive advisory lock. It returns a nil release
+// function when another instance holds the lock.
+func (db *DB) TryArchiveLock(ctx context.Context) (func(), error) {
+	return db.tryAdvisoryLock(ctx, archiveLockKey)
+}
+
+// OldestLogTimestamp returns the timestamp of the oldest stored log, or nil
+// when there are none
+func (db *DB) OldestLogTimestamp(ctx context.Context) (*time.Time, error) {
+	var oldest *time.Time
+	err := db.conn.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM analytics_logs`).Scan(&oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oldest log: %w", err)
+	}
+	return oldest, nil
+}
+
+// HasUnarchivedLogs reports whether any log with a timestamp in [from, to)
+// belongs to a day and event type without an archived file
+func (db *DB) HasUnarchivedLogs(ctx context.Context, from, to time.Time) (bool, error) {
+	var pending bool
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM analytics_logs
+			WHERE timestamp >= $1 AND timestamp < $2 AND NOT `+archivedCondition+`
+		)`, from, to).Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for unarchived logs: %w", err)
+	}
+
+	return pending, nil
+}
+
+// LogEventTypesBetween returns the event types of the logs with timestamps in
+// [from, to)
+func (db *DB) LogEventTypesBetween(ctx context.Context, from, to time.Time) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT DISTINCT event_type FROM analytics_logs
+		WHERE timestamp >= $1 AND timestamp < $2
+		ORDER BY event_type`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event types to archive: %w", err)
+	}
+	defer rows.Close()
+
+	var eventTypes []string
+	for rows.Next() {
+		var eventType string
+		if err := rows.Scan(&eventType); err != nil {
+			return nil, fmt.Errorf("failed to scan event type: %w", err)
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	return eventTypes, rows.Err()
+}
+
+// ArchiveLogs moves up to limit logs of an event type with timestamps in
+// [from, to) out of analytics_logs. The logs are locked and passed to export,
+// which stores them and describes the file written; the file is then added
+// to archive_manifest and the logs deleted, and taken off the count of stored
+// logs, in the same transaction. It
+// returns the number of logs archived.
+//
+// If the transaction fails after export, the stored file is left without a
+// manifest entry and the logs are archived again by a later call.
+func (db *DB) ArchiveLogs(ctx context.Context, eventType string, from, to time.Time, limit int, export func([]models.AnalyticsLog) (*models.ArchiveFile, error)) (int, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+logSelectList+`
+		FROM analytics_logs
+		WHERE timestamp >= $1 AND timestamp < $2 AND event_type = $3
+		ORDER BY timestamp, id
+		LIMIT $4
+		FOR UPDATE`, from, to, eventType, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select logs to archive: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []models.AnalyticsLog
+	var ids []int64
+	for rows.Next() {
+		var log models.AnalyticsLog
+		if err := scanLog(rows, &log); err != nil {
+			return 0, fmt.Errorf("failed to scan log: %w", err)
+		}
+		logs = append(logs, log)
+		ids = append(ids, log.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to select logs to archive: %w", err)
+	}
+	rows.Close()
+
+	if len(logs) == 0 {
+		return 0, nil
+	}
+
+	file, err := export(logs)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO archive_manifest (object_key, log_date, event_type, row_count, size_bytes, sha256,
+			min_timestamp, max_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, archived_at`,
+		file.ObjectKey, file.LogDate.Format(archiveDate), file.EventType, file.RowCount, file.SizeBytes,
+		file.SHA256, file.MinTimestamp, file.MaxTimestamp,
+	).Scan(&file.ID, &file.ArchivedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record archive file: %w", err)
+	}
+
+	var deleted int
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		WITH deleted AS (
+			DELETE FROM analytics_logs
+			WHERE timestamp >= $1 AND timestamp < $2 AND id = ANY($3)
+			RETURNING event_id, created_at
+		), forgotten AS (
+			DELETE FROM %s
+			WHERE event_id IN (SELECT event_id FROM deleted)
+		), uncounted AS (%s
+		)
+		SELECT COUNT(*) FROM deleted`, eventIDTable, uncountRolledUp("deleted")),
+		from, to, pq.Array(ids)).Scan(&deleted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete archived logs: %w", err)
+	}
+	if deleted != len(logs) {
+		return 0, fmt.Errorf("deleted %d of %d archived logs", deleted, len(logs))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(logs), nil
+}
+
+// ListArchiveFiles returns the archived files of the UTC days from through
+// to, optionally for one event type, oldest first
+func (db *DB) ListArchiveFiles(from, to time.Time, eventType string) ([]models.ArchiveFile, error) {
+	conditions := []string{"log_date >= $1", "log_date <= $2"}
+	args := []interface{}{from.Format(archiveDate), to.Format(archiveDate)}
+	if eventType != "" {
+		args = append(args, eventType)
+		conditions = append(conditions, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT id, object_key, log_date, event_type, row_count, size_bytes, sha256,
+			min_timestamp, max_timestamp, archived_at
+		FROM archive_manifest
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY log_date, event_type, min_timestamp, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.ArchiveFile
+	for rows.Next() {
+		var f models.ArchiveFile
+		if err := rows.Scan(&f.ID, &f.ObjectKey, &f.LogDate, &f.EventType, &f.RowCount, &f.SizeBytes, &f.SHA256,
+			&f.MinTimestamp, &f.MaxTimestamp, &f.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan archive file: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	return files, rows.Err()
+}
+
+// RestoreLogs copies archived logs into analytics_logs_restored, keeping
+// their original IDs and creation times and skipping logs already restored.
+// It returns the number of logs added.
+func (db *DB) RestoreLogs(logs []models.AnalyticsLog) (int64, error) {
+	if len(logs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	columnList := strings.Join(restoreColumns, ", ")
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TEMPORARY TABLE %s ON COMMIT DROP AS
+		SELECT %s FROM analytics_logs_restored WITH NO DATA`,
+		restoreStagingTable, columnList))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(restoreStagingTable, restoreColumns...))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range logs {
+		values := append([]interface{}{logs[i].ID, logs[i].CreatedAt, logs[i].ProcessedAt}, logValues(&logs[i])...)
+		if _, err := stmt.Exec(values...); err != nil {
+			return 0, fmt.Errorf("failed to copy log: %w", err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return 0, fmt.Errorf("failed to execute restore copy: %w", err)
+	}
+
+	result, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO analytics_logs_restored (%[1]s)
+		SELECT %[1]s FROM %[2]s
+		ON CONFLICT (event_id) DO NOTHING`, columnList, restoreStagingTable))
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge restored logs: %w", err)
+	}
+
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return restored, nil
+}
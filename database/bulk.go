@@ -0,0 +1,175 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log-ingestion-server/models"
+
+	"github.com/lib/pq"
+)
+
+// stagingTable is a per-transaction temporary table used to bulk load logs
+// before merging them into analytics_logs
+const stagingTable = "analytics_logs_staging"
+
+// eventIDTable holds the event_id of every stored log. analytics_logs is
+// partitioned by timestamp and cannot enforce a unique event_id itself, so
+// inserts claim each ID here in the same transaction.
+const eventIDTable = "analytics_log_event_ids"
+
+// InsertLogsBatch bulk loads multiple analytics logs with the COPY protocol in
+// a single round trip. Like a plain INSERT, the whole batch fails if any
+// event_id is already stored.
+func (db *DB) InsertLogsBatch(logs []models.AnalyticsLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := claimEventIDs(tx, logs); err != nil {
+		return err
+	}
+
+	if err := copyLogs(tx, "analytics_logs", logColumns, logs, false); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// InsertLogsBatchSkipDuplicates bulk loads multiple analytics logs, silently
+// skipping logs whose event_id is already stored or repeated earlier in the
+// batch. Logs are copied into a temporary staging table; only those whose
+// event_id is newly claimed are merged. The returned slice reports, per input index,
+// whether that log was inserted.
+func (db *DB) InsertLogsBatchSkipDuplicates(logs []models.AnalyticsLog) ([]bool, error) {
+	inserted := make([]bool, len(logs))
+	if len(logs) == 0 {
+		return inserted, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Deriving the staging columns from analytics_logs keeps their types in
+	// sync with migrations
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TEMPORARY TABLE %s ON COMMIT DROP AS
+		SELECT 0::INTEGER AS idx, %s FROM analytics_logs WITH NO DATA`,
+		stagingTable, logColumnList))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	columns := append([]string{"idx"}, logColumns...)
+	if err := copyLogs(tx, stagingTable, columns, logs, true); err != nil {
+		return nil, err
+	}
+
+	// DISTINCT ON keeps the first occurrence of an event_id within the batch
+	rows, err := tx.Query(fmt.Sprintf(`
+		WITH deduplicated AS (
+			SELECT DISTINCT ON (event_id) *
+			FROM %[2]s
+			ORDER BY event_id, idx
+		), claimed AS (
+			INSERT INTO %[3]s (event_id, timestamp)
+			SELECT event_id, timestamp FROM deduplicated
+			ON CONFLICT (event_id) DO NOTHING
+			RETURNING event_id
+		), merged AS (
+			INSERT INTO analytics_logs (%[1]s)
+			SELECT %[1]s FROM deduplicated
+			WHERE event_id IN (SELECT event_id FROM claimed)
+			RETURNING event_id
+		)
+		SELECT MIN(s.idx)
+		FROM merged m
+		JOIN %[2]s s ON s.event_id = m.event_id
+		GROUP BY m.event_id`, logColumnList, stagingTable, eventIDTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staged logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idx int
+		if err := rows.Scan(&idx); err != nil {
+			return nil, fmt.Errorf("failed to scan merged log: %w", err)
+		}
+		if idx >= 0 && idx < len(inserted) {
+			inserted[idx] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to merge staged logs: %w", err)
+	}
+	rows.Close()
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// claimEventIDs records the event_id of each log, failing with a duplicate key
+// error if any is already stored
+func claimEventIDs(tx *sql.Tx, logs []models.AnalyticsLog) error {
+	stmt, err := tx.Prepare(pq.CopyIn(eventIDTable, "event_id", "timestamp"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare event ID copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range logs {
+		if _, err := stmt.Exec(logs[i].EventID, logs[i].Timestamp); err != nil {
+			return fmt.Errorf("failed to copy event ID: %w", err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to claim event IDs: %w", err)
+	}
+
+	return nil
+}
+
+// copyLogs streams logs into table with COPY FROM STDIN. When withIndex is
+// set, each row is prefixed with its position in logs.
+func copyLogs(tx *sql.Tx, table string, columns []string, logs []models.AnalyticsLog, withIndex bool) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range logs {
+		values := logValues(&logs[i])
+		if withIndex {
+			values = append([]interface{}{i}, values...)
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return fmt.Errorf("failed to copy log: %w", err)
+		}
+	}
+
+	// An empty Exec flushes the buffered rows and reports constraint errors
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to execute batch copy: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+)
+
+const clientConfigOverrideColumns = `id, name, position, app_version, platform, sampling_rates,
+		disabled_events, max_batch_size, flush_interval_seconds, is_active, created_at, updated_at`
+
+// ListClientConfigOverrides returns every client config override in
+// application order, including inactive overrides
+func (db *DB) ListClientConfigOverrides() ([]models.ClientConfigOverride, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + clientConfigOverrideColumns + `
+		FROM client_config_overrides
+		ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list client config overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []models.ClientConfigOverride
+	for rows.Next() {
+		var o models.ClientConfigOverride
+		var samplingRates, disabledEvents []byte
+		if err := rows.Scan(&o.ID, &o.Name, &o.Position, &o.AppVersion, &o.Platform, &samplingRates,
+			&disabledEvents, &o.MaxBatchSize, &o.FlushIntervalSeconds, &o.IsActive, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan client config override: %w", err)
+		}
+		if err := json.Unmarshal(samplingRates, &o.SamplingRates); err != nil {
+			return nil, fmt.Errorf("failed to decode sampling rates of override %s: %w", o.Name, err)
+		}
+		if err := json.Unmarshal(disabledEvents, &o.DisabledEvents); err != nil {
+			return nil, fmt.Errorf("failed to decode disabled events of override %s: %w", o.Name, err)
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
+
+// InsertClientConfigOverride stores a new client config override. ID,
+// CreatedAt and UpdatedAt are filled in.
+func (db *DB) InsertClientConfigOverride(o *models.ClientConfigOverride) error {
+	samplingRates, disabledEvents, err := encodeOverrideLists(o)
+	if err != nil {
+		return err
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO client_config_overrides (name, position, app_version, platform, sampling_rates,
+			disabled_events, max_batch_size, flush_interval_seconds, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`,
+		o.Name, o.Position, o.AppVersion, o.Platform, samplingRates,
+		disabledEvents, o.MaxBatchSize, o.FlushIntervalSeconds, o.IsActive,
+	).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert client config override: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateClientConfigOverride replaces an existing client config override. It
+// returns false if the override does not exist.
+func (db *DB) UpdateClientConfigOverride(o *models.ClientConfigOverride) (bool, error) {
+	samplingRates, disabledEvents, err := encodeOverrideLists(o)
+	if err != nil {
+		return false, err
+	}
+
+	err = db.conn.QueryRow(`
+		UPDATE client_config_overrides SET
+			name = $2, position = $3, app_version = $4, platform = $5, sampling_rates = $6,
+			disabled_events = $7, max_batch_size = $8, flush_interval_seconds = $9, is_active = $10,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		o.ID, o.Name, o.Position, o.AppVersion, o.Platform, samplingRates,
+		disabledEvents, o.MaxBatchSize, o.FlushIntervalSeconds, o.IsActive,
+	).Scan(&o.CreatedAt, &o.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update client config override: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteClientConfigOverride removes a client config override. It returns
+// false if the override does not exist.
+func (db *DB) DeleteClientConfigOverride(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM client_config_overrides WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete client config override: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func encodeOverrideLists(o *models.ClientConfigOverride) ([]byte, []byte, error) {
+	samplingRates := o.SamplingRates
+	if samplingRates == nil {
+		samplingRates = map[string]float64{}
+	}
+	disabledEvents := o.DisabledEvents
+	if disabledEvents == nil {
+		disabledEvents = []string{}
+	}
+
+	rates, err := json.Marshal(samplingRates)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := json.Marshal(disabledEvents)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rates, events, nil
+}
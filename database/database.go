@@ -6,15 +6,95 @@ import (
 	"fmt"
 	"log-ingestion-server/config"
 	"log-ingestion-server/models"
+	"strings"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+// logColumns lists the analytics_logs columns written on insert, in the
+// order returned by logValues
+var logColumns = []string{
+	"event_id", "timestamp", "event_type", "event_name", "properties",
+	"user_id", "session_id", "app_version", "device_info", "sequence_number", "priority",
+	"corrected_timestamp", "clock_skew_ms", "clock_skew_flagged", "sample_rate",
+}
+
+var (
+	logColumnList   = strings.Join(logColumns, ", ")
+	logPlaceholders = placeholders(1, len(logColumns))
+)
+
+// logValues returns the insert arguments for a log, matching logColumns
+func logValues(log *models.AnalyticsLog) []interface{} {
+	return []interface{}{
+		log.EventID,
+		log.Timestamp,
+		log.EventType,
+		log.EventName,
+		log.Properties,
+		log.UserID,
+		log.SessionID,
+		log.AppVersion,
+		log.DeviceInfo,
+		log.SequenceNumber,
+		log.Priority,
+		log.CorrectedTimestamp,
+		log.ClockSkewMs,
+		log.ClockSkewFlagged,
+		sampleRate(log),
+	}
+}
+
+// sampleRate returns the rate a log was kept at; logs that never went through
+// sampling were kept in full
+func sampleRate(log *models.AnalyticsLog) float64 {
+	if log.SampleRate <= 0 {
+		return 1
+	}
+	return log.SampleRate
+}
+
+// logSelectList lists the analytics_logs columns read by scanLog
+const logSelectList = `id, event_id, timestamp, event_type, event_name, properties,
+			   user_id, session_id, app_version, device_info, sequence_number,
+			   priority, created_at, processed_at,
+			   corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate`
+
+// scanLog reads a row selected with logSelectList
+func scanLog(rows *sql.Rows, log *models.AnalyticsLog) error {
+	return rows.Scan(
+		&log.ID,
+		&log.EventID,
+		&log.Timestamp,
+		&log.EventType,
+		&log.EventName,
+		&log.Properties,
+		&log.UserID,
+		&log.SessionID,
+		&log.AppVersion,
+		&log.DeviceInfo,
+		&log.SequenceNumber,
+		&log.Priority,
+		&log.CreatedAt,
+		&log.ProcessedAt,
+		&log.CorrectedTimestamp,
+		&log.ClockSkewMs,
+		&log.ClockSkewFlagged,
+		&log.SampleRate,
+	)
+}
+
+// placeholders returns "$start, $start+1, ..." for n parameters
+func placeholders(start, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(params, ", ")
+}
+
 // DB wraps the database connection and provides methods for data operations
 type DB struct {
 	conn   *sql.DB
@@ -47,30 +127,6 @@ func NewDB(cfg *config.Config) (*DB, error) {
 	return db, nil
 }
 
-// RunMigrations runs database migrations
-func (db *DB) RunMigrations() error {
-	driver, err := postgres.WithInstance(db.conn, &postgres.Config{})
-	if err != nil {
-		return fmt.Errorf("failed to create migration driver: %w", err)
-	}
-
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres",
-		driver,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create migration instance: %w", err)
-	}
-
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	logrus.Info("Database migrations completed successfully")
-	return nil
-}
-
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -78,37 +134,40 @@ func (db *DB) Close() error {
 
 // InsertLog inserts a single analytics log
 func (db *DB) InsertLog(log *models.AnalyticsLog) error {
-	query := `
-		INSERT INTO analytics_logs (
-			event_id, timestamp, event_type, event_name, properties,
-			user_id, session_id, app_version, device_info, sequence_number, priority
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, created_at`
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	err := db.conn.QueryRow(
-		query,
-		log.EventID,
-		log.Timestamp,
-		log.EventType,
-		log.EventName,
-		log.Properties,
-		log.UserID,
-		log.SessionID,
-		log.AppVersion,
-		log.DeviceInfo,
-		log.SequenceNumber,
-		log.Priority,
-	).Scan(&log.ID, &log.CreatedAt)
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (event_id, timestamp)
+		VALUES ($1, $2)`, eventIDTable), log.EventID, log.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert log: %w", err)
+	}
 
+	query := fmt.Sprintf(`
+		INSERT INTO analytics_logs (%s)
+		VALUES (%s)
+		RETURNING id, created_at`, logColumnList, logPlaceholders)
+
+	err = tx.QueryRow(query, logValues(log)...).Scan(&log.ID, &log.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert log: %w", err)
 	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-// InsertLogsBatch inserts multiple analytics logs in a batch
-func (db *DB) InsertLogsBatch(logs []models.AnalyticsLog) error {
+// InsertLogsBatchPrepared inserts multiple analytics logs with one prepared
+// INSERT per log inside a transaction. It is the original batch insert path,
+// kept as the baseline for the insert benchmark; use InsertLogsBatch instead.
+func (db *DB) InsertLogsBatchPrepared(logs []models.AnalyticsLog) error {
 	if len(logs) == 0 {
 		return nil
 	}
@@ -119,31 +178,20 @@ func (db *DB) InsertLogsBatch(logs []models.AnalyticsLog) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO analytics_logs (
-			event_id, timestamp, event_type, event_name, properties,
-			user_id, session_id, app_version, device_info, sequence_number, priority
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)
+	if err := claimEventIDs(tx, logs); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO analytics_logs (%s)
+		VALUES (%s)`, logColumnList, logPlaceholders))
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	for _, log := range logs {
-		_, err = stmt.Exec(
-			log.EventID,
-			log.Timestamp,
-			log.EventType,
-			log.EventName,
-			log.Properties,
-			log.UserID,
-			log.SessionID,
-			log.AppVersion,
-			log.DeviceInfo,
-			log.SequenceNumber,
-			log.Priority,
-		)
-		if err != nil {
+	for i := range logs {
+		if _, err = stmt.Exec(logValues(&logs[i])...); err != nil {
 			return fmt.Errorf("failed to execute batch insert: %w", err)
 		}
 	}
@@ -221,10 +269,83 @@ func (db *DB) InsertAPIKey(apiKey *models.APIKey) error {
 	return nil
 }
 
-// GetMetrics retrieves analytics metrics
+// GetMetrics retrieves analytics metrics from the log rollups, which trail
+// ingestion by up to the rollup interval; AsOf tells how far they reach.
+// TotalLogs counts the logs rolled up that are still stored, while the other
+// counts cover logs ingested in their window. Without the rollup aggregator,
+// the stored logs are counted directly.
 func (db *DB) GetMetrics() (*models.MetricsResponse, error) {
+	if !db.config.Rollup.Enabled {
+		return db.liveMetrics()
+	}
+
 	metrics := &models.MetricsResponse{}
 
+	asOf, err := db.RollupWatermark()
+	if err != nil {
+		return nil, err
+	}
+	metrics.AsOf = asOf
+
+	// Total logs
+	metrics.TotalLogs, err = db.storedLogCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total logs: %w", err)
+	}
+
+	now := time.Now()
+
+	// Logs in last hour
+	lastHour := now.Add(-time.Hour)
+	metrics.LogsLastHour, err = db.rollupTotal(models.GranularityMinute, &lastHour)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs last hour: %w", err)
+	}
+
+	// Logs in last day
+	lastDay := now.Add(-24 * time.Hour)
+	metrics.LogsLastDay, err = db.rollupTotal(models.GranularityMinute, &lastDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs last day: %w", err)
+	}
+
+	// Active sessions (last 30 minutes)
+	_, recent, err := db.RollupUniques(models.GranularityMinute, now.Add(-30*time.Minute), now, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+	metrics.ActiveSessions = recent.Sessions
+
+	// Top event types
+	rows, err := db.conn.Query(`
+		SELECT event_type, SUM(log_count) as count 
+		FROM log_rollups 
+		WHERE granularity = 'minute' AND bucket >= $1
+		GROUP BY event_type 
+		ORDER BY count DESC 
+		LIMIT 10`, lastDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top event types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventType models.EventTypeCount
+		if err := rows.Scan(&eventType.EventType, &eventType.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan event type: %w", err)
+		}
+		metrics.TopEventTypes = append(metrics.TopEventTypes, eventType)
+	}
+
+	return metrics, nil
+}
+
+// liveMetrics counts the stored logs directly, for when there are no rollups
+// to read, so AsOf is always now
+func (db *DB) liveMetrics() (*models.MetricsResponse, error) {
+	now := time.Now()
+	metrics := &models.MetricsResponse{AsOf: &now}
+
 	// Total logs
 	err := db.conn.QueryRow("SELECT COUNT(*) FROM analytics_logs").Scan(&metrics.TotalLogs)
 	if err != nil {
@@ -233,7 +354,7 @@ func (db *DB) GetMetrics() (*models.MetricsResponse, error) {
 
 	// Logs in last hour
 	err = db.conn.QueryRow(`
-		SELECT COUNT(*) FROM analytics_logs 
+		SELECT COUNT(*) FROM analytics_logs
 		WHERE created_at >= NOW() - INTERVAL '1 hour'`).Scan(&metrics.LogsLastHour)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get logs last hour: %w", err)
@@ -241,7 +362,7 @@ func (db *DB) GetMetrics() (*models.MetricsResponse, error) {
 
 	// Logs in last day
 	err = db.conn.QueryRow(`
-		SELECT COUNT(*) FROM analytics_logs 
+		SELECT COUNT(*) FROM analytics_logs
 		WHERE created_at >= NOW() - INTERVAL '1 day'`).Scan(&metrics.LogsLastDay)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get logs last day: %w", err)
@@ -249,7 +370,7 @@ func (db *DB) GetMetrics() (*models.MetricsResponse, error) {
 
 	// Active sessions (last 30 minutes)
 	err = db.conn.QueryRow(`
-		SELECT COUNT(DISTINCT session_id) FROM analytics_logs 
+		SELECT COUNT(DISTINCT session_id) FROM analytics_logs
 		WHERE created_at >= NOW() - INTERVAL '30 minutes' AND session_id IS NOT NULL`).Scan(&metrics.ActiveSessions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active sessions: %w", err)
@@ -257,11 +378,11 @@ func (db *DB) GetMetrics() (*models.MetricsResponse, error) {
 
 	// Top event types
 	rows, err := db.conn.Query(`
-		SELECT event_type, COUNT(*) as count 
-		FROM analytics_logs 
+		SELECT event_type, COUNT(*) as count
+		FROM analytics_logs
 		WHERE created_at >= NOW() - INTERVAL '1 day'
-		GROUP BY event_type 
-		ORDER BY count DESC 
+		GROUP BY event_type
+		ORDER BY count DESC
 		LIMIT 10`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get top event types: %w", err)
@@ -287,11 +408,67 @@ func (db *DB) HealthCheck() error {
 	return db.conn.PingContext(ctx)
 }
 
-// GetLogCount returns the total number of logs
+// tryAdvisoryLock takes a session-level advisory lock on a dedicated
+// connection, for jobs whose work spans several transactions. It returns a
+// nil release function when another session holds the lock.
+func (db *DB) tryAdvisoryLock(ctx context.Context, key int64) (func(), error) {
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, nil
+}
+
+// advisoryLock is tryAdvisoryLock waiting for the lock when another session
+// holds it, after calling waiting
+func (db *DB) advisoryLock(ctx context.Context, key int64, waiting func()) (func(), error) {
+	release, err := db.tryAdvisoryLock(ctx, key)
+	if err != nil || release != nil {
+		return release, err
+	}
+	waiting()
+
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, nil
+}
+
+// GetLogCount returns the number of stored logs. With the rollup aggregator
+// it is kept with the rollups and counts the logs up to the watermark.
 func (db *DB) GetLogCount() (int64, error) {
-	var count int64
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM analytics_logs").Scan(&count)
-	return count, err
+	if !db.config.Rollup.Enabled {
+		var count int64
+		if err := db.conn.QueryRow("SELECT COUNT(*) FROM analytics_logs").Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to count logs: %w", err)
+		}
+		return count, nil
+	}
+	return db.storedLogCount()
 }
 
 // LogFilter represents filtering options for logs
@@ -393,12 +570,7 @@ func (db *DB) GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, e
 	sortBy := "created_at"
 	if filter.SortBy != "" {
 		// Validate sort field to prevent SQL injection
-		validSortFields := map[string]bool{
-			"id": true, "event_id": true, "timestamp": true, "event_type": true,
-			"event_name": true, "user_id": true, "session_id": true,
-			"app_version": true, "priority": true, "created_at": true,
-		}
-		if validSortFields[filter.SortBy] {
+		if LogSortFields[filter.SortBy] {
 			sortBy = filter.SortBy
 		}
 	}
@@ -420,12 +592,10 @@ func (db *DB) GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, e
 
 	// Build final query
 	query := fmt.Sprintf(`
-		SELECT id, event_id, timestamp, event_type, event_name, properties,
-			   user_id, session_id, app_version, device_info, sequence_number,
-			   priority, created_at, processed_at
+		SELECT %s
 		FROM analytics_logs 
 		%s 
-		%s`, whereClause, limitClause)
+		%s`, logSelectList, whereClause, limitClause)
 
 	rows, err := db.conn.Query(query, args...)
 	if err != nil {
@@ -436,22 +606,7 @@ func (db *DB) GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, e
 	var logs []models.AnalyticsLog
 	for rows.Next() {
 		var log models.AnalyticsLog
-		err := rows.Scan(
-			&log.ID,
-			&log.EventID,
-			&log.Timestamp,
-			&log.EventType,
-			&log.EventName,
-			&log.Properties,
-			&log.UserID,
-			&log.SessionID,
-			&log.AppVersion,
-			&log.DeviceInfo,
-			&log.SequenceNumber,
-			&log.Priority,
-			&log.CreatedAt,
-			&log.ProcessedAt,
-		)
+		err := scanLog(rows, &log)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan filtered log: %w", err)
 		}
@@ -464,9 +619,7 @@ func (db *DB) GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, e
 // GetRecentLogs returns recent logs for debugging
 func (db *DB) GetRecentLogs(limit int) ([]models.AnalyticsLog, error) {
 	query := `
-		SELECT id, event_id, timestamp, event_type, event_name, properties,
-			   user_id, session_id, app_version, device_info, sequence_number,
-			   priority, created_at, processed_at
+		SELECT ` + logSelectList + `
 		FROM analytics_logs 
 		ORDER BY created_at DESC 
 		LIMIT $1`
@@ -480,22 +633,7 @@ func (db *DB) GetRecentLogs(limit int) ([]models.AnalyticsLog, error) {
 	var logs []models.AnalyticsLog
 	for rows.Next() {
 		var log models.AnalyticsLog
-		err := rows.Scan(
-			&log.ID,
-			&log.EventID,
-			&log.Timestamp,
-			&log.EventType,
-			&log.EventName,
-			&log.Properties,
-			&log.UserID,
-			&log.SessionID,
-			&log.AppVersion,
-			&log.DeviceInfo,
-			&log.SequenceNumber,
-			&log.Priority,
-			&log.CreatedAt,
-			&log.ProcessedAt,
-		)
+		err := scanLog(rows, &log)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan log: %w", err)
 		}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+)
+
+// builtinEventTypes mirrors the event types created by the initial migration
+var builtinEventTypes = []models.EventType{
+	{Name: "behavioral", Description: "User interactions, habits completed, etc.", DefaultPriority: "normal", RetentionClass: "long", IsActive: true},
+	{Name: "telemetry", Description: "Service performance, API calls, etc.", DefaultPriority: "normal", RetentionClass: "short", IsActive: true},
+	{Name: "observability", Description: "Provider state changes, system events, OTLP service logs", DefaultPriority: "normal", RetentionClass: "standard", IsActive: true},
+	{Name: "error", Description: "Error tracking with context and stack traces", DefaultPriority: "high", RetentionClass: "long", IsActive: true},
+	{Name: "performance", Description: "Performance metrics and measurements", DefaultPriority: "normal", RetentionClass: "short", IsActive: true},
+}
+
+// ListEventTypes returns the whole event type taxonomy, including inactive types
+func (db *DB) ListEventTypes() ([]models.EventType, error) {
+	rows, err := db.conn.Query(`
+		SELECT name, description, default_priority, retention_class, is_active, created_at, updated_at
+		FROM event_types
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event types: %w", err)
+	}
+	defer rows.Close()
+
+	var eventTypes []models.EventType
+	for rows.Next() {
+		var t models.EventType
+		if err := rows.Scan(&t.Name, &t.Description, &t.DefaultPriority, &t.RetentionClass, &t.IsActive, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event type: %w", err)
+		}
+		eventTypes = append(eventTypes, t)
+	}
+
+	return eventTypes, rows.Err()
+}
+
+// UpsertEventType creates an event type or replaces an existing one's
+// settings. CreatedAt and UpdatedAt are filled in.
+func (db *DB) UpsertEventType(t *models.EventType) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO event_types (name, description, default_priority, retention_class, is_active)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			default_priority = EXCLUDED.default_priority,
+			retention_class = EXCLUDED.retention_class,
+			is_active = EXCLUDED.is_active,
+			updated_at = NOW()
+		RETURNING created_at, updated_at`,
+		t.Name, t.Description, t.DefaultPriority, t.RetentionClass, t.IsActive,
+	).Scan(&t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert event type: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateEventType stops accepting an event type. It returns false if the
+// type does not exist.
+func (db *DB) DeactivateEventType(name string) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE event_types SET is_active = FALSE, updated_at = NOW()
+		WHERE name = $1`,
+		name)
+	if err != nil {
+		return false, fmt.Errorf("failed to deactivate event type: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log-ingestion-server/models"
+	"time"
+)
+
+// ReserveIdempotencyKey claims an idempotency key for an API key. It returns
+// true if the key was free (or its previous record had expired) and the caller
+// should process the request. Otherwise it returns the stored record, whose
+// StatusCode is nil while the original request is still in flight.
+//
+// A reservation only lasts for lease, so a key whose request crashed the
+// server before completing can be taken over once the lease runs out.
+func (db *DB) ReserveIdempotencyKey(apiKeyHash, key, requestHash string, lease time.Duration) (*models.IdempotencyRecord, bool, error) {
+	_, err := db.conn.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE api_key_hash = $1 AND idempotency_key = $2 AND expires_at < NOW()`,
+		apiKeyHash, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO idempotency_keys (api_key_hash, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (api_key_hash, idempotency_key) DO NOTHING`,
+		apiKeyHash, key, requestHash, time.Now().Add(lease))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil, true, nil
+	}
+
+	var record models.IdempotencyRecord
+	err = db.conn.QueryRow(`
+		SELECT idempotency_key, request_hash, status_code, content_type, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE api_key_hash = $1 AND idempotency_key = $2`,
+		apiKeyHash, key).Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// The conflicting record was released concurrently; let the client retry
+			return &models.IdempotencyRecord{Key: key, RequestHash: requestHash}, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return &record, false, nil
+}
+
+// CompleteIdempotencyKey stores the response for a reserved idempotency key
+// and keeps it for ttl. A response that is already stored is left alone.
+func (db *DB) CompleteIdempotencyKey(apiKeyHash, key string, statusCode int, contentType string, body []byte, ttl time.Duration) error {
+	_, err := db.conn.Exec(`
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5, expires_at = $6
+		WHERE api_key_hash = $1 AND idempotency_key = $2 AND status_code IS NULL`,
+		apiKeyHash, key, statusCode, contentType, body, time.Now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a reserved idempotency key so that a retry of
+// a failed request is processed again. A stored response is never released.
+func (db *DB) ReleaseIdempotencyKey(apiKeyHash, key string) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE api_key_hash = $1 AND idempotency_key = $2 AND status_code IS NULL`,
+		apiKeyHash, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeExpiredIdempotencyKeys deletes idempotency records past their window
+func (db *DB) PurgeExpiredIdempotencyKeys() (int64, error) {
+	result, err := db.conn.Exec("DELETE FROM idempotency_keys WHERE expires_at < NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
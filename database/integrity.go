@@ -0,0 +1,132 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"time"
+)
+
+// QuietSession is a session whose latest event arrived at LastEventAt
+type QuietSession struct {
+	SessionID   string
+	LastEventAt time.Time
+}
+
+// SessionSequence returns a session's logs that carry a sequence number, in
+// the order they were stored
+func (db *DB) SessionSequence(sessionID string) ([]models.SequencedEvent, error) {
+	rows, err := db.conn.Query(`
+		SELECT event_id, sequence_number, app_version, created_at
+		FROM analytics_logs
+		WHERE session_id = $1 AND sequence_number IS NOT NULL
+		ORDER BY created_at, id`,
+		sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session sequence: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.SequencedEvent
+	for rows.Next() {
+		var e models.SequencedEvent
+		if err := rows.Scan(&e.EventID, &e.SequenceNumber, &e.AppVersion, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sequenced event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// QuietSessions returns up to limit sequenced sessions that received events
+// after since but none after until, oldest first
+func (db *DB) QuietSessions(since, until time.Time, limit int) ([]QuietSession, error) {
+	rows, err := db.conn.Query(`
+		SELECT session_id, MAX(created_at) AS last_event_at
+		FROM analytics_logs
+		WHERE created_at > $1 AND session_id IS NOT NULL AND sequence_number IS NOT NULL
+		GROUP BY session_id
+		HAVING MAX(created_at) <= $2
+		ORDER BY last_event_at
+		LIMIT $3`,
+		since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find quiet sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []QuietSession
+	for rows.Next() {
+		var s QuietSession
+		if err := rows.Scan(&s.SessionID, &s.LastEventAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quiet session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// UpsertSessionIntegrity stores the latest integrity result of a session
+func (db *DB) UpsertSessionIntegrity(r *models.SessionIntegrity) error {
+	ranges, err := json.Marshal(r.MissingRanges)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO session_integrity (
+			session_id, app_version, first_sequence, last_sequence, expected_events,
+			received_events, missing_events, missing_ranges, out_of_order_events,
+			replayed_events, last_event_at, analyzed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (session_id) DO UPDATE SET
+			app_version = EXCLUDED.app_version,
+			first_sequence = EXCLUDED.first_sequence,
+			last_sequence = EXCLUDED.last_sequence,
+			expected_events = EXCLUDED.expected_events,
+			received_events = EXCLUDED.received_events,
+			missing_events = EXCLUDED.missing_events,
+			missing_ranges = EXCLUDED.missing_ranges,
+			out_of_order_events = EXCLUDED.out_of_order_events,
+			replayed_events = EXCLUDED.replayed_events,
+			last_event_at = EXCLUDED.last_event_at,
+			analyzed_at = EXCLUDED.analyzed_at`,
+		r.SessionID, r.AppVersion, r.FirstSequence, r.LastSequence, r.ExpectedEvents,
+		r.ReceivedEvents, r.MissingEvents, ranges, r.OutOfOrderEvents,
+		r.ReplayedEvents, r.LastEventAt, r.AnalyzedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store session integrity: %w", err)
+	}
+
+	return nil
+}
+
+// IntegrityByAppVersion sums the integrity results of sessions whose last
+// event arrived after since, per app version
+func (db *DB) IntegrityByAppVersion(since time.Time) ([]models.IntegrityAggregate, error) {
+	rows, err := db.conn.Query(`
+		SELECT COALESCE(app_version, 'unknown'), COUNT(*), SUM(expected_events),
+			   SUM(missing_events), SUM(out_of_order_events), SUM(replayed_events)
+		FROM session_integrity
+		WHERE last_event_at > $1
+		GROUP BY 1`,
+		since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate session integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var aggregates []models.IntegrityAggregate
+	for rows.Next() {
+		var a models.IntegrityAggregate
+		if err := rows.Scan(&a.AppVersion, &a.Sessions, &a.ExpectedEvents,
+			&a.MissingEvents, &a.OutOfOrderEvents, &a.ReplayedEvents); err != nil {
+			return nil, fmt.Errorf("failed to scan session integrity aggregate: %w", err)
+		}
+		aggregates = append(aggregates, a)
+	}
+
+	return aggregates, rows.Err()
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"log-ingestion-server/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps logs and API keys in memory. It is meant for tests and
+// throwaway dev servers: nothing survives a restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	logs     []models.AnalyticsLog
+	eventIDs map[string]bool
+	apiKeys  map[string]*models.APIKey
+	nextID   int64
+	nextKey  int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		eventIDs: make(map[string]bool),
+		apiKeys:  make(map[string]*models.APIKey),
+	}
+}
+
+// InsertLog stores a log, filling in its ID and CreatedAt
+func (m *MemoryStore) InsertLog(log *models.AnalyticsLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.eventIDs[log.EventID] {
+		return fmt.Errorf("failed to insert log %s: %w", log.EventID, ErrDuplicateEvent)
+	}
+	m.insert(log)
+	return nil
+}
+
+// InsertLogsBatch stores logs, or none of them if any event_id is already
+// stored or repeated within the batch
+func (m *MemoryStore) InsertLogsBatch(logs []models.AnalyticsLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool, len(logs))
+	for i := range logs {
+		if m.eventIDs[logs[i].EventID] || seen[logs[i].EventID] {
+			return fmt.Errorf("failed to insert batch: log %s: %w", logs[i].EventID, ErrDuplicateEvent)
+		}
+		seen[logs[i].EventID] = true
+	}
+
+	for i := range logs {
+		log := logs[i]
+		m.insert(&log)
+	}
+	return nil
+}
+
+// InsertLogsBatchSkipDuplicates stores the logs whose event_id is new,
+// keeping the first of repeated IDs within the batch
+func (m *MemoryStore) InsertLogsBatchSkipDuplicates(logs []models.AnalyticsLog) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := make([]bool, len(logs))
+	for i := range logs {
+		if m.eventIDs[logs[i].EventID] {
+			continue
+		}
+		log := logs[i]
+		m.insert(&log)
+		inserted[i] = true
+	}
+	return inserted, nil
+}
+
+// insert stores a log with the defaults PostgreSQL would fill in; m.mu must
+// be held
+func (m *MemoryStore) insert(log *models.AnalyticsLog) {
+	m.nextID++
+	log.ID = m.nextID
+	log.CreatedAt = time.Now()
+	log.SampleRate = sampleRate(log)
+	if log.Priority == "" {
+		log.Priority = "normal"
+	}
+
+	m.logs = append(m.logs, *log)
+	m.eventIDs[log.EventID] = true
+}
+
+// GetFilteredLogs returns the logs matching filter and their total count
+func (m *MemoryStore) GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, error) {
+	m.mu.RLock()
+	var matched []models.AnalyticsLog
+	for i := range m.logs {
+		if matchesFilter(&m.logs[i], &filter) {
+			matched = append(matched, m.logs[i])
+		}
+	}
+	m.mu.RUnlock()
+
+	sortBy := "created_at"
+	if LogSortFields[filter.SortBy] {
+		sortBy = filter.SortBy
+	}
+	descending := filter.SortOrder != "ASC" && filter.SortOrder != "asc"
+
+	slices.SortStableFunc(matched, func(a, b models.AnalyticsLog) int {
+		c := compareLogs(&a, &b, sortBy)
+		if descending {
+			return -c
+		}
+		return c
+	})
+
+	total := int64(len(matched))
+	start := min(max(filter.Offset, 0), len(matched))
+	end := min(start+max(filter.Limit, 0), len(matched))
+
+	return matched[start:end], total, nil
+}
+
+// GetRecentLogs returns the most recently stored logs, newest first
+func (m *MemoryStore) GetRecentLogs(limit int) ([]models.AnalyticsLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	logs := make([]models.AnalyticsLog, 0, min(max(limit, 0), len(m.logs)))
+	for i := len(m.logs) - 1; i >= 0 && len(logs) < limit; i-- {
+		logs = append(logs, m.logs[i])
+	}
+	return logs, nil
+}
+
+// GetMetrics counts the stored logs directly, so AsOf is always now
+func (m *MemoryStore) GetMetrics() (*models.MetricsResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	lastHour := now.Add(-time.Hour)
+	lastDay := now.Add(-24 * time.Hour)
+	activeSince := now.Add(-30 * time.Minute)
+
+	metrics := &models.MetricsResponse{
+		TotalLogs: int64(len(m.logs)),
+		AsOf:      &now,
+	}
+	sessions := make(map[string]bool)
+	eventTypes := make(map[string]int64)
+	for i := range m.logs {
+		log := &m.logs[i]
+		if !log.CreatedAt.Before(lastHour) {
+			metrics.LogsLastHour++
+		}
+		if !log.CreatedAt.Before(lastDay) {
+			metrics.LogsLastDay++
+			eventTypes[log.EventType]++
+		}
+		if log.SessionID != nil && !log.CreatedAt.Before(activeSince) {
+			sessions[*log.SessionID] = true
+		}
+	}
+	metrics.ActiveSessions = int64(len(sessions))
+
+	for eventType, count := range eventTypes {
+		metrics.TopEventTypes = append(metrics.TopEventTypes, models.EventTypeCount{EventType: eventType, Count: count})
+	}
+	slices.SortFunc(metrics.TopEventTypes, func(a, b models.EventTypeCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.EventType, b.EventType))
+	})
+	if len(metrics.TopEventTypes) > 10 {
+		metrics.TopEventTypes = metrics.TopEventTypes[:10]
+	}
+
+	return metrics, nil
+}
+
+// GetLogCount returns the number of stored logs
+func (m *MemoryStore) GetLogCount() (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.logs)), nil
+}
+
+// GetAPIKey returns an active API key by hash, or nil if there is none
+func (m *MemoryStore) GetAPIKey(keyHash string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	apiKey, ok := m.apiKeys[keyHash]
+	if !ok || !apiKey.IsActive {
+		return nil, nil
+	}
+	result := *apiKey
+	return &result, nil
+}
+
+// InsertAPIKey stores an API key, filling in its ID and CreatedAt
+func (m *MemoryStore) InsertAPIKey(apiKey *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.apiKeys[apiKey.KeyHash]; exists {
+		return fmt.Errorf("failed to insert API key: key hash already stored")
+	}
+
+	m.nextKey++
+	apiKey.ID = m.nextKey
+	apiKey.CreatedAt = time.Now()
+
+	stored := *apiKey
+	m.apiKeys[apiKey.KeyHash] = &stored
+	return nil
+}
+
+// UpdateAPIKeyUsage records a use of an API key
+func (m *MemoryStore) UpdateAPIKeyUsage(keyHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if apiKey, ok := m.apiKeys[keyHash]; ok {
+		now := time.Now()
+		apiKey.LastUsedAt = &now
+		apiKey.UsageCount++
+	}
+	return nil
+}
+
+// HealthCheck always succeeds
+func (m *MemoryStore) HealthCheck() error {
+	return nil
+}
+
+// Close is a no-op; the logs are dropped with the store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// matchesFilter reports whether a log matches the conditions of filter, as
+// GetFilteredLogs applies them in SQL
+func matchesFilter(log *models.AnalyticsLog, filter *LogFilter) bool {
+	if filter.EventType != "" && log.EventType != filter.EventType {
+		return false
+	}
+	if filter.EventName != "" && log.EventName != filter.EventName {
+		return false
+	}
+	if filter.UserID != "" && (log.UserID == nil || *log.UserID != filter.UserID) {
+		return false
+	}
+	if filter.SessionID != "" && (log.SessionID == nil || *log.SessionID != filter.SessionID) {
+		return false
+	}
+	if filter.AppVersion != "" && (log.AppVersion == nil || *log.AppVersion != filter.AppVersion) {
+		return false
+	}
+	if filter.Priority != "" && log.Priority != filter.Priority {
+		return false
+	}
+	if filter.ProviderName != "" {
+		tags, _ := log.Properties["tags"].(map[string]interface{})
+		if provider, _ := tags["provider"].(string); provider != filter.ProviderName {
+			return false
+		}
+	}
+	if filter.StartTime != nil && log.CreatedAt.Before(*filter.StartTime) {
+		return false
+	}
+	if filter.EndTime != nil && log.CreatedAt.After(*filter.EndTime) {
+		return false
+	}
+	return true
+}
+
+// compareLogs orders logs by one of LogSortFields ascending, with NULLs last
+// like PostgreSQL
+func compareLogs(a, b *models.AnalyticsLog, field string) int {
+	switch field {
+	case "id":
+		return cmp.Compare(a.ID, b.ID)
+	case "event_id":
+		return strings.Compare(a.EventID, b.EventID)
+	case "timestamp":
+		return a.Timestamp.Compare(b.Timestamp)
+	case "event_type":
+		return strings.Compare(a.EventType, b.EventType)
+	case "event_name":
+		return strings.Compare(a.EventName, b.EventName)
+	case "user_id":
+		return compareNullable(a.UserID, b.UserID, strings.Compare)
+	case "session_id":
+		return compareNullable(a.SessionID, b.SessionID, strings.Compare)
+	case "app_version":
+		return compareNullable(a.AppVersion, b.AppVersion, strings.Compare)
+	case "priority":
+		return strings.Compare(a.Priority, b.Priority)
+	case "corrected_timestamp":
+		return compareNullable(a.CorrectedTimestamp, b.CorrectedTimestamp, time.Time.Compare)
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
+
+func compareNullable[T any](a, b *T, compare func(T, T) int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return compare(*a, *b)
+	}
+}
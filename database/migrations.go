@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log-ingestion-server/migrations"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/sirupsen/logrus"
+)
+
+// migrationLockKey is held while the schema is migrated, so when several
+// replicas start together one migrates and the others wait for it.
+// golang-migrate locks each operation too; holding this lock across reading
+// the version and migrating lets waiting instances say so in their logs.
+const migrationLockKey = 0x6d696772617465 // "migrate"
+
+// Migration is one of the migrations embedded in the binary
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// MigrationStatus compares the database schema with the embedded migrations
+type MigrationStatus struct {
+	// Version is the last applied migration, 0 before the first
+	Version uint
+	// Dirty is set when migration Version failed part way; repair the schema
+	// by hand, then force the version it is now at
+	Dirty bool
+	// Latest is the newest embedded migration
+	Latest uint
+	// Migrations lists the embedded migrations, oldest first
+	Migrations []Migration
+}
+
+// Pending returns the number of embedded migrations not applied yet
+func (s *MigrationStatus) Pending() int {
+	pending := 0
+	for _, m := range s.Migrations {
+		if !m.Applied {
+			pending++
+		}
+	}
+	return pending
+}
+
+// migrateLogger reports golang-migrate's progress through logrus
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	logrus.Infof("Migration "+strings.TrimSuffix(format, "\n"), v...)
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
+
+// RunMigrations applies every pending migration
+func (db *DB) RunMigrations() error {
+	err := db.withMigrator(func(m *migrate.Migrate) error {
+		version, _, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+
+		latest, _, err := m.Version()
+		if err != nil {
+			return err
+		}
+		if latest == version {
+			logrus.Infof("Database schema is up to date at version %d", latest)
+		} else {
+			logrus.Infof("Database schema migrated from version %d to %d", version, latest)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations
+func (db *DB) MigrateDown(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("the number of migrations to revert must be positive")
+	}
+
+	err := db.withMigrator(func(m *migrate.Migrate) error {
+		return m.Steps(-steps)
+	})
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to revert migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateTo applies or reverts migrations until the schema is at version
+func (db *DB) MigrateTo(version uint) error {
+	err := db.withMigrator(func(m *migrate.Migrate) error {
+		return m.Migrate(version)
+	})
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// ForceMigrationVersion records version as applied and clears the dirty
+// flag without running any migration; -1 records that none is applied
+func (db *DB) ForceMigrationVersion(version int) error {
+	err := db.withMigrator(func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// GetMigrationStatus compares the applied version with the embedded
+// migrations
+func (db *DB) GetMigrationStatus() (*MigrationStatus, error) {
+	status := &MigrationStatus{}
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	defer src.Close()
+
+	err = db.withMigrator(func(m *migrate.Migrate) error {
+		version, dirty, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version, status.Dirty = version, dirty
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	version, err := src.First()
+	for err == nil {
+		r, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", version, readErr)
+		}
+		r.Close()
+
+		status.Migrations = append(status.Migrations, Migration{
+			Version: version,
+			Name:    name,
+			Applied: version < status.Version || (version == status.Version && !status.Dirty),
+		})
+		status.Latest = version
+
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	return status, nil
+}
+
+// withMigrator runs fn on a migrator of the embedded migrations, holding
+// migrationLockKey
+func (db *DB) withMigrator(fn func(m *migrate.Migrate) error) error {
+	ctx := context.Background()
+	release, err := db.advisoryLock(ctx, migrationLockKey, func() {
+		logrus.Info("Waiting for another instance to finish migrating the database")
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	// The driver gets a connection of its own: built WithInstance, closing
+	// it would close the pool the server keeps using
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		src.Close()
+		conn.Close()
+		return fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return fmt.Errorf("failed to create migration instance: %w", err)
+	}
+	defer m.Close()
+	m.Log = migrateLogger{}
+
+	err = fn(m)
+
+	var dirty migrate.ErrDirty
+	if errors.As(err, &dirty) {
+		return fmt.Errorf("migration %d failed part way and the database is marked dirty; repair the schema, then run `migrate force` with the version it is at", dirty.Version)
+	}
+	return err
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// defaultLogPartition receives the logs no other partition of analytics_logs
+// covers
+const defaultLogPartition = "analytics_logs_default"
+
+// partitionLockKey is the advisory lock held while partitions are created or
+// detached, so replicas do not run maintenance at the same time
+const partitionLockKey = 0x6c6f6770617274 // "logpart"
+
+// partitionBoundTime is the layout of partition bounds printed in UTC
+const partitionBoundTime = "2006-01-02 15:04:05-07"
+
+var partitionBound = regexp.MustCompile(`^FOR VALUES FROM \('([^']+)'\) TO \('([^']+)'\)$`)
+
+// LogPartition is a time range partition of analytics_logs covering
+// [From, To)
+type LogPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// ListLogPartitions returns the range partitions attached to analytics_logs,
+// oldest first. The default partition and partitions with unbounded ranges
+// are left out.
+func (db *DB) ListLogPartitions() ([]LogPartition, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Bounds are printed in the session time zone
+	if _, err := tx.Exec("SET LOCAL TimeZone = 'UTC'"); err != nil {
+		return nil, fmt.Errorf("failed to list log partitions: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT c.relname, pg_get_expr(c.relpartbound, c.oid)
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'analytics_logs'::regclass`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []LogPartition
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, fmt.Errorf("failed to scan log partition: %w", err)
+		}
+
+		match := partitionBound.FindStringSubmatch(bound)
+		if match == nil {
+			continue
+		}
+		from, errFrom := time.Parse(partitionBoundTime, match[1])
+		to, errTo := time.Parse(partitionBoundTime, match[2])
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		partitions = append(partitions, LogPartition{Name: name, From: from.UTC(), To: to.UTC()})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list log partitions: %w", err)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].From.Before(partitions[j].From)
+	})
+	return partitions, nil
+}
+
+// DefaultLogPeriods returns the start of each period, truncated to unit (day
+// or month) in UTC, that has logs in the default partition with a timestamp
+// in [since, until)
+func (db *DB) DefaultLogPeriods(unit string, since, until time.Time) ([]time.Time, error) {
+	rows, err := db.conn.Query(fmt.Sprintf(`
+		SELECT DISTINCT date_trunc($1, timestamp AT TIME ZONE 'UTC') AS period
+		FROM %s
+		WHERE timestamp >= $2 AND timestamp < $3
+		ORDER BY period`, defaultLogPartition),
+		unit, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find default partition periods: %w", err)
+	}
+	defer rows.Close()
+
+	var periods []time.Time
+	for rows.Next() {
+		var period time.Time
+		if err := rows.Scan(&period); err != nil {
+			return nil, fmt.Errorf("failed to scan default partition period: %w", err)
+		}
+		periods = append(periods, time.Date(period.Year(), period.Month(), period.Day(), 0, 0, 0, 0, time.UTC))
+	}
+
+	return periods, rows.Err()
+}
+
+// CreateLogPartition creates a partition of analytics_logs for [from, to) and
+// moves the logs of that range out of the default partition. Inserts that
+// would land in the default partition wait until it commits. It returns
+// false without doing anything when another instance holds the maintenance
+// lock.
+func (db *DB) CreateLogPartition(name string, from, to time.Time) (bool, int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if locked, err := tryPartitionLock(tx); err != nil || !locked {
+		return false, 0, err
+	}
+
+	table := pq.QuoteIdentifier(name)
+
+	// Attaching needs an exclusive lock on the default partition; taking it
+	// first avoids upgrading from the lock the move below takes
+	if _, err := tx.Exec(fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", defaultLogPartition)); err != nil {
+		return false, 0, fmt.Errorf("failed to lock default log partition: %w", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TABLE %s (LIKE analytics_logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, table))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to create log partition %s: %w", name, err)
+	}
+
+	result, err := tx.Exec(fmt.Sprintf(`
+		WITH moved AS (
+			DELETE FROM %s
+			WHERE timestamp >= $1 AND timestamp < $2
+			RETURNING *
+		)
+		INSERT INTO %s SELECT * FROM moved`, defaultLogPartition, table),
+		from, to)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to move logs into partition %s: %w", name, err)
+	}
+	moved, _ := result.RowsAffected()
+
+	// Partition bounds must be literals; the indexes and primary key of
+	// analytics_logs are built on the table as it is attached
+	_, err = tx.Exec(fmt.Sprintf(`
+		ALTER TABLE analytics_logs ATTACH PARTITION %s
+		FOR VALUES FROM (%s) TO (%s)`, table, boundLiteral(from), boundLiteral(to)))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to attach log partition %s: %w", name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, moved, nil
+}
+
+// DetachLogPartition detaches a partition from analytics_logs, leaving it as a
+// standalone table, takes its logs off the count of stored logs and forgets
+// the event IDs of its range so the event ID table does not grow without
+// bound. It returns false without doing anything
+// when another instance holds the maintenance lock.
+func (db *DB) DetachLogPartition(partition LogPartition) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if locked, err := tryPartitionLock(tx); err != nil || !locked {
+		return false, err
+	}
+
+	table := pq.QuoteIdentifier(partition.Name)
+	if _, err := tx.Exec(uncountRolledUp(table)); err != nil {
+		return false, fmt.Errorf("failed to uncount logs of %s: %w", partition.Name, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE analytics_logs DETACH PARTITION %s", table)); err != nil {
+		return false, fmt.Errorf("failed to detach log partition %s: %w", partition.Name, err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE timestamp >= $1 AND timestamp < $2`, eventIDTable),
+		partition.From, partition.To)
+	if err != nil {
+		return false, fmt.Errorf("failed to prune event IDs of %s: %w", partition.Name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// DropLogPartition detaches a partition from analytics_logs and drops it,
+// taking its logs off the count of stored logs and forgetting the event IDs
+// of its range. It returns false without doing
+// anything when the partition maintainer holds its lock.
+func (db *DB) DropLogPartition(partition LogPartition) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if locked, err := tryPartitionLock(tx); err != nil || !locked {
+		return false, err
+	}
+
+	table := pq.QuoteIdentifier(partition.Name)
+	if _, err := tx.Exec(uncountRolledUp(table)); err != nil {
+		return false, fmt.Errorf("failed to uncount logs of %s: %w", partition.Name, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE analytics_logs DETACH PARTITION %s", table)); err != nil {
+		return false, fmt.Errorf("failed to detach log partition %s: %w", partition.Name, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DROP TABLE %s", table)); err != nil {
+		return false, fmt.Errorf("failed to drop log partition %s: %w", partition.Name, err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE timestamp >= $1 AND timestamp < $2`, eventIDTable),
+		partition.From, partition.To)
+	if err != nil {
+		return false, fmt.Errorf("failed to prune event IDs of %s: %w", partition.Name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+func tryPartitionLock(tx *sql.Tx) (bool, error) {
+	var locked bool
+	if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", partitionLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("failed to take partition maintenance lock: %w", err)
+	}
+	return locked, nil
+}
+
+func boundLiteral(t time.Time) string {
+	return pq.QuoteLiteral(t.UTC().Format(partitionBoundTime))
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+	"strings"
+	"time"
+)
+
+// retentionLockKey is the advisory lock held for a whole retention run, so
+// only one replica purges at a time
+const retentionLockKey = 0x726574656e74 // "retent"
+
+const retentionPolicyColumns = `id, name, position, event_type, event_name, priority,
+		retention_class, retention_days, is_active, created_at, updated_at`
+
+// ListRetentionPolicies returns every retention policy in evaluation order,
+// including inactive policies
+func (db *DB) ListRetentionPolicies() ([]models.RetentionPolicy, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + retentionPolicyColumns + `
+		FROM retention_policies
+		ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []models.RetentionPolicy
+	for rows.Next() {
+		var p models.RetentionPolicy
+		if err := rows.Scan(&p.ID, &p.Name, &p.Position, &p.EventType, &p.EventName, &p.Priority,
+			&p.RetentionClass, &p.RetentionDays, &p.IsActive, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+// InsertRetentionPolicy stores a new retention policy. ID, CreatedAt and
+// UpdatedAt are filled in.
+func (db *DB) InsertRetentionPolicy(p *models.RetentionPolicy) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO retention_policies (name, position, event_type, event_name, priority,
+			retention_class, retention_days, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		p.Name, p.Position, p.EventType, p.EventName, p.Priority,
+		p.RetentionClass, p.RetentionDays, p.IsActive,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert retention policy: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRetentionPolicy replaces the settings of an existing retention
+// policy. It returns false if the policy does not exist.
+func (db *DB) UpdateRetentionPolicy(p *models.RetentionPolicy) (bool, error) {
+	err := db.conn.QueryRow(`
+		UPDATE retention_policies SET
+			name = $2, position = $3, event_type = $4, event_name = $5, priority = $6,
+			retention_class = $7, retention_days = $8, is_active = $9,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		p.ID, p.Name, p.Position, p.EventType, p.EventName, p.Priority,
+		p.RetentionClass, p.RetentionDays, p.IsActive,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update retention policy: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteRetentionPolicy removes a retention policy. It returns false if the
+// policy does not exist.
+func (db *DB) DeleteRetentionPolicy(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM retention_policies WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// TryRetentionLock takes the retention advisory lock. It returns a nil
+// release function when another instance holds the lock; otherwise release
+// must be called once the run is over.
+func (db *DB) TryRetentionLock(ctx context.Context) (func(), error) {
+	return db.tryAdvisoryLock(ctx, retentionLockKey)
+}
+
+// PurgeExpiredLogs deletes up to limit logs older than cutoff that match
+// policy and none of the earlier policies, forgetting their event IDs and
+// taking them off the count of stored logs. With
+// archivedOnly, logs whose day and event type have no archive_manifest entry
+// are kept. It returns the number of logs deleted.
+func (db *DB) PurgeExpiredLogs(ctx context.Context, policy models.RetentionPolicy, earlier []models.RetentionPolicy, cutoff time.Time, limit int, archivedOnly bool) (int64, error) {
+	args := []interface{}{cutoff, limit}
+	conditions := []string{"timestamp < $1", retentionMatch(policy, &args)}
+	for _, p := range earlier {
+		conditions = append(conditions, "NOT "+retentionMatch(p, &args))
+	}
+	if archivedOnly {
+		conditions = append(conditions, archivedCondition)
+	}
+
+	var deleted int64
+	err := db.conn.QueryRowContext(ctx, fmt.Sprintf(`
+		WITH expired AS (
+			SELECT id, timestamp FROM analytics_logs
+			WHERE %s
+			LIMIT $2
+		), deleted AS (
+			DELETE FROM analytics_logs l
+			USING expired e
+			WHERE l.id = e.id AND l.timestamp = e.timestamp
+			RETURNING l.event_id, l.created_at
+		), forgotten AS (
+			DELETE FROM %s
+			WHERE event_id IN (SELECT event_id FROM deleted)
+		), uncounted AS (%s
+		)
+		SELECT COUNT(*) FROM deleted`, strings.Join(conditions, " AND "), eventIDTable, uncountRolledUp("deleted")),
+		args...).Scan(&deleted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge logs of retention policy %s: %w", policy.Name, err)
+	}
+
+	return deleted, nil
+}
+
+// PurgeServerMetrics deletes up to limit server metrics older than cutoff and
+// returns the number deleted
+func (db *DB) PurgeServerMetrics(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `
+		DELETE FROM server_metrics
+		WHERE id IN (
+			SELECT id FROM server_metrics
+			WHERE timestamp < $1
+			LIMIT $2
+		)`, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge server metrics: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// InsertRetentionRun records a retention run and deletes the records of all
+// but the latest keep runs. ID is filled in.
+func (db *DB) InsertRetentionRun(run *models.RetentionRun, keep int) error {
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return err
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO retention_runs (instance, status, started_at, finished_at, rows_deleted,
+			partitions_dropped, error, results)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		run.Instance, run.Status, run.StartedAt, run.FinishedAt, run.RowsDeleted,
+		run.PartitionsDropped, run.Error, results,
+	).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert retention run: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		DELETE FROM retention_runs
+		WHERE id NOT IN (SELECT id FROM retention_runs ORDER BY started_at DESC LIMIT $1)`, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune retention runs: %w", err)
+	}
+
+	return nil
+}
+
+// ListRetentionRuns returns the latest limit retention runs, newest first
+func (db *DB) ListRetentionRuns(limit int) ([]models.RetentionRun, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, instance, status, started_at, finished_at, rows_deleted,
+			partitions_dropped, error, results
+		FROM retention_runs
+		ORDER BY started_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.RetentionRun
+	for rows.Next() {
+		var r models.RetentionRun
+		var results []byte
+		if err := rows.Scan(&r.ID, &r.Instance, &r.Status, &r.StartedAt, &r.FinishedAt, &r.RowsDeleted,
+			&r.PartitionsDropped, &r.Error, &results); err != nil {
+			return nil, fmt.Errorf("failed to scan retention run: %w", err)
+		}
+		if err := json.Unmarshal(results, &r.Results); err != nil {
+			return nil, fmt.Errorf("failed to decode results of retention run %d: %w", r.ID, err)
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+// retentionMatch returns the condition matching the logs of a policy,
+// appending its arguments to args
+func retentionMatch(p models.RetentionPolicy, args *[]interface{}) string {
+	var conditions []string
+	add := func(condition string, value interface{}) {
+		*args = append(*args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(*args)))
+	}
+
+	if p.EventType != "" {
+		add("event_type = $%d", p.EventType)
+	}
+	if p.EventName != "" {
+		if strings.ContainsAny(p.EventName, "*?") {
+			add("event_name LIKE $%d", likePattern(p.EventName))
+		} else {
+			add("event_name = $%d", p.EventName)
+		}
+	}
+	if p.Priority != "" {
+		add("COALESCE(priority, 'normal') = $%d", p.Priority)
+	}
+	if p.RetentionClass != "" {
+		add("event_type IN (SELECT name FROM event_types WHERE retention_class = $%d)", p.RetentionClass)
+	}
+
+	if len(conditions) == 0 {
+		return "TRUE"
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// likePattern converts a shell-style pattern using * and ? to a LIKE pattern
+func likePattern(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,451 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log-ingestion-server/hll"
+	"log-ingestion-server/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// rollupLockKey is the advisory lock held by the rollup aggregator, so only
+// one replica aggregates at a time
+const rollupLockKey = 0x726f6c6c7570 // "rollup"
+
+// rollupStateName identifies the analytics_logs watermark in log_rollup_state
+const rollupStateName = "analytics_logs"
+
+// bucketUTC truncates a timestamptz column to a granularity in UTC
+func bucketUTC(granularity, column string) string {
+	return fmt.Sprintf("date_trunc(%s, %s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'", granularity, column)
+}
+
+// RollupGroupColumns lists the log_rollups columns a series can be grouped
+// or filtered by
+var RollupGroupColumns = map[string]bool{
+	"event_type": true, "event_name": true, "app_version": true, "priority": true,
+}
+
+// RollupFilter selects a time series of log counts
+type RollupFilter struct {
+	Granularity string
+	From        time.Time
+	To          time.Time
+	// GroupBy is one of RollupGroupColumns, or empty for a single series
+	GroupBy string
+	// Where restricts RollupGroupColumns to exact values
+	Where map[string]string
+}
+
+// TryRollupLock takes the rollup advisory lock. It returns a nil release
+// function when another instance holds the lock.
+func (db *DB) TryRollupLock(ctx context.Context) (func(), error) {
+	return db.tryAdvisoryLock(ctx, rollupLockKey)
+}
+
+// RollupWatermark returns the creation time up to which logs have been rolled
+// up, or nil if the rollups were never started
+func (db *DB) RollupWatermark() (*time.Time, error) {
+	var watermark time.Time
+	err := db.conn.QueryRow(`SELECT watermark FROM log_rollup_state WHERE name = $1`, rollupStateName).Scan(&watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+	return &watermark, nil
+}
+
+// StartRollups sets the initial watermark to the creation time of the oldest
+// log, or to fallback when there are none, unless it is already set
+func (db *DB) StartRollups(fallback time.Time) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO log_rollup_state (name, watermark)
+		SELECT $1, COALESCE((SELECT MIN(created_at) FROM analytics_logs), $2)
+		ON CONFLICT (name) DO NOTHING`, rollupStateName, fallback)
+	if err != nil {
+		return fmt.Errorf("failed to start rollups: %w", err)
+	}
+	return nil
+}
+
+// RollUpLogs adds the logs created in [from, to) to the minute, hour and day
+// rollups and to the count of stored logs, and moves the watermark from from
+// to to, in one transaction so each log is counted exactly once. It returns
+// the number of logs rolled up.
+func (db *DB) RollUpLogs(ctx context.Context, from, to time.Time) (int64, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var watermark time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT watermark FROM log_rollup_state WHERE name = $1 FOR UPDATE`, rollupStateName).Scan(&watermark)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock rollup watermark: %w", err)
+	}
+	if !watermark.Equal(from) {
+		return 0, fmt.Errorf("rollup watermark moved to %s", watermark.Format(time.RFC3339Nano))
+	}
+
+	var rolledUp int64
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		WITH counts AS (
+			SELECT %s AS minute_bucket, event_type, event_name,
+				COALESCE(app_version, '') AS app_version, COALESCE(priority, 'normal') AS priority,
+				COUNT(*) AS log_count, SUM(1 / sample_rate) AS estimated_count
+			FROM analytics_logs
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY 1, 2, 3, 4, 5
+		), rolled_up AS (
+			INSERT INTO log_rollups (granularity, bucket, event_type, event_name, app_version, priority,
+				log_count, estimated_count)
+			SELECT g.granularity, %s, event_type, event_name, app_version, priority,
+				SUM(log_count), SUM(estimated_count)
+			FROM counts
+			CROSS JOIN (VALUES ('minute'), ('hour'), ('day')) AS g(granularity)
+			GROUP BY 1, 2, 3, 4, 5, 6
+			ON CONFLICT (granularity, bucket, event_type, event_name, app_version, priority) DO UPDATE SET
+				log_count = log_rollups.log_count + EXCLUDED.log_count,
+				estimated_count = log_rollups.estimated_count + EXCLUDED.estimated_count
+		)
+		SELECT COALESCE(SUM(log_count), 0) FROM counts`,
+		bucketUTC("'minute'", "created_at"), bucketUTC("g.granularity", "minute_bucket")),
+		from, to).Scan(&rolledUp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up log counts: %w", err)
+	}
+
+	if err := rollUpSketches(ctx, tx, from, to); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE log_rollup_state SET watermark = $2, log_count = log_count + $3, updated_at = NOW()
+		WHERE name = $1`,
+		rollupStateName, to, rolledUp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move rollup watermark: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return rolledUp, nil
+}
+
+// sketchKey identifies a row of log_rollup_sketches
+type sketchKey struct {
+	granularity string
+	bucket      time.Time
+	eventType   string
+}
+
+type sketchPair struct {
+	users, sessions *hll.Sketch
+}
+
+// sketchBuckets returns the start of the minute, hour and day buckets (UTC)
+// that the users and sessions of a minute bucket are added to
+func sketchBuckets(minute time.Time) map[string]time.Time {
+	minute = minute.UTC()
+	return map[string]time.Time{
+		models.GranularityMinute: minute,
+		models.GranularityHour:   minute.Truncate(time.Hour),
+		models.GranularityDay:    time.Date(minute.Year(), minute.Month(), minute.Day(), 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// rollUpSketches adds the users and sessions of the logs created in [from,
+// to) to the sketches of their buckets. PostgreSQL hashes the IDs, so only
+// one row per distinct ID and minute is transferred.
+func rollUpSketches(ctx context.Context, tx *sql.Tx, from, to time.Time) error {
+	minute := bucketUTC("'minute'", "created_at")
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT %[1]s, event_type, TRUE, hashtextextended(user_id, 0)
+		FROM analytics_logs
+		WHERE created_at >= $1 AND created_at < $2 AND user_id IS NOT NULL
+		UNION ALL
+		SELECT DISTINCT %[1]s, event_type, FALSE, hashtextextended(session_id, 0)
+		FROM analytics_logs
+		WHERE created_at >= $1 AND created_at < $2 AND session_id IS NOT NULL`, minute),
+		from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read users and sessions: %w", err)
+	}
+	defer rows.Close()
+
+	added := make(map[sketchKey]*sketchPair)
+	for rows.Next() {
+		var bucket time.Time
+		var eventType string
+		var isUser bool
+		var hash int64
+		if err := rows.Scan(&bucket, &eventType, &isUser, &hash); err != nil {
+			return fmt.Errorf("failed to scan user or session: %w", err)
+		}
+
+		for granularity, start := range sketchBuckets(bucket) {
+			key := sketchKey{granularity, start, eventType}
+			pair := added[key]
+			if pair == nil {
+				pair = &sketchPair{users: hll.New(), sessions: hll.New()}
+				added[key] = pair
+			}
+			if isUser {
+				pair.users.Add(uint64(hash))
+			} else {
+				pair.sessions.Add(uint64(hash))
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read users and sessions: %w", err)
+	}
+	rows.Close()
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	// Merge with the sketches already stored for the buckets touched
+	dayFrom := time.Date(from.UTC().Year(), from.UTC().Month(), from.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	stored, err := tx.QueryContext(ctx, `
+		SELECT granularity, bucket, event_type, users, sessions
+		FROM log_rollup_sketches
+		WHERE (granularity = 'minute' AND bucket >= $1 AND bucket < $4)
+			OR (granularity = 'hour' AND bucket >= $2 AND bucket < $4)
+			OR (granularity = 'day' AND bucket >= $3 AND bucket < $4)
+		FOR UPDATE`,
+		from.UTC().Truncate(time.Minute), from.UTC().Truncate(time.Hour), dayFrom, to)
+	if err != nil {
+		return fmt.Errorf("failed to read rollup sketches: %w", err)
+	}
+	defer stored.Close()
+
+	for stored.Next() {
+		var key sketchKey
+		var users, sessions []byte
+		if err := stored.Scan(&key.granularity, &key.bucket, &key.eventType, &users, &sessions); err != nil {
+			return fmt.Errorf("failed to scan rollup sketch: %w", err)
+		}
+		key.bucket = key.bucket.UTC()
+
+		pair := added[key]
+		if pair == nil {
+			continue
+		}
+		existing := &sketchPair{users: hll.New(), sessions: hll.New()}
+		if err := existing.users.UnmarshalBinary(users); err != nil {
+			return fmt.Errorf("failed to decode rollup sketch: %w", err)
+		}
+		if err := existing.sessions.UnmarshalBinary(sessions); err != nil {
+			return fmt.Errorf("failed to decode rollup sketch: %w", err)
+		}
+		pair.users.Merge(existing.users)
+		pair.sessions.Merge(existing.sessions)
+	}
+	if err := stored.Err(); err != nil {
+		return fmt.Errorf("failed to read rollup sketches: %w", err)
+	}
+	stored.Close()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO log_rollup_sketches (granularity, bucket, event_type, users, sessions)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (granularity, bucket, event_type) DO UPDATE SET
+			users = EXCLUDED.users,
+			sessions = EXCLUDED.sessions`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rollup sketch upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	for key, pair := range added {
+		users, _ := pair.users.MarshalBinary()
+		sessions, _ := pair.sessions.MarshalBinary()
+		if _, err := stmt.ExecContext(ctx, key.granularity, key.bucket, key.eventType, users, sessions); err != nil {
+			return fmt.Errorf("failed to store rollup sketch: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PruneRollups deletes the rollups of a granularity older than before and
+// returns the number of count rows deleted
+func (db *DB) PruneRollups(granularity string, before time.Time) (int64, error) {
+	result, err := db.conn.Exec(`
+		DELETE FROM log_rollups WHERE granularity = $1 AND bucket < $2`, granularity, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune %s rollups: %w", granularity, err)
+	}
+
+	_, err = db.conn.Exec(`
+		DELETE FROM log_rollup_sketches WHERE granularity = $1 AND bucket < $2`, granularity, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune %s rollup sketches: %w", granularity, err)
+	}
+
+	return result.RowsAffected()
+}
+
+// RollupSeries returns the log counts of the buckets in [From, To), ordered
+// by bucket and group. Buckets without logs are left out.
+func (db *DB) RollupSeries(filter RollupFilter) ([]models.RollupPoint, error) {
+	group := "''"
+	if filter.GroupBy != "" {
+		if !RollupGroupColumns[filter.GroupBy] {
+			return nil, fmt.Errorf("invalid rollup group %q", filter.GroupBy)
+		}
+		group = filter.GroupBy
+	}
+
+	conditions := []string{"granularity = $1", "bucket >= $2", "bucket < $3"}
+	args := []interface{}{filter.Granularity, filter.From, filter.To}
+	for column, value := range filter.Where {
+		if !RollupGroupColumns[column] {
+			return nil, fmt.Errorf("invalid rollup filter %q", column)
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	rows, err := db.conn.Query(fmt.Sprintf(`
+		SELECT bucket, %s, SUM(log_count), SUM(estimated_count)
+		FROM log_rollups
+		WHERE %s
+		GROUP BY 1, 2
+		ORDER BY 1, 2`, group, strings.Join(conditions, " AND ")),
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollup series: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.RollupPoint{}
+	for rows.Next() {
+		var p models.RollupPoint
+		if err := rows.Scan(&p.Bucket, &p.Group, &p.Count, &p.EstimatedCount); err != nil {
+			return nil, fmt.Errorf("failed to scan rollup point: %w", err)
+		}
+		p.Bucket = p.Bucket.UTC()
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+// RollupUniques returns the estimated distinct users and sessions of each
+// bucket in [from, to), optionally for one event type, and over the whole
+// range
+func (db *DB) RollupUniques(granularity string, from, to time.Time, eventType string) ([]models.UniquesPoint, models.UniquesPoint, error) {
+	var total models.UniquesPoint
+
+	query := `
+		SELECT bucket, users, sessions
+		FROM log_rollup_sketches
+		WHERE granularity = $1 AND bucket >= $2 AND bucket < $3`
+	args := []interface{}{granularity, from, to}
+	if eventType != "" {
+		query += " AND event_type = $4"
+		args = append(args, eventType)
+	}
+	query += " ORDER BY bucket"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, total, fmt.Errorf("failed to get rollup sketches: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.UniquesPoint{}
+	allUsers, allSessions := hll.New(), hll.New()
+	var bucketUsers, bucketSessions *hll.Sketch
+	flush := func() {
+		if bucketUsers != nil {
+			points[len(points)-1].Users = bucketUsers.Estimate()
+			points[len(points)-1].Sessions = bucketSessions.Estimate()
+		}
+	}
+
+	for rows.Next() {
+		var bucket time.Time
+		var users, sessions []byte
+		if err := rows.Scan(&bucket, &users, &sessions); err != nil {
+			return nil, total, fmt.Errorf("failed to scan rollup sketch: %w", err)
+		}
+		bucket = bucket.UTC()
+
+		if len(points) == 0 || !points[len(points)-1].Bucket.Equal(bucket) {
+			flush()
+			points = append(points, models.UniquesPoint{Bucket: bucket})
+			bucketUsers, bucketSessions = hll.New(), hll.New()
+		}
+
+		var u, s hll.Sketch
+		if err := u.UnmarshalBinary(users); err != nil {
+			return nil, total, fmt.Errorf("failed to decode rollup sketch: %w", err)
+		}
+		if err := s.UnmarshalBinary(sessions); err != nil {
+			return nil, total, fmt.Errorf("failed to decode rollup sketch: %w", err)
+		}
+		bucketUsers.Merge(&u)
+		bucketSessions.Merge(&s)
+		allUsers.Merge(&u)
+		allSessions.Merge(&s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, total, fmt.Errorf("failed to get rollup sketches: %w", err)
+	}
+	flush()
+
+	total.Users = allUsers.Estimate()
+	total.Sessions = allSessions.Estimate()
+	return points, total, nil
+}
+
+// uncountRolledUp returns an UPDATE statement, usable as a CTE, that takes
+// the logs of deleted, a table or CTE of logs being removed from
+// analytics_logs, that were already rolled up off the count of stored logs.
+// Updating the watermark row waits for a concurrent rollup to commit and is
+// then evaluated against the watermark it moved to, and a rollup waits for
+// the deletion in turn, so each log is subtracted only if it was counted.
+func uncountRolledUp(deleted string) string {
+	return fmt.Sprintf(`
+		UPDATE log_rollup_state
+		SET log_count = log_count - (
+			SELECT COUNT(*) FROM %s d WHERE d.created_at < log_rollup_state.watermark)
+		WHERE name = %s`, deleted, pq.QuoteLiteral(rollupStateName))
+}
+
+// storedLogCount returns the number of logs rolled up that are still stored
+func (db *DB) storedLogCount() (int64, error) {
+	var count int64
+	err := db.conn.QueryRow(`SELECT log_count FROM log_rollup_state WHERE name = $1`, rollupStateName).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return count, err
+}
+
+// rollupTotal sums the logs of a granularity's buckets since a time, or all
+// of them when since is nil
+func (db *DB) rollupTotal(granularity string, since *time.Time) (int64, error) {
+	var total int64
+	err := db.conn.QueryRow(`
+		SELECT COALESCE(SUM(log_count), 0)
+		FROM log_rollups
+		WHERE granularity = $1 AND ($2::TIMESTAMPTZ IS NULL OR bucket >= $2)`,
+		granularity, since).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"log-ingestion-server/models"
+	"testing"
+	"time"
+)
+
+func TestSketchBuckets(t *testing.T) {
+	tests := []struct {
+		name       string
+		minute     time.Time
+		wantMinute string
+		wantHour   string
+		wantDay    string
+	}{
+		{
+			name:       "within an hour",
+			minute:     time.Date(2026, 10, 16, 9, 42, 0, 0, time.UTC),
+			wantMinute: "2026-10-16T09:42:00Z",
+			wantHour:   "2026-10-16T09:00:00Z",
+			wantDay:    "2026-10-16T00:00:00Z",
+		},
+		{
+			name:       "last minute of the day",
+			minute:     time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC),
+			wantMinute: "2026-10-16T23:59:00Z",
+			wantHour:   "2026-10-16T23:00:00Z",
+			wantDay:    "2026-10-16T00:00:00Z",
+		},
+		{
+			// The driver may return the bucket in the session time zone; the
+			// day is still the UTC one
+			name:       "non-UTC time zone",
+			minute:     time.Date(2026, 10, 17, 1, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			wantMinute: "2026-10-16T23:30:00Z",
+			wantHour:   "2026-10-16T23:00:00Z",
+			wantDay:    "2026-10-16T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets := sketchBuckets(tt.minute)
+			if len(buckets) != 3 {
+				t.Fatalf("buckets = %v, want minute, hour and day", buckets)
+			}
+			for granularity, want := range map[string]string{
+				models.GranularityMinute: tt.wantMinute,
+				models.GranularityHour:   tt.wantHour,
+				models.GranularityDay:    tt.wantDay,
+			} {
+				if got := buckets[granularity].Format(time.RFC3339); got != want {
+					t.Errorf("%s bucket = %s, want %s", granularity, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+)
+
+const samplingRuleColumns = `id, name, position, event_type, event_name, app_version, property,
+		action, keep_rate, sample_by, is_active, created_at, updated_at`
+
+// ListSamplingRules returns every sampling rule in evaluation order,
+// including inactive rules
+func (db *DB) ListSamplingRules() ([]models.SamplingRule, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + samplingRuleColumns + `
+		FROM sampling_rules
+		ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sampling rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.SamplingRule
+	for rows.Next() {
+		var r models.SamplingRule
+		if err := scanSamplingRule(rows, &r); err != nil {
+			return nil, fmt.Errorf("failed to scan sampling rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// InsertSamplingRule stores a new sampling rule. ID, CreatedAt and UpdatedAt
+// are filled in.
+func (db *DB) InsertSamplingRule(r *models.SamplingRule) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO sampling_rules (name, position, event_type, event_name, app_version, property,
+			action, keep_rate, sample_by, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`,
+		r.Name, r.Position, r.EventType, r.EventName, r.AppVersion, r.Property,
+		r.Action, r.KeepRate, r.SampleBy, r.IsActive,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert sampling rule: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSamplingRule replaces the settings of an existing sampling rule. It
+// returns false if the rule does not exist.
+func (db *DB) UpdateSamplingRule(r *models.SamplingRule) (bool, error) {
+	err := db.conn.QueryRow(`
+		UPDATE sampling_rules SET
+			name = $2, position = $3, event_type = $4, event_name = $5, app_version = $6,
+			property = $7, action = $8, keep_rate = $9, sample_by = $10, is_active = $11,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		r.ID, r.Name, r.Position, r.EventType, r.EventName, r.AppVersion,
+		r.Property, r.Action, r.KeepRate, r.SampleBy, r.IsActive,
+	).Scan(&r.CreatedAt, &r.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update sampling rule: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteSamplingRule removes a sampling rule. It returns false if the rule
+// does not exist.
+func (db *DB) DeleteSamplingRule(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM sampling_rules WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete sampling rule: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func scanSamplingRule(rows *sql.Rows, r *models.SamplingRule) error {
+	return rows.Scan(&r.ID, &r.Name, &r.Position, &r.EventType, &r.EventName, &r.AppVersion,
+		&r.Property, &r.Action, &r.KeepRate, &r.SampleBy, &r.IsActive, &r.CreatedAt, &r.UpdatedAt)
+}
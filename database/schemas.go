@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log-ingestion-server/models"
+)
+
+const eventSchemaColumns = "id, event_name, version, mode, schema, is_active, created_at"
+
+// InsertEventSchema stores a new schema version. A zero Version is assigned
+// the next version for the event name. ID, Version and CreatedAt are filled in.
+func (db *DB) InsertEventSchema(s *models.EventSchema) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO event_schemas (event_name, version, mode, schema, is_active)
+		SELECT $1, CASE WHEN $2 > 0 THEN $2 ELSE COALESCE(MAX(version), 0) + 1 END, $3, $4, $5
+		FROM event_schemas WHERE event_name = $1
+		RETURNING id, version, created_at`,
+		s.EventName, s.Version, s.Mode, []byte(s.Schema), s.IsActive,
+	).Scan(&s.ID, &s.Version, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert event schema: %w", err)
+	}
+
+	return nil
+}
+
+// ListEventSchemas returns every schema version, optionally for one event name
+func (db *DB) ListEventSchemas(eventName string) ([]models.EventSchema, error) {
+	query := "SELECT " + eventSchemaColumns + " FROM event_schemas"
+	var args []interface{}
+	if eventName != "" {
+		query += " WHERE event_name = $1"
+		args = append(args, eventName)
+	}
+	query += " ORDER BY event_name, version DESC"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event schemas: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEventSchemas(rows)
+}
+
+// ActiveEventSchemas returns the enforced schema of each event name: its
+// highest active version
+func (db *DB) ActiveEventSchemas() ([]models.EventSchema, error) {
+	rows, err := db.conn.Query(`
+		SELECT DISTINCT ON (event_name) ` + eventSchemaColumns + `
+		FROM event_schemas
+		WHERE is_active
+		ORDER BY event_name, version DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active event schemas: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEventSchemas(rows)
+}
+
+// DeactivateEventSchema stops enforcing a schema version. It returns false if
+// the version does not exist.
+func (db *DB) DeactivateEventSchema(eventName string, version int) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE event_schemas SET is_active = FALSE
+		WHERE event_name = $1 AND version = $2`,
+		eventName, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to deactivate event schema: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func scanEventSchemas(rows *sql.Rows) ([]models.EventSchema, error) {
+	var schemas []models.EventSchema
+	for rows.Next() {
+		var s models.EventSchema
+		var raw []byte
+		if err := rows.Scan(&s.ID, &s.EventName, &s.Version, &s.Mode, &raw, &s.IsActive, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event schema: %w", err)
+		}
+		s.Schema = raw
+		schemas = append(schemas, s)
+	}
+
+	return schemas, rows.Err()
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"log-ingestion-server/models"
+)
+
+// ErrDuplicateEvent is returned when a log's event_id is already stored. Its
+// message matches PostgreSQL's unique violation, which callers check for.
+var ErrDuplicateEvent = errors.New("duplicate key: event_id is already stored")
+
+// Store is the storage behind log ingestion, queries, metrics, API keys and
+// health checks. DB implements it on PostgreSQL; MemoryStore and the sqlite
+// package implement it for tests and the single-binary dev mode, without the
+// admin tables, rollups and background jobs that need PostgreSQL.
+type Store interface {
+	// InsertLog inserts a log, filling in its ID and CreatedAt
+	InsertLog(log *models.AnalyticsLog) error
+	// InsertLogsBatch inserts logs atomically; the whole batch fails if any
+	// event_id is already stored
+	InsertLogsBatch(logs []models.AnalyticsLog) error
+	// InsertLogsBatchSkipDuplicates inserts logs whose event_id is new and
+	// reports, per input index, whether the log was inserted
+	InsertLogsBatchSkipDuplicates(logs []models.AnalyticsLog) ([]bool, error)
+
+	GetFilteredLogs(filter LogFilter) ([]models.AnalyticsLog, int64, error)
+	GetRecentLogs(limit int) ([]models.AnalyticsLog, error)
+	GetMetrics() (*models.MetricsResponse, error)
+	GetLogCount() (int64, error)
+
+	// GetAPIKey returns an active API key by hash, or nil if there is none
+	GetAPIKey(keyHash string) (*models.APIKey, error)
+	InsertAPIKey(apiKey *models.APIKey) error
+	UpdateAPIKeyUsage(keyHash string) error
+
+	HealthCheck() error
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
+
+// LogSortFields lists the fields GetFilteredLogs can sort by
+var LogSortFields = map[string]bool{
+	"id": true, "event_id": true, "timestamp": true, "event_type": true,
+	"event_name": true, "user_id": true, "session_id": true,
+	"app_version": true, "priority": true, "created_at": true,
+	"corrected_timestamp": true,
+}
+
+// Builtins serves the registries the event types created by the initial
+// migration and no schemas, rules or overrides. It stands in for the admin
+// tables when logs are kept by a Store other than DB.
+type Builtins struct{}
+
+// ListEventTypes returns the built-in event types
+func (Builtins) ListEventTypes() ([]models.EventType, error) {
+	eventTypes := make([]models.EventType, len(builtinEventTypes))
+	copy(eventTypes, builtinEventTypes)
+	return eventTypes, nil
+}
+
+// ActiveEventSchemas returns no schemas
+func (Builtins) ActiveEventSchemas() ([]models.EventSchema, error) {
+	return nil, nil
+}
+
+// ListSamplingRules returns no rules
+func (Builtins) ListSamplingRules() ([]models.SamplingRule, error) {
+	return nil, nil
+}
+
+// ListTransformRules returns no rules
+func (Builtins) ListTransformRules() ([]models.TransformRule, error) {
+	return nil, nil
+}
+
+// ListClientConfigOverrides returns no overrides
+func (Builtins) ListClientConfigOverrides() ([]models.ClientConfigOverride, error) {
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+)
+
+const transformRuleColumns = `id, name, position, event_type, event_name, min_app_version,
+		max_app_version, operations, is_active, created_at, updated_at`
+
+// ListTransformRules returns every transform rule in application order,
+// including inactive rules
+func (db *DB) ListTransformRules() ([]models.TransformRule, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + transformRuleColumns + `
+		FROM transform_rules
+		ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transform rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.TransformRule
+	for rows.Next() {
+		var r models.TransformRule
+		var operations []byte
+		if err := rows.Scan(&r.ID, &r.Name, &r.Position, &r.EventType, &r.EventName, &r.MinAppVersion,
+			&r.MaxAppVersion, &operations, &r.IsActive, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transform rule: %w", err)
+		}
+		if err := json.Unmarshal(operations, &r.Operations); err != nil {
+			return nil, fmt.Errorf("failed to decode operations of transform rule %s: %w", r.Name, err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// InsertTransformRule stores a new transform rule. ID, CreatedAt and
+// UpdatedAt are filled in.
+func (db *DB) InsertTransformRule(r *models.TransformRule) error {
+	operations, err := json.Marshal(r.Operations)
+	if err != nil {
+		return err
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO transform_rules (name, position, event_type, event_name, min_app_version,
+			max_app_version, operations, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		r.Name, r.Position, r.EventType, r.EventName, r.MinAppVersion,
+		r.MaxAppVersion, operations, r.IsActive,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert transform rule: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTransformRule replaces an existing transform rule. It returns false
+// if the rule does not exist.
+func (db *DB) UpdateTransformRule(r *models.TransformRule) (bool, error) {
+	operations, err := json.Marshal(r.Operations)
+	if err != nil {
+		return false, err
+	}
+
+	err = db.conn.QueryRow(`
+		UPDATE transform_rules SET
+			name = $2, position = $3, event_type = $4, event_name = $5, min_app_version = $6,
+			max_app_version = $7, operations = $8, is_active = $9, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		r.ID, r.Name, r.Position, r.EventType, r.EventName, r.MinAppVersion,
+		r.MaxAppVersion, operations, r.IsActive,
+	).Scan(&r.CreatedAt, &r.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update transform rule: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteTransformRule removes a transform rule. It returns false if the rule
+// does not exist.
+func (db *DB) DeleteTransformRule(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM transform_rules WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transform rule: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
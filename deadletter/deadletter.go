@@ -0,0 +1,87 @@
+// Package deadletter keeps accepted logs that the database refused to store,
+// so they can be inspected and re-ingested instead of being lost.
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Record is one line of a dead-letter file
+type Record struct {
+	FailedAt time.Time           `json:"failed_at"`
+	Source   string              `json:"source"`
+	Error    string              `json:"error"`
+	Log      models.AnalyticsLog `json:"log"`
+}
+
+// Writer appends dead-lettered logs as NDJSON to one file per UTC day
+type Writer struct {
+	dir     string
+	mu      sync.Mutex
+	written *prometheus.CounterVec
+}
+
+// Open returns a writer for dir, creating the directory if needed
+func Open(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	w := &Writer{
+		dir: dir,
+		written: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dead_letter_logs_total",
+				Help: "Total number of logs written to the dead-letter directory",
+			},
+			[]string{"source"},
+		),
+	}
+	prometheus.MustRegister(w.written)
+
+	return w, nil
+}
+
+// Write appends a log together with the error that kept it out of the
+// database. The file is synced before Write returns, so the caller may
+// release its own copy of the log once Write succeeds.
+func (w *Writer) Write(source string, log *models.AnalyticsLog, cause error) error {
+	now := time.Now().UTC()
+	line, err := json.Marshal(Record{FailedAt: now, Source: source, Error: cause.Error(), Log: *log})
+	if err != nil {
+		return fmt.Errorf("failed to encode dead-letter record: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	path := filepath.Join(w.dir, "deadletter-"+now.Format("20060102")+".ndjson")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write dead-letter record: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync dead-letter file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close dead-letter file: %w", err)
+	}
+
+	w.written.WithLabelValues(source).Inc()
+	return nil
+}
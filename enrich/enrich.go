@@ -0,0 +1,90 @@
+// Package enrich adds server-side context to ingested logs: the platform
+// parsed from the request's User-Agent and the location of its client IP,
+// looked up offline in a MaxMind-format database.
+package enrich
+
+import (
+	"log-ingestion-server/models"
+	"net"
+)
+
+// device_info keys written by the enricher. Keys the client already set are
+// never overwritten.
+const (
+	KeyOS             = "os"
+	KeyOSVersion      = "os_version"
+	KeyRuntime        = "runtime"
+	KeyRuntimeVersion = "runtime_version"
+	KeyDeviceType     = "device_type"
+	KeyCountry        = "country"
+	KeyRegion         = "region"
+	KeyCity           = "city"
+	KeyIP             = "ip"
+)
+
+// Enricher resolves request metadata into device_info fields
+type Enricher struct {
+	geo    *GeoIP
+	dropIP bool
+}
+
+// New creates an enricher. geo is optional; with dropIP the client IP is only
+// used for the location lookup and never stored.
+func New(geo *GeoIP, dropIP bool) *Enricher {
+	return &Enricher{geo: geo, dropIP: dropIP}
+}
+
+// Client is the enrichment resolved once for a request and applied to each of
+// its logs
+type Client struct {
+	fields map[string]string
+}
+
+// Resolve parses the User-Agent and looks up the client IP of a request
+func (e *Enricher) Resolve(clientIP, userAgent string) *Client {
+	fields := make(map[string]string)
+
+	ua := ParseUserAgent(userAgent)
+	setIfPresent(fields, KeyOS, ua.OS)
+	setIfPresent(fields, KeyOSVersion, ua.OSVersion)
+	setIfPresent(fields, KeyRuntime, ua.Runtime)
+	setIfPresent(fields, KeyRuntimeVersion, ua.RuntimeVersion)
+	setIfPresent(fields, KeyDeviceType, ua.DeviceType)
+
+	if ip := net.ParseIP(clientIP); ip != nil {
+		if e.geo != nil {
+			if location, ok := e.geo.Lookup(ip); ok {
+				setIfPresent(fields, KeyCountry, location.Country)
+				setIfPresent(fields, KeyRegion, location.Region)
+				setIfPresent(fields, KeyCity, location.City)
+			}
+		}
+		if !e.dropIP {
+			fields[KeyIP] = ip.String()
+		}
+	}
+
+	return &Client{fields: fields}
+}
+
+// Apply adds the resolved fields to a log's device_info, keeping any value
+// the client sent itself
+func (c *Client) Apply(log *models.AnalyticsLog) {
+	if c == nil || len(c.fields) == 0 {
+		return
+	}
+	if log.DeviceInfo == nil {
+		log.DeviceInfo = make(models.JSONB)
+	}
+	for key, value := range c.fields {
+		if _, exists := log.DeviceInfo[key]; !exists {
+			log.DeviceInfo[key] = value
+		}
+	}
+}
+
+func setIfPresent(fields map[string]string, key, value string) {
+	if value != "" {
+		fields[key] = value
+	}
+}
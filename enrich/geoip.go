@@ -0,0 +1,100 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is the geographic position of an IP address
+type Location struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// GeoIP looks up IP addresses in a MaxMind-format City or Country database
+// read from local disk. The file can be reloaded while lookups are running.
+type GeoIP struct {
+	path string
+
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+}
+
+// geoRecord holds the fields of a City or Country record that make up a
+// Location; Country databases leave the city and subdivisions empty
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+}
+
+// OpenGeoIP loads the .mmdb file at path
+func OpenGeoIP(path string) (*GeoIP, error) {
+	g := &GeoIP{path: path}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload reads the database file again, keeping the current data if the new
+// file is unreadable. The file is read into memory rather than mapped, so it
+// can be replaced in place while lookups run.
+func (g *GeoIP) Reload() error {
+	buf, err := os.ReadFile(g.path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", g.path, err)
+	}
+
+	g.mu.Lock()
+	g.reader = reader
+	g.mu.Unlock()
+
+	return nil
+}
+
+// DatabaseType returns the database_type from the file's metadata
+func (g *GeoIP) DatabaseType() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.reader.Metadata.DatabaseType
+}
+
+// Lookup returns the location of ip, or false if the database has no record
+// for it
+func (g *GeoIP) Lookup(ip net.IP) (Location, bool) {
+	g.mu.RLock()
+	reader := g.reader
+	g.mu.RUnlock()
+
+	var record geoRecord
+	if err := reader.Lookup(ip, &record); err != nil {
+		return Location{}, false
+	}
+
+	location := Location{
+		Country: record.Country.ISOCode,
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+	}
+
+	return location, location != Location{}
+}
@@ -0,0 +1,353 @@
+package enrich
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Data section field types, from the MaxMind DB format specification
+const (
+	typePointer = 1
+	typeString  = 2
+	typeDouble  = 3
+	typeBytes   = 4
+	typeUint16  = 5
+	typeUint32  = 6
+	typeMap     = 7
+	typeUint64  = 9
+	typeArray   = 11
+	typeBool    = 14
+)
+
+// mmdbPointer is encoded as a pointer to a data section offset
+type mmdbPointer uint
+
+// encodeMMDBValue encodes a value in the MaxMind DB data format
+func encodeMMDBValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(mmdbControl(typeString, len(v)), v...)
+	case []byte:
+		return append(mmdbControl(typeBytes, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(mmdbControl(typeDouble, 8), math.Float64bits(v))
+	case uint16:
+		return mmdbUint(typeUint16, uint64(v))
+	case uint32:
+		return mmdbUint(typeUint32, uint64(v))
+	case uint64:
+		return mmdbUint(typeUint64, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		return mmdbControl(typeBool, size)
+	case []interface{}:
+		data := mmdbControl(typeArray, len(v))
+		for _, item := range v {
+			data = append(data, encodeMMDBValue(item)...)
+		}
+		return data
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		data := mmdbControl(typeMap, len(v))
+		for _, key := range keys {
+			data = append(data, encodeMMDBValue(key)...)
+			data = append(data, encodeMMDBValue(v[key])...)
+		}
+		return data
+	case mmdbPointer:
+		// Pointers below 2048 fit the control byte and one more
+		return []byte{typePointer<<5 | byte(v>>8&0x7), byte(v)}
+	}
+	panic("unsupported fixture value")
+}
+
+func mmdbUint(typ int, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append(mmdbControl(typ, len(b)), b...)
+}
+
+// mmdbControl encodes the control byte(s) of a field
+func mmdbControl(typ, size int) []byte {
+	var ctrl []byte
+	if typ > typeMap {
+		ctrl = []byte{0, byte(typ - 7)}
+	} else {
+		ctrl = []byte{byte(typ << 5)}
+	}
+
+	switch {
+	case size < 29:
+		ctrl[0] |= byte(size)
+	case size < 285:
+		ctrl[0] |= 29
+		ctrl = append(ctrl, byte(size-29))
+	default:
+		ctrl[0] |= 30
+		ctrl = binary.BigEndian.AppendUint16(ctrl, uint16(size-285))
+	}
+	return ctrl
+}
+
+// fixtureNetwork maps a network to the data section offset of its record
+type fixtureNetwork struct {
+	cidr   string
+	offset int
+}
+
+// buildMMDB writes a MaxMind DB file holding networks, whose records are
+// encoded in data
+func buildMMDB(t *testing.T, ipVersion, recordSize int, networks []fixtureNetwork, data []byte) []byte {
+	t.Helper()
+
+	// Records of the search tree: a node index, empty, or data
+	const empty = -1
+	type node [2]int
+	nodes := []node{{empty, empty}}
+	dataRecords := make(map[[2]int]int)
+
+	for _, network := range networks {
+		ip, ipNet, err := net.ParseCIDR(network.cidr)
+		if err != nil {
+			t.Fatalf("invalid fixture network: %v", err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		address := ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			if ipVersion == 4 {
+				address = ip4
+			} else {
+				// IPv4 networks live under ::/96
+				address = append(make(net.IP, 12), ip4...)
+				ones += 96
+			}
+		}
+
+		current := 0
+		for i := 0; i < ones; i++ {
+			bit := int(address[i/8]>>(7-i%8)) & 1
+			if i == ones-1 {
+				dataRecords[[2]int{current, bit}] = network.offset
+				break
+			}
+			if nodes[current][bit] == empty {
+				nodes = append(nodes, node{empty, empty})
+				nodes[current][bit] = len(nodes) - 1
+			}
+			current = nodes[current][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var file []byte
+	for n, children := range nodes {
+		var records [2]uint32
+		for bit, child := range children {
+			switch offset, ok := dataRecords[[2]int{n, bit}]; {
+			case ok:
+				records[bit] = uint32(nodeCount + 16 + offset)
+			case child == empty:
+				records[bit] = uint32(nodeCount)
+			default:
+				records[bit] = uint32(child)
+			}
+		}
+
+		left, right := records[0], records[1]
+		switch recordSize {
+		case 24:
+			file = append(file, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			file = append(file, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>24&0x0F)<<4|byte(right>>24&0x0F), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			file = binary.BigEndian.AppendUint32(file, left)
+			file = binary.BigEndian.AppendUint32(file, right)
+		}
+	}
+
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+	file = append(file, metadataMarker...)
+	file = append(file, encodeMMDBValue(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1760601600),
+		"database_type":               "Test-City",
+		"description":                 map[string]interface{}{"en": "Test fixture"},
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})...)
+	return file
+}
+
+// fixtureRecords returns a data section with a City record, a Country record
+// that shares the city's country through a pointer, and their offsets
+func fixtureRecords() (data []byte, city, country int) {
+	us := encodeMMDBValue(map[string]interface{}{"iso_code": "US", "geoname_id": uint32(6252001)})
+	data = append(data, us...)
+
+	city = len(data)
+	data = append(data, encodeMMDBValue(map[string]interface{}{
+		"city":    map[string]interface{}{"names": map[string]interface{}{"en": "San Francisco"}},
+		"country": mmdbPointer(0),
+		"location": map[string]interface{}{
+			"latitude":  37.7758,
+			"longitude": -122.4128,
+		},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"iso_code": "CA", "names": map[string]interface{}{"en": "California"}},
+		},
+		"traits": map[string]interface{}{"is_anycast": false},
+	})...)
+
+	country = len(data)
+	data = append(data, encodeMMDBValue(map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "DE"},
+	})...)
+	return data, city, country
+}
+
+func writeGeoIP(t *testing.T, file []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "GeoIP.mmdb")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	data, city, country := fixtureRecords()
+	sanFrancisco := Location{Country: "US", Region: "California", City: "San Francisco"}
+	germany := Location{Country: "DE"}
+
+	lookups := []struct {
+		ip           string
+		wantLocation Location
+		wantOK       bool
+		// ipv6Only marks addresses only an IPv6 database can hold
+		ipv6Only bool
+	}{
+		{ip: "203.0.113.7", wantLocation: sanFrancisco, wantOK: true},
+		{ip: "203.0.113.255", wantLocation: sanFrancisco, wantOK: true},
+		{ip: "198.51.100.1", wantLocation: germany, wantOK: true},
+		{ip: "::ffff:198.51.100.1", wantLocation: germany, wantOK: true},
+		{ip: "203.0.114.1"},
+		{ip: "10.0.0.1"},
+		{ip: "2001:db8::1", wantLocation: germany, wantOK: true, ipv6Only: true},
+		{ip: "2001:db9::1", ipv6Only: true},
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		networks := []fixtureNetwork{
+			{cidr: "203.0.113.0/24", offset: city},
+			{cidr: "198.51.100.0/25", offset: country},
+		}
+		if ipVersion == 6 {
+			networks = append(networks, fixtureNetwork{cidr: "2001:db8::/32", offset: country})
+		}
+
+		for _, recordSize := range []int{24, 28, 32} {
+			file := buildMMDB(t, ipVersion, recordSize, networks, data)
+			geo, err := OpenGeoIP(writeGeoIP(t, file))
+			if err != nil {
+				t.Fatalf("IPv%d, %d-bit records: OpenGeoIP: %v", ipVersion, recordSize, err)
+			}
+			if geo.DatabaseType() != "Test-City" {
+				t.Errorf("database type = %q, want Test-City", geo.DatabaseType())
+			}
+
+			for _, lookup := range lookups {
+				ip := net.ParseIP(lookup.ip)
+				wantOK := lookup.wantOK && (ipVersion == 6 || !lookup.ipv6Only)
+
+				location, ok := geo.Lookup(ip)
+				if ok != wantOK || (ok && location != lookup.wantLocation) {
+					t.Errorf("IPv%d, %d-bit records: Lookup(%s) = %+v, %t, want %+v, %t",
+						ipVersion, recordSize, lookup.ip, location, ok, lookup.wantLocation, wantOK)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenGeoIPRejectsInvalidFiles(t *testing.T) {
+	data, city, _ := fixtureRecords()
+	valid := buildMMDB(t, 4, 24, []fixtureNetwork{{cidr: "203.0.113.0/24", offset: city}}, data)
+
+	// Metadata alone, describing a record size the format does not define
+	badRecordSize := append(append([]byte{}, metadataMarker...), encodeMMDBValue(map[string]interface{}{
+		"node_count": uint32(1), "record_size": uint16(20), "ip_version": uint16(4),
+	})...)
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{name: "empty", file: nil},
+		{name: "no metadata marker", file: []byte("not a database")},
+		{name: "truncated metadata", file: valid[:len(valid)-100]},
+		{name: "metadata not a map", file: append(append([]byte{}, metadataMarker...), encodeMMDBValue("metadata")...)},
+		{name: "unsupported record size", file: badRecordSize},
+		{name: "search tree larger than the file", file: valid[len(valid)-200:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenGeoIP(writeGeoIP(t, tt.file)); err == nil {
+				t.Error("OpenGeoIP accepted the file")
+			}
+		})
+	}
+}
+
+func TestLookupCorruptData(t *testing.T) {
+	// The record of the only network points past the end of the data section
+	file := buildMMDB(t, 4, 24, []fixtureNetwork{{cidr: "203.0.113.0/24", offset: 500}}, encodeMMDBValue("x"))
+	geo, err := OpenGeoIP(writeGeoIP(t, file))
+	if err != nil {
+		t.Fatalf("OpenGeoIP: %v", err)
+	}
+	if location, ok := geo.Lookup(net.ParseIP("203.0.113.1")); ok {
+		t.Errorf("Lookup of a record outside the data section = %+v, want no location", location)
+	}
+}
+
+func TestGeoIPReloadKeepsDataOnError(t *testing.T) {
+	data, city, _ := fixtureRecords()
+	path := writeGeoIP(t, buildMMDB(t, 4, 24, []fixtureNetwork{{cidr: "203.0.113.0/24", offset: city}}, data))
+	geo, err := OpenGeoIP(path)
+	if err != nil {
+		t.Fatalf("OpenGeoIP: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := geo.Reload(); err == nil {
+		t.Error("Reload accepted an invalid file")
+	}
+	if location, ok := geo.Lookup(net.ParseIP("203.0.113.1")); !ok || location.City != "San Francisco" {
+		t.Errorf("Lookup after a failed reload = %+v, %t, want the old data", location, ok)
+	}
+}
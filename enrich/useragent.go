@@ -0,0 +1,116 @@
+package enrich
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UserAgent is the normalized platform described by a User-Agent header
+type UserAgent struct {
+	OS             string
+	OSVersion      string
+	Runtime        string
+	RuntimeVersion string
+	DeviceType     string
+}
+
+// Device types
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// osPatterns are tried in order; the first capture group is the version
+var osPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"iPadOS", regexp.MustCompile(`iPad.*? OS (\d+(?:[_.]\d+)*)`)},
+	{"iOS", regexp.MustCompile(`(?:iPhone|iPod).*? OS (\d+(?:[_.]\d+)*)`)},
+	{"Android", regexp.MustCompile(`Android[ /]?(\d+(?:\.\d+)*)?`)},
+	{"ChromeOS", regexp.MustCompile(`CrOS \S+ (\d+(?:\.\d+)*)`)},
+	{"Windows", regexp.MustCompile(`Windows NT (\d+\.\d+)`)},
+	{"macOS", regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)},
+	{"iOS", regexp.MustCompile(`CFNetwork/\S+ Darwin/`)},
+	{"Linux", regexp.MustCompile(`Linux`)},
+}
+
+// windowsVersions maps NT kernel versions to marketing names
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+}
+
+// runtimePatterns are tried in order, so browsers built on Chrome or Safari
+// are listed before them; the first capture group is the version
+var runtimePatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Dart", regexp.MustCompile(`Dart/(\d+(?:\.\d+)*)`)},
+	{"OkHttp", regexp.MustCompile(`okhttp/(\d+(?:\.\d+)*)`)},
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+(?:\.\d+)*)`)},
+	{"Opera", regexp.MustCompile(`OPR/(\d+(?:\.\d+)*)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+(?:\.\d+)*)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+(?:\.\d+)*)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+(?:\.\d+)*)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+(?:\.\d+)*).*Safari/`)},
+	{"CFNetwork", regexp.MustCompile(`CFNetwork/(\d+(?:\.\d+)*)`)},
+	{"curl", regexp.MustCompile(`curl/(\d+(?:\.\d+)*)`)},
+	{"Go", regexp.MustCompile(`Go-http-client/(\d+(?:\.\d+)*)`)},
+	{"Python", regexp.MustCompile(`python-requests/(\d+(?:\.\d+)*)`)},
+}
+
+var botPattern = regexp.MustCompile(`(?i)bot|crawler|spider|slurp`)
+
+// ParseUserAgent extracts the operating system, the browser or HTTP client
+// runtime, and the device type from a User-Agent header. Unrecognized parts
+// are left empty.
+func ParseUserAgent(header string) UserAgent {
+	var ua UserAgent
+	if header == "" {
+		return ua
+	}
+
+	for _, p := range osPatterns {
+		match := p.pattern.FindStringSubmatch(header)
+		if match == nil {
+			continue
+		}
+		ua.OS = p.name
+		if len(match) > 1 {
+			ua.OSVersion = strings.ReplaceAll(match[1], "_", ".")
+		}
+		if p.name == "Windows" {
+			if name, ok := windowsVersions[ua.OSVersion]; ok {
+				ua.OSVersion = name
+			}
+		}
+		break
+	}
+
+	for _, p := range runtimePatterns {
+		if match := p.pattern.FindStringSubmatch(header); match != nil {
+			ua.Runtime = p.name
+			ua.RuntimeVersion = match[1]
+			break
+		}
+	}
+
+	switch {
+	case botPattern.MatchString(header):
+		ua.DeviceType = DeviceBot
+	case ua.OS == "iPadOS" || ua.OS == "Android" && !strings.Contains(header, "Mobile"):
+		ua.DeviceType = DeviceTablet
+	case ua.OS == "iOS" || ua.OS == "Android":
+		ua.DeviceType = DeviceMobile
+	case ua.OS == "Windows" || ua.OS == "macOS" || ua.OS == "Linux" || ua.OS == "ChromeOS":
+		ua.DeviceType = DeviceDesktop
+	}
+
+	return ua
+}
@@ -0,0 +1,130 @@
+package enrich
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   UserAgent
+	}{
+		{
+			name:   "empty",
+			header: "",
+			want:   UserAgent{},
+		},
+		{
+			name:   "Safari on iPhone",
+			header: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			want:   UserAgent{OS: "iOS", OSVersion: "17.4.1", Runtime: "Safari", RuntimeVersion: "17.4.1", DeviceType: DeviceMobile},
+		},
+		{
+			name:   "Chrome on iPad",
+			header: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			want:   UserAgent{OS: "iPadOS", OSVersion: "16.6", Runtime: "Chrome", RuntimeVersion: "120.0.6099.119", DeviceType: DeviceTablet},
+		},
+		{
+			name:   "Firefox on iPhone",
+			header: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			want:   UserAgent{OS: "iOS", OSVersion: "17.2", Runtime: "Firefox", RuntimeVersion: "121.0", DeviceType: DeviceMobile},
+		},
+		{
+			name:   "iOS app over CFNetwork",
+			header: "HabitApp/3.2.0 CFNetwork/1494.0.7 Darwin/23.4.0",
+			want:   UserAgent{OS: "iOS", Runtime: "CFNetwork", RuntimeVersion: "1494.0.7", DeviceType: DeviceMobile},
+		},
+		{
+			name:   "Chrome on Android phone",
+			header: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+			want:   UserAgent{OS: "Android", OSVersion: "14", Runtime: "Chrome", RuntimeVersion: "124.0.6367.82", DeviceType: DeviceMobile},
+		},
+		{
+			name:   "Chrome on Android tablet",
+			header: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
+			want:   UserAgent{OS: "Android", OSVersion: "13", Runtime: "Chrome", RuntimeVersion: "123.0.0.0", DeviceType: DeviceTablet},
+		},
+		{
+			name:   "Samsung Internet before Chrome",
+			header: "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			want:   UserAgent{OS: "Android", OSVersion: "14", Runtime: "Samsung Internet", RuntimeVersion: "24.0", DeviceType: DeviceMobile},
+		},
+		{
+			name:   "Android app over OkHttp",
+			header: "okhttp/4.12.0",
+			want:   UserAgent{Runtime: "OkHttp", RuntimeVersion: "4.12.0"},
+		},
+		{
+			name:   "Flutter app",
+			header: "Dart/3.3 (dart:io)",
+			want:   UserAgent{Runtime: "Dart", RuntimeVersion: "3.3"},
+		},
+		{
+			name:   "Edge on Windows 11",
+			header: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+			want:   UserAgent{OS: "Windows", OSVersion: "10", Runtime: "Edge", RuntimeVersion: "124.0.2478.67", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Firefox on Windows 7",
+			header: "Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:115.0) Gecko/20100101 Firefox/115.0",
+			want:   UserAgent{OS: "Windows", OSVersion: "7", Runtime: "Firefox", RuntimeVersion: "115.0", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "unknown Windows version kept",
+			header: "Mozilla/5.0 (Windows NT 5.1) Firefox/52.0",
+			want:   UserAgent{OS: "Windows", OSVersion: "5.1", Runtime: "Firefox", RuntimeVersion: "52.0", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Opera on macOS",
+			header: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36 OPR/108.0.0.0",
+			want:   UserAgent{OS: "macOS", OSVersion: "10.15.7", Runtime: "Opera", RuntimeVersion: "108.0.0.0", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Safari on macOS",
+			header: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			want:   UserAgent{OS: "macOS", OSVersion: "14.4.1", Runtime: "Safari", RuntimeVersion: "17.4.1", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Chrome on ChromeOS",
+			header: "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want:   UserAgent{OS: "ChromeOS", OSVersion: "14541.0.0", Runtime: "Chrome", RuntimeVersion: "124.0.0.0", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Firefox on Linux",
+			header: "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want:   UserAgent{OS: "Linux", Runtime: "Firefox", RuntimeVersion: "125.0", DeviceType: DeviceDesktop},
+		},
+		{
+			name:   "Googlebot",
+			header: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.91 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:   UserAgent{OS: "Android", OSVersion: "6.0.1", Runtime: "Chrome", RuntimeVersion: "124.0.6367.91", DeviceType: DeviceBot},
+		},
+		{
+			name:   "curl",
+			header: "curl/8.7.1",
+			want:   UserAgent{Runtime: "curl", RuntimeVersion: "8.7.1"},
+		},
+		{
+			name:   "Go client",
+			header: "Go-http-client/2.0",
+			want:   UserAgent{Runtime: "Go", RuntimeVersion: "2.0"},
+		},
+		{
+			name:   "Python requests",
+			header: "python-requests/2.31.0",
+			want:   UserAgent{Runtime: "Python", RuntimeVersion: "2.31.0"},
+		},
+		{
+			name:   "unrecognized",
+			header: "SomethingElse/1.0",
+			want:   UserAgent{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUserAgent(tt.header); got != tt.want {
+				t.Errorf("ParseUserAgent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log-ingestion-server/middleware"
+	"log-ingestion-server/models"
+	"log-ingestion-server/wireformat"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// bindIngestBody decodes an ingest request body into a log or batch, choosing
+// JSON, Protocol Buffers or MessagePack from the Content-Type header. On
+// failure it writes the 400 or 413 response and returns false.
+func (h *IngestHandler) bindIngestBody(c *gin.Context, v interface{}) bool {
+	format := wireformat.FromContentType(c.GetHeader("Content-Type"))
+
+	var err error
+	if format == wireformat.JSON {
+		err = c.ShouldBindJSON(v)
+	} else {
+		var body []byte
+		body, err = io.ReadAll(c.Request.Body)
+		if err == nil {
+			err = decodeBinaryBody(format, body, v)
+		}
+	}
+
+	if err == nil {
+		return true
+	}
+	if respondBodyTooLarge(c, err) {
+		return false
+	}
+
+	logrus.Errorf("Failed to decode %s request body: %v", format, err)
+
+	response := models.ErrorResponse{
+		Error:   "invalid_json",
+		Message: "Invalid JSON format",
+	}
+	switch format {
+	case wireformat.Protobuf:
+		response = models.ErrorResponse{
+			Error:   "invalid_protobuf",
+			Message: "Invalid Protocol Buffers payload",
+		}
+	case wireformat.MsgPack:
+		response = models.ErrorResponse{
+			Error:   "invalid_msgpack",
+			Message: "Invalid MessagePack payload",
+		}
+	}
+	c.JSON(http.StatusBadRequest, response)
+	return false
+}
+
+// bindJSON decodes a JSON request body into v. On failure it writes the
+// response, 413 when the body is past its size limit and 400 otherwise, and
+// returns false.
+func bindJSON(c *gin.Context, v interface{}) bool {
+	err := c.ShouldBindJSON(v)
+	if err == nil {
+		return true
+	}
+	if respondBodyTooLarge(c, err) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error:   "invalid_json",
+		Message: "Invalid JSON format",
+	})
+	return false
+}
+
+// respondBodyTooLarge writes a 413 response when err is the request body
+// passing its size limit, and reports whether it did
+func respondBodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *middleware.BodyTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+
+	c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+		Error:   "request_too_large",
+		Message: fmt.Sprintf("Request body too large. Maximum size is %d MB", tooLarge.LimitMB),
+	})
+	return true
+}
+
+// decodeBinaryBody decodes a Protocol Buffers or MessagePack body
+func decodeBinaryBody(format wireformat.Format, body []byte, v interface{}) error {
+	if format == wireformat.MsgPack {
+		return wireformat.UnmarshalMsgPack(body, v)
+	}
+
+	switch target := v.(type) {
+	case *models.AnalyticsLog:
+		return wireformat.UnmarshalLog(body, target)
+	case *models.BatchRequest:
+		return wireformat.UnmarshalBatch(body, target)
+	default:
+		return fmt.Errorf("no protobuf message for %T", v)
+	}
+}
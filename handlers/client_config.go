@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/clientconfig"
+	"log-ingestion-server/database"
+	"log-ingestion-server/enrich"
+	"log-ingestion-server/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// ClientConfigHandler serves the remote configuration of client SDKs and
+// manages its overrides
+type ClientConfigHandler struct {
+	db        *database.DB
+	registry  *clientconfig.Registry
+	validator *validator.Validate
+}
+
+// NewClientConfigHandler creates a new client config handler
+func NewClientConfigHandler(db *database.DB, registry *clientconfig.Registry) *ClientConfigHandler {
+	return &ClientConfigHandler{
+		db:        db,
+		registry:  registry,
+		validator: validator.New(),
+	}
+}
+
+// GetClientConfig returns the configuration for the calling client. Clients
+// identify themselves with the app_version and platform query parameters; the
+// platform defaults to the OS parsed from the User-Agent. The response carries
+// an ETag so clients can revalidate with If-None-Match.
+func (h *ClientConfigHandler) GetClientConfig(c *gin.Context) {
+	platform := c.Query("platform")
+	if platform == "" {
+		platform = enrich.ParseUserAgent(c.Request.UserAgent()).OS
+	}
+
+	doc := h.registry.Resolve(c.Query("app_version"), platform)
+	etag := `"` + doc.Version + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", doc.RefreshIntervalSeconds))
+	c.Header("Vary", "User-Agent")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Client config retrieved successfully",
+		Data:    doc,
+	})
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListOverrides returns every override in application order, including
+// inactive overrides
+func (h *ClientConfigHandler) ListOverrides(c *gin.Context) {
+	overrides, err := h.db.ListClientConfigOverrides()
+	if err != nil {
+		logrus.Errorf("Failed to list client config overrides: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list client config overrides",
+		})
+		return
+	}
+	if overrides == nil {
+		overrides = []models.ClientConfigOverride{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Client config overrides retrieved successfully",
+		Data:    overrides,
+	})
+}
+
+// CreateOverride adds an override. It is served on this instance from the
+// next request and on the others within a minute.
+func (h *ClientConfigHandler) CreateOverride(c *gin.Context) {
+	override, ok := h.bindOverride(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.InsertClientConfigOverride(override); err != nil {
+		h.respondSaveError(c, override, err)
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Created client config override %s", override.Name)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Client config override created successfully",
+		Data:    override,
+	})
+}
+
+// UpdateOverride replaces an existing override
+func (h *ClientConfigHandler) UpdateOverride(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	override, ok := h.bindOverride(c)
+	if !ok {
+		return
+	}
+	override.ID = id
+
+	found, err := h.db.UpdateClientConfigOverride(override)
+	if err != nil {
+		h.respondSaveError(c, override, err)
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "override_not_found",
+			Message: fmt.Sprintf("Client config override %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Updated client config override %s", override.Name)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Client config override updated successfully",
+		Data:    override,
+	})
+}
+
+// DeleteOverride removes an override
+func (h *ClientConfigHandler) DeleteOverride(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.db.DeleteClientConfigOverride(id)
+	if err != nil {
+		logrus.Errorf("Failed to delete client config override: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete client config override",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "override_not_found",
+			Message: fmt.Sprintf("Client config override %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Client config override %d deleted", id),
+	})
+}
+
+// bindOverride decodes and validates an override from the request body,
+// responding with an error and returning false if it is invalid
+func (h *ClientConfigHandler) bindOverride(c *gin.Context) (*models.ClientConfigOverride, bool) {
+	var request models.ClientConfigOverrideRequest
+	if !bindJSON(c, &request) {
+		return nil, false
+	}
+
+	override := &models.ClientConfigOverride{
+		Name:                 request.Name,
+		Position:             request.Position,
+		AppVersion:           request.AppVersion,
+		Platform:             request.Platform,
+		SamplingRates:        request.SamplingRates,
+		DisabledEvents:       request.DisabledEvents,
+		MaxBatchSize:         request.MaxBatchSize,
+		FlushIntervalSeconds: request.FlushIntervalSeconds,
+		IsActive:             true,
+	}
+	if override.SamplingRates == nil {
+		override.SamplingRates = map[string]float64{}
+	}
+	if override.DisabledEvents == nil {
+		override.DisabledEvents = []string{}
+	}
+	if request.IsActive != nil {
+		override.IsActive = *request.IsActive
+	}
+
+	var validationErrors []models.ValidationError
+	if err := h.validator.Struct(&request); err != nil {
+		if fieldErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, fieldError := range fieldErrors {
+				validationErrors = append(validationErrors, models.ValidationError{
+					Field:   fieldError.Field(),
+					Message: getValidationMessage(fieldError),
+				})
+			}
+		}
+	} else if err := clientconfig.Validate(*override); err != nil {
+		validationErrors = append(validationErrors, models.ValidationError{
+			Field:   "app_version",
+			Message: err.Error(),
+		})
+	}
+
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid client config override",
+			Details: validationErrors,
+		})
+		return nil, false
+	}
+
+	return override, true
+}
+
+// respondSaveError maps a failed insert or update to a response
+func (h *ClientConfigHandler) respondSaveError(c *gin.Context, override *models.ClientConfigOverride, err error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "override_exists",
+			Message: fmt.Sprintf("Client config override %s already exists", override.Name),
+		})
+		return
+	}
+	logrus.Errorf("Failed to save client config override: %v", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to save client config override",
+	})
+}
+
+// reload refreshes the registry so changes apply to the next request
+func (h *ClientConfigHandler) reload() {
+	if err := h.registry.Load(); err != nil {
+		logrus.Errorf("Failed to reload client config overrides: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sentAtHeader carries the client's clock reading when it sent the request
+const sentAtHeader = "X-Sent-At"
+
+// clockOffset returns how far the client's clock is behind the server's
+// (negative when it is ahead), or nil when the request has no sent-at time.
+// The header may be an RFC 3339 timestamp or Unix milliseconds.
+func (h *IngestHandler) clockOffset(c *gin.Context, bodySentAt *time.Time) (*time.Duration, error) {
+	receivedAt := time.Now()
+
+	sentAt := bodySentAt
+	if sentAt == nil {
+		header := c.GetHeader(sentAtHeader)
+		if header == "" {
+			return nil, nil
+		}
+		parsed, err := parseSentAt(header)
+		if err != nil {
+			return nil, err
+		}
+		sentAt = &parsed
+	}
+
+	offset := receivedAt.Sub(*sentAt)
+	if offset >= 0 {
+		h.metrics.ClockSkew.WithLabelValues("behind").Observe(offset.Seconds())
+	} else {
+		h.metrics.ClockSkew.WithLabelValues("ahead").Observe(-offset.Seconds())
+	}
+
+	return &offset, nil
+}
+
+func parseSentAt(header string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, header); err == nil {
+		return t, nil
+	}
+	if ms, err := strconv.ParseInt(header, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or Unix milliseconds", sentAtHeader)
+}
+
+// applyClockSkew fills in the server-computed timestamp fields of a log.
+// Timestamps taken from the device are shifted by the request's clock offset;
+// logs without a device timestamp were stamped by the server and need no
+// correction. The skew is flagged once it exceeds the configured threshold.
+func (h *IngestHandler) applyClockSkew(info *requestInfo, log *models.AnalyticsLog, deviceTimestamp bool) {
+	corrected := log.Timestamp
+	log.ClockSkewMs = nil
+	log.ClockSkewFlagged = false
+
+	if offset := info.clockOffset; offset != nil {
+		skew := offset.Milliseconds()
+		log.ClockSkewMs = &skew
+		log.ClockSkewFlagged = *offset > h.clockSkewThreshold || *offset < -h.clockSkewThreshold
+		if deviceTimestamp {
+			corrected = log.Timestamp.Add(*offset)
+		}
+	}
+
+	log.CorrectedTimestamp = &corrected
+}
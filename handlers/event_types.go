@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"log-ingestion-server/taxonomy"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// eventTypeNamePattern keeps event type names usable as metric labels and
+// query parameters
+var eventTypeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// EventTypeHandler manages the event type taxonomy
+type EventTypeHandler struct {
+	db         *database.DB
+	eventTypes *taxonomy.Registry
+	validator  *validator.Validate
+}
+
+// NewEventTypeHandler creates a new event type handler
+func NewEventTypeHandler(db *database.DB, eventTypes *taxonomy.Registry) *EventTypeHandler {
+	return &EventTypeHandler{
+		db:         db,
+		eventTypes: eventTypes,
+		validator:  validator.New(),
+	}
+}
+
+// ListEventTypes returns the taxonomy, including retired types
+func (h *EventTypeHandler) ListEventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Event types retrieved successfully",
+		Data:    h.eventTypes.All(),
+	})
+}
+
+// UpsertEventType creates an event type or updates an existing one. Omitted
+// settings keep their current value, or the default for a new type.
+func (h *EventTypeHandler) UpsertEventType(c *gin.Context) {
+	name := c.Param("name")
+	if !eventTypeNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_event_type",
+			Message: "Event type names must be lowercase letters, digits and underscores, starting with a letter (max 50)",
+		})
+		return
+	}
+
+	var request models.UpsertEventTypeRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		var validationErrors []models.ValidationError
+		if fieldErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, fieldError := range fieldErrors {
+				validationErrors = append(validationErrors, models.ValidationError{
+					Field:   fieldError.Field(),
+					Message: getValidationMessage(fieldError),
+				})
+			}
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid event type",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	eventType, exists := h.eventTypes.Get(name)
+	if !exists {
+		eventType = models.EventType{
+			Name:            name,
+			DefaultPriority: "normal",
+			RetentionClass:  models.RetentionStandard,
+			IsActive:        true,
+		}
+	}
+	if request.Description != "" {
+		eventType.Description = request.Description
+	}
+	if request.DefaultPriority != "" {
+		eventType.DefaultPriority = request.DefaultPriority
+	}
+	if request.RetentionClass != "" {
+		eventType.RetentionClass = request.RetentionClass
+	}
+	if request.IsActive != nil {
+		eventType.IsActive = *request.IsActive
+	}
+
+	if err := h.db.UpsertEventType(&eventType); err != nil {
+		logrus.Errorf("Failed to save event type: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to save event type",
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Saved event type %s (priority %s, retention %s, active %t)",
+		eventType.Name, eventType.DefaultPriority, eventType.RetentionClass, eventType.IsActive)
+
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	c.JSON(status, models.SuccessResponse{
+		Success: true,
+		Message: "Event type saved successfully",
+		Data:    eventType,
+	})
+}
+
+// DeactivateEventType stops accepting an event type. Stored logs are kept and
+// can still be queried by it.
+func (h *EventTypeHandler) DeactivateEventType(c *gin.Context) {
+	name := c.Param("name")
+
+	if h.eventTypes.Accepts(name) && len(h.eventTypes.ActiveNames()) == 1 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "last_event_type",
+			Message: "At least one event type must remain active",
+		})
+		return
+	}
+
+	found, err := h.db.DeactivateEventType(name)
+	if err != nil {
+		logrus.Errorf("Failed to deactivate event type: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to deactivate event type",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "event_type_not_found",
+			Message: fmt.Sprintf("Event type %s does not exist", name),
+		})
+		return
+	}
+
+	h.reload()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Event type %s deactivated", name),
+	})
+}
+
+// reload refreshes the registry so changes apply to the next request
+func (h *EventTypeHandler) reload() {
+	if err := h.eventTypes.Load(); err != nil {
+		logrus.Errorf("Failed to reload event types: %v", err)
+	}
+}
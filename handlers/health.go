@@ -12,13 +12,13 @@ import (
 
 // HealthHandler handles health check and monitoring endpoints
 type HealthHandler struct {
-	db        *database.DB
+	db        database.Store
 	startTime time.Time
 	version   string
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(db *database.DB, version string) *HealthHandler {
+func NewHealthHandler(db database.Store, version string) *HealthHandler {
 	return &HealthHandler{
 		db:        db,
 		startTime: time.Now(),
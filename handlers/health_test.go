@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"net/http"
+	"testing"
+)
+
+// failingStore is a memory store whose health check or queries fail
+type failingStore struct {
+	*database.MemoryStore
+	healthErr error
+	queryErr  error
+}
+
+func (s *failingStore) HealthCheck() error {
+	return s.healthErr
+}
+
+func (s *failingStore) GetLogCount() (int64, error) {
+	if s.queryErr != nil {
+		return 0, s.queryErr
+	}
+	return s.MemoryStore.GetLogCount()
+}
+
+func TestHealthCheck(t *testing.T) {
+	down := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		store        *failingStore
+		wantCode     int
+		wantStatus   string
+		wantDatabase string
+	}{
+		{
+			name:         "healthy",
+			store:        &failingStore{},
+			wantCode:     http.StatusOK,
+			wantStatus:   "healthy",
+			wantDatabase: "healthy",
+		},
+		{
+			name:         "database down",
+			store:        &failingStore{healthErr: down},
+			wantCode:     http.StatusServiceUnavailable,
+			wantStatus:   "unhealthy",
+			wantDatabase: "unhealthy",
+		},
+		{
+			name:         "queries failing",
+			store:        &failingStore{queryErr: down},
+			wantCode:     http.StatusPartialContent,
+			wantStatus:   "degraded",
+			wantDatabase: "healthy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.store.MemoryStore = database.NewMemoryStore()
+			h := NewHealthHandler(tt.store, "test")
+
+			w := serve(h.HealthCheck, http.MethodGet, "")
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var status models.HealthStatus
+			if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if status.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status.Status, tt.wantStatus)
+			}
+			if got := status.Services["database"]; got != tt.wantDatabase {
+				t.Errorf("services.database = %q, want %q", got, tt.wantDatabase)
+			}
+		})
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		healthErr error
+		wantCode  int
+	}{
+		{name: "ready", wantCode: http.StatusOK},
+		{name: "database down", healthErr: errors.New("connection refused"), wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(&failingStore{MemoryStore: database.NewMemoryStore(), healthErr: tt.healthErr}, "test")
+
+			if w := serve(h.ReadinessCheck, http.MethodGet, ""); w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	store := database.NewMemoryStore()
+	for _, eventID := range []string{"e1", "e2"} {
+		if err := store.InsertLog(&models.AnalyticsLog{EventID: eventID, EventType: "behavioral", EventName: "x"}); err != nil {
+			t.Fatalf("failed to store log: %v", err)
+		}
+	}
+	h := NewHealthHandler(store, "test")
+
+	w := serve(h.GetStatus, http.MethodGet, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Database struct {
+				TotalLogs int64 `json:"total_logs"`
+			} `json:"database"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Data.Database.TotalLogs != 2 {
+		t.Errorf("total_logs = %d, want 2", response.Data.Database.TotalLogs)
+	}
+}
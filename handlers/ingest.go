@@ -1,9 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"log-ingestion-server/config"
 	"log-ingestion-server/database"
+	"log-ingestion-server/enrich"
 	"log-ingestion-server/models"
+	"log-ingestion-server/pipeline"
+	"log-ingestion-server/redact"
+	"log-ingestion-server/sampling"
+	"log-ingestion-server/schema"
+	"log-ingestion-server/taxonomy"
+	"log-ingestion-server/transform"
+	"log-ingestion-server/wal"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,9 +27,33 @@ import (
 
 // IngestHandler handles log ingestion requests
 type IngestHandler struct {
-	db        *database.DB
-	validator *validator.Validate
-	metrics   *Metrics
+	db         database.Store
+	pipeline   *pipeline.Pipeline
+	validator  *validator.Validate
+	schemas    *schema.Registry
+	eventTypes *taxonomy.Registry
+	redactor   *redact.Redactor
+	enricher   *enrich.Enricher
+	sampling   *sampling.Registry
+	transforms *transform.Registry
+
+	clockSkewThreshold time.Duration
+	metrics            *Metrics
+	maxBatchSize       int
+
+	streamTimeout time.Duration
+}
+
+// IngestOptions holds the registries and processors that prepare each log
+// before it is queued. EventTypes is required; the others are skipped when
+// nil.
+type IngestOptions struct {
+	EventTypes *taxonomy.Registry
+	Schemas    *schema.Registry
+	Redactor   *redact.Redactor
+	Enricher   *enrich.Enricher
+	Sampling   *sampling.Registry
+	Transforms *transform.Registry
 }
 
 // Metrics holds Prometheus metrics
@@ -30,14 +64,19 @@ type Metrics struct {
 	BatchSize         *prometheus.HistogramVec
 	ValidationErrors  *prometheus.CounterVec
 	DatabaseErrors    *prometheus.CounterVec
+	Redactions        *prometheus.CounterVec
+	ClockSkew         *prometheus.HistogramVec
+	SamplingDecisions *prometheus.CounterVec
+	TransformsApplied *prometheus.CounterVec
+	TransformErrors   *prometheus.CounterVec
 }
 
 // NewIngestHandler creates a new ingest handler
-func NewIngestHandler(db *database.DB) *IngestHandler {
+func NewIngestHandler(db database.Store, p *pipeline.Pipeline, cfg *config.Config, opts IngestOptions) *IngestHandler {
 	validator := validator.New()
-	
+
 	// Register custom validation for event types
-	validator.RegisterValidation("event_type", validateEventType)
+	validator.RegisterValidation("event_type", validateEventType(opts.EventTypes))
 
 	metrics := &Metrics{
 		RequestsTotal: prometheus.NewCounterVec(
@@ -63,8 +102,8 @@ func NewIngestHandler(db *database.DB) *IngestHandler {
 		),
 		BatchSize: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
-				Name: "batch_size",
-				Help: "Size of log batches",
+				Name:    "batch_size",
+				Help:    "Size of log batches",
 				Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
 			},
 			[]string{"endpoint"},
@@ -74,7 +113,7 @@ func NewIngestHandler(db *database.DB) *IngestHandler {
 				Name: "validation_errors_total",
 				Help: "Total number of validation errors",
 			},
-			[]string{"field", "error_type"},
+			[]string{"field", "error_type", "event_name"},
 		),
 		DatabaseErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -83,6 +122,42 @@ func NewIngestHandler(db *database.DB) *IngestHandler {
 			},
 			[]string{"operation"},
 		),
+		Redactions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redactions_total",
+				Help: "Total number of fields redacted from ingested logs",
+			},
+			[]string{"rule", "action"},
+		),
+		ClockSkew: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_clock_skew_seconds",
+				Help:    "Absolute difference between client sent-at times and server receive times",
+				Buckets: []float64{0.1, 1, 5, 30, 60, 300, 900, 3600, 21600, 86400, 604800},
+			},
+			[]string{"direction"},
+		),
+		SamplingDecisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sampling_decisions_total",
+				Help: "Total number of logs matched by a sampling rule, by decision",
+			},
+			[]string{"rule", "decision"},
+		),
+		TransformsApplied: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "transforms_applied_total",
+				Help: "Total number of logs rewritten by a transform rule",
+			},
+			[]string{"rule"},
+		),
+		TransformErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "transform_errors_total",
+				Help: "Total number of transform operations skipped because they failed",
+			},
+			[]string{"rule"},
+		),
 	}
 
 	// Register metrics
@@ -93,19 +168,36 @@ func NewIngestHandler(db *database.DB) *IngestHandler {
 		metrics.BatchSize,
 		metrics.ValidationErrors,
 		metrics.DatabaseErrors,
+		metrics.Redactions,
+		metrics.ClockSkew,
+		metrics.SamplingDecisions,
+		metrics.TransformsApplied,
+		metrics.TransformErrors,
 	)
 
 	return &IngestHandler{
-		db:        db,
-		validator: validator,
-		metrics:   metrics,
+		db:         db,
+		pipeline:   p,
+		validator:  validator,
+		schemas:    opts.Schemas,
+		eventTypes: opts.EventTypes,
+		redactor:   opts.Redactor,
+		enricher:   opts.Enricher,
+		sampling:   opts.Sampling,
+		transforms: opts.Transforms,
+
+		clockSkewThreshold: cfg.ClockSkewThreshold,
+		metrics:            metrics,
+		maxBatchSize:       cfg.MaxBatchSize,
+
+		streamTimeout: cfg.StreamTimeout,
 	}
 }
 
 // IngestSingle handles single log ingestion
 func (h *IngestHandler) IngestSingle(c *gin.Context) {
 	start := time.Now()
-	
+
 	defer func() {
 		duration := time.Since(start).Seconds()
 		h.metrics.RequestDuration.WithLabelValues("POST", "/ingest").Observe(duration)
@@ -113,25 +205,18 @@ func (h *IngestHandler) IngestSingle(c *gin.Context) {
 	}()
 
 	var log models.AnalyticsLog
-	if err := c.ShouldBindJSON(&log); err != nil {
-		logrus.Errorf("Failed to bind JSON: %v", err)
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_json",
-			Message: "Invalid JSON format",
-		})
+	if !h.bindIngestBody(c, &log) {
 		return
 	}
 
-	// Set default values
-	h.setDefaultValues(&log)
+	info, ok := h.newRequestInfo(c, nil)
+	if !ok {
+		return
+	}
 
-	// Validate the log
-	if err := h.validator.Struct(&log); err != nil {
-		validationErrors := h.formatValidationErrors(err)
-		for _, ve := range validationErrors {
-			h.metrics.ValidationErrors.WithLabelValues(ve.Field, "validation").Inc()
-		}
-		
+	// Apply defaults, validate, sample, enrich and redact the log
+	keep, validationErrors := h.prepareLog(info, &log)
+	if len(validationErrors) > 0 {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "validation_error",
 			Message: "Invalid log data",
@@ -140,38 +225,37 @@ func (h *IngestHandler) IngestSingle(c *gin.Context) {
 		return
 	}
 
-	// Insert into database
-	if err := h.db.InsertLog(&log); err != nil {
-		logrus.Errorf("Failed to insert log: %v", err)
-		h.metrics.DatabaseErrors.WithLabelValues("insert_single").Inc()
-		
-		if strings.Contains(err.Error(), "duplicate key") {
-			c.JSON(http.StatusConflict, models.ErrorResponse{
-				Error:   "duplicate_event",
-				Message: "Event with this ID already exists",
-			})
-			return
-		}
-		
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to store log",
+	// Logs discarded by the sampling rules are acknowledged so clients do not
+	// retry them
+	if !keep {
+		c.JSON(http.StatusAccepted, models.SuccessResponse{
+			Success: true,
+			Message: "Log accepted and discarded by sampling rules",
+			Data: map[string]interface{}{
+				"event_id": log.EventID,
+				"dropped":  true,
+			},
 		})
 		return
 	}
 
+	// Hand off to the ingest pipeline
+	if err := h.pipeline.Enqueue(log); err != nil {
+		h.respondEnqueueError(c, err)
+		return
+	}
+
 	// Update metrics
 	h.metrics.LogsIngested.WithLabelValues(log.EventType, log.Priority).Inc()
 	h.metrics.BatchSize.WithLabelValues("single").Observe(1)
 
-	logrus.Debugf("Ingested single log: %s", log.EventID)
+	logrus.Debugf("Accepted single log: %s", log.EventID)
 
-	c.JSON(http.StatusCreated, models.SuccessResponse{
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
 		Success: true,
-		Message: "Log ingested successfully",
+		Message: "Log accepted for ingestion",
 		Data: map[string]interface{}{
 			"event_id": log.EventID,
-			"id":       log.ID,
 		},
 	})
 }
@@ -179,7 +263,7 @@ func (h *IngestHandler) IngestSingle(c *gin.Context) {
 // IngestBatch handles batch log ingestion
 func (h *IngestHandler) IngestBatch(c *gin.Context) {
 	start := time.Now()
-	
+
 	defer func() {
 		duration := time.Since(start).Seconds()
 		h.metrics.RequestDuration.WithLabelValues("POST", "/batch-ingest").Observe(duration)
@@ -187,12 +271,7 @@ func (h *IngestHandler) IngestBatch(c *gin.Context) {
 	}()
 
 	var batchRequest models.BatchRequest
-	if err := c.ShouldBindJSON(&batchRequest); err != nil {
-		logrus.Errorf("Failed to bind JSON: %v", err)
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_json",
-			Message: "Invalid JSON format",
-		})
+	if !h.bindIngestBody(c, &batchRequest) {
 		return
 	}
 
@@ -205,29 +284,34 @@ func (h *IngestHandler) IngestBatch(c *gin.Context) {
 	}
 
 	// Validate batch size
-	maxBatchSize := 1000 // This should come from config
-	if len(batchRequest.Logs) > maxBatchSize {
+	if len(batchRequest.Logs) > h.maxBatchSize {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "batch_too_large",
-			Message: fmt.Sprintf("Batch size cannot exceed %d logs", maxBatchSize),
+			Message: fmt.Sprintf("Batch size cannot exceed %d logs", h.maxBatchSize),
 		})
 		return
 	}
 
-	// Set default values and validate each log
+	// Apply defaults, validate, sample, enrich and redact each log
 	var validationErrors []models.ValidationError
 	validLogs := make([]models.AnalyticsLog, 0, len(batchRequest.Logs))
+	dropped := 0
+	info, ok := h.newRequestInfo(c, batchRequest.SentAt)
+	if !ok {
+		return
+	}
 
 	for i, log := range batchRequest.Logs {
-		h.setDefaultValues(&log)
-		
-		if err := h.validator.Struct(&log); err != nil {
-			for _, ve := range h.formatValidationErrors(err) {
+		keep, logErrors := h.prepareLog(info, &log)
+		switch {
+		case len(logErrors) > 0:
+			for _, ve := range logErrors {
 				ve.Field = fmt.Sprintf("logs[%d].%s", i, ve.Field)
 				validationErrors = append(validationErrors, ve)
-				h.metrics.ValidationErrors.WithLabelValues(ve.Field, "validation").Inc()
 			}
-		} else {
+		case !keep:
+			dropped++
+		default:
 			validLogs = append(validLogs, log)
 		}
 	}
@@ -242,16 +326,12 @@ func (h *IngestHandler) IngestBatch(c *gin.Context) {
 		return
 	}
 
-	// Insert batch into database
-	if err := h.db.InsertLogsBatch(validLogs); err != nil {
-		logrus.Errorf("Failed to insert batch: %v", err)
-		h.metrics.DatabaseErrors.WithLabelValues("insert_batch").Inc()
-		
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to store logs",
-		})
-		return
+	// Hand off to the ingest pipeline
+	if len(validLogs) > 0 {
+		if err := h.pipeline.Enqueue(validLogs...); err != nil {
+			h.respondEnqueueError(c, err)
+			return
+		}
 	}
 
 	// Update metrics
@@ -260,50 +340,245 @@ func (h *IngestHandler) IngestBatch(c *gin.Context) {
 	}
 	h.metrics.BatchSize.WithLabelValues("batch").Observe(float64(len(validLogs)))
 
-	logrus.Infof("Ingested batch of %d logs", len(validLogs))
+	logrus.Infof("Accepted batch of %d logs", len(validLogs))
 
-	c.JSON(http.StatusCreated, models.SuccessResponse{
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
 		Success: true,
-		Message: fmt.Sprintf("Batch of %d logs ingested successfully", len(validLogs)),
+		Message: fmt.Sprintf("Batch of %d logs accepted for ingestion", len(validLogs)),
 		Data: map[string]interface{}{
-			"logs_processed": len(validLogs),
+			"logs_accepted":  len(validLogs),
+			"logs_dropped":   dropped,
 			"total_received": len(batchRequest.Logs),
 		},
 	})
 }
 
+// respondEnqueueError maps pipeline backpressure errors to HTTP responses
+func (h *IngestHandler) respondEnqueueError(c *gin.Context, err error) {
+	status, retryAfter, response := enqueueErrorResponse(err)
+	if retryAfter != "" {
+		c.Header("Retry-After", retryAfter)
+	}
+	c.JSON(status, response)
+}
+
+// enqueueErrorResponse maps a pipeline error to a status code, Retry-After
+// value and error body
+func enqueueErrorResponse(err error) (int, string, models.ErrorResponse) {
+	switch {
+	case errors.Is(err, pipeline.ErrQueueFull):
+		return http.StatusTooManyRequests, "1", models.ErrorResponse{
+			Error:   "queue_full",
+			Message: "Ingest queue is full. Please retry later.",
+		}
+	case errors.Is(err, wal.ErrFull):
+		return http.StatusServiceUnavailable, "30", models.ErrorResponse{
+			Error:   "storage_full",
+			Message: "Server backlog is full. Please retry later.",
+		}
+	case errors.Is(err, pipeline.ErrClosed):
+		return http.StatusServiceUnavailable, "", models.ErrorResponse{
+			Error:   "shutting_down",
+			Message: "Server is shutting down. Please retry later.",
+		}
+	default:
+		logrus.Errorf("Failed to enqueue logs: %v", err)
+		return http.StatusInternalServerError, "", models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to accept logs",
+		}
+	}
+}
+
 // setDefaultValues sets default values for log fields
 func (h *IngestHandler) setDefaultValues(log *models.AnalyticsLog) {
 	if log.Timestamp.IsZero() {
 		log.Timestamp = time.Now()
 	}
-	
+
 	if log.Priority == "" {
-		log.Priority = "normal"
+		log.Priority = h.eventTypes.DefaultPriority(log.EventType)
 	}
-	
+
 	if log.Properties == nil {
 		log.Properties = make(models.JSONB)
 	}
-	
+
 	if log.DeviceInfo == nil {
 		log.DeviceInfo = make(models.JSONB)
 	}
 }
 
+// requestInfo is resolved once per request and applied to each of its logs
+type requestInfo struct {
+	client      *enrich.Client
+	clockOffset *time.Duration
+}
+
+// newRequestInfo resolves the enrichment for a request's client and its
+// clock offset, from bodySentAt or else the X-Sent-At header. It responds
+// with an error and returns false if the sent-at time is malformed.
+func (h *IngestHandler) newRequestInfo(c *gin.Context, bodySentAt *time.Time) (*requestInfo, bool) {
+	info := &requestInfo{}
+	if h.enricher != nil {
+		info.client = h.enricher.Resolve(c.ClientIP(), c.Request.UserAgent())
+	}
+
+	offset, err := h.clockOffset(c, bodySentAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_sent_at",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	info.clockOffset = offset
+
+	return info, true
+}
+
+// prepareLog applies defaults and the transform rules to a log, validates it,
+// applies the sampling rules, corrects its timestamp for clock skew, adds the
+// request's enrichment and redacts sensitive values. It returns the
+// validation errors if the log must be rejected, and false if the sampling
+// rules discard it. Transforms run first so older payloads are validated in
+// their current shape; redaction runs last so schemas and rules see the
+// payload before it and enriched fields are redacted too.
+func (h *IngestHandler) prepareLog(info *requestInfo, log *models.AnalyticsLog) (bool, []models.ValidationError) {
+	deviceTimestamp := !log.Timestamp.IsZero()
+	h.setDefaultValues(log)
+	h.transformLog(log)
+
+	if validationErrors := h.validateLog(log); len(validationErrors) > 0 {
+		return false, validationErrors
+	}
+
+	if !h.sampleLog(log) {
+		return false, nil
+	}
+
+	h.applyClockSkew(info, log, deviceTimestamp)
+	info.client.Apply(log)
+
+	if h.redactor != nil {
+		for _, r := range h.redactor.Apply(log) {
+			h.metrics.Redactions.WithLabelValues(r.Rule, r.Action).Inc()
+		}
+	}
+
+	return true, nil
+}
+
+// transformLog rewrites a log with the active transform rules
+func (h *IngestHandler) transformLog(log *models.AnalyticsLog) {
+	if h.transforms == nil {
+		return
+	}
+
+	for _, applied := range h.transforms.Apply(log) {
+		h.metrics.TransformsApplied.WithLabelValues(applied.Rule).Inc()
+		if len(applied.Errors) > 0 {
+			h.metrics.TransformErrors.WithLabelValues(applied.Rule).Add(float64(len(applied.Errors)))
+			logrus.Debugf("Transform rule %s skipped operations on %s: %v", applied.Rule, log.EventID, applied.Errors)
+		}
+	}
+}
+
+// sampleLog records the rate a log is kept at and reports whether it should
+// be stored. A sample_rate sent by the client, from the sampling rates of its
+// remote config, is combined with the server's.
+func (h *IngestHandler) sampleLog(log *models.AnalyticsLog) bool {
+	clientRate := log.SampleRate
+	if !(clientRate > 0 && clientRate <= 1) {
+		// Also catches NaN, which binary formats can carry
+		clientRate = 1
+	}
+	log.SampleRate = clientRate
+	if h.sampling == nil {
+		return true
+	}
+
+	decision := h.sampling.Decide(log)
+	if decision.Rule != "" {
+		h.metrics.SamplingDecisions.WithLabelValues(decision.Rule, decision.Outcome).Inc()
+	}
+	if !decision.Keep {
+		return false
+	}
+
+	log.SampleRate = clientRate * decision.Rate
+	return true
+}
+
+// validateLog runs struct validation and then the properties schema
+// registered for the log's event name, recording failures in metrics. Schema
+// violations fail the log only in reject mode; in warn mode they are listed
+// on the log under _schema_violations, and in log mode they are only logged.
+func (h *IngestHandler) validateLog(log *models.AnalyticsLog) []models.ValidationError {
+	if err := h.validator.Struct(log); err != nil {
+		validationErrors := h.formatValidationErrors(err)
+		for _, ve := range validationErrors {
+			h.recordValidationError(ve.Field, "validation", log.EventName)
+		}
+		return validationErrors
+	}
+
+	if h.schemas == nil {
+		return nil
+	}
+
+	result := h.schemas.Check(log.EventName, log.Properties)
+	if result == nil {
+		return nil
+	}
+
+	for range result.Violations {
+		h.recordValidationError("properties", "schema_"+result.Mode, log.EventName)
+	}
+
+	switch result.Mode {
+	case models.SchemaModeReject:
+		validationErrors := make([]models.ValidationError, len(result.Violations))
+		for i, v := range result.Violations {
+			validationErrors[i] = models.ValidationError{Field: v.Path, Message: v.Message}
+		}
+		return validationErrors
+	case models.SchemaModeWarn:
+		log.Properties[models.SchemaViolationsProperty] = result.Violations
+		logrus.Warnf("Event %s (%s) violates schema v%d: %s", log.EventID, log.EventName, result.Version, result.Summary())
+	default:
+		logrus.Infof("Event %s (%s) violates schema v%d: %s", log.EventID, log.EventName, result.Version, result.Summary())
+	}
+
+	return nil
+}
+
+// recordValidationError counts a validation failure. Only event names with a
+// registered schema are used as labels, keeping the metric's cardinality bounded.
+func (h *IngestHandler) recordValidationError(field, errorType, eventName string) {
+	if h.schemas == nil || !h.schemas.Has(eventName) {
+		eventName = "other"
+	}
+	h.metrics.ValidationErrors.WithLabelValues(field, errorType, eventName).Inc()
+}
+
 // formatValidationErrors formats validation errors into a readable format
 func (h *IngestHandler) formatValidationErrors(err error) []models.ValidationError {
 	var validationErrors []models.ValidationError
-	
+
 	if validatorErr, ok := err.(validator.ValidationErrors); ok {
 		for _, fieldError := range validatorErr {
+			message := getValidationMessage(fieldError)
+			if fieldError.Tag() == "event_type" {
+				message = fmt.Sprintf("Must be a valid event type (%s)", strings.Join(h.eventTypes.ActiveNames(), ", "))
+			}
 			validationErrors = append(validationErrors, models.ValidationError{
 				Field:   fieldError.Field(),
-				Message: getValidationMessage(fieldError),
+				Message: message,
 			})
 		}
 	}
-	
+
 	return validationErrors
 }
 
@@ -321,24 +596,18 @@ func getValidationMessage(fieldError validator.FieldError) string {
 	case "email":
 		return "Must be a valid email address"
 	case "event_type":
-		return "Must be a valid event type (behavioral, telemetry, observability, error, performance)"
+		return "Must be a valid event type"
 	default:
 		return fmt.Sprintf("Validation failed for tag '%s'", fieldError.Tag())
 	}
 }
 
-// validateEventType is a custom validation function for event types
-func validateEventType(fl validator.FieldLevel) bool {
-	eventType := fl.Field().String()
-	validTypes := []string{"behavioral", "telemetry", "observability", "error", "performance"}
-	
-	for _, validType := range validTypes {
-		if eventType == validType {
-			return true
-		}
+// validateEventType returns a custom validation function accepting the active
+// event types of the taxonomy
+func validateEventType(eventTypes *taxonomy.Registry) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return eventTypes.Accepts(fl.Field().String())
 	}
-	
-	return false
 }
 
 // GetMetrics returns current metrics (for admin/monitoring purposes)
@@ -389,7 +658,7 @@ func (h *IngestHandler) GetRecentLogs(c *gin.Context) {
 // GetFilteredLogs returns filtered logs based on query parameters
 func (h *IngestHandler) GetFilteredLogs(c *gin.Context) {
 	start := time.Now()
-	
+
 	defer func() {
 		duration := time.Since(start).Seconds()
 		h.metrics.RequestDuration.WithLabelValues("GET", "/logs/filter").Observe(duration)
@@ -454,18 +723,10 @@ func (h *IngestHandler) GetFilteredLogs(c *gin.Context) {
 
 	// Validate event type if provided
 	if filter.EventType != "" {
-		validTypes := []string{"behavioral", "telemetry", "observability", "error", "performance"}
-		valid := false
-		for _, validType := range validTypes {
-			if filter.EventType == validType {
-				valid = true
-				break
-			}
-		}
-		if !valid {
+		if !h.eventTypes.Known(filter.EventType) {
 			c.JSON(http.StatusBadRequest, models.ErrorResponse{
 				Error:   "invalid_event_type",
-				Message: "event_type must be one of: behavioral, telemetry, observability, error, performance",
+				Message: fmt.Sprintf("event_type must be one of: %s", strings.Join(h.eventTypes.ActiveNames(), ", ")),
 			})
 			return
 		}
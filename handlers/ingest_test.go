@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"log-ingestion-server/middleware"
+	"log-ingestion-server/models"
+	"log-ingestion-server/pipeline"
+	"log-ingestion-server/taxonomy"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useTestRegistry gives each test its own Prometheus registry, since the
+// handlers and pipeline register their metrics when they are created
+func useTestRegistry(t *testing.T) {
+	t.Helper()
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+}
+
+// newTestIngestHandler creates an ingest handler storing logs in a fresh
+// memory store with the built-in event types. drain flushes the pipeline so
+// the store can be inspected.
+func newTestIngestHandler(t *testing.T) (h *IngestHandler, store *database.MemoryStore, drain func()) {
+	t.Helper()
+	useTestRegistry(t)
+
+	cfg := &config.Config{
+		MaxBatchSize:    4,
+		WorkerPoolSize:  1,
+		IngestQueueSize: 100,
+		BatchTimeout:    10 * time.Millisecond,
+	}
+
+	eventTypes := taxonomy.NewRegistry(database.Builtins{})
+	if err := eventTypes.Load(); err != nil {
+		t.Fatalf("failed to load event types: %v", err)
+	}
+
+	store = database.NewMemoryStore()
+	p := pipeline.NewPipeline(store, nil, nil, cfg)
+	p.Start()
+
+	drained := false
+	drain = func() {
+		if drained {
+			return
+		}
+		drained = true
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.Shutdown(ctx); err != nil {
+			t.Fatalf("failed to drain pipeline: %v", err)
+		}
+	}
+	t.Cleanup(drain)
+
+	h = NewIngestHandler(store, p, cfg, IngestOptions{EventTypes: eventTypes})
+	return h, store, drain
+}
+
+// serve runs handler on a JSON request and returns the recorded response
+func serve(handler gin.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w
+}
+
+// testLog returns a valid log as JSON
+func testLog(eventID string) string {
+	return fmt.Sprintf(`{"event_id":%q,"event_type":"behavioral","event_name":"habit_completed","properties":{"habit":"read"}}`, eventID)
+}
+
+func batchBody(logs ...string) string {
+	return `{"logs":[` + strings.Join(logs, ",") + `]}`
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) models.ErrorResponse {
+	t.Helper()
+	var response models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return response
+}
+
+func TestIngestSingle(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantError string
+		wantCount int64
+	}{
+		{name: "valid log", body: testLog("e1"), wantCode: http.StatusAccepted, wantCount: 1},
+		{name: "malformed JSON", body: `{"event_id":`, wantCode: http.StatusBadRequest, wantError: "invalid_json"},
+		{name: "missing event_id", body: `{"event_type":"behavioral","event_name":"x"}`, wantCode: http.StatusBadRequest, wantError: "validation_error"},
+		{name: "unknown event type", body: `{"event_id":"e1","event_type":"nope","event_name":"x"}`, wantCode: http.StatusBadRequest, wantError: "validation_error"},
+		{name: "event_id longer than its column", body: testLog(strings.Repeat("e", 256)), wantCode: http.StatusBadRequest, wantError: "validation_error"},
+		{name: "invalid priority", body: `{"event_id":"e1","event_type":"behavioral","event_name":"x","priority":"urgent"}`, wantCode: http.StatusBadRequest, wantError: "validation_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store, drain := newTestIngestHandler(t)
+
+			w := serve(h.IngestSingle, http.MethodPost, tt.body)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantError != "" {
+				if got := decodeError(t, w).Error; got != tt.wantError {
+					t.Errorf("error = %q, want %q", got, tt.wantError)
+				}
+			}
+
+			drain()
+			if count, _ := store.GetLogCount(); count != tt.wantCount {
+				t.Errorf("stored %d logs, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestIngestBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    []string
+		body      string
+		wantCode  int
+		wantError string
+		wantCount int64
+	}{
+		{
+			name:      "accepted",
+			body:      batchBody(testLog("e1"), testLog("e2"), testLog("e3")),
+			wantCode:  http.StatusAccepted,
+			wantCount: 3,
+		},
+		{
+			name:      "duplicate within the batch is stored once",
+			body:      batchBody(testLog("e1"), testLog("e1"), testLog("e2")),
+			wantCode:  http.StatusAccepted,
+			wantCount: 2,
+		},
+		{
+			name:      "duplicate of a stored log is skipped",
+			stored:    []string{"e1"},
+			body:      batchBody(testLog("e1"), testLog("e2")),
+			wantCode:  http.StatusAccepted,
+			wantCount: 2,
+		},
+		{
+			name:      "empty",
+			body:      `{"logs":[]}`,
+			wantCode:  http.StatusBadRequest,
+			wantError: "empty_batch",
+		},
+		{
+			name:      "too large",
+			body:      batchBody(testLog("e1"), testLog("e2"), testLog("e3"), testLog("e4"), testLog("e5")),
+			wantCode:  http.StatusBadRequest,
+			wantError: "batch_too_large",
+		},
+		{
+			name:      "one invalid log rejects the batch",
+			body:      batchBody(testLog("e1"), `{"event_id":"e2","event_type":"nope","event_name":"x"}`),
+			wantCode:  http.StatusBadRequest,
+			wantError: "validation_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store, drain := newTestIngestHandler(t)
+			for _, eventID := range tt.stored {
+				if err := store.InsertLog(&models.AnalyticsLog{EventID: eventID, EventType: "behavioral", EventName: "x"}); err != nil {
+					t.Fatalf("failed to store %s: %v", eventID, err)
+				}
+			}
+
+			w := serve(h.IngestBatch, http.MethodPost, tt.body)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantError != "" {
+				if got := decodeError(t, w).Error; got != tt.wantError {
+					t.Errorf("error = %q, want %q", got, tt.wantError)
+				}
+			}
+
+			drain()
+			if count, _ := store.GetLogCount(); count != tt.wantCount {
+				t.Errorf("stored %d logs, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestIngestBatchPartial(t *testing.T) {
+	h, store, _ := newTestIngestHandler(t)
+	if err := store.InsertLog(&models.AnalyticsLog{EventID: "stored", EventType: "behavioral", EventName: "x"}); err != nil {
+		t.Fatalf("failed to store log: %v", err)
+	}
+
+	body := batchBody(
+		testLog("new"),
+		testLog("stored"),
+		`{"event_id":"bad","event_type":"nope","event_name":"x"}`,
+		fmt.Sprintf(`{"event_id":"long","event_type":"behavioral","event_name":%q}`, strings.Repeat("n", 101)),
+	)
+	w := serve(h.IngestBatchPartial, http.MethodPost, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var response struct {
+		Data models.PartialBatchResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := []string{models.BatchItemAccepted, models.BatchItemDuplicate, models.BatchItemInvalid, models.BatchItemInvalid}
+	for i, status := range want {
+		if got := response.Data.Results[i].Status; got != status {
+			t.Errorf("results[%d].status = %q, want %q", i, got, status)
+		}
+	}
+	if count, _ := store.GetLogCount(); count != 2 {
+		t.Errorf("stored %d logs, want 2", count)
+	}
+}
+
+func TestIngestBatchPartialMalformedBody(t *testing.T) {
+	h, _, _ := newTestIngestHandler(t)
+
+	w := serve(h.IngestBatchPartial, http.MethodPost, `{"logs":`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("invalid_json")) {
+		t.Errorf("body = %s, want invalid_json", w.Body.String())
+	}
+}
+
+// TestIngestBodyTooLarge checks that bodies passing the size limit once
+// decompressed are answered with 413, not as invalid JSON
+func TestIngestBodyTooLarge(t *testing.T) {
+	h, store, drain := newTestIngestHandler(t)
+
+	router := gin.New()
+	router.Use(middleware.DecompressionMiddleware(1, nil))
+	router.POST("/ingest", h.IngestSingle)
+	router.POST("/batch-ingest", h.IngestBatch)
+	router.POST("/v2/batch-ingest", h.IngestBatchPartial)
+
+	large := fmt.Sprintf(`{"event_id":"e1","event_type":"behavioral","event_name":"x","properties":{"pad":%q}}`, strings.Repeat("a", 2*1024*1024))
+	tests := []struct {
+		path string
+		body string
+	}{
+		{path: "/ingest", body: large},
+		{path: "/batch-ingest", body: batchBody(large)},
+		{path: "/v2/batch-ingest", body: batchBody(large)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var compressed bytes.Buffer
+			gz := gzip.NewWriter(&compressed)
+			gz.Write([]byte(tt.body))
+			gz.Close()
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, &compressed)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+			}
+			if got := decodeError(t, w).Error; got != "request_too_large" {
+				t.Errorf("error = %q, want request_too_large", got)
+			}
+		})
+	}
+
+	drain()
+	if count, _ := store.GetLogCount(); count != 0 {
+		t.Errorf("stored %d logs, want none", count)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log-ingestion-server/middleware"
+	"log-ingestion-server/models"
+	"log-ingestion-server/otlp"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// google.rpc.Code values used in OTLP error responses
+const (
+	rpcInvalidArgument   = 3
+	rpcResourceExhausted = 8
+	rpcInternal          = 13
+	rpcUnavailable       = 14
+)
+
+// otlpEncoding is the wire format of an OTLP/HTTP request and its response
+type otlpEncoding struct {
+	contentType string
+	unmarshal   func([]byte) (*otlp.Request, error)
+	response    func(rejected int64, message string) []byte
+	status      func(code int32, message string) []byte
+}
+
+var (
+	otlpProtobuf = otlpEncoding{
+		contentType: "application/x-protobuf",
+		unmarshal:   otlp.UnmarshalProtobuf,
+		response:    otlp.MarshalProtobufResponse,
+		status:      otlp.MarshalProtobufStatus,
+	}
+	otlpJSON = otlpEncoding{
+		contentType: "application/json",
+		unmarshal:   otlp.UnmarshalJSON,
+		response:    otlp.MarshalJSONResponse,
+		status:      otlp.MarshalJSONStatus,
+	}
+)
+
+// IngestOTLPLogs handles OTLP/HTTP log exports (POST /v1/logs) in protobuf or
+// JSON encoding. Records are mapped onto analytics logs with event type
+// observability and enqueued like any other batch; records failing validation
+// are dropped and reported through partial success.
+func (h *IngestHandler) IngestOTLPLogs(c *gin.Context) {
+	start := time.Now()
+
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RequestDuration.WithLabelValues("POST", "/v1/logs").Observe(duration)
+		h.metrics.RequestsTotal.WithLabelValues("POST", "/v1/logs", fmt.Sprintf("%d", c.Writer.Status())).Inc()
+	}()
+
+	var encoding otlpEncoding
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "application/x-protobuf", "application/protobuf":
+		encoding = otlpProtobuf
+	case "application/json":
+		encoding = otlpJSON
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error:   "unsupported_media_type",
+			Message: "Content-Type must be application/x-protobuf or application/json",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *middleware.BodyTooLargeError
+		if errors.As(err, &tooLarge) {
+			respondOTLPStatus(c, encoding, http.StatusRequestEntityTooLarge, rpcInvalidArgument, "Request body too large")
+			return
+		}
+		respondOTLPStatus(c, encoding, http.StatusBadRequest, rpcInvalidArgument, "Could not read request body")
+		return
+	}
+
+	request, err := encoding.unmarshal(body)
+	if err != nil {
+		h.recordValidationError("otlp", "invalid_encoding", "")
+		respondOTLPStatus(c, encoding, http.StatusBadRequest, rpcInvalidArgument, err.Error())
+		return
+	}
+
+	if count := request.RecordCount(); count > h.maxBatchSize {
+		respondOTLPStatus(c, encoding, http.StatusBadRequest, rpcInvalidArgument,
+			fmt.Sprintf("Export cannot exceed %d log records", h.maxBatchSize))
+		return
+	}
+
+	logs := request.Logs()
+	validLogs := make([]models.AnalyticsLog, 0, len(logs))
+	var rejected int64
+	var rejectMessage string
+
+	// OTLP senders are collectors rather than end-user devices, so their
+	// address and User-Agent are not used for enrichment
+	info := &requestInfo{}
+
+	for i := range logs {
+		keep, validationErrors := h.prepareLog(info, &logs[i])
+		if len(validationErrors) > 0 {
+			rejected++
+			if rejectMessage == "" {
+				rejectMessage = fmt.Sprintf("%s: %s", validationErrors[0].Field, validationErrors[0].Message)
+			}
+			continue
+		}
+		// Records discarded by the sampling rules count as accepted
+		if !keep {
+			continue
+		}
+		validLogs = append(validLogs, logs[i])
+	}
+
+	if len(validLogs) > 0 {
+		if err := h.pipeline.Enqueue(validLogs...); err != nil {
+			status, retryAfter, response := enqueueErrorResponse(err)
+			if retryAfter != "" {
+				c.Header("Retry-After", retryAfter)
+			}
+			code := int32(rpcInternal)
+			switch status {
+			case http.StatusTooManyRequests:
+				code = rpcResourceExhausted
+			case http.StatusServiceUnavailable:
+				code = rpcUnavailable
+			}
+			respondOTLPStatus(c, encoding, status, code, response.Message)
+			return
+		}
+	}
+
+	for _, log := range validLogs {
+		h.metrics.LogsIngested.WithLabelValues(log.EventType, log.Priority).Inc()
+	}
+	h.metrics.BatchSize.WithLabelValues("otlp").Observe(float64(len(validLogs)))
+
+	if rejected > 0 {
+		logrus.Warnf("Rejected %d of %d OTLP log records: %s", rejected, len(logs), rejectMessage)
+	}
+
+	c.Data(http.StatusOK, encoding.contentType, encoding.response(rejected, rejectMessage))
+}
+
+// respondOTLPStatus writes an OTLP error response: a google.rpc.Status in the
+// request's encoding
+func respondOTLPStatus(c *gin.Context, encoding otlpEncoding, httpStatus int, code int32, message string) {
+	c.Data(httpStatus, encoding.contentType, encoding.status(code, message))
+}
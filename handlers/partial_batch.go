@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// rawBatchRequest defers decoding of individual logs so that one malformed
+// log does not reject the whole batch
+type rawBatchRequest struct {
+	Logs   []json.RawMessage `json:"logs"`
+	SentAt *time.Time        `json:"sent_at"`
+}
+
+// IngestBatchPartial handles batch ingestion with per-item results. Valid logs
+// are stored synchronously, duplicates are skipped, and invalid logs are
+// reported individually so clients can drop exactly the rows that will never
+// succeed.
+func (h *IngestHandler) IngestBatchPartial(c *gin.Context) {
+	start := time.Now()
+
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RequestDuration.WithLabelValues("POST", "/v2/batch-ingest").Observe(duration)
+		h.metrics.RequestsTotal.WithLabelValues("POST", "/v2/batch-ingest", fmt.Sprintf("%d", c.Writer.Status())).Inc()
+	}()
+
+	var batchRequest rawBatchRequest
+	if err := c.ShouldBindJSON(&batchRequest); err != nil {
+		if respondBodyTooLarge(c, err) {
+			return
+		}
+		logrus.Errorf("Failed to bind JSON: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Invalid JSON format",
+		})
+		return
+	}
+
+	if len(batchRequest.Logs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "empty_batch",
+			Message: "Batch cannot be empty",
+		})
+		return
+	}
+
+	if len(batchRequest.Logs) > h.maxBatchSize {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "batch_too_large",
+			Message: fmt.Sprintf("Batch size cannot exceed %d logs", h.maxBatchSize),
+		})
+		return
+	}
+
+	response := models.PartialBatchResponse{
+		Results: make([]models.BatchItemResult, len(batchRequest.Logs)),
+	}
+
+	// Decode, default and validate each log independently
+	validLogs := make([]models.AnalyticsLog, 0, len(batchRequest.Logs))
+	validIndexes := make([]int, 0, len(batchRequest.Logs))
+	seen := make(map[string]bool, len(batchRequest.Logs))
+	info, ok := h.newRequestInfo(c, batchRequest.SentAt)
+	if !ok {
+		return
+	}
+
+	for i, raw := range batchRequest.Logs {
+		result := &response.Results[i]
+		result.Index = i
+
+		var log models.AnalyticsLog
+		if err := json.Unmarshal(raw, &log); err != nil {
+			result.Status = models.BatchItemInvalid
+			result.Errors = []models.ValidationError{{Field: "log", Message: "Invalid JSON format"}}
+			h.recordValidationError("log", "invalid_json", "")
+			continue
+		}
+		result.EventID = log.EventID
+
+		keep, validationErrors := h.prepareLog(info, &log)
+		if len(validationErrors) > 0 {
+			result.Status = models.BatchItemInvalid
+			result.Errors = validationErrors
+			continue
+		}
+		if !keep {
+			result.Status = models.BatchItemDropped
+			continue
+		}
+
+		// Repeats within the same batch are duplicates of the first occurrence
+		if seen[log.EventID] {
+			result.Status = models.BatchItemDuplicate
+			continue
+		}
+		seen[log.EventID] = true
+
+		validLogs = append(validLogs, log)
+		validIndexes = append(validIndexes, i)
+	}
+
+	inserted, err := h.db.InsertLogsBatchSkipDuplicates(validLogs)
+	if err != nil {
+		logrus.Errorf("Failed to insert partial batch: %v", err)
+		h.metrics.DatabaseErrors.WithLabelValues("insert_batch_partial").Inc()
+
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to store logs, nothing was stored. Please retry the batch.",
+		})
+		return
+	}
+
+	for j, i := range validIndexes {
+		if inserted[j] {
+			response.Results[i].Status = models.BatchItemAccepted
+			h.metrics.LogsIngested.WithLabelValues(validLogs[j].EventType, validLogs[j].Priority).Inc()
+		} else {
+			response.Results[i].Status = models.BatchItemDuplicate
+		}
+	}
+
+	for _, result := range response.Results {
+		switch result.Status {
+		case models.BatchItemAccepted:
+			response.Accepted++
+		case models.BatchItemDuplicate:
+			response.Duplicates++
+		case models.BatchItemInvalid:
+			response.Invalid++
+		case models.BatchItemDropped:
+			response.Dropped++
+		}
+	}
+
+	h.metrics.BatchSize.WithLabelValues("batch_partial").Observe(float64(len(batchRequest.Logs)))
+
+	logrus.Infof("Partial batch processed: %d accepted, %d duplicate, %d invalid, %d dropped",
+		response.Accepted, response.Duplicates, response.Invalid, response.Dropped)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: response.Invalid == 0,
+		Message: fmt.Sprintf("Batch processed: %d accepted, %d duplicate, %d invalid",
+			response.Accepted, response.Duplicates, response.Invalid),
+		Data: response,
+	})
+}
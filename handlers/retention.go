@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"log-ingestion-server/retention"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRetentionRuns is the number of runs listed when no limit is given
+const defaultRetentionRuns = 20
+
+// RetentionHandler manages the retention policies and reports the runs of the
+// retention job
+type RetentionHandler struct {
+	db           *database.DB
+	validator    *validator.Validate
+	archiveAfter time.Duration
+}
+
+// NewRetentionHandler creates a new retention handler. archiveAfter is the
+// archiver's ARCHIVE_AFTER, or zero when logs are not archived; policies must
+// then keep logs longer.
+func NewRetentionHandler(db *database.DB, archiveAfter time.Duration) *RetentionHandler {
+	return &RetentionHandler{
+		db:           db,
+		validator:    validator.New(),
+		archiveAfter: archiveAfter,
+	}
+}
+
+// ListPolicies returns every policy in evaluation order, including inactive
+// policies
+func (h *RetentionHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.db.ListRetentionPolicies()
+	if err != nil {
+		logrus.Errorf("Failed to list retention policies: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list retention policies",
+		})
+		return
+	}
+	if policies == nil {
+		policies = []models.RetentionPolicy{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Retention policies retrieved successfully",
+		Data:    policies,
+	})
+}
+
+// CreatePolicy adds a policy. It is enforced from the next run of the
+// retention job.
+func (h *RetentionHandler) CreatePolicy(c *gin.Context) {
+	policy, ok := h.bindPolicy(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.InsertRetentionPolicy(policy); err != nil {
+		h.respondSaveError(c, policy, err)
+		return
+	}
+
+	logrus.Infof("Created retention policy %s (%d days)", policy.Name, policy.RetentionDays)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Retention policy created successfully",
+		Data:    policy,
+	})
+}
+
+// UpdatePolicy replaces an existing policy
+func (h *RetentionHandler) UpdatePolicy(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	policy, ok := h.bindPolicy(c)
+	if !ok {
+		return
+	}
+	policy.ID = id
+
+	found, err := h.db.UpdateRetentionPolicy(policy)
+	if err != nil {
+		h.respondSaveError(c, policy, err)
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "retention_policy_not_found",
+			Message: fmt.Sprintf("Retention policy %d does not exist", id),
+		})
+		return
+	}
+
+	logrus.Infof("Updated retention policy %s (%d days)", policy.Name, policy.RetentionDays)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Retention policy updated successfully",
+		Data:    policy,
+	})
+}
+
+// DeletePolicy removes a policy
+func (h *RetentionHandler) DeletePolicy(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.db.DeleteRetentionPolicy(id)
+	if err != nil {
+		logrus.Errorf("Failed to delete retention policy: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete retention policy",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "retention_policy_not_found",
+			Message: fmt.Sprintf("Retention policy %d does not exist", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Retention policy %d deleted", id),
+	})
+}
+
+// ListRuns returns the latest runs of the retention job from every instance,
+// newest first, with the rows each policy removed
+func (h *RetentionHandler) ListRuns(c *gin.Context) {
+	limit := defaultRetentionRuns
+	if param := c.Query("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_limit",
+				Message: "limit must be between 1 and 100",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.db.ListRetentionRuns(limit)
+	if err != nil {
+		logrus.Errorf("Failed to list retention runs: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list retention runs",
+		})
+		return
+	}
+	if runs == nil {
+		runs = []models.RetentionRun{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Retention runs retrieved successfully",
+		Data:    runs,
+	})
+}
+
+// bindPolicy decodes and validates a policy from the request body,
+// responding with an error and returning false if it is invalid
+func (h *RetentionHandler) bindPolicy(c *gin.Context) (*models.RetentionPolicy, bool) {
+	var request models.RetentionPolicyRequest
+	if !bindJSON(c, &request) {
+		return nil, false
+	}
+
+	policy := &models.RetentionPolicy{
+		Name:           request.Name,
+		Position:       request.Position,
+		EventType:      request.EventType,
+		EventName:      request.EventName,
+		Priority:       request.Priority,
+		RetentionClass: request.RetentionClass,
+		RetentionDays:  request.RetentionDays,
+		IsActive:       true,
+	}
+	if request.IsActive != nil {
+		policy.IsActive = *request.IsActive
+	}
+
+	var validationErrors []models.ValidationError
+	if err := h.validator.Struct(&request); err != nil {
+		validationErrors = formatRequestErrors(err)
+	} else if err := retention.Validate(*policy); err != nil {
+		validationErrors = append(validationErrors, models.ValidationError{
+			Field:   "event_name",
+			Message: err.Error(),
+		})
+	} else if err := retention.ValidateArchive(*policy, h.archiveAfter); err != nil {
+		validationErrors = append(validationErrors, models.ValidationError{
+			Field:   "retention_days",
+			Message: err.Error(),
+		})
+	}
+
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid retention policy",
+			Details: validationErrors,
+		})
+		return nil, false
+	}
+
+	return policy, true
+}
+
+// respondSaveError maps a failed insert or update to a response
+func (h *RetentionHandler) respondSaveError(c *gin.Context, policy *models.RetentionPolicy, err error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "retention_policy_exists",
+			Message: fmt.Sprintf("Retention policy %s already exists", policy.Name),
+		})
+		return
+	}
+	logrus.Errorf("Failed to save retention policy: %v", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to save retention policy",
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRollupBuckets bounds the number of buckets a series request may span
+const maxRollupBuckets = 1500
+
+// rollupGranularities maps each granularity to its bucket width and the span
+// returned when no range is given
+var rollupGranularities = map[string]struct {
+	width, span time.Duration
+}{
+	models.GranularityMinute: {time.Minute, time.Hour},
+	models.GranularityHour:   {time.Hour, 24 * time.Hour},
+	models.GranularityDay:    {24 * time.Hour, 30 * 24 * time.Hour},
+}
+
+// RollupHandler serves time series of log counts and distinct users and
+// sessions from the log rollups
+type RollupHandler struct {
+	db *database.DB
+}
+
+// NewRollupHandler creates a new rollup handler
+func NewRollupHandler(db *database.DB) *RollupHandler {
+	return &RollupHandler{db: db}
+}
+
+// GetSeries returns the number of logs per bucket, optionally grouped by and
+// filtered on event_type, event_name, app_version or priority
+func (h *RollupHandler) GetSeries(c *gin.Context) {
+	granularity, from, to, ok := rollupRange(c)
+	if !ok {
+		return
+	}
+
+	filter := database.RollupFilter{
+		Granularity: granularity,
+		From:        from,
+		To:          to,
+		GroupBy:     c.Query("group_by"),
+		Where:       map[string]string{},
+	}
+	if filter.GroupBy != "" && !database.RollupGroupColumns[filter.GroupBy] {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_group_by",
+			Message: "group_by must be event_type, event_name, app_version or priority",
+		})
+		return
+	}
+	for column := range database.RollupGroupColumns {
+		if value, ok := c.GetQuery(column); ok {
+			filter.Where[column] = value
+		}
+	}
+
+	asOf, err := h.db.RollupWatermark()
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	points, err := h.db.RollupSeries(filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Log series retrieved successfully",
+		Data: models.RollupSeries{
+			Granularity: granularity,
+			From:        from,
+			To:          to,
+			GroupBy:     filter.GroupBy,
+			AsOf:        asOf,
+			Points:      points,
+		},
+	})
+}
+
+// GetUniques returns the estimated number of distinct users and sessions per
+// bucket and over the whole range, optionally for one event type
+func (h *RollupHandler) GetUniques(c *gin.Context) {
+	granularity, from, to, ok := rollupRange(c)
+	if !ok {
+		return
+	}
+	eventType := c.Query("event_type")
+
+	asOf, err := h.db.RollupWatermark()
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	points, total, err := h.db.RollupUniques(granularity, from, to, eventType)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Distinct users and sessions retrieved successfully",
+		Data: models.UniquesSeries{
+			Granularity: granularity,
+			From:        from,
+			To:          to,
+			EventType:   eventType,
+			AsOf:        asOf,
+			Users:       total.Users,
+			Sessions:    total.Sessions,
+			Points:      points,
+		},
+	})
+}
+
+// rollupRange reads the granularity and time range of a request, responding
+// with an error and returning false if they are invalid. from is rounded down
+// to the start of its bucket.
+func rollupRange(c *gin.Context) (string, time.Time, time.Time, bool) {
+	granularity := c.DefaultQuery("granularity", models.GranularityHour)
+	bucket, ok := rollupGranularities[granularity]
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_granularity",
+			Message: "granularity must be minute, hour or day",
+		})
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	to := time.Now().UTC()
+	if param := c.Query("to"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_to",
+				Message: "to must be in RFC3339 format (e.g., 2023-01-01T00:00:00Z)",
+			})
+			return "", time.Time{}, time.Time{}, false
+		}
+		to = parsed.UTC()
+	}
+
+	from := to.Add(-bucket.span)
+	if param := c.Query("from"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_from",
+				Message: "from must be in RFC3339 format (e.g., 2023-01-01T00:00:00Z)",
+			})
+			return "", time.Time{}, time.Time{}, false
+		}
+		from = parsed.UTC()
+	}
+	from = from.Truncate(bucket.width)
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_range",
+			Message: "from must be before to",
+		})
+		return "", time.Time{}, time.Time{}, false
+	}
+	if to.Sub(from) > maxRollupBuckets*bucket.width {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "range_too_large",
+			Message: fmt.Sprintf("The range spans more than %d %s buckets; use a coarser granularity", maxRollupBuckets, granularity),
+		})
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	return granularity, from, to, true
+}
+
+func (h *RollupHandler) respondError(c *gin.Context, err error) {
+	logrus.Errorf("Failed to read log rollups: %v", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to retrieve log rollups",
+	})
+}
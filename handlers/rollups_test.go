@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log-ingestion-server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRollupRange(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		wantGranularity string
+		wantFrom        string
+		wantTo          string
+		wantError       string
+	}{
+		{
+			name:            "explicit range",
+			query:           "granularity=minute&from=2026-10-16T09:00:00Z&to=2026-10-16T10:00:00Z",
+			wantGranularity: models.GranularityMinute,
+			wantFrom:        "2026-10-16T09:00:00Z",
+			wantTo:          "2026-10-16T10:00:00Z",
+		},
+		{
+			name:            "from rounded down to its bucket",
+			query:           "granularity=hour&from=2026-10-16T09:42:17Z&to=2026-10-16T12:00:00Z",
+			wantGranularity: models.GranularityHour,
+			wantFrom:        "2026-10-16T09:00:00Z",
+			wantTo:          "2026-10-16T12:00:00Z",
+		},
+		{
+			name:            "offsets converted to UTC",
+			query:           "granularity=day&from=2026-10-16T01:00:00%2B02:00&to=2026-10-18T00:00:00%2B02:00",
+			wantGranularity: models.GranularityDay,
+			wantFrom:        "2026-10-15T00:00:00Z",
+			wantTo:          "2026-10-17T22:00:00Z",
+		},
+		{
+			name:            "default span before to",
+			query:           "to=2026-10-16T10:30:00Z",
+			wantGranularity: models.GranularityHour,
+			wantFrom:        "2026-10-15T10:00:00Z",
+			wantTo:          "2026-10-16T10:30:00Z",
+		},
+		{
+			name:            "largest range",
+			query:           "granularity=minute&from=2026-10-15T00:00:00Z&to=2026-10-16T01:00:00Z",
+			wantGranularity: models.GranularityMinute,
+			wantFrom:        "2026-10-15T00:00:00Z",
+			wantTo:          "2026-10-16T01:00:00Z",
+		},
+		{
+			name:      "one bucket too many",
+			query:     "granularity=minute&from=2026-10-15T00:00:00Z&to=2026-10-16T01:01:00Z",
+			wantError: "range_too_large",
+		},
+		{
+			name:      "unknown granularity",
+			query:     "granularity=week",
+			wantError: "invalid_granularity",
+		},
+		{
+			name:      "invalid from",
+			query:     "from=yesterday",
+			wantError: "invalid_from",
+		},
+		{
+			name:      "invalid to",
+			query:     "to=2026-10-16",
+			wantError: "invalid_to",
+		},
+		{
+			name:      "from after to",
+			query:     "from=2026-10-16T12:00:00Z&to=2026-10-16T10:00:00Z",
+			wantError: "invalid_range",
+		},
+		{
+			name:      "empty once rounded",
+			query:     "granularity=hour&from=2026-10-16T10:05:00Z&to=2026-10-16T10:00:00Z",
+			wantError: "invalid_range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			granularity, from, to, ok := rollupRange(c)
+			if tt.wantError != "" {
+				if ok || w.Code != http.StatusBadRequest {
+					t.Fatalf("rollupRange = %t with status %d, want a 400", ok, w.Code)
+				}
+				if got := decodeError(t, w).Error; got != tt.wantError {
+					t.Errorf("error = %q, want %q", got, tt.wantError)
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("rollupRange failed: %s", w.Body)
+			}
+			if granularity != tt.wantGranularity {
+				t.Errorf("granularity = %s, want %s", granularity, tt.wantGranularity)
+			}
+			if got := from.Format(time.RFC3339); got != tt.wantFrom {
+				t.Errorf("from = %s, want %s", got, tt.wantFrom)
+			}
+			if got := to.Format(time.RFC3339); got != tt.wantTo {
+				t.Errorf("to = %s, want %s", got, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"log-ingestion-server/sampling"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// SamplingRuleHandler manages the server-side sampling and drop rules
+type SamplingRuleHandler struct {
+	db        *database.DB
+	registry  *sampling.Registry
+	validator *validator.Validate
+}
+
+// NewSamplingRuleHandler creates a new sampling rule handler
+func NewSamplingRuleHandler(db *database.DB, registry *sampling.Registry) *SamplingRuleHandler {
+	return &SamplingRuleHandler{
+		db:        db,
+		registry:  registry,
+		validator: validator.New(),
+	}
+}
+
+// ListSamplingRules returns every rule in evaluation order, including
+// inactive rules
+func (h *SamplingRuleHandler) ListSamplingRules(c *gin.Context) {
+	rules, err := h.db.ListSamplingRules()
+	if err != nil {
+		logrus.Errorf("Failed to list sampling rules: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list sampling rules",
+		})
+		return
+	}
+	if rules == nil {
+		rules = []models.SamplingRule{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Sampling rules retrieved successfully",
+		Data:    rules,
+	})
+}
+
+// CreateSamplingRule adds a rule. It applies to the next request on this
+// instance and within a minute on the others.
+func (h *SamplingRuleHandler) CreateSamplingRule(c *gin.Context) {
+	rule, ok := h.bindRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.InsertSamplingRule(rule); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "sampling_rule_exists",
+				Message: fmt.Sprintf("Sampling rule %s already exists", rule.Name),
+			})
+			return
+		}
+		logrus.Errorf("Failed to save sampling rule: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to save sampling rule",
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Created sampling rule %s (%s)", rule.Name, describeRule(rule))
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Sampling rule created successfully",
+		Data:    rule,
+	})
+}
+
+// UpdateSamplingRule replaces an existing rule
+func (h *SamplingRuleHandler) UpdateSamplingRule(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	rule, ok := h.bindRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = id
+
+	found, err := h.db.UpdateSamplingRule(rule)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "sampling_rule_exists",
+				Message: fmt.Sprintf("Sampling rule %s already exists", rule.Name),
+			})
+			return
+		}
+		logrus.Errorf("Failed to save sampling rule: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to save sampling rule",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "sampling_rule_not_found",
+			Message: fmt.Sprintf("Sampling rule %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Updated sampling rule %s (%s)", rule.Name, describeRule(rule))
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Sampling rule updated successfully",
+		Data:    rule,
+	})
+}
+
+// DeleteSamplingRule removes a rule
+func (h *SamplingRuleHandler) DeleteSamplingRule(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.db.DeleteSamplingRule(id)
+	if err != nil {
+		logrus.Errorf("Failed to delete sampling rule: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete sampling rule",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "sampling_rule_not_found",
+			Message: fmt.Sprintf("Sampling rule %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Sampling rule %d deleted", id),
+	})
+}
+
+// bindRule decodes and validates a rule from the request body, responding
+// with an error and returning false if it is invalid
+func (h *SamplingRuleHandler) bindRule(c *gin.Context) (*models.SamplingRule, bool) {
+	var request models.SamplingRuleRequest
+	if !bindJSON(c, &request) {
+		return nil, false
+	}
+
+	var validationErrors []models.ValidationError
+	if err := h.validator.Struct(&request); err != nil {
+		if fieldErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, fieldError := range fieldErrors {
+				validationErrors = append(validationErrors, models.ValidationError{
+					Field:   fieldError.Field(),
+					Message: getValidationMessage(fieldError),
+				})
+			}
+		}
+	} else if request.Action == models.SamplingActionSample && request.KeepRate == nil {
+		validationErrors = append(validationErrors, models.ValidationError{
+			Field:   "keep_rate",
+			Message: "keep_rate is required for sample rules",
+		})
+	}
+
+	rule := &models.SamplingRule{
+		Name:       request.Name,
+		Position:   request.Position,
+		EventType:  request.EventType,
+		EventName:  request.EventName,
+		AppVersion: request.AppVersion,
+		Property:   request.Property,
+		Action:     request.Action,
+		SampleBy:   request.SampleBy,
+		IsActive:   true,
+	}
+	switch request.Action {
+	case models.SamplingActionSample:
+		if request.KeepRate != nil {
+			rule.KeepRate = *request.KeepRate
+		}
+	case models.SamplingActionDrop:
+		rule.KeepRate = 0
+	default:
+		rule.KeepRate = 1
+	}
+	if rule.SampleBy == "" {
+		rule.SampleBy = "session_id"
+	}
+	if request.IsActive != nil {
+		rule.IsActive = *request.IsActive
+	}
+
+	if len(validationErrors) == 0 {
+		if err := sampling.Validate(*rule); err != nil {
+			validationErrors = append(validationErrors, models.ValidationError{
+				Field:   "rule",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid sampling rule",
+			Details: validationErrors,
+		})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// pathID parses the :id path parameter, responding with an error and
+// returning false if it is invalid
+func pathID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "ID must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// describeRule summarizes a rule's action for logging
+func describeRule(rule *models.SamplingRule) string {
+	if rule.Action == models.SamplingActionSample {
+		return fmt.Sprintf("keep %.4g by %s", rule.KeepRate, rule.SampleBy)
+	}
+	return rule.Action
+}
+
+// reload refreshes the registry so changes apply to the next request
+func (h *SamplingRuleHandler) reload() {
+	if err := h.registry.Load(); err != nil {
+		logrus.Errorf("Failed to reload sampling rules: %v", err)
+	}
+}
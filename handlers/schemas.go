@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"log-ingestion-server/schema"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// SchemaHandler manages the per-event-name properties schemas
+type SchemaHandler struct {
+	db        *database.DB
+	registry  *schema.Registry
+	validator *validator.Validate
+}
+
+// NewSchemaHandler creates a new schema handler
+func NewSchemaHandler(db *database.DB, registry *schema.Registry) *SchemaHandler {
+	return &SchemaHandler{
+		db:        db,
+		registry:  registry,
+		validator: validator.New(),
+	}
+}
+
+// RegisterSchema stores a new schema version for an event name. The newest
+// active version of each event name is the one enforced at ingestion.
+func (h *SchemaHandler) RegisterSchema(c *gin.Context) {
+	var request models.RegisterSchemaRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		var validationErrors []models.ValidationError
+		if fieldErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, fieldError := range fieldErrors {
+				validationErrors = append(validationErrors, models.ValidationError{
+					Field:   fieldError.Field(),
+					Message: getValidationMessage(fieldError),
+				})
+			}
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid schema registration",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	if _, err := schema.Compile(request.Schema); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_schema",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	eventSchema := &models.EventSchema{
+		EventName: request.EventName,
+		Version:   request.Version,
+		Mode:      request.Mode,
+		Schema:    request.Schema,
+		IsActive:  true,
+	}
+	if err := h.db.InsertEventSchema(eventSchema); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "schema_version_exists",
+				Message: fmt.Sprintf("Version %d of %s is already registered", request.Version, request.EventName),
+			})
+			return
+		}
+		logrus.Errorf("Failed to register schema: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to register schema",
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Registered schema %s v%d (%s)", eventSchema.EventName, eventSchema.Version, eventSchema.Mode)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Schema registered successfully",
+		Data:    eventSchema,
+	})
+}
+
+// ListSchemas returns every schema version, optionally filtered by event_name
+func (h *SchemaHandler) ListSchemas(c *gin.Context) {
+	h.respondSchemas(c, c.Query("event_name"))
+}
+
+// GetSchemas returns every version registered for one event name
+func (h *SchemaHandler) GetSchemas(c *gin.Context) {
+	h.respondSchemas(c, c.Param("event_name"))
+}
+
+// DeactivateSchema stops enforcing a schema version; the next newest active
+// version, if any, takes over
+func (h *SchemaHandler) DeactivateSchema(c *gin.Context) {
+	eventName := c.Param("event_name")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_version",
+			Message: "Version must be a positive integer",
+		})
+		return
+	}
+
+	found, err := h.db.DeactivateEventSchema(eventName, version)
+	if err != nil {
+		logrus.Errorf("Failed to deactivate schema: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to deactivate schema",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "schema_not_found",
+			Message: fmt.Sprintf("Version %d of %s is not registered", version, eventName),
+		})
+		return
+	}
+
+	h.reload()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Schema %s v%d deactivated", eventName, version),
+	})
+}
+
+func (h *SchemaHandler) respondSchemas(c *gin.Context, eventName string) {
+	schemas, err := h.db.ListEventSchemas(eventName)
+	if err != nil {
+		logrus.Errorf("Failed to list schemas: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list schemas",
+		})
+		return
+	}
+
+	if schemas == nil {
+		schemas = []models.EventSchema{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Schemas retrieved successfully",
+		Data:    schemas,
+	})
+}
+
+// reload refreshes the registry so changes apply to the next request
+func (h *SchemaHandler) reload() {
+	if err := h.registry.Load(); err != nil {
+		logrus.Errorf("Failed to reload event schemas: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log-ingestion-server/database"
+	"log-ingestion-server/integrity"
+	"log-ingestion-server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SessionHandler reports on the logs received for a session
+type SessionHandler struct {
+	db *database.DB
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(db *database.DB) *SessionHandler {
+	return &SessionHandler{db: db}
+}
+
+// GetIntegrity analyzes a session's sequence numbers as currently stored,
+// reporting missing ranges, out-of-order arrivals and replays
+func (h *SessionHandler) GetIntegrity(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	events, err := h.db.SessionSequence(sessionID)
+	if err != nil {
+		logrus.Errorf("Failed to get session sequence: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve session events",
+		})
+		return
+	}
+
+	report := integrity.Analyze(sessionID, events)
+	if report == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "session_not_found",
+			Message: "No events with sequence numbers were found for this session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Session integrity analyzed successfully",
+		Data:    report,
+	})
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log-ingestion-server/middleware"
+	"log-ingestion-server/models"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxStreamLineSize bounds a single NDJSON record
+	maxStreamLineSize = 1 << 20
+
+	// maxStreamFailures bounds the number of failures listed in the summary
+	maxStreamFailures = 1000
+)
+
+// ndjsonContentTypes are the accepted media types for streaming ingestion
+var ndjsonContentTypes = map[string]bool{
+	"application/x-ndjson": true,
+	"application/ndjson":   true,
+	"application/jsonl":    true,
+}
+
+// IngestStream handles NDJSON streaming ingestion. The body is decoded line by
+// line and valid logs are stored in chunks of MaxBatchSize as they are read,
+// so arbitrarily large dumps never have to fit in memory. The response lists
+// rejected lines by line number.
+func (h *IngestHandler) IngestStream(c *gin.Context) {
+	start := time.Now()
+
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.RequestDuration.WithLabelValues("POST", "/stream-ingest").Observe(duration)
+		h.metrics.RequestsTotal.WithLabelValues("POST", "/stream-ingest", fmt.Sprintf("%d", c.Writer.Status())).Inc()
+	}()
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if !ndjsonContentTypes[mediaType] {
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error:   "unsupported_media_type",
+			Message: "Content-Type must be application/x-ndjson",
+		})
+		return
+	}
+
+	// Large uploads outlive the server-wide read and write timeouts
+	controller := http.NewResponseController(c.Writer)
+	deadline := time.Now().Add(h.streamTimeout)
+	if err := controller.SetReadDeadline(deadline); err != nil {
+		logrus.Debugf("Could not extend read deadline for stream: %v", err)
+	}
+	if err := controller.SetWriteDeadline(deadline); err != nil {
+		logrus.Debugf("Could not extend write deadline for stream: %v", err)
+	}
+
+	summary := models.StreamIngestSummary{Failures: []models.StreamLineFailure{}}
+	chunk := make([]models.AnalyticsLog, 0, h.maxBatchSize)
+
+	info, ok := h.newRequestInfo(c, nil)
+	if !ok {
+		return
+	}
+	reader := bufio.NewReaderSize(c.Request.Body, 64*1024)
+	lineNumber := 0
+
+	for {
+		line, tooLong, err := readStreamLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.finishStream(c, &summary, &chunk, fmt.Errorf("failed to read request body: %w", err))
+			return
+		}
+
+		lineNumber++
+		summary.LinesRead = lineNumber
+
+		if tooLong {
+			h.addStreamFailure(&summary, lineNumber, "", []models.ValidationError{{
+				Field:   "line",
+				Message: fmt.Sprintf("Line exceeds %d bytes", maxStreamLineSize),
+			}})
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			h.processStreamLine(info, &summary, &chunk, lineNumber, line)
+		}
+
+		if len(chunk) >= h.maxBatchSize {
+			if err := h.flushStreamChunk(&summary, &chunk); err != nil {
+				h.finishStream(c, &summary, &chunk, err)
+				return
+			}
+			summary.CommittedThroughLine = lineNumber
+		}
+	}
+
+	h.finishStream(c, &summary, &chunk, nil)
+}
+
+// processStreamLine decodes and validates one line, adding it to the chunk
+func (h *IngestHandler) processStreamLine(info *requestInfo, summary *models.StreamIngestSummary, chunk *[]models.AnalyticsLog, lineNumber int, line []byte) {
+	var log models.AnalyticsLog
+	if err := json.Unmarshal(line, &log); err != nil {
+		h.recordValidationError("line", "invalid_json", "")
+		h.addStreamFailure(summary, lineNumber, "", []models.ValidationError{{
+			Field:   "line",
+			Message: "Invalid JSON format",
+		}})
+		return
+	}
+
+	keep, validationErrors := h.prepareLog(info, &log)
+	if len(validationErrors) > 0 {
+		h.addStreamFailure(summary, lineNumber, log.EventID, validationErrors)
+		return
+	}
+	if !keep {
+		summary.Dropped++
+		return
+	}
+
+	*chunk = append(*chunk, log)
+}
+
+// flushStreamChunk stores the pending chunk, counting duplicates
+func (h *IngestHandler) flushStreamChunk(summary *models.StreamIngestSummary, chunk *[]models.AnalyticsLog) error {
+	logs := *chunk
+	if len(logs) == 0 {
+		return nil
+	}
+
+	inserted, err := h.db.InsertLogsBatchSkipDuplicates(logs)
+	if err != nil {
+		h.metrics.DatabaseErrors.WithLabelValues("insert_stream").Inc()
+		return err
+	}
+
+	for i, ok := range inserted {
+		if ok {
+			summary.Accepted++
+			h.metrics.LogsIngested.WithLabelValues(logs[i].EventType, logs[i].Priority).Inc()
+		} else {
+			summary.Duplicates++
+		}
+	}
+	h.metrics.BatchSize.WithLabelValues("stream").Observe(float64(len(logs)))
+
+	*chunk = logs[:0]
+	return nil
+}
+
+// finishStream flushes the final chunk and writes the summary. If the stream
+// failed part way, lines after CommittedThroughLine were not stored.
+func (h *IngestHandler) finishStream(c *gin.Context, summary *models.StreamIngestSummary, chunk *[]models.AnalyticsLog, streamErr error) {
+	if streamErr == nil {
+		streamErr = h.flushStreamChunk(summary, chunk)
+		if streamErr == nil {
+			summary.CommittedThroughLine = summary.LinesRead
+		}
+	}
+
+	if streamErr != nil {
+		logrus.Errorf("Stream ingestion stopped after line %d: %v", summary.LinesRead, streamErr)
+
+		status := http.StatusServiceUnavailable
+		errorCode := "database_error"
+		var tooLarge *middleware.BodyTooLargeError
+		if errors.As(streamErr, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			errorCode = "request_too_large"
+		}
+
+		c.JSON(status, gin.H{
+			"error":   errorCode,
+			"message": fmt.Sprintf("Stream stopped; lines after %d were not stored", summary.CommittedThroughLine),
+			"summary": summary,
+		})
+		return
+	}
+
+	logrus.Infof("Stream ingested: %d lines, %d accepted, %d duplicate, %d invalid, %d dropped",
+		summary.LinesRead, summary.Accepted, summary.Duplicates, summary.Invalid, summary.Dropped)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: summary.Invalid == 0,
+		Message: fmt.Sprintf("Stream processed: %d lines, %d accepted, %d duplicate, %d invalid",
+			summary.LinesRead, summary.Accepted, summary.Duplicates, summary.Invalid),
+		Data: summary,
+	})
+}
+
+// addStreamFailure records a rejected line, listing at most maxStreamFailures
+func (h *IngestHandler) addStreamFailure(summary *models.StreamIngestSummary, lineNumber int, eventID string, validationErrors []models.ValidationError) {
+	summary.Invalid++
+	if len(summary.Failures) >= maxStreamFailures {
+		summary.FailuresTruncated = true
+		return
+	}
+
+	summary.Failures = append(summary.Failures, models.StreamLineFailure{
+		Line:    lineNumber,
+		EventID: eventID,
+		Errors:  validationErrors,
+	})
+}
+
+// readStreamLine reads the next newline-terminated line. Lines longer than
+// maxStreamLineSize are consumed and reported with tooLong set.
+func readStreamLine(reader *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		fragment, isPrefix, readErr := reader.ReadLine()
+		if readErr != nil {
+			if readErr == io.EOF && (line != nil || tooLong) {
+				return line, tooLong, nil
+			}
+			return line, tooLong, readErr
+		}
+
+		if !tooLong {
+			if len(line)+len(fragment) > maxStreamLineSize {
+				tooLong = true
+				line = nil
+			} else {
+				line = append(line, fragment...)
+				if line == nil {
+					line = []byte{}
+				}
+			}
+		}
+
+		if !isPrefix {
+			return line, tooLong, nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"log-ingestion-server/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IngestSyslog prepares logs converted from syslog messages the same way as
+// ingested requests and enqueues those that are kept. It returns how many
+// failed validation; those are dropped, since a syslog sender cannot be told.
+func (h *IngestHandler) IngestSyslog(logs []models.AnalyticsLog) (int, error) {
+	validLogs := make([]models.AnalyticsLog, 0, len(logs))
+	var rejected int
+
+	// Syslog has no request, so there is no client to enrich from or
+	// sent-at time to correct clock skew with
+	info := &requestInfo{}
+
+	for i := range logs {
+		keep, validationErrors := h.prepareLog(info, &logs[i])
+		if len(validationErrors) > 0 {
+			rejected++
+			logrus.Debugf("Rejected syslog message %s: %s: %s",
+				logs[i].EventID, validationErrors[0].Field, validationErrors[0].Message)
+			continue
+		}
+		if !keep {
+			continue
+		}
+		validLogs = append(validLogs, logs[i])
+	}
+
+	if len(validLogs) == 0 {
+		return rejected, nil
+	}
+
+	if err := h.pipeline.Enqueue(validLogs...); err != nil {
+		return rejected, err
+	}
+
+	for _, log := range validLogs {
+		h.metrics.LogsIngested.WithLabelValues(log.EventType, log.Priority).Inc()
+	}
+	h.metrics.BatchSize.WithLabelValues("syslog").Observe(float64(len(validLogs)))
+
+	return rejected, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log-ingestion-server/models"
+	"log-ingestion-server/redact"
+	"log-ingestion-server/syslog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syslogLog converts a raw syslog message the way the listener does
+func syslogLog(t *testing.T, raw string) models.AnalyticsLog {
+	t.Helper()
+	msg, err := syslog.Parse([]byte(raw), time.Now())
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", raw, err)
+	}
+	return msg.ToLog("10.0.0.1", "udp")
+}
+
+func TestIngestSyslog(t *testing.T) {
+	h, store, drain := newTestIngestHandler(t)
+	rules, err := redact.BuiltinRules([]string{"email"}, redact.ActionMask)
+	if err != nil {
+		t.Fatalf("BuiltinRules: %v", err)
+	}
+	if h.redactor, err = redact.New(rules, ""); err != nil {
+		t.Fatalf("redact.New: %v", err)
+	}
+
+	valid := syslogLog(t, "<165>1 2026-10-16T12:00:00Z host app 42 backup_done - Sent report to ops@example.com")
+	// An event_name longer than its column fails validation
+	invalid := syslogLog(t, "<165>1 2026-10-16T12:00:00Z host app 42 "+strings.Repeat("m", 101)+" - too long")
+
+	rejected, err := h.IngestSyslog([]models.AnalyticsLog{valid, invalid})
+	if err != nil {
+		t.Fatalf("IngestSyslog: %v", err)
+	}
+	if rejected != 1 {
+		t.Errorf("rejected %d logs, want 1", rejected)
+	}
+
+	drain()
+	logs, _ := store.GetRecentLogs(10)
+	if len(logs) != 1 {
+		t.Fatalf("stored %d logs, want 1", len(logs))
+	}
+	if message, _ := logs[0].Properties["message"].(string); strings.Contains(message, "ops@example.com") {
+		t.Errorf("message = %q, want the email redacted", message)
+	}
+}
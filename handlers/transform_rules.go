@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"log-ingestion-server/transform"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// TransformRuleHandler manages the event transformation rules
+type TransformRuleHandler struct {
+	db        *database.DB
+	registry  *transform.Registry
+	validator *validator.Validate
+}
+
+// NewTransformRuleHandler creates a new transform rule handler
+func NewTransformRuleHandler(db *database.DB, registry *transform.Registry) *TransformRuleHandler {
+	return &TransformRuleHandler{
+		db:        db,
+		registry:  registry,
+		validator: validator.New(),
+	}
+}
+
+// ListTransformRules returns every rule in application order, including
+// inactive rules
+func (h *TransformRuleHandler) ListTransformRules(c *gin.Context) {
+	rules, err := h.db.ListTransformRules()
+	if err != nil {
+		logrus.Errorf("Failed to list transform rules: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to list transform rules",
+		})
+		return
+	}
+	if rules == nil {
+		rules = []models.TransformRule{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Transform rules retrieved successfully",
+		Data:    rules,
+	})
+}
+
+// CreateTransformRule adds a rule. It applies to the next request on this
+// instance and within a minute on the others.
+func (h *TransformRuleHandler) CreateTransformRule(c *gin.Context) {
+	rule, ok := h.bindRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.InsertTransformRule(rule); err != nil {
+		h.respondSaveError(c, rule, err)
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Created transform rule %s (%d operations)", rule.Name, len(rule.Operations))
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Transform rule created successfully",
+		Data:    rule,
+	})
+}
+
+// UpdateTransformRule replaces an existing rule
+func (h *TransformRuleHandler) UpdateTransformRule(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	rule, ok := h.bindRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = id
+
+	found, err := h.db.UpdateTransformRule(rule)
+	if err != nil {
+		h.respondSaveError(c, rule, err)
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "transform_rule_not_found",
+			Message: fmt.Sprintf("Transform rule %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	logrus.Infof("Updated transform rule %s (%d operations)", rule.Name, len(rule.Operations))
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Transform rule updated successfully",
+		Data:    rule,
+	})
+}
+
+// DeleteTransformRule removes a rule
+func (h *TransformRuleHandler) DeleteTransformRule(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.db.DeleteTransformRule(id)
+	if err != nil {
+		logrus.Errorf("Failed to delete transform rule: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete transform rule",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "transform_rule_not_found",
+			Message: fmt.Sprintf("Transform rule %d does not exist", id),
+		})
+		return
+	}
+
+	h.reload()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Transform rule %d deleted", id),
+	})
+}
+
+// DryRun applies the active rules, or the unsaved rules given in the request,
+// to a sample log and returns it before and after. Nothing is stored.
+func (h *TransformRuleHandler) DryRun(c *gin.Context) {
+	var request models.TransformDryRunRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid dry run request",
+			Details: formatRequestErrors(err),
+		})
+		return
+	}
+
+	rules := h.registry.Rules()
+	if request.Rules != nil {
+		candidates := make([]models.TransformRule, len(request.Rules))
+		for i, r := range request.Rules {
+			candidates[i] = transformRuleFromRequest(r)
+		}
+
+		var err error
+		if rules, err = transform.Compile(candidates); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid transform rule",
+				Details: []models.ValidationError{{Field: "rules", Message: err.Error()}},
+			})
+			return
+		}
+	}
+
+	result := models.TransformDryRunResult{After: request.Log}
+	if err := copyLog(&result.Before, &request.Log); err != nil {
+		logrus.Errorf("Failed to copy dry run log: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to run transform rules",
+		})
+		return
+	}
+
+	result.Applied = rules.Apply(&result.After)
+	if result.Applied == nil {
+		result.Applied = []models.TransformApplication{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d transform rules matched", len(result.Applied)),
+		Data:    result,
+	})
+}
+
+// copyLog deep-copies a log, including its nested properties
+func copyLog(dst, src *models.AnalyticsLog) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// bindRule decodes and validates a rule from the request body, responding
+// with an error and returning false if it is invalid
+func (h *TransformRuleHandler) bindRule(c *gin.Context) (*models.TransformRule, bool) {
+	var request models.TransformRuleRequest
+	if !bindJSON(c, &request) {
+		return nil, false
+	}
+
+	rule := transformRuleFromRequest(request)
+
+	var validationErrors []models.ValidationError
+	if err := h.validator.Struct(&request); err != nil {
+		validationErrors = formatRequestErrors(err)
+	} else if err := transform.Validate(rule); err != nil {
+		validationErrors = append(validationErrors, models.ValidationError{
+			Field:   "rule",
+			Message: err.Error(),
+		})
+	}
+
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid transform rule",
+			Details: validationErrors,
+		})
+		return nil, false
+	}
+
+	return &rule, true
+}
+
+func transformRuleFromRequest(request models.TransformRuleRequest) models.TransformRule {
+	rule := models.TransformRule{
+		Name:          request.Name,
+		Position:      request.Position,
+		EventType:     request.EventType,
+		EventName:     request.EventName,
+		MinAppVersion: request.MinAppVersion,
+		MaxAppVersion: request.MaxAppVersion,
+		Operations:    request.Operations,
+		IsActive:      true,
+	}
+	if request.IsActive != nil {
+		rule.IsActive = *request.IsActive
+	}
+	return rule
+}
+
+// formatRequestErrors lists the field errors of an admin request
+func formatRequestErrors(err error) []models.ValidationError {
+	var validationErrors []models.ValidationError
+	if fieldErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, fieldError := range fieldErrors {
+			validationErrors = append(validationErrors, models.ValidationError{
+				Field:   fieldError.Field(),
+				Message: getValidationMessage(fieldError),
+			})
+		}
+	}
+	return validationErrors
+}
+
+// respondSaveError maps a failed insert or update to a response
+func (h *TransformRuleHandler) respondSaveError(c *gin.Context, rule *models.TransformRule, err error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "transform_rule_exists",
+			Message: fmt.Sprintf("Transform rule %s already exists", rule.Name),
+		})
+		return
+	}
+	logrus.Errorf("Failed to save transform rule: %v", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to save transform rule",
+	})
+}
+
+// reload refreshes the registry so changes apply to the next request
+func (h *TransformRuleHandler) reload() {
+	if err := h.registry.Load(); err != nil {
+		logrus.Errorf("Failed to reload transform rules: %v", err)
+	}
+}
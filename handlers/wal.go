@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log-ingestion-server/models"
+	"log-ingestion-server/wal"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WALHandler exposes the write-ahead log backlog for operators
+type WALHandler struct {
+	wal *wal.WAL
+}
+
+// NewWALHandler creates a new WAL handler. The WAL may be nil when disabled.
+func NewWALHandler(w *wal.WAL) *WALHandler {
+	return &WALHandler{wal: w}
+}
+
+// GetStatus returns the current WAL backlog
+func (h *WALHandler) GetStatus(c *gin.Context) {
+	if h.wal == nil {
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Message: "Write-ahead log is disabled",
+			Data:    gin.H{"enabled": false},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "WAL status retrieved successfully",
+		Data: gin.H{
+			"enabled": true,
+			"stats":   h.wal.Stats(),
+		},
+	})
+}
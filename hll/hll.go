@@ -0,0 +1,158 @@
+// Package hll implements HyperLogLog sketches for estimating the number of
+// distinct users and sessions in the log rollups. Sketches use 2^12
+// registers, for a standard error of about 1.6%, and are stored sparsely
+// while few registers are set so that quiet rollup buckets stay small.
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	precision = 12
+	registers = 1 << precision
+)
+
+// Encodings of a marshaled sketch, in its first byte
+const (
+	encodingSparse byte = 1
+	encodingDense  byte = 2
+)
+
+// sparseEntrySize is the size of an encoded sparse register: a 2-byte index
+// and a 1-byte value
+const sparseEntrySize = 3
+
+// Sketch estimates the number of distinct 64-bit hashes added to it. The zero
+// value is an empty sketch.
+type Sketch struct {
+	registers []uint8
+}
+
+// New returns an empty sketch
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add records a 64-bit hash of an item. Hashes must be uniformly distributed.
+func (s *Sketch) Add(hash uint64) {
+	if s.registers == nil {
+		s.registers = make([]uint8, registers)
+	}
+
+	index := hash >> (64 - precision)
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1)) + 1)
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+// Merge adds every item of o to s
+func (s *Sketch) Merge(o *Sketch) {
+	if o == nil || o.registers == nil {
+		return
+	}
+	if s.registers == nil {
+		s.registers = make([]uint8, registers)
+	}
+	for i, r := range o.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+}
+
+// Estimate returns the estimated number of distinct items
+func (s *Sketch) Estimate() int64 {
+	if s.registers == nil {
+		return 0
+	}
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
+
+// MarshalBinary encodes the sketch, sparsely if that is smaller
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	set := 0
+	for _, r := range s.registers {
+		if r != 0 {
+			set++
+		}
+	}
+
+	if set*sparseEntrySize < registers {
+		data := make([]byte, 1, 1+set*sparseEntrySize)
+		data[0] = encodingSparse
+		for i, r := range s.registers {
+			if r != 0 {
+				data = binary.BigEndian.AppendUint16(data, uint16(i))
+				data = append(data, r)
+			}
+		}
+		return data, nil
+	}
+
+	data := make([]byte, 1+registers)
+	data[0] = encodingDense
+	copy(data[1:], s.registers)
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary. An empty input
+// decodes to an empty sketch.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	s.registers = nil
+	if len(data) == 0 {
+		return nil
+	}
+
+	switch data[0] {
+	case encodingSparse:
+		entries := data[1:]
+		if len(entries)%sparseEntrySize != 0 {
+			return errors.New("hll: truncated sparse sketch")
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		s.registers = make([]uint8, registers)
+		for i := 0; i < len(entries); i += sparseEntrySize {
+			index := binary.BigEndian.Uint16(entries[i:])
+			if index >= registers {
+				return errors.New("hll: register index out of range")
+			}
+			s.registers[index] = entries[i+2]
+		}
+	case encodingDense:
+		if len(data) != 1+registers {
+			return errors.New("hll: dense sketch has the wrong size")
+		}
+		s.registers = make([]uint8, registers)
+		copy(s.registers, data[1:])
+	default:
+		return errors.New("hll: unknown sketch encoding")
+	}
+
+	return nil
+}
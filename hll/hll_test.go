@@ -0,0 +1,152 @@
+package hll
+
+import (
+	"math"
+	"testing"
+)
+
+// hash spreads sequential IDs uniformly over 64 bits (splitmix64), standing
+// in for the hashes PostgreSQL computes for user and session IDs
+func hash(id uint64) uint64 {
+	z := id + 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// sketchOf returns a sketch of the IDs in [from, to)
+func sketchOf(from, to uint64) *Sketch {
+	s := New()
+	for id := from; id < to; id++ {
+		s.Add(hash(id))
+	}
+	return s
+}
+
+// assertEstimate fails unless the estimate is within 5% (about three standard
+// errors) of want
+func assertEstimate(t *testing.T, s *Sketch, want int64) {
+	t.Helper()
+	got := s.Estimate()
+	if math.Abs(float64(got-want)) > 0.05*float64(want) {
+		t.Errorf("estimate = %d, want %d within 5%%", got, want)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	if got := New().Estimate(); got != 0 {
+		t.Errorf("empty sketch estimate = %d, want 0", got)
+	}
+
+	for _, n := range []int64{10, 100, 1000, 10000, 100000, 1000000} {
+		assertEstimate(t, sketchOf(0, uint64(n)), n)
+	}
+}
+
+func TestEstimateIgnoresRepeats(t *testing.T) {
+	s := sketchOf(0, 5000)
+	for i := 0; i < 3; i++ {
+		for id := uint64(0); id < 5000; id++ {
+			s.Add(hash(id))
+		}
+	}
+	assertEstimate(t, s, 5000)
+}
+
+func TestMerge(t *testing.T) {
+	// Two overlapping sets, as the same users in two buckets
+	a := sketchOf(0, 60000)
+	b := sketchOf(40000, 100000)
+
+	merged := New()
+	merged.Merge(a)
+	merged.Merge(b)
+	assertEstimate(t, merged, 100000)
+
+	// Merging in any order gives the same sketch
+	reversed := New()
+	reversed.Merge(b)
+	reversed.Merge(a)
+	if merged.Estimate() != reversed.Estimate() {
+		t.Errorf("merge order changed the estimate: %d != %d", merged.Estimate(), reversed.Estimate())
+	}
+
+	// Merging a sketch into itself or an empty one changes nothing
+	before := merged.Estimate()
+	merged.Merge(merged)
+	merged.Merge(New())
+	merged.Merge(nil)
+	if merged.Estimate() != before {
+		t.Errorf("estimate after merging itself and empty sketches = %d, want %d", merged.Estimate(), before)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		sketch   *Sketch
+		encoding byte
+	}{
+		{name: "empty", sketch: New(), encoding: encodingSparse},
+		{name: "sparse", sketch: sketchOf(0, 100), encoding: encodingSparse},
+		{name: "dense", sketch: sketchOf(0, 50000), encoding: encodingDense},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.sketch.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if data[0] != tt.encoding {
+				t.Errorf("encoding = %d, want %d", data[0], tt.encoding)
+			}
+			if tt.encoding == encodingSparse && len(data) >= 1+registers {
+				t.Errorf("sparse sketch takes %d bytes, more than a dense one", len(data))
+			}
+
+			decoded := New()
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary: %v", err)
+			}
+			if decoded.Estimate() != tt.sketch.Estimate() {
+				t.Errorf("decoded estimate = %d, want %d", decoded.Estimate(), tt.sketch.Estimate())
+			}
+			for i := range tt.sketch.registers {
+				if decoded.registers[i] != tt.sketch.registers[i] {
+					t.Fatalf("register %d = %d, want %d", i, decoded.registers[i], tt.sketch.registers[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "empty input", data: nil},
+		{name: "sparse without entries", data: []byte{encodingSparse}},
+		{name: "sparse entry", data: []byte{encodingSparse, 0x00, 0x07, 3}},
+		{name: "truncated sparse entry", data: []byte{encodingSparse, 0x00, 0x07}, wantErr: true},
+		{name: "sparse index out of range", data: []byte{encodingSparse, 0x10, 0x00, 3}, wantErr: true},
+		{name: "dense with the wrong size", data: make([]byte, registers), wantErr: true},
+		{name: "unknown encoding", data: []byte{9, 0, 0, 1}, wantErr: true},
+	}
+	tests[5].data[0] = encodingDense
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sketchOf(0, 10)
+			err := s.UnmarshalBinary(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalBinary error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && len(tt.data) <= 1 && s.Estimate() != 0 {
+				t.Errorf("estimate = %d, want an empty sketch", s.Estimate())
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package integrity checks the sequence numbers clients attach to the logs
+// of a session, revealing events lost, reordered or replayed between the
+// device's offline buffer and the database.
+package integrity
+
+import (
+	"log-ingestion-server/models"
+	"sort"
+	"time"
+)
+
+// maxMissingRanges caps the ranges listed in a report; the missing count
+// stays exact
+const maxMissingRanges = 1000
+
+// Analyze computes the integrity of one session from its sequenced events in
+// arrival order. Sequence numbers are expected to be contiguous from the
+// lowest to the highest one received:
+//
+//   - missing: numbers in that span that never arrived
+//   - out of order: events that arrived after a higher number had
+//   - replayed: extra arrivals of a number already received under another
+//     event ID
+//
+// It returns nil when there are no events.
+func Analyze(sessionID string, events []models.SequencedEvent) *models.SessionIntegrity {
+	if len(events) == 0 {
+		return nil
+	}
+
+	report := &models.SessionIntegrity{
+		SessionID:      sessionID,
+		FirstSequence:  events[0].SequenceNumber,
+		LastSequence:   events[0].SequenceNumber,
+		ReceivedEvents: len(events),
+		MissingRanges:  []models.SequenceRange{},
+		AnalyzedAt:     time.Now(),
+	}
+
+	seen := make(map[int]bool, len(events))
+	seenIDs := make(map[string]bool, len(events))
+	highest := events[0].SequenceNumber
+	for i, e := range events {
+		if i > 0 && e.SequenceNumber < highest {
+			report.OutOfOrderEvents++
+		}
+		if e.SequenceNumber > highest {
+			highest = e.SequenceNumber
+		}
+
+		// The same event delivered twice is a duplicate, not a replay
+		if seen[e.SequenceNumber] && !seenIDs[e.EventID] {
+			report.ReplayedEvents++
+		}
+		seen[e.SequenceNumber] = true
+		seenIDs[e.EventID] = true
+
+		if e.SequenceNumber < report.FirstSequence {
+			report.FirstSequence = e.SequenceNumber
+		}
+		if e.SequenceNumber > report.LastSequence {
+			report.LastSequence = e.SequenceNumber
+		}
+		if e.CreatedAt.After(report.LastEventAt) {
+			report.LastEventAt = e.CreatedAt
+		}
+		if e.AppVersion != nil {
+			report.AppVersion = e.AppVersion
+		}
+	}
+
+	sequences := make([]int, 0, len(seen))
+	for sequence := range seen {
+		sequences = append(sequences, sequence)
+	}
+	sort.Ints(sequences)
+
+	for i := 1; i < len(sequences); i++ {
+		gap := sequences[i] - sequences[i-1] - 1
+		if gap <= 0 {
+			continue
+		}
+		report.MissingEvents += gap
+		if len(report.MissingRanges) < maxMissingRanges {
+			report.MissingRanges = append(report.MissingRanges, models.SequenceRange{
+				From: sequences[i-1] + 1,
+				To:   sequences[i] - 1,
+			})
+		}
+	}
+
+	report.ExpectedEvents = report.LastSequence - report.FirstSequence + 1
+	report.LossRate = float64(report.MissingEvents) / float64(report.ExpectedEvents)
+
+	return report
+}
@@ -0,0 +1,155 @@
+package integrity
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sequence builds events arriving in the given order, one second apart
+func sequence(numbers ...int) []models.SequencedEvent {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	events := make([]models.SequencedEvent, len(numbers))
+	for i, n := range numbers {
+		events[i] = models.SequencedEvent{
+			EventID:        fmt.Sprintf("e-%d-%d", i, n),
+			SequenceNumber: n,
+			CreatedAt:      start.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return events
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []models.SequencedEvent
+		want   models.SessionIntegrity
+	}{
+		{
+			name:   "in order",
+			events: sequence(0, 1, 2, 3, 4),
+			want:   models.SessionIntegrity{FirstSequence: 0, LastSequence: 4, ExpectedEvents: 5, ReceivedEvents: 5},
+		},
+		{
+			name:   "single event",
+			events: sequence(7),
+			want:   models.SessionIntegrity{FirstSequence: 7, LastSequence: 7, ExpectedEvents: 1, ReceivedEvents: 1},
+		},
+		{
+			name:   "gaps",
+			events: sequence(1, 2, 5, 6, 9),
+			want: models.SessionIntegrity{
+				FirstSequence: 1, LastSequence: 9, ExpectedEvents: 9, ReceivedEvents: 5,
+				MissingEvents: 4, MissingRanges: []models.SequenceRange{{From: 3, To: 4}, {From: 7, To: 8}},
+				LossRate: 4.0 / 9,
+			},
+		},
+		{
+			name:   "out of order",
+			events: sequence(0, 2, 1, 4, 3),
+			want:   models.SessionIntegrity{FirstSequence: 0, LastSequence: 4, ExpectedEvents: 5, ReceivedEvents: 5, OutOfOrderEvents: 2},
+		},
+		{
+			name:   "offline buffer flushed late",
+			events: sequence(10, 11, 12, 3, 4, 5),
+			want: models.SessionIntegrity{
+				FirstSequence: 3, LastSequence: 12, ExpectedEvents: 10, ReceivedEvents: 6, OutOfOrderEvents: 3,
+				MissingEvents: 4, MissingRanges: []models.SequenceRange{{From: 6, To: 9}},
+				LossRate: 0.4,
+			},
+		},
+		{
+			name:   "replays",
+			events: sequence(0, 1, 2, 1, 2, 3),
+			want:   models.SessionIntegrity{FirstSequence: 0, LastSequence: 3, ExpectedEvents: 4, ReceivedEvents: 6, OutOfOrderEvents: 1, ReplayedEvents: 2},
+		},
+		{
+			// Numbering that starts over within a session shows as replays of
+			// the numbers already received
+			name:   "numbering restarted within the session",
+			events: sequence(0, 1, 2, 0, 1),
+			want:   models.SessionIntegrity{FirstSequence: 0, LastSequence: 2, ExpectedEvents: 3, ReceivedEvents: 5, OutOfOrderEvents: 2, ReplayedEvents: 2},
+		},
+		{
+			name: "duplicate delivery of the same event",
+			events: func() []models.SequencedEvent {
+				events := sequence(0, 1, 1, 2)
+				events[2].EventID = events[1].EventID
+				return events
+			}(),
+			want: models.SessionIntegrity{FirstSequence: 0, LastSequence: 2, ExpectedEvents: 3, ReceivedEvents: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Analyze("s-1", tt.events)
+			if got == nil {
+				t.Fatal("Analyze returned no report")
+			}
+			if got.AnalyzedAt.IsZero() {
+				t.Error("AnalyzedAt not set")
+			}
+			if want := tt.events[len(tt.events)-1].CreatedAt; !got.LastEventAt.Equal(want) {
+				t.Errorf("LastEventAt = %s, want %s", got.LastEventAt, want)
+			}
+
+			tt.want.SessionID = "s-1"
+			if tt.want.MissingRanges == nil {
+				tt.want.MissingRanges = []models.SequenceRange{}
+			}
+			tt.want.LastEventAt, tt.want.AnalyzedAt = got.LastEventAt, got.AnalyzedAt
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Analyze = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeNoEvents(t *testing.T) {
+	if report := Analyze("s-1", nil); report != nil {
+		t.Errorf("Analyze with no events = %+v, want nil", report)
+	}
+}
+
+// TestAnalyzeSessionsIndependently checks that every session is numbered on
+// its own: a new session starting over, or starting past zero, is not loss
+func TestAnalyzeSessionsIndependently(t *testing.T) {
+	first := Analyze("s-1", sequence(0, 1, 2, 3))
+	second := Analyze("s-2", sequence(0, 1, 2))
+	resumed := Analyze("s-3", sequence(500, 501, 502))
+
+	for _, report := range []*models.SessionIntegrity{first, second, resumed} {
+		if report.MissingEvents != 0 || report.ReplayedEvents != 0 || report.OutOfOrderEvents != 0 || report.LossRate != 0 {
+			t.Errorf("session %s = %+v, want no loss, replays or reordering", report.SessionID, report)
+		}
+	}
+	if resumed.FirstSequence != 500 || resumed.ExpectedEvents != 3 {
+		t.Errorf("session s-3 expects %d events from %d, want 3 from 500", resumed.ExpectedEvents, resumed.FirstSequence)
+	}
+}
+
+func TestAnalyzeAppVersionAndMissingRangeCap(t *testing.T) {
+	numbers := make([]int, 0, maxMissingRanges+2)
+	for i := 0; i <= maxMissingRanges+1; i++ {
+		numbers = append(numbers, i*2)
+	}
+	events := sequence(numbers...)
+	old, current := "2.3.0", "2.4.0"
+	events[0].AppVersion = &old
+	events[len(events)-1].AppVersion = &current
+
+	report := Analyze("s-1", events)
+	if len(report.MissingRanges) != maxMissingRanges {
+		t.Errorf("listed %d missing ranges, want the cap of %d", len(report.MissingRanges), maxMissingRanges)
+	}
+	if report.MissingEvents != maxMissingRanges+1 {
+		t.Errorf("MissingEvents = %d, want %d", report.MissingEvents, maxMissingRanges+1)
+	}
+	if report.AppVersion == nil || *report.AppVersion != current {
+		t.Errorf("AppVersion = %v, want the latest %s", report.AppVersion, current)
+	}
+}
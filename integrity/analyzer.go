@@ -0,0 +1,204 @@
+package integrity
+
+import (
+	"log-ingestion-server/database"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionsPerRound bounds the sessions analyzed in one round
+const sessionsPerRound = 1000
+
+// Options configures the background analyzer
+type Options struct {
+	// Interval between analysis rounds
+	Interval time.Duration
+	// SessionIdle is how long a session must go without events before it is
+	// analyzed; late events cause it to be analyzed again
+	SessionIdle time.Duration
+	// Window is how far back the per-app-version metrics look
+	Window time.Duration
+}
+
+// Analyzer periodically analyzes sessions that have gone quiet, stores the
+// results in session_integrity and exports data-loss rates per app version.
+// Results are upserted and the metrics recomputed from the table, so several
+// replicas can run it at once.
+type Analyzer struct {
+	db      *database.DB
+	opts    Options
+	metrics *Metrics
+
+	watermark time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the integrity analyzer
+type Metrics struct {
+	SessionsAnalyzed prometheus.Counter
+	Sessions         *prometheus.GaugeVec
+	ExpectedEvents   *prometheus.GaugeVec
+	MissingEvents    *prometheus.GaugeVec
+	OutOfOrderEvents *prometheus.GaugeVec
+	ReplayedEvents   *prometheus.GaugeVec
+	LossRate         *prometheus.GaugeVec
+}
+
+// NewAnalyzer creates an analyzer that starts with sessions active within the
+// metrics window
+func NewAnalyzer(db *database.DB, opts Options) *Analyzer {
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, []string{"app_version"})
+	}
+
+	metrics := &Metrics{
+		SessionsAnalyzed: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "session_integrity_sessions_analyzed_total",
+				Help: "Total number of session integrity analyses run by this instance",
+			},
+		),
+		Sessions:         gauge("session_integrity_sessions", "Sessions analyzed within the integrity window"),
+		ExpectedEvents:   gauge("session_integrity_expected_events", "Events expected from sequence numbers within the integrity window"),
+		MissingEvents:    gauge("session_integrity_missing_events", "Sequence numbers never received within the integrity window"),
+		OutOfOrderEvents: gauge("session_integrity_out_of_order_events", "Events received after a higher sequence number within the integrity window"),
+		ReplayedEvents:   gauge("session_integrity_replayed_events", "Repeated sequence numbers received within the integrity window"),
+		LossRate:         gauge("session_integrity_loss_ratio", "Fraction of expected events never received within the integrity window"),
+	}
+
+	prometheus.MustRegister(
+		metrics.SessionsAnalyzed,
+		metrics.Sessions,
+		metrics.ExpectedEvents,
+		metrics.MissingEvents,
+		metrics.OutOfOrderEvents,
+		metrics.ReplayedEvents,
+		metrics.LossRate,
+	)
+
+	return &Analyzer{
+		db:        db,
+		opts:      opts,
+		metrics:   metrics,
+		watermark: time.Now().Add(-opts.Window),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs analysis rounds in the background until Stop is called
+func (a *Analyzer) Start() {
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			a.runRound()
+
+			select {
+			case <-ticker.C:
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+
+	logrus.Infof("Session integrity analyzer started (every %s, sessions idle for %s)", a.opts.Interval, a.opts.SessionIdle)
+}
+
+// Stop waits for the current round to finish and stops the analyzer
+func (a *Analyzer) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// runRound analyzes the sessions that went quiet since the previous round and
+// refreshes the metrics
+func (a *Analyzer) runRound() {
+	cutoff := time.Now().Add(-a.opts.SessionIdle)
+
+	for {
+		sessions, err := a.db.QuietSessions(a.watermark, cutoff, sessionsPerRound)
+		if err != nil {
+			logrus.Errorf("Session integrity analysis failed: %v", err)
+			return
+		}
+
+		for _, session := range sessions {
+			if err := a.analyzeSession(session.SessionID); err != nil {
+				logrus.Errorf("Session integrity analysis failed for %s: %v", session.SessionID, err)
+				return
+			}
+			a.watermark = session.LastEventAt
+		}
+
+		if len(sessions) < sessionsPerRound {
+			break
+		}
+
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+	}
+	a.watermark = cutoff
+
+	a.refreshMetrics()
+}
+
+func (a *Analyzer) analyzeSession(sessionID string) error {
+	events, err := a.db.SessionSequence(sessionID)
+	if err != nil {
+		return err
+	}
+
+	report := Analyze(sessionID, events)
+	if report == nil {
+		return nil
+	}
+
+	if err := a.db.UpsertSessionIntegrity(report); err != nil {
+		return err
+	}
+	a.metrics.SessionsAnalyzed.Inc()
+
+	if report.MissingEvents > 0 || report.ReplayedEvents > 0 {
+		logrus.Debugf("Session %s: %d of %d events missing, %d out of order, %d replayed",
+			sessionID, report.MissingEvents, report.ExpectedEvents, report.OutOfOrderEvents, report.ReplayedEvents)
+	}
+
+	return nil
+}
+
+// refreshMetrics recomputes the per-app-version gauges over the window
+func (a *Analyzer) refreshMetrics() {
+	aggregates, err := a.db.IntegrityByAppVersion(time.Now().Add(-a.opts.Window))
+	if err != nil {
+		logrus.Errorf("Failed to aggregate session integrity: %v", err)
+		return
+	}
+
+	for _, gauge := range []*prometheus.GaugeVec{
+		a.metrics.Sessions, a.metrics.ExpectedEvents, a.metrics.MissingEvents,
+		a.metrics.OutOfOrderEvents, a.metrics.ReplayedEvents, a.metrics.LossRate,
+	} {
+		gauge.Reset()
+	}
+
+	for _, agg := range aggregates {
+		a.metrics.Sessions.WithLabelValues(agg.AppVersion).Set(float64(agg.Sessions))
+		a.metrics.ExpectedEvents.WithLabelValues(agg.AppVersion).Set(float64(agg.ExpectedEvents))
+		a.metrics.MissingEvents.WithLabelValues(agg.AppVersion).Set(float64(agg.MissingEvents))
+		a.metrics.OutOfOrderEvents.WithLabelValues(agg.AppVersion).Set(float64(agg.OutOfOrderEvents))
+		a.metrics.ReplayedEvents.WithLabelValues(agg.AppVersion).Set(float64(agg.ReplayedEvents))
+		if agg.ExpectedEvents > 0 {
+			a.metrics.LossRate.WithLabelValues(agg.AppVersion).Set(float64(agg.MissingEvents) / float64(agg.ExpectedEvents))
+		}
+	}
+}
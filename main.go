@@ -2,11 +2,31 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"log-ingestion-server/archive"
 	"log-ingestion-server/auth"
+	"log-ingestion-server/blobstore"
+	"log-ingestion-server/clientconfig"
 	"log-ingestion-server/config"
 	"log-ingestion-server/database"
+	"log-ingestion-server/deadletter"
+	"log-ingestion-server/enrich"
 	"log-ingestion-server/handlers"
+	"log-ingestion-server/integrity"
 	"log-ingestion-server/middleware"
+	"log-ingestion-server/partition"
+	"log-ingestion-server/pipeline"
+	"log-ingestion-server/redact"
+	"log-ingestion-server/retention"
+	"log-ingestion-server/rollup"
+	"log-ingestion-server/sampling"
+	"log-ingestion-server/schema"
+	"log-ingestion-server/sqlite"
+	"log-ingestion-server/syslog"
+	"log-ingestion-server/taxonomy"
+	"log-ingestion-server/transform"
+	"log-ingestion-server/wal"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,11 +43,28 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
+	skipMigrations := flag.Bool("skip-migrations", false, "do not apply pending migrations at startup (same as DB_AUTO_MIGRATE=false)")
+	healthCheck := flag.Bool("health-check", false, "check the health endpoint of the server running on PORT and exit, for the container HEALTHCHECK")
+	flag.Parse()
+
+	if *healthCheck {
+		runHealthCheck(config.HealthCheckURL())
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logrus.Fatalf("Failed to load configuration: %v", err)
 	}
+	if *skipMigrations {
+		cfg.Database.AutoMigrate = false
+	}
 
 	// Setup logging
 	setupLogging(cfg.LogLevel)
@@ -35,27 +72,224 @@ func main() {
 	logrus.Infof("Starting Log Ingestion Server v%s", VERSION)
 	logrus.Infof("Environment: %s", cfg.Environment)
 
-	// Initialize database
-	db, err := database.NewDB(cfg)
+	// Open the log store. db is nil when it is not PostgreSQL, and the
+	// features that need PostgreSQL are disabled.
+	db, store, err := openStore(cfg)
 	if err != nil {
 		logrus.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
+	defer store.Close()
 
-	// Run database migrations
-	if err := db.RunMigrations(); err != nil {
-		logrus.Fatalf("Failed to run migrations: %v", err)
+	var registries registryStore = database.Builtins{}
+	if db != nil {
+		registries = db
+	} else {
+		disablePostgresFeatures(cfg)
 	}
 
 	// Initialize authentication service
-	authService := auth.NewAuthService(db)
+	authService := auth.NewAuthService(store)
 	if err := authService.InitializeAPIKeys(cfg.APIKeys); err != nil {
 		logrus.Fatalf("Failed to initialize API keys: %v", err)
 	}
 
+	// Open the write-ahead log, recovering segments from a previous run
+	var writeAheadLog *wal.WAL
+	if cfg.WAL.Enabled {
+		fsyncPolicy, err := wal.ParseFsyncPolicy(cfg.WAL.FsyncPolicy)
+		if err != nil {
+			logrus.Fatalf("Invalid WAL configuration: %v", err)
+		}
+
+		writeAheadLog, err = wal.Open(wal.Options{
+			Dir:           cfg.WAL.Dir,
+			SegmentSize:   int64(cfg.WAL.SegmentSizeMB) << 20,
+			MaxSize:       int64(cfg.WAL.MaxSizeMB) << 20,
+			Fsync:         fsyncPolicy,
+			FsyncInterval: cfg.WAL.FsyncInterval,
+		})
+		if err != nil {
+			logrus.Fatalf("Failed to open write-ahead log: %v", err)
+		}
+	}
+
+	// Keep logs the database refuses instead of dropping them
+	var deadLetter *deadletter.Writer
+	if cfg.WAL.DeadLetterDir != "" {
+		deadLetter, err = deadletter.Open(cfg.WAL.DeadLetterDir)
+		if err != nil {
+			logrus.Fatalf("Failed to open dead-letter directory: %v", err)
+		}
+	}
+
+	// Start the asynchronous ingest pipeline
+	ingestPipeline := pipeline.NewPipeline(store, writeAheadLog, deadLetter, cfg)
+
+	// Replay events accepted before the last shutdown or crash
+	if err := ingestPipeline.Recover(); err != nil {
+		logrus.Errorf("WAL recovery incomplete, will retry in background: %v", err)
+	}
+
+	ingestPipeline.Start()
+
+	// Load the event type taxonomy
+	eventTypes := taxonomy.NewRegistry(registries)
+	if err := eventTypes.Load(); err != nil {
+		logrus.Fatalf("Failed to load event types: %v", err)
+	}
+	eventTypes.StartRefresh(time.Minute)
+
+	// Load the properties schemas enforced per event name
+	schemaRegistry := schema.NewRegistry(registries)
+	if err := schemaRegistry.Load(); err != nil {
+		logrus.Fatalf("Failed to load event schemas: %v", err)
+	}
+	schemaRegistry.StartRefresh(time.Minute)
+
+	// Load the server-side sampling and drop rules
+	samplingRules := sampling.NewRegistry(registries)
+	if err := samplingRules.Load(); err != nil {
+		logrus.Fatalf("Failed to load sampling rules: %v", err)
+	}
+	samplingRules.StartRefresh(time.Minute)
+
+	// Load the event transformation rules
+	transformRules := transform.NewRegistry(registries)
+	if err := transformRules.Load(); err != nil {
+		logrus.Fatalf("Failed to load transform rules: %v", err)
+	}
+	transformRules.StartRefresh(time.Minute)
+
+	// Load the overrides of the configuration served to client SDKs
+	clientConfigs := clientconfig.NewRegistry(registries, clientconfig.Defaults{
+		MaxBatchSize:    cfg.MaxBatchSize,
+		FlushInterval:   cfg.SDK.FlushInterval,
+		RefreshInterval: cfg.SDK.RefreshInterval,
+	})
+	if err := clientConfigs.Load(); err != nil {
+		logrus.Fatalf("Failed to load client config overrides: %v", err)
+	}
+	clientConfigs.StartRefresh(time.Minute)
+
+	// Build the PII redaction rules
+	var redactor *redact.Redactor
+	if cfg.Redaction.Enabled {
+		redactor, err = redact.FromConfig(cfg.Redaction)
+		if err != nil {
+			logrus.Fatalf("Failed to configure redaction: %v", err)
+		}
+	}
+
+	// Set up user-agent and GeoIP enrichment
+	var enricher *enrich.Enricher
+	if cfg.Enrichment.Enabled {
+		var geo *enrich.GeoIP
+		if cfg.Enrichment.GeoIPPath != "" {
+			geo, err = enrich.OpenGeoIP(cfg.Enrichment.GeoIPPath)
+			if err != nil {
+				logrus.Fatalf("Failed to open GeoIP database: %v", err)
+			}
+			logrus.Infof("Loaded GeoIP database %s (%s)", cfg.Enrichment.GeoIPPath, geo.DatabaseType())
+			reloadGeoIPOnHangup(geo)
+		}
+		enricher = enrich.New(geo, cfg.Enrichment.DropIP)
+	}
+
+	// Analyze sequence numbers of sessions that have gone quiet
+	var integrityAnalyzer *integrity.Analyzer
+	if cfg.Integrity.Enabled {
+		integrityAnalyzer = integrity.NewAnalyzer(db, integrity.Options{
+			Interval:    cfg.Integrity.Interval,
+			SessionIdle: cfg.Integrity.SessionIdle,
+			Window:      cfg.Integrity.Window,
+		})
+		integrityAnalyzer.Start()
+	}
+
+	// Keep analytics_logs partitions ahead of incoming logs
+	var partitionMaintainer *partition.Maintainer
+	if cfg.Partition.Enabled {
+		partitionMaintainer = partition.NewMaintainer(db, partition.Options{
+			Interval:      cfg.Partition.Interval,
+			Premake:       cfg.Partition.Premake,
+			DetachAfter:   cfg.Partition.DetachAfter,
+			CheckInterval: cfg.Partition.CheckInterval,
+		})
+		partitionMaintainer.Start()
+	}
+
+	// Delete logs and server metrics past their retention
+	var retentionPurger *retention.Purger
+	if cfg.Retention.Enabled {
+		retentionPurger = retention.NewPurger(db, retention.Options{
+			Interval:               cfg.Retention.Interval,
+			BatchSize:              cfg.Retention.BatchSize,
+			ServerMetricsRetention: cfg.Retention.ServerMetricsRetention,
+			ArchiveAfter:           archiveAfter(cfg),
+		})
+		retentionPurger.Start()
+	}
+
+	// Roll up new logs into the minute, hour and day counts behind the metrics API
+	var rollupAggregator *rollup.Aggregator
+	if cfg.Rollup.Enabled {
+		rollupAggregator = rollup.NewAggregator(db, rollup.Options{
+			Interval:        cfg.Rollup.Interval,
+			MinuteRetention: cfg.Rollup.MinuteRetention,
+			HourRetention:   cfg.Rollup.HourRetention,
+		})
+		rollupAggregator.Start()
+	}
+
+	// Move logs past their hot retention to Parquet files in the archive store
+	var archiver *archive.Archiver
+	if cfg.Archive.Enabled {
+		store, err := blobstore.FromConfig(cfg.Archive)
+		if err != nil {
+			logrus.Fatalf("Failed to open archive store: %v", err)
+		}
+		archiver = archive.NewArchiver(db, store, archive.Options{
+			After:          cfg.Archive.After,
+			Interval:       cfg.Archive.Interval,
+			MaxRowsPerFile: cfg.Archive.MaxRowsPerFile,
+			Prefix:         cfg.Archive.Prefix,
+		})
+		archiver.Start()
+	}
+
 	// Initialize handlers
-	ingestHandler := handlers.NewIngestHandler(db)
-	healthHandler := handlers.NewHealthHandler(db, VERSION)
+	ingestHandler := handlers.NewIngestHandler(store, ingestPipeline, cfg, handlers.IngestOptions{
+		EventTypes: eventTypes,
+		Schemas:    schemaRegistry,
+		Redactor:   redactor,
+		Enricher:   enricher,
+		Sampling:   samplingRules,
+		Transforms: transformRules,
+	})
+	healthHandler := handlers.NewHealthHandler(store, VERSION)
+	walHandler := handlers.NewWALHandler(writeAheadLog)
+	schemaHandler := handlers.NewSchemaHandler(db, schemaRegistry)
+	eventTypeHandler := handlers.NewEventTypeHandler(db, eventTypes)
+	samplingRuleHandler := handlers.NewSamplingRuleHandler(db, samplingRules)
+	clientConfigHandler := handlers.NewClientConfigHandler(db, clientConfigs)
+	transformRuleHandler := handlers.NewTransformRuleHandler(db, transformRules)
+	retentionHandler := handlers.NewRetentionHandler(db, archiveAfter(cfg))
+	rollupHandler := handlers.NewRollupHandler(db)
+	sessionHandler := handlers.NewSessionHandler(db)
+
+	// Optionally accept syslog from devices that cannot speak HTTP
+	var syslogServer *syslog.Server
+	if cfg.Syslog.Enabled {
+		syslogServer = syslog.NewServer(syslog.Options{
+			UDPAddr:        cfg.Syslog.UDPAddr,
+			TCPAddr:        cfg.Syslog.TCPAddr,
+			MaxMessageSize: cfg.Syslog.MaxMessageSizeKB << 10,
+			BatchSize:      cfg.MaxBatchSize,
+		}, ingestHandler)
+		if err := syslogServer.Start(); err != nil {
+			logrus.Fatalf("Failed to start syslog listener: %v", err)
+		}
+	}
 
 	// Setup Gin
 	gin.SetMode(cfg.GinMode)
@@ -67,7 +301,12 @@ func main() {
 	router.Use(middleware.SecurityHeadersMiddleware())
 	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.TimeoutMiddleware(cfg.RequestTimeout))
-	router.Use(middleware.RequestSizeLimit(cfg.MaxRequestSizeMB))
+	// Streaming uploads get their own, larger body limit
+	sizeOverrides := map[string]int{
+		"/api/v1/stream-ingest": cfg.StreamMaxRequestSizeMB,
+	}
+	router.Use(middleware.RequestSizeLimit(cfg.MaxRequestSizeMB, sizeOverrides))
+	router.Use(middleware.DecompressionMiddleware(cfg.MaxRequestSizeMB, sizeOverrides))
 	
 	if cfg.EnableCORS {
 		router.Use(middleware.CORSMiddleware(cfg.AllowedOrigins))
@@ -86,19 +325,80 @@ func main() {
 		router.GET(cfg.MetricsPath, gin.WrapH(promhttp.Handler()))
 	}
 
+	// Replay responses for retried ingest requests carrying an Idempotency-Key
+	idempotency := func(c *gin.Context) { c.Next() }
+	if db != nil {
+		idempotency = middleware.IdempotencyMiddleware(db, cfg.IdempotencyTTL, cfg.IdempotencyLease)
+		middleware.StartIdempotencyCleanup(db, time.Hour)
+	}
+
 	// API v1 routes with authentication
 	v1 := router.Group("/api/v1")
 	v1.Use(authService.AuthMiddleware())
 	{
 		// Log ingestion endpoints
-		v1.POST("/ingest", ingestHandler.IngestSingle)
-		v1.POST("/batch-ingest", ingestHandler.IngestBatch)
+		v1.POST("/ingest", idempotency, ingestHandler.IngestSingle)
+		v1.POST("/batch-ingest", idempotency, ingestHandler.IngestBatch)
+		v1.POST("/stream-ingest", ingestHandler.IngestStream)
 
 		// Admin/monitoring endpoints
-		v1.GET("/status", healthHandler.GetStatus)
-		v1.GET("/metrics", ingestHandler.GetMetrics)
-		v1.GET("/logs/recent", ingestHandler.GetRecentLogs)
-		v1.GET("/logs/filter", ingestHandler.GetFilteredLogs)
+		compress := middleware.CompressionMiddleware()
+		v1.GET("/status", compress, healthHandler.GetStatus)
+		v1.GET("/metrics", compress, ingestHandler.GetMetrics)
+		v1.GET("/logs/recent", compress, ingestHandler.GetRecentLogs)
+		v1.GET("/logs/filter", compress, ingestHandler.GetFilteredLogs)
+		v1.GET("/client-config", clientConfigHandler.GetClientConfig)
+
+		admin := v1.Group("/admin")
+		admin.GET("/wal", walHandler.GetStatus)
+
+		// Rollups, session integrity and the admin tables only exist in PostgreSQL
+		if db != nil {
+			v1.GET("/metrics/series", compress, rollupHandler.GetSeries)
+			v1.GET("/metrics/uniques", compress, rollupHandler.GetUniques)
+			v1.GET("/sessions/:id/integrity", compress, sessionHandler.GetIntegrity)
+
+			admin.POST("/schemas", schemaHandler.RegisterSchema)
+			admin.GET("/schemas", schemaHandler.ListSchemas)
+			admin.GET("/schemas/:event_name", schemaHandler.GetSchemas)
+			admin.DELETE("/schemas/:event_name/:version", schemaHandler.DeactivateSchema)
+			admin.GET("/event-types", eventTypeHandler.ListEventTypes)
+			admin.PUT("/event-types/:name", eventTypeHandler.UpsertEventType)
+			admin.DELETE("/event-types/:name", eventTypeHandler.DeactivateEventType)
+			admin.GET("/sampling-rules", samplingRuleHandler.ListSamplingRules)
+			admin.POST("/sampling-rules", samplingRuleHandler.CreateSamplingRule)
+			admin.PUT("/sampling-rules/:id", samplingRuleHandler.UpdateSamplingRule)
+			admin.DELETE("/sampling-rules/:id", samplingRuleHandler.DeleteSamplingRule)
+			admin.GET("/transform-rules", transformRuleHandler.ListTransformRules)
+			admin.POST("/transform-rules", transformRuleHandler.CreateTransformRule)
+			admin.POST("/transform-rules/dry-run", transformRuleHandler.DryRun)
+			admin.PUT("/transform-rules/:id", transformRuleHandler.UpdateTransformRule)
+			admin.DELETE("/transform-rules/:id", transformRuleHandler.DeleteTransformRule)
+			admin.GET("/client-config/overrides", clientConfigHandler.ListOverrides)
+			admin.POST("/client-config/overrides", clientConfigHandler.CreateOverride)
+			admin.PUT("/client-config/overrides/:id", clientConfigHandler.UpdateOverride)
+			admin.DELETE("/client-config/overrides/:id", clientConfigHandler.DeleteOverride)
+			admin.GET("/retention/policies", retentionHandler.ListPolicies)
+			admin.POST("/retention/policies", retentionHandler.CreatePolicy)
+			admin.PUT("/retention/policies/:id", retentionHandler.UpdatePolicy)
+			admin.DELETE("/retention/policies/:id", retentionHandler.DeletePolicy)
+			admin.GET("/retention/runs", retentionHandler.ListRuns)
+		}
+	}
+
+	// API v2 routes with authentication
+	v2 := router.Group("/api/v2")
+	v2.Use(authService.AuthMiddleware())
+	{
+		// Batch ingestion with per-item results
+		v2.POST("/batch-ingest", idempotency, ingestHandler.IngestBatchPartial)
+	}
+
+	// OpenTelemetry OTLP/HTTP logs receiver
+	otlpRoutes := router.Group("/v1")
+	otlpRoutes.Use(authService.AuthMiddleware())
+	{
+		otlpRoutes.POST("/logs", ingestHandler.IngestOTLPLogs)
 	}
 
 	// Create HTTP server
@@ -136,9 +436,123 @@ func main() {
 		logrus.Errorf("Server forced to shutdown: %v", err)
 	}
 
+	if syslogServer != nil {
+		if err := syslogServer.Shutdown(ctx); err != nil {
+			logrus.Errorf("Syslog listener did not stop cleanly: %v", err)
+		}
+	}
+
+	if integrityAnalyzer != nil {
+		integrityAnalyzer.Stop()
+	}
+
+	if partitionMaintainer != nil {
+		partitionMaintainer.Stop()
+	}
+
+	if retentionPurger != nil {
+		retentionPurger.Stop()
+	}
+
+	if rollupAggregator != nil {
+		rollupAggregator.Stop()
+	}
+
+	if archiver != nil {
+		archiver.Stop()
+	}
+
+	// Flush everything accepted before the listener closed
+	if err := ingestPipeline.Shutdown(ctx); err != nil {
+		logrus.Errorf("Ingest pipeline did not drain before shutdown: %v", err)
+	}
+
 	logrus.Info("Server exited")
 }
 
+// registryStore loads what the registries cache: the admin tables in
+// PostgreSQL, or database.Builtins for the other storage backends
+type registryStore interface {
+	taxonomy.Store
+	schema.Store
+	sampling.Store
+	transform.Store
+	clientconfig.Store
+}
+
+// openStore opens the configured log store, returning it also as a
+// *database.DB when it is PostgreSQL, after running migrations
+func openStore(cfg *config.Config) (*database.DB, database.Store, error) {
+	switch cfg.Storage.Backend {
+	case "memory":
+		logrus.Warn("Using the in-memory store: logs are lost when the server stops")
+		return nil, database.NewMemoryStore(), nil
+	case "sqlite":
+		store, err := sqlite.Open(cfg.Storage.SQLitePath)
+		return nil, store, err
+	}
+
+	db, err := database.NewDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !cfg.Database.AutoMigrate {
+		warnPendingMigrations(db)
+		return db, db, nil
+	}
+
+	// Run database migrations
+	if err := db.RunMigrations(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, db, nil
+}
+
+// warnPendingMigrations logs when the schema is behind the binary, for
+// deploys that migrate as a separate step
+func warnPendingMigrations(db *database.DB) {
+	status, err := db.GetMigrationStatus()
+	if err != nil {
+		logrus.Warnf("Skipping migrations, and could not check the schema version: %v", err)
+		return
+	}
+
+	switch {
+	case status.Dirty:
+		logrus.Warnf("Skipping migrations, but migration %d failed part way; run `migrate status`", status.Version)
+	case status.Pending() > 0:
+		logrus.Warnf("Skipping migrations with %d pending (schema at version %d, latest %d); run `migrate up`",
+			status.Pending(), status.Version, status.Latest)
+	default:
+		logrus.Infof("Skipping migrations, schema is up to date at version %d", status.Version)
+	}
+}
+
+// archiveAfter returns how old logs are when they are archived, or zero when
+// the archiver is disabled
+func archiveAfter(cfg *config.Config) time.Duration {
+	if !cfg.Archive.Enabled {
+		return 0
+	}
+	return cfg.Archive.After
+}
+
+// disablePostgresFeatures turns off the background jobs that need
+// PostgreSQL when logs are kept elsewhere
+func disablePostgresFeatures(cfg *config.Config) {
+	cfg.Integrity.Enabled = false
+	cfg.Partition.Enabled = false
+	cfg.Retention.Enabled = false
+	cfg.Rollup.Enabled = false
+	cfg.Archive.Enabled = false
+
+	logrus.Warnf("Storage backend %s: background jobs, idempotency keys, metric series, session integrity and the admin API need PostgreSQL and are disabled",
+		cfg.Storage.Backend)
+}
+
 // setupLogging configures the logging system
 func setupLogging(logLevel string) {
 	level, err := logrus.ParseLevel(logLevel)
@@ -166,10 +580,26 @@ func printStartupInfo(cfg *config.Config) {
 	logrus.Infof("Version: %s", VERSION)
 	logrus.Infof("Port: %s", cfg.Port)
 	logrus.Infof("Environment: %s", cfg.Environment)
-	logrus.Infof("Database: %s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
+	switch cfg.Storage.Backend {
+	case "postgres":
+		logrus.Infof("Database: %s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
+	case "sqlite":
+		logrus.Infof("Storage: sqlite (%s)", cfg.Storage.SQLitePath)
+	default:
+		logrus.Infof("Storage: %s", cfg.Storage.Backend)
+	}
 	logrus.Infof("API Keys configured: %d", len(cfg.APIKeys))
 	logrus.Infof("Max batch size: %d", cfg.MaxBatchSize)
 	logrus.Infof("Worker pool size: %d", cfg.WorkerPoolSize)
+	logrus.Infof("Ingest queue size: %d (flush every %s)", cfg.IngestQueueSize, cfg.BatchTimeout)
+	logrus.Infof("Write-ahead log enabled: %t", cfg.WAL.Enabled)
+	logrus.Infof("Syslog listener enabled: %t", cfg.Syslog.Enabled)
+	logrus.Infof("PII redaction enabled: %t", cfg.Redaction.Enabled)
+	logrus.Infof("Enrichment enabled: %t (GeoIP: %t, drop IP: %t)", cfg.Enrichment.Enabled, cfg.Enrichment.GeoIPPath != "", cfg.Enrichment.DropIP)
+	logrus.Infof("Partition maintenance enabled: %t (%s)", cfg.Partition.Enabled, cfg.Partition.Interval)
+	logrus.Infof("Retention purger enabled: %t", cfg.Retention.Enabled)
+	logrus.Infof("Rollup aggregator enabled: %t", cfg.Rollup.Enabled)
+	logrus.Infof("Archiver enabled: %t", cfg.Archive.Enabled)
 	logrus.Infof("Rate limit: %d requests/minute", cfg.RateLimitRequestsPerMinute)
 	logrus.Infof("Metrics enabled: %t", cfg.EnableMetrics)
 	logrus.Infof("CORS enabled: %t", cfg.EnableCORS)
@@ -181,13 +611,34 @@ func printStartupInfo(cfg *config.Config) {
 	logrus.Info("Available endpoints:")
 	logrus.Info("  POST /api/v1/ingest - Single log ingestion")
 	logrus.Info("  POST /api/v1/batch-ingest - Batch log ingestion")
+	logrus.Info("  POST /api/v1/stream-ingest - NDJSON streaming ingestion")
 	logrus.Info("  GET /health - Health check")
 	logrus.Info("  GET /readiness - Readiness check")
 	logrus.Info("  GET /liveness - Liveness check")
 	logrus.Info("  GET /api/v1/status - Service status")
 	logrus.Info("  GET /api/v1/metrics - Analytics metrics")
+	logrus.Info("  GET /api/v1/metrics/series - Log counts per minute, hour or day")
+	logrus.Info("  GET /api/v1/metrics/uniques - Distinct users and sessions per minute, hour or day")
 	logrus.Info("  GET /api/v1/logs/recent - Recent logs")
 	logrus.Info("  GET /api/v1/logs/filter - Filtered logs with advanced search")
+	logrus.Info("  GET /api/v1/sessions/:id/integrity - Session sequence-number integrity")
+	logrus.Info("  GET /api/v1/client-config - Remote configuration for client SDKs")
+	logrus.Info("  GET /api/v1/admin/wal - Write-ahead log backlog")
+	logrus.Info("  POST /api/v1/admin/schemas - Register an event properties schema")
+	logrus.Info("  GET /api/v1/admin/schemas - List event properties schemas")
+	logrus.Info("  GET /api/v1/admin/event-types - List the event type taxonomy")
+	logrus.Info("  PUT /api/v1/admin/event-types/:name - Create or update an event type")
+	logrus.Info("  GET /api/v1/admin/sampling-rules - List sampling and drop rules")
+	logrus.Info("  POST /api/v1/admin/sampling-rules - Create a sampling or drop rule")
+	logrus.Info("  GET /api/v1/admin/transform-rules - List event transformation rules")
+	logrus.Info("  POST /api/v1/admin/transform-rules/dry-run - Preview transformation rules on a sample log")
+	logrus.Info("  GET /api/v1/admin/client-config/overrides - List client config overrides")
+	logrus.Info("  POST /api/v1/admin/client-config/overrides - Create a client config override")
+	logrus.Info("  GET /api/v1/admin/retention/policies - List retention policies")
+	logrus.Info("  POST /api/v1/admin/retention/policies - Create a retention policy")
+	logrus.Info("  GET /api/v1/admin/retention/runs - Recent retention runs and rows removed")
+	logrus.Info("  POST /api/v2/batch-ingest - Batch ingestion with per-item results")
+	logrus.Info("  POST /v1/logs - OpenTelemetry OTLP/HTTP logs")
 	
 	if cfg.EnableMetrics {
 		logrus.Infof("  GET %s - Prometheus metrics", cfg.MetricsPath)
@@ -195,3 +646,38 @@ func printStartupInfo(cfg *config.Config) {
 	
 	logrus.Info("========================================")
 }
+
+// reloadGeoIPOnHangup re-reads the GeoIP database whenever the process
+// receives SIGHUP, so an updated file can be swapped in without a restart
+func reloadGeoIPOnHangup(geo *enrich.GeoIP) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			if err := geo.Reload(); err != nil {
+				logrus.Errorf("Failed to reload GeoIP database, keeping the previous one: %v", err)
+				continue
+			}
+			logrus.Infof("Reloaded GeoIP database (%s)", geo.DatabaseType())
+		}
+	}()
+}
+
+// runHealthCheck requests the health endpoint of the local server and exits
+// non-zero unless it reports healthy. The runtime image has no shell or
+// curl, so the container HEALTHCHECK runs the binary with --health-check.
+func runHealthCheck(url string) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "health check failed: %v\n", err)
+		os.Exit(1)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "health check failed: %s returned %s\n", url, resp.Status)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log-ingestion-server/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// compressionMetrics holds Prometheus metrics shared by the compression middlewares
+type compressionMetrics struct {
+	Ratio       *prometheus.HistogramVec
+	Bytes       *prometheus.CounterVec
+	Unsupported *prometheus.CounterVec
+}
+
+var (
+	compressionMetricsOnce sync.Once
+	compressionStats       *compressionMetrics
+)
+
+func getCompressionMetrics() *compressionMetrics {
+	compressionMetricsOnce.Do(func() {
+		compressionStats = &compressionMetrics{
+			Ratio: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name:    "http_compression_ratio",
+					Help:    "Ratio of uncompressed to compressed body size",
+					Buckets: []float64{1, 1.5, 2, 3, 4, 6, 8, 12, 16, 32},
+				},
+				[]string{"direction", "encoding"},
+			),
+			Bytes: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "http_compression_bytes_total",
+					Help: "Total body bytes before and after compression",
+				},
+				[]string{"direction", "encoding", "form"},
+			),
+			Unsupported: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "http_unsupported_content_encoding_total",
+					Help: "Total number of requests rejected for an unsupported Content-Encoding",
+				},
+				[]string{"encoding"},
+			),
+		}
+
+		prometheus.MustRegister(
+			compressionStats.Ratio,
+			compressionStats.Bytes,
+			compressionStats.Unsupported,
+		)
+	})
+
+	return compressionStats
+}
+
+// observe records one compressed body
+func (m *compressionMetrics) observe(direction, encoding string, compressed, uncompressed int64) {
+	if compressed <= 0 {
+		return
+	}
+	m.Ratio.WithLabelValues(direction, encoding).Observe(float64(uncompressed) / float64(compressed))
+	m.Bytes.WithLabelValues(direction, encoding, "compressed").Add(float64(compressed))
+	m.Bytes.WithLabelValues(direction, encoding, "uncompressed").Add(float64(uncompressed))
+}
+
+// DecompressionMiddleware transparently decodes request bodies sent with
+// Content-Encoding gzip, deflate or zstd. The size limit, including per-route
+// overrides, applies to the decoded body, so small compressed payloads cannot
+// expand past it; reading past it returns a *BodyTooLargeError.
+func DecompressionMiddleware(maxSizeMB int, overridesMB map[string]int) gin.HandlerFunc {
+	metrics := getCompressionMetrics()
+
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		compressed := &countingReader{reader: c.Request.Body}
+
+		var decoded io.ReadCloser
+		var err error
+		switch encoding {
+		case "gzip", "x-gzip":
+			decoded, err = gzip.NewReader(compressed)
+		case "deflate":
+			decoded, err = newDeflateReader(compressed)
+		case "zstd":
+			decoded, err = newZstdReader(compressed)
+		default:
+			metrics.Unsupported.WithLabelValues(encoding).Inc()
+			c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+				Error:   "unsupported_content_encoding",
+				Message: fmt.Sprintf("Content-Encoding %q is not supported; use gzip, deflate or zstd", encoding),
+			})
+			c.Abort()
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_compressed_body",
+				Message: fmt.Sprintf("Request body is not valid %s data", encoding),
+			})
+			c.Abort()
+			return
+		}
+
+		limitMB := routeSizeLimit(c, maxSizeMB, overridesMB)
+		uncompressed := &countingReader{reader: limitBody(c, decoded, limitMB)}
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{uncompressed, decoded}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+
+		c.Next()
+
+		metrics.observe("request", encoding, compressed.n, uncompressed.n)
+	}
+}
+
+// newDeflateReader accepts both zlib-wrapped deflate, as specified for HTTP,
+// and the raw deflate streams some clients send instead
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// zstdMaxWindow bounds the memory a zstd frame can make the decoder allocate.
+// It covers frames compressed at every standard level; only long-distance
+// matching (zstd --long) needs more.
+const zstdMaxWindow = 8 << 20
+
+// newZstdReader decodes a zstd stream on the calling goroutine. The decoded
+// size is limited by the caller, like the other encodings.
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderMaxWindow(zstdMaxWindow),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		writer, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return writer
+	},
+}
+
+// CompressionMiddleware gzip-compresses responses for clients that send a
+// matching Accept-Encoding
+func CompressionMiddleware() gin.HandlerFunc {
+	metrics := getCompressionMetrics()
+
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+
+		if !acceptsEncoding(c.GetHeader("Accept-Encoding"), "gzip") || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		counter := &countingWriter{writer: c.Writer}
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(counter)
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, gzip: gz}
+		c.Writer = writer
+
+		defer func() {
+			if writer.started {
+				gz.Close()
+				metrics.observe("response", "gzip", counter.n, writer.uncompressed)
+			}
+			gzipWriterPool.Put(gz)
+		}()
+
+		c.Next()
+	}
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		return quality > 0
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body of responses that have one
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gzip         *gzip.Writer
+	started      bool
+	uncompressed int64
+}
+
+// start sets the encoding headers before the first body write
+func (w *gzipResponseWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if code != http.StatusNoContent && code != http.StatusNotModified {
+		w.start()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	w.start()
+	w.uncompressed += int64(len(data))
+	return w.gzip.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush pushes compressed data through to the client
+func (w *gzipResponseWriter) Flush() {
+	if w.started {
+		w.gzip.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
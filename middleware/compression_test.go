@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func gzipBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func zlibBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func rawDeflateBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// zstdBody compresses data as one frame declaring its size
+func zstdBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("failed to create zstd writer: %v", err)
+	}
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+// zstdStreamBody compresses data as a stream without a declared size
+func zstdStreamBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("failed to create zstd writer: %v", err)
+	}
+	io.Copy(w, bytes.NewReader(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+// decompressionRouter echoes the decoded request body, answering 413 when it
+// exceeds the size limit and 400 when it cannot be decoded
+func decompressionRouter(maxSizeMB int) *gin.Engine {
+	router := gin.New()
+	router.Use(DecompressionMiddleware(maxSizeMB, nil))
+	router.POST("/", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		var tooLarge *BodyTooLargeError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.Status(http.StatusRequestEntityTooLarge)
+		case err != nil:
+			c.Status(http.StatusBadRequest)
+		default:
+			c.Data(http.StatusOK, "application/json", body)
+		}
+	})
+	return router
+}
+
+func TestDecompressionMiddleware(t *testing.T) {
+	payload := []byte(`{"logs":[{"event_id":"e1","event_type":"behavioral","event_name":"x"}]}`)
+	oversized := bytes.Repeat([]byte("a"), 2*1024*1024)
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantCode int
+		wantBody []byte
+	}{
+		{name: "uncompressed", body: payload, wantCode: http.StatusOK, wantBody: payload},
+		{name: "identity", encoding: "identity", body: payload, wantCode: http.StatusOK, wantBody: payload},
+		{name: "gzip", encoding: "gzip", body: gzipBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "x-gzip", encoding: "x-gzip", body: gzipBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "encoding is case-insensitive", encoding: "GZIP", body: gzipBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "deflate with zlib wrapper", encoding: "deflate", body: zlibBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "raw deflate", encoding: "deflate", body: rawDeflateBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "zstd", encoding: "zstd", body: zstdBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "streamed zstd", encoding: "zstd", body: zstdStreamBody(t, payload), wantCode: http.StatusOK, wantBody: payload},
+		{name: "unsupported encoding", encoding: "br", body: payload, wantCode: http.StatusUnsupportedMediaType},
+		{name: "corrupt gzip", encoding: "gzip", body: []byte("not gzip"), wantCode: http.StatusBadRequest},
+		{name: "corrupt zstd", encoding: "zstd", body: []byte("not zstd"), wantCode: http.StatusBadRequest},
+		{name: "gzip expanding past the limit", encoding: "gzip", body: gzipBody(t, oversized), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "deflate expanding past the limit", encoding: "deflate", body: zlibBody(t, oversized), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "zstd expanding past the limit", encoding: "zstd", body: zstdBody(t, oversized), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "streamed zstd expanding past the limit", encoding: "zstd", body: zstdStreamBody(t, oversized), wantCode: http.StatusRequestEntityTooLarge},
+	}
+
+	router := decompressionRouter(1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantBody != nil && !bytes.Equal(w.Body.Bytes(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log-ingestion-server/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client's key
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayHeader marks responses replayed from a previous request
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyStore persists idempotency keys and their responses
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(apiKeyHash, key, requestHash string, lease time.Duration) (*models.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(apiKeyHash, key string, statusCode int, contentType string, body []byte, ttl time.Duration) error
+	ReleaseIdempotencyKey(apiKeyHash, key string) error
+	PurgeExpiredIdempotencyKeys() (int64, error)
+}
+
+// IdempotencyMiddleware honors the Idempotency-Key header. The first request
+// with a key is processed and its response stored per API key for ttl; retries
+// with the same key and body receive the stored response, and reusing the key
+// with a different body is rejected. While the first request runs, the key is
+// held for at most lease, so a request lost to a crash does not block its
+// retries for the whole ttl. It must run after authentication.
+func IdempotencyMiddleware(store IdempotencyStore, ttl, lease time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_idempotency_key",
+				Message: "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request_body",
+				Message: "Could not read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+		apiKeyHash := c.GetString("api_key_hash")
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, bodyBytes)
+
+		record, reserved, err := store.ReserveIdempotencyKey(apiKeyHash, key, requestHash, lease)
+		if err != nil {
+			// Ingestion must keep working when the key store is unavailable
+			logrus.Warnf("Idempotency check skipped: %v", err)
+			c.Next()
+			return
+		}
+
+		if !reserved {
+			replayIdempotentResponse(c, record, requestHash)
+			return
+		}
+
+		release := func() {
+			if err := store.ReleaseIdempotencyKey(apiKeyHash, key); err != nil {
+				logrus.Errorf("Failed to release idempotency key: %v", err)
+			}
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// A panicking handler stored nothing, so free the key for the retry
+		// and let the recovery middleware answer
+		completed := false
+		defer func() {
+			if !completed {
+				release()
+			}
+		}()
+		c.Next()
+		completed = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			// Nothing was stored, so a retry must be processed again
+			release()
+			return
+		}
+
+		contentType := recorder.Header().Get("Content-Type")
+		if err := store.CompleteIdempotencyKey(apiKeyHash, key, status, contentType, recorder.body.Bytes(), ttl); err != nil {
+			logrus.Errorf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// replayIdempotentResponse answers a request whose key was already used
+func replayIdempotentResponse(c *gin.Context, record *models.IdempotencyRecord, requestHash string) {
+	defer c.Abort()
+
+	if record.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "idempotency_key_reused",
+			Message: "Idempotency-Key was already used with a different request body",
+		})
+		return
+	}
+
+	if record.StatusCode == nil {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "idempotency_request_in_progress",
+			Message: "A request with this Idempotency-Key is still being processed",
+		})
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if record.ContentType != nil && *record.ContentType != "" {
+		contentType = *record.ContentType
+	}
+
+	c.Header(IdempotentReplayHeader, "true")
+	c.Data(*record.StatusCode, contentType, record.ResponseBody)
+}
+
+// StartIdempotencyCleanup periodically purges expired idempotency keys
+func StartIdempotencyCleanup(store IdempotencyStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := store.PurgeExpiredIdempotencyKeys()
+			if err != nil {
+				logrus.Errorf("Failed to purge idempotency keys: %v", err)
+				continue
+			}
+			if purged > 0 {
+				logrus.Debugf("Purged %d expired idempotency keys", purged)
+			}
+		}
+	}()
+}
+
+// hashRequest fingerprints a request so key reuse with a different body is detected
+func hashRequest(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder captures the response body while passing it through
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
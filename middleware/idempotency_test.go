@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"log-ingestion-server/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryIdempotencyStore keeps idempotency keys in a map, expiring them the
+// way the database does
+type memoryIdempotencyStore struct {
+	records map[string]*models.IdempotencyRecord
+	now     time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*models.IdempotencyRecord), now: time.Now()}
+}
+
+func (s *memoryIdempotencyStore) ReserveIdempotencyKey(apiKeyHash, key, requestHash string, lease time.Duration) (*models.IdempotencyRecord, bool, error) {
+	if record, ok := s.records[apiKeyHash+key]; ok && !record.ExpiresAt.Before(s.now) {
+		return record, false, nil
+	}
+	s.records[apiKeyHash+key] = &models.IdempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: s.now, ExpiresAt: s.now.Add(lease)}
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(apiKeyHash, key string, statusCode int, contentType string, body []byte, ttl time.Duration) error {
+	if record, ok := s.records[apiKeyHash+key]; ok && record.StatusCode == nil {
+		record.StatusCode = &statusCode
+		record.ContentType = &contentType
+		record.ResponseBody = body
+		record.ExpiresAt = s.now.Add(ttl)
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(apiKeyHash, key string) error {
+	if record, ok := s.records[apiKeyHash+key]; ok && record.StatusCode == nil {
+		delete(s.records, apiKeyHash+key)
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) PurgeExpiredIdempotencyKeys() (int64, error) {
+	return 0, nil
+}
+
+const (
+	testTTL   = 24 * time.Hour
+	testLease = time.Minute
+)
+
+// idempotencyRouter counts the requests that reach its handler, which answers
+// with the status in the X-Status header or panics when it is "panic"
+func idempotencyRouter(store IdempotencyStore, handled *int) *gin.Engine {
+	router := gin.New()
+	router.Use(ErrorHandlingMiddleware())
+	router.POST("/", IdempotencyMiddleware(store, testTTL, testLease), func(c *gin.Context) {
+		*handled++
+		switch c.GetHeader("X-Status") {
+		case "panic":
+			panic("handler failed")
+		case "500":
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
+		default:
+			c.JSON(http.StatusAccepted, gin.H{"n": *handled})
+		}
+	})
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, key, body, status string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	if status != "" {
+		req.Header.Set("X-Status", status)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	var handled int
+	router := idempotencyRouter(store, &handled)
+
+	first := sendIdempotent(router, "k1", `{"a":1}`, "")
+	retry := sendIdempotent(router, "k1", `{"a":1}`, "")
+
+	if handled != 1 {
+		t.Errorf("handler ran %d times, want 1", handled)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("retry missing %s header", IdempotentReplayHeader)
+	}
+	if record := store.records["k1"]; !record.ExpiresAt.Equal(store.now.Add(testTTL)) {
+		t.Errorf("stored response expires at %s, want after the TTL", record.ExpiresAt)
+	}
+
+	if w := sendIdempotent(router, "k1", `{"a":2}`, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reuse with a different body = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	var handled int
+	router := idempotencyRouter(store, &handled)
+
+	// A request holding the key, e.g. on a server that has since crashed
+	body := `{"a":1}`
+	if _, reserved, _ := store.ReserveIdempotencyKey("", "k1", hashRequest(http.MethodPost, "/", []byte(body)), testLease); !reserved {
+		t.Fatal("failed to reserve key")
+	}
+	if record := store.records["k1"]; !record.ExpiresAt.Equal(store.now.Add(testLease)) {
+		t.Fatalf("reservation expires at %s, want after the lease", record.ExpiresAt)
+	}
+
+	if w := sendIdempotent(router, "k1", body, ""); w.Code != http.StatusConflict {
+		t.Errorf("retry during the lease = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	store.now = store.now.Add(testLease + time.Second)
+	if w := sendIdempotent(router, "k1", body, ""); w.Code != http.StatusAccepted {
+		t.Errorf("retry after the lease = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	if handled != 1 {
+		t.Errorf("handler ran %d times, want 1", handled)
+	}
+}
+
+func TestIdempotencyReleasesFailedRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		wantCode int
+	}{
+		{name: "server error", status: "500", wantCode: http.StatusInternalServerError},
+		{name: "panic", status: "panic", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryIdempotencyStore()
+			var handled int
+			router := idempotencyRouter(store, &handled)
+
+			if w := sendIdempotent(router, "k1", `{"a":1}`, tt.status); w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if _, ok := store.records["k1"]; ok {
+				t.Fatal("key still reserved after the request failed")
+			}
+
+			if w := sendIdempotent(router, "k1", `{"a":1}`, ""); w.Code != http.StatusAccepted {
+				t.Errorf("retry = %d, want %d", w.Code, http.StatusAccepted)
+			}
+			if handled != 2 {
+				t.Errorf("handler ran %d times, want 2", handled)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log-ingestion-server/models"
@@ -16,25 +17,71 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// RequestSizeLimit creates middleware to limit request body size
-func RequestSizeLimit(maxSizeMB int) gin.HandlerFunc {
-	maxSize := int64(maxSizeMB * 1024 * 1024) // Convert MB to bytes
-	
+// RequestSizeLimit creates middleware to limit request body size. Routes in
+// overridesMB, keyed by route path, use their own limit instead.
+func RequestSizeLimit(maxSizeMB int, overridesMB map[string]int) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		limitMB := routeSizeLimit(c, maxSizeMB, overridesMB)
+		maxSize := int64(limitMB) * 1024 * 1024 // Convert MB to bytes
+
 		if c.Request.ContentLength > maxSize {
 			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
 				Error:   "request_too_large",
-				Message: fmt.Sprintf("Request body too large. Maximum size is %d MB", maxSizeMB),
+				Message: fmt.Sprintf("Request body too large. Maximum size is %d MB", limitMB),
 			})
 			c.Abort()
 			return
 		}
 		
-		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+		c.Request.Body = limitBody(c, c.Request.Body, limitMB)
 		c.Next()
 	}
 }
 
+// BodyTooLargeError is the error reading a request body returns once the
+// body passes its size limit. For compressed bodies the limit applies to the
+// decoded body. Handlers answer it with 413 Request Entity Too Large.
+type BodyTooLargeError struct {
+	LimitMB int
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body too large; maximum size is %d MB", e.LimitMB)
+}
+
+// limitBody limits a request body to limitMB. Reading past the limit returns
+// a *BodyTooLargeError and makes the server close the connection.
+func limitBody(c *gin.Context, body io.ReadCloser, limitMB int) io.ReadCloser {
+	return &limitedBody{
+		ReadCloser: http.MaxBytesReader(c.Writer, body, int64(limitMB)*1024*1024),
+		limitMB:    limitMB,
+	}
+}
+
+// limitedBody replaces the error of http.MaxBytesReader with a
+// *BodyTooLargeError
+type limitedBody struct {
+	io.ReadCloser
+	limitMB int
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = &BodyTooLargeError{LimitMB: b.limitMB}
+	}
+	return n, err
+}
+
+// routeSizeLimit returns the body size limit in MB for the matched route
+func routeSizeLimit(c *gin.Context, defaultMB int, overridesMB map[string]int) int {
+	if limitMB, ok := overridesMB[c.FullPath()]; ok {
+		return limitMB
+	}
+	return defaultMB
+}
+
 // RateLimitMiddleware creates a rate limiting middleware
 func RateLimitMiddleware(requestsPerMinute int, burst int) gin.HandlerFunc {
 	limiter := rate.NewLimiter(rate.Limit(requestsPerMinute)/60, burst) // Convert per minute to per second
@@ -109,8 +156,8 @@ func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 	}
 	
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key"}
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Content-Encoding", "Authorization", "X-API-Key", IdempotencyKeyHeader}
+	config.ExposeHeaders = []string{"Content-Length", IdempotentReplayHeader}
 	config.AllowCredentials = true
 	config.MaxAge = 12 * time.Hour
 	
@@ -193,12 +240,3 @@ func RequestValidationMiddleware() gin.HandlerFunc {
 func generateRequestID() string {
 	return fmt.Sprintf("req_%d", time.Now().UnixNano())
 }
-
-// CompressionMiddleware adds response compression
-func CompressionMiddleware() gin.HandlerFunc {
-	// This would typically use a compression library like gzip
-	// For now, we'll just return a placeholder
-	return func(c *gin.Context) {
-		c.Next()
-	}
-}
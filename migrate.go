@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `Usage: log-ingestion-server migrate <command>
+
+Commands:
+  up         apply every pending migration
+  down N     revert the last N applied migrations
+  goto V     apply or revert migrations until the schema is at version V
+  status     list the embedded migrations and which are applied
+  force V    record version V as applied and clear the dirty flag, without
+             running any migration (-1 records that none is applied)
+
+The database is configured by the same DB_* variables as the server.`
+
+// runMigrateCommand runs the migrate subcommand on the configured database
+// and exits
+func runMigrateCommand(args []string) {
+	run := parseMigrateCommand(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		migrateFatal("failed to load configuration: %v", err)
+	}
+	setupLogging(cfg.LogLevel)
+
+	if cfg.Storage.Backend != "postgres" {
+		migrateFatal("migrations apply to PostgreSQL only, STORAGE_BACKEND is %s", cfg.Storage.Backend)
+	}
+
+	db, err := database.NewDB(cfg)
+	if err != nil {
+		migrateFatal("failed to connect to database: %v", err)
+	}
+
+	err = run(db)
+	db.Close()
+	if err != nil {
+		migrateFatal("%v", err)
+	}
+}
+
+// parseMigrateCommand checks the command line before connecting, exiting
+// with the usage when it is wrong
+func parseMigrateCommand(args []string) func(db *database.DB) error {
+	if len(args) == 0 {
+		migrateUsageError("missing command")
+	}
+
+	switch command := args[0]; command {
+	case "up":
+		requireArgs(args, 1)
+		return (*database.DB).RunMigrations
+	case "down":
+		requireArgs(args, 2)
+		steps, err := strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			migrateUsageError("down needs a positive number of migrations")
+		}
+		return func(db *database.DB) error { return db.MigrateDown(steps) }
+	case "goto":
+		requireArgs(args, 2)
+		version, err := strconv.ParseUint(args[1], 10, 0)
+		if err != nil {
+			migrateUsageError("goto needs a migration version")
+		}
+		return func(db *database.DB) error { return db.MigrateTo(uint(version)) }
+	case "force":
+		requireArgs(args, 2)
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < -1 {
+			migrateUsageError("force needs a migration version, or -1")
+		}
+		return func(db *database.DB) error { return db.ForceMigrationVersion(version) }
+	case "status":
+		requireArgs(args, 1)
+		return printMigrationStatus
+	default:
+		migrateUsageError(fmt.Sprintf("unknown command %q", command))
+		return nil
+	}
+}
+
+// printMigrationStatus writes the schema version and each embedded
+// migration to stdout
+func printMigrationStatus(db *database.DB) error {
+	status, err := db.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+	for _, m := range status.Migrations {
+		state := "pending"
+		switch {
+		case m.Version == status.Version && status.Dirty:
+			state = "dirty"
+		case m.Applied:
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, state)
+	}
+	w.Flush()
+
+	fmt.Printf("\nSchema version: %d", status.Version)
+	if status.Dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Printf(", latest: %d, pending: %d\n", status.Latest, status.Pending())
+	return nil
+}
+
+// requireArgs exits with the usage unless the command has n arguments,
+// counting itself
+func requireArgs(args []string, n int) {
+	if len(args) != n {
+		migrateUsageError(fmt.Sprintf("%s takes %d argument(s)", args[0], n-1))
+	}
+}
+
+func migrateUsageError(message string) {
+	fmt.Fprintf(os.Stderr, "%s\n\n%s\n", message, migrateUsage)
+	os.Exit(2)
+}
+
+func migrateFatal(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, "migrate: "+format+"\n", v...)
+	os.Exit(1)
+}
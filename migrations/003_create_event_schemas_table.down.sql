@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_event_schemas_active;
+
+-- Drop tables
+DROP TABLE IF EXISTS event_schemas;
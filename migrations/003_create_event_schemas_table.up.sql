@@ -0,0 +1,14 @@
+-- Create table for JSON Schemas validating properties per event name
+CREATE TABLE IF NOT EXISTS event_schemas (
+    id BIGSERIAL PRIMARY KEY,
+    event_name VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    mode VARCHAR(16) NOT NULL CHECK (mode IN ('reject', 'warn', 'log')),
+    schema JSONB NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (event_name, version)
+);
+
+-- Create index for loading the enforced version of each event
+CREATE INDEX IF NOT EXISTS idx_event_schemas_active ON event_schemas(event_name, version DESC) WHERE is_active;
@@ -0,0 +1,19 @@
+-- Create table for the event type taxonomy
+CREATE TABLE IF NOT EXISTS event_types (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    default_priority VARCHAR(20) NOT NULL DEFAULT 'normal' CHECK (default_priority IN ('normal', 'high')),
+    retention_class VARCHAR(20) NOT NULL DEFAULT 'standard' CHECK (retention_class IN ('short', 'standard', 'long')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Seed the event types that were previously hardcoded
+INSERT INTO event_types (name, description, default_priority, retention_class) VALUES
+    ('behavioral', 'User interactions, habits completed, etc.', 'normal', 'long'),
+    ('telemetry', 'Service performance, API calls, etc.', 'normal', 'short'),
+    ('observability', 'Provider state changes, system events, OTLP service logs', 'normal', 'standard'),
+    ('error', 'Error tracking with context and stack traces', 'high', 'long'),
+    ('performance', 'Performance metrics and measurements', 'normal', 'short')
+ON CONFLICT (name) DO NOTHING;
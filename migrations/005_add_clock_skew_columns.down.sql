@@ -0,0 +1,8 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_analytics_logs_clock_skew_flagged;
+DROP INDEX IF EXISTS idx_analytics_logs_corrected_timestamp;
+
+-- Drop columns
+ALTER TABLE analytics_logs DROP COLUMN IF EXISTS clock_skew_flagged;
+ALTER TABLE analytics_logs DROP COLUMN IF EXISTS clock_skew_ms;
+ALTER TABLE analytics_logs DROP COLUMN IF EXISTS corrected_timestamp;
@@ -0,0 +1,8 @@
+-- Record the client clock skew observed at ingestion alongside the original timestamp
+ALTER TABLE analytics_logs ADD COLUMN IF NOT EXISTS corrected_timestamp TIMESTAMPTZ;
+ALTER TABLE analytics_logs ADD COLUMN IF NOT EXISTS clock_skew_ms BIGINT;
+ALTER TABLE analytics_logs ADD COLUMN IF NOT EXISTS clock_skew_flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create indexes for querying by corrected time and finding skewed devices
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_corrected_timestamp ON analytics_logs(corrected_timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_clock_skew_flagged ON analytics_logs(clock_skew_flagged) WHERE clock_skew_flagged;
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_analytics_logs_session_sequence;
+DROP INDEX IF EXISTS idx_session_integrity_app_version;
+DROP INDEX IF EXISTS idx_session_integrity_analyzed_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS session_integrity;
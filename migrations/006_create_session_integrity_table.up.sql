@@ -0,0 +1,22 @@
+-- Create table for per-session sequence-number integrity results
+CREATE TABLE IF NOT EXISTS session_integrity (
+    session_id VARCHAR(255) PRIMARY KEY,
+    app_version VARCHAR(50),
+    first_sequence INTEGER NOT NULL,
+    last_sequence INTEGER NOT NULL,
+    expected_events INTEGER NOT NULL,
+    received_events INTEGER NOT NULL,
+    missing_events INTEGER NOT NULL,
+    missing_ranges JSONB,
+    out_of_order_events INTEGER NOT NULL,
+    replayed_events INTEGER NOT NULL,
+    last_event_at TIMESTAMPTZ NOT NULL,
+    analyzed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for aggregating recent results per app version
+CREATE INDEX IF NOT EXISTS idx_session_integrity_analyzed_at ON session_integrity(analyzed_at);
+CREATE INDEX IF NOT EXISTS idx_session_integrity_app_version ON session_integrity(app_version);
+
+-- Create index for reading a session's events in arrival order
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_sequence ON analytics_logs(session_id, created_at, id) WHERE sequence_number IS NOT NULL;
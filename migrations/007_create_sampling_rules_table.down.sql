@@ -0,0 +1,5 @@
+-- Drop sample rate column
+ALTER TABLE analytics_logs DROP COLUMN IF EXISTS sample_rate;
+
+-- Drop table
+DROP TABLE IF EXISTS sampling_rules;
@@ -0,0 +1,19 @@
+-- Create table for server-side sampling and drop rules
+CREATE TABLE IF NOT EXISTS sampling_rules (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    event_type VARCHAR(50) NOT NULL DEFAULT '',
+    event_name VARCHAR(100) NOT NULL DEFAULT '',
+    app_version VARCHAR(50) NOT NULL DEFAULT '',
+    property TEXT NOT NULL DEFAULT '',
+    action VARCHAR(20) NOT NULL CHECK (action IN ('sample', 'drop', 'keep')),
+    keep_rate DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (keep_rate >= 0 AND keep_rate <= 1),
+    sample_by VARCHAR(20) NOT NULL DEFAULT 'session_id' CHECK (sample_by IN ('session_id', 'user_id')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Record the sample rate each stored log was kept at
+ALTER TABLE analytics_logs ADD COLUMN IF NOT EXISTS sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1;
@@ -0,0 +1,15 @@
+-- Create table for client configuration overrides targeted by app version and platform
+CREATE TABLE IF NOT EXISTS client_config_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    app_version VARCHAR(50) NOT NULL DEFAULT '',
+    platform VARCHAR(50) NOT NULL DEFAULT '',
+    sampling_rates JSONB NOT NULL DEFAULT '{}',
+    disabled_events JSONB NOT NULL DEFAULT '[]',
+    max_batch_size INTEGER CHECK (max_batch_size > 0),
+    flush_interval_seconds INTEGER CHECK (flush_interval_seconds > 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
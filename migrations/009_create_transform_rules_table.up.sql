@@ -0,0 +1,14 @@
+-- Create table for event transformation rules applied at ingestion
+CREATE TABLE IF NOT EXISTS transform_rules (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    event_type VARCHAR(50) NOT NULL DEFAULT '',
+    event_name VARCHAR(100) NOT NULL DEFAULT '',
+    min_app_version VARCHAR(50) NOT NULL DEFAULT '',
+    max_app_version VARCHAR(50) NOT NULL DEFAULT '',
+    operations JSONB NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
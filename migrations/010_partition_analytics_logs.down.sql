@@ -0,0 +1,57 @@
+-- Convert analytics_logs back to a single table. Only logs in attached
+-- partitions are kept; detached partitions are left as standalone tables.
+ALTER TABLE analytics_logs RENAME TO analytics_logs_partitioned;
+ALTER SEQUENCE analytics_logs_id_seq OWNED BY NONE;
+
+CREATE TABLE analytics_logs (
+    id BIGINT NOT NULL DEFAULT nextval('analytics_logs_id_seq'),
+    event_id VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    event_type VARCHAR(50) NOT NULL,
+    event_name VARCHAR(100) NOT NULL,
+    properties JSONB,
+    user_id VARCHAR(255),
+    session_id VARCHAR(255),
+    app_version VARCHAR(50),
+    device_info JSONB,
+    sequence_number INTEGER,
+    priority VARCHAR(20) DEFAULT 'normal',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ,
+    corrected_timestamp TIMESTAMPTZ,
+    clock_skew_ms BIGINT,
+    clock_skew_flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1
+);
+
+INSERT INTO analytics_logs (
+    id, event_id, timestamp, event_type, event_name, properties, user_id, session_id,
+    app_version, device_info, sequence_number, priority, created_at, processed_at,
+    corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate
+)
+SELECT
+    id, event_id, timestamp, event_type, event_name, properties, user_id, session_id,
+    app_version, device_info, sequence_number, priority, created_at, processed_at,
+    corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate
+FROM analytics_logs_partitioned;
+
+DROP TABLE analytics_logs_partitioned;
+ALTER SEQUENCE analytics_logs_id_seq OWNED BY analytics_logs.id;
+
+ALTER TABLE analytics_logs ADD PRIMARY KEY (id);
+ALTER TABLE analytics_logs ADD CONSTRAINT analytics_logs_event_id_key UNIQUE (event_id);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_timestamp ON analytics_logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_type ON analytics_logs(event_type);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_name ON analytics_logs(event_name);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_user_id ON analytics_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_id ON analytics_logs(session_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_created_at ON analytics_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_priority ON analytics_logs(priority);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_properties_gin ON analytics_logs USING GIN(properties);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_device_info_gin ON analytics_logs USING GIN(device_info);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_corrected_timestamp ON analytics_logs(corrected_timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_clock_skew_flagged ON analytics_logs(clock_skew_flagged) WHERE clock_skew_flagged;
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_sequence ON analytics_logs(session_id, created_at, id) WHERE sequence_number IS NOT NULL;
+
+DROP TABLE IF EXISTS analytics_log_event_ids;
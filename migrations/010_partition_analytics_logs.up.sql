@@ -0,0 +1,84 @@
+-- Convert analytics_logs to declarative range partitioning on timestamp.
+-- Partitions are created and detached by the partition maintainer; rows
+-- outside every partition land in analytics_logs_default until it moves them.
+--
+-- The migration runs in one transaction and copies every existing log into
+-- the default partition, then rebuilds the indexes there. analytics_logs is
+-- renamed in the first statements, so it stays under an ACCESS EXCLUSIVE
+-- lock until the copy and the index builds commit: writes and reads of logs
+-- wait for the whole migration, which takes time in proportion to the table
+-- and needs disk for a second copy of it. On a large table, stop ingestion
+-- or run `migrate up` in a maintenance window. The partition maintainer then moves the copied logs out of the
+-- default partition a period at a time, each under a short lock of its own.
+
+-- Unique keys of a partitioned table must include the partition key, so
+-- event_id uniqueness is enforced by a companion table instead. Writers claim
+-- an event_id here in the same transaction as the log insert.
+CREATE TABLE IF NOT EXISTS analytics_log_event_ids (
+    event_id VARCHAR(255) PRIMARY KEY,
+    timestamp TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_log_event_ids_timestamp ON analytics_log_event_ids(timestamp);
+
+-- Keep the existing table aside, and its id sequence alive once it is dropped
+ALTER TABLE analytics_logs RENAME TO analytics_logs_unpartitioned;
+ALTER SEQUENCE analytics_logs_id_seq OWNED BY NONE;
+
+CREATE TABLE analytics_logs (
+    id BIGINT NOT NULL DEFAULT nextval('analytics_logs_id_seq'),
+    event_id VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    event_type VARCHAR(50) NOT NULL,
+    event_name VARCHAR(100) NOT NULL,
+    properties JSONB,
+    user_id VARCHAR(255),
+    session_id VARCHAR(255),
+    app_version VARCHAR(50),
+    device_info JSONB,
+    sequence_number INTEGER,
+    priority VARCHAR(20) DEFAULT 'normal',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ,
+    corrected_timestamp TIMESTAMPTZ,
+    clock_skew_ms BIGINT,
+    clock_skew_flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE analytics_logs_default PARTITION OF analytics_logs DEFAULT;
+
+-- Move the existing logs and claim their event IDs
+INSERT INTO analytics_log_event_ids (event_id, timestamp)
+SELECT event_id, timestamp FROM analytics_logs_unpartitioned;
+
+INSERT INTO analytics_logs (
+    id, event_id, timestamp, event_type, event_name, properties, user_id, session_id,
+    app_version, device_info, sequence_number, priority, created_at, processed_at,
+    corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate
+)
+SELECT
+    id, event_id, timestamp, event_type, event_name, properties, user_id, session_id,
+    app_version, device_info, sequence_number, priority, created_at, processed_at,
+    corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate
+FROM analytics_logs_unpartitioned;
+
+DROP TABLE analytics_logs_unpartitioned;
+ALTER SEQUENCE analytics_logs_id_seq OWNED BY analytics_logs.id;
+
+-- Recreate the constraints and indexes, now inherited by every partition
+ALTER TABLE analytics_logs ADD PRIMARY KEY (id, timestamp);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_id ON analytics_logs(event_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_timestamp ON analytics_logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_type ON analytics_logs(event_type);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_name ON analytics_logs(event_name);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_user_id ON analytics_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_id ON analytics_logs(session_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_created_at ON analytics_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_priority ON analytics_logs(priority);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_properties_gin ON analytics_logs USING GIN(properties);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_device_info_gin ON analytics_logs USING GIN(device_info);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_corrected_timestamp ON analytics_logs(corrected_timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_clock_skew_flagged ON analytics_logs(clock_skew_flagged) WHERE clock_skew_flagged;
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_sequence ON analytics_logs(session_id, created_at, id) WHERE sequence_number IS NOT NULL;
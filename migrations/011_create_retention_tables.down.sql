@@ -0,0 +1,3 @@
+-- Drop tables
+DROP TABLE IF EXISTS retention_runs;
+DROP TABLE IF EXISTS retention_policies;
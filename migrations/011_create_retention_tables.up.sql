@@ -0,0 +1,29 @@
+-- Create table for per-event retention policies
+CREATE TABLE IF NOT EXISTS retention_policies (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    event_type VARCHAR(50) NOT NULL DEFAULT '',
+    event_name VARCHAR(100) NOT NULL DEFAULT '',
+    priority VARCHAR(20) NOT NULL DEFAULT '' CHECK (priority IN ('', 'normal', 'high')),
+    retention_class VARCHAR(20) NOT NULL DEFAULT '' CHECK (retention_class IN ('', 'short', 'standard', 'long')),
+    retention_days INTEGER NOT NULL CHECK (retention_days > 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create table recording the runs of the retention job
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    instance VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('succeeded', 'failed', 'interrupted')),
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    rows_deleted BIGINT NOT NULL DEFAULT 0,
+    partitions_dropped INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    results JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started_at ON retention_runs(started_at);
@@ -0,0 +1,4 @@
+-- Drop tables
+DROP TABLE IF EXISTS log_rollup_state;
+DROP TABLE IF EXISTS log_rollup_sketches;
+DROP TABLE IF EXISTS log_rollups;
@@ -0,0 +1,31 @@
+-- Create table for log counts rolled up per minute, hour and day (UTC)
+CREATE TABLE IF NOT EXISTS log_rollups (
+    granularity VARCHAR(10) NOT NULL CHECK (granularity IN ('minute', 'hour', 'day')),
+    bucket TIMESTAMPTZ NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    event_name VARCHAR(100) NOT NULL,
+    app_version VARCHAR(50) NOT NULL DEFAULT '',
+    priority VARCHAR(20) NOT NULL DEFAULT 'normal',
+    log_count BIGINT NOT NULL DEFAULT 0,
+    estimated_count DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (granularity, bucket, event_type, event_name, app_version, priority)
+);
+
+-- Create table for HyperLogLog sketches of distinct users and sessions
+CREATE TABLE IF NOT EXISTS log_rollup_sketches (
+    granularity VARCHAR(10) NOT NULL CHECK (granularity IN ('minute', 'hour', 'day')),
+    bucket TIMESTAMPTZ NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    users BYTEA NOT NULL,
+    sessions BYTEA NOT NULL,
+    PRIMARY KEY (granularity, bucket, event_type)
+);
+
+-- Create table tracking how far analytics_logs has been rolled up, and how
+-- many of the logs rolled up are still stored
+CREATE TABLE IF NOT EXISTS log_rollup_state (
+    name VARCHAR(50) PRIMARY KEY,
+    watermark TIMESTAMPTZ NOT NULL,
+    log_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
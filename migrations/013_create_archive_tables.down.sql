@@ -0,0 +1,3 @@
+-- Drop tables
+DROP TABLE IF EXISTS analytics_logs_restored;
+DROP TABLE IF EXISTS archive_manifest;
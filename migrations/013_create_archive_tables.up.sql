@@ -0,0 +1,44 @@
+-- Create table listing the Parquet files logs were archived to
+CREATE TABLE IF NOT EXISTS archive_manifest (
+    id BIGSERIAL PRIMARY KEY,
+    object_key VARCHAR(1024) NOT NULL UNIQUE,
+    log_date DATE NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    row_count BIGINT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    min_timestamp TIMESTAMPTZ NOT NULL,
+    max_timestamp TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_manifest_log_date ON archive_manifest(log_date, event_type);
+
+-- Create table receiving logs restored from the archive. It is kept apart
+-- from analytics_logs so the archiver does not move restored logs straight
+-- back out.
+CREATE TABLE IF NOT EXISTS analytics_logs_restored (
+    id BIGINT NOT NULL,
+    event_id VARCHAR(255) NOT NULL UNIQUE,
+    timestamp TIMESTAMPTZ NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    event_name VARCHAR(100) NOT NULL,
+    properties JSONB,
+    user_id VARCHAR(255),
+    session_id VARCHAR(255),
+    app_version VARCHAR(50),
+    device_info JSONB,
+    sequence_number INTEGER,
+    priority VARCHAR(20) DEFAULT 'normal',
+    created_at TIMESTAMPTZ NOT NULL,
+    processed_at TIMESTAMPTZ,
+    corrected_timestamp TIMESTAMPTZ,
+    clock_skew_ms BIGINT,
+    clock_skew_flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1,
+    restored_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_restored_timestamp ON analytics_logs_restored(timestamp);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_restored_event_type ON analytics_logs_restored(event_type);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_restored_user_id ON analytics_logs_restored(user_id);
@@ -25,35 +25,94 @@ func (j *JSONB) Scan(value interface{}) error {
 		return nil
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, j)
+	case string:
+		// Drivers such as SQLite's return JSON kept as TEXT as a string
+		return json.Unmarshal([]byte(v), j)
+	default:
 		return fmt.Errorf("cannot scan %T into JSONB", value)
 	}
-
-	return json.Unmarshal(bytes, j)
 }
 
 // AnalyticsLog represents a single analytics log entry
 type AnalyticsLog struct {
-	ID             int64     `json:"id" db:"id"`
-	EventID        string    `json:"event_id" db:"event_id" validate:"required"`
-	Timestamp      time.Time `json:"timestamp" db:"timestamp" validate:"required"`
-	EventType      string    `json:"event_type" db:"event_type" validate:"required,oneof=behavioral telemetry observability error performance"`
-	EventName      string    `json:"event_name" db:"event_name" validate:"required"`
-	Properties     JSONB     `json:"properties" db:"properties"`
-	UserID         *string   `json:"user_id" db:"user_id"`
-	SessionID      *string   `json:"session_id" db:"session_id"`
-	AppVersion     *string   `json:"app_version" db:"app_version"`
-	DeviceInfo     JSONB     `json:"device_info" db:"device_info"`
-	SequenceNumber *int      `json:"sequence_number" db:"sequence_number"`
-	Priority       string    `json:"priority" db:"priority" validate:"oneof=normal high"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	// Length limits match the analytics_logs column widths, so a log that
+	// passes validation cannot fail its insert on them
+	ID             int64      `json:"id" db:"id"`
+	EventID        string     `json:"event_id" db:"event_id" validate:"required,max=255"`
+	Timestamp      time.Time  `json:"timestamp" db:"timestamp" validate:"required"`
+	EventType      string     `json:"event_type" db:"event_type" validate:"required,max=50,event_type"`
+	EventName      string     `json:"event_name" db:"event_name" validate:"required,max=100"`
+	Properties     JSONB      `json:"properties" db:"properties"`
+	UserID         *string    `json:"user_id" db:"user_id" validate:"omitempty,max=255"`
+	SessionID      *string    `json:"session_id" db:"session_id" validate:"omitempty,max=255"`
+	AppVersion     *string    `json:"app_version" db:"app_version" validate:"omitempty,max=50"`
+	DeviceInfo     JSONB      `json:"device_info" db:"device_info"`
+	SequenceNumber *int       `json:"sequence_number" db:"sequence_number"`
+	Priority       string     `json:"priority" db:"priority" validate:"oneof=normal high"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	ProcessedAt    *time.Time `json:"processed_at" db:"processed_at"`
+
+	// Computed by the server at ingestion from the request's sent-at time
+	CorrectedTimestamp *time.Time `json:"corrected_timestamp" db:"corrected_timestamp"`
+	ClockSkewMs        *int64     `json:"clock_skew_ms" db:"clock_skew_ms"`
+	ClockSkewFlagged   bool       `json:"clock_skew_flagged" db:"clock_skew_flagged"`
+
+	// Fraction of matching logs kept by server-side sampling; weight stored
+	// rows by 1/sample_rate to estimate the original volume
+	SampleRate float64 `json:"sample_rate" db:"sample_rate"`
 }
 
 // BatchRequest represents a batch of analytics logs
 type BatchRequest struct {
-	Logs []AnalyticsLog `json:"logs" validate:"required,dive"`
+	Logs   []AnalyticsLog `json:"logs" validate:"required,dive"`
+	SentAt *time.Time     `json:"sent_at"`
+}
+
+// Per-item statuses reported by partial-success batch ingestion
+const (
+	BatchItemAccepted  = "accepted"
+	BatchItemDuplicate = "duplicate"
+	BatchItemInvalid   = "invalid"
+	BatchItemDropped   = "dropped"
+)
+
+// BatchItemResult reports the outcome of one log in a partial-success batch
+type BatchItemResult struct {
+	Index   int               `json:"index"`
+	EventID string            `json:"event_id,omitempty"`
+	Status  string            `json:"status"`
+	Errors  []ValidationError `json:"errors,omitempty"`
+}
+
+// PartialBatchResponse summarizes a partial-success batch ingestion
+type PartialBatchResponse struct {
+	Accepted   int               `json:"accepted"`
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Dropped    int               `json:"dropped"`
+	Results    []BatchItemResult `json:"results"`
+}
+
+// StreamLineFailure reports a rejected line in an NDJSON stream
+type StreamLineFailure struct {
+	Line    int               `json:"line"`
+	EventID string            `json:"event_id,omitempty"`
+	Errors  []ValidationError `json:"errors"`
+}
+
+// StreamIngestSummary summarizes an NDJSON streaming ingestion
+type StreamIngestSummary struct {
+	LinesRead            int                 `json:"lines_read"`
+	Accepted             int                 `json:"accepted"`
+	Duplicates           int                 `json:"duplicates"`
+	Invalid              int                 `json:"invalid"`
+	Dropped              int                 `json:"dropped"`
+	CommittedThroughLine int                 `json:"committed_through_line"`
+	Failures             []StreamLineFailure `json:"failures"`
+	FailuresTruncated    bool                `json:"failures_truncated"`
 }
 
 // APIKey represents an API key in the database
@@ -68,6 +127,353 @@ type APIKey struct {
 	UsageCount  int64      `json:"usage_count" db:"usage_count"`
 }
 
+// IdempotencyRecord represents a remembered response for an Idempotency-Key
+type IdempotencyRecord struct {
+	Key          string    `json:"key" db:"idempotency_key"`
+	RequestHash  string    `json:"request_hash" db:"request_hash"`
+	StatusCode   *int      `json:"status_code" db:"status_code"`
+	ContentType  *string   `json:"content_type" db:"content_type"`
+	ResponseBody []byte    `json:"-" db:"response_body"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// Enforcement modes for event schemas
+const (
+	SchemaModeReject = "reject"
+	SchemaModeWarn   = "warn"
+	SchemaModeLog    = "log"
+)
+
+// SchemaViolationsProperty is the properties key listing schema violations on
+// logs accepted under warn mode
+const SchemaViolationsProperty = "_schema_violations"
+
+// EventSchema is a versioned JSON Schema for the properties of one event name
+type EventSchema struct {
+	ID        int64           `json:"id" db:"id"`
+	EventName string          `json:"event_name" db:"event_name"`
+	Version   int             `json:"version" db:"version"`
+	Mode      string          `json:"mode" db:"mode"`
+	Schema    json.RawMessage `json:"schema" db:"schema"`
+	IsActive  bool            `json:"is_active" db:"is_active"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// RegisterSchemaRequest registers a new version of an event schema. Version
+// defaults to one past the latest registered version.
+type RegisterSchemaRequest struct {
+	EventName string          `json:"event_name" validate:"required,max=255"`
+	Version   int             `json:"version" validate:"min=0"`
+	Mode      string          `json:"mode" validate:"required,oneof=reject warn log"`
+	Schema    json.RawMessage `json:"schema" validate:"required"`
+}
+
+// Retention classes an event type can belong to
+const (
+	RetentionShort    = "short"
+	RetentionStandard = "standard"
+	RetentionLong     = "long"
+)
+
+// EventType is one entry of the event type taxonomy. Inactive types are no
+// longer accepted at ingestion but remain queryable.
+type EventType struct {
+	Name            string    `json:"name" db:"name"`
+	Description     string    `json:"description" db:"description"`
+	DefaultPriority string    `json:"default_priority" db:"default_priority"`
+	RetentionClass  string    `json:"retention_class" db:"retention_class"`
+	IsActive        bool      `json:"is_active" db:"is_active"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UpsertEventTypeRequest creates or updates an event type
+type UpsertEventTypeRequest struct {
+	Description     string `json:"description" validate:"max=1000"`
+	DefaultPriority string `json:"default_priority" validate:"omitempty,oneof=normal high"`
+	RetentionClass  string `json:"retention_class" validate:"omitempty,oneof=short standard long"`
+	IsActive        *bool  `json:"is_active"`
+}
+
+// Sampling rule actions
+const (
+	SamplingActionSample = "sample"
+	SamplingActionDrop   = "drop"
+	SamplingActionKeep   = "keep"
+)
+
+// SamplingRule decides whether matching logs are stored. Rules are evaluated
+// in position order and the first match applies; empty match fields match
+// any log.
+type SamplingRule struct {
+	ID         int64     `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	Position   int       `json:"position" db:"position"`
+	EventType  string    `json:"event_type" db:"event_type"`
+	EventName  string    `json:"event_name" db:"event_name"`
+	AppVersion string    `json:"app_version" db:"app_version"`
+	Property   string    `json:"property" db:"property"`
+	Action     string    `json:"action" db:"action"`
+	KeepRate   float64   `json:"keep_rate" db:"keep_rate"`
+	SampleBy   string    `json:"sample_by" db:"sample_by"`
+	IsActive   bool      `json:"is_active" db:"is_active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SamplingRuleRequest creates or replaces a sampling rule
+type SamplingRuleRequest struct {
+	Name       string   `json:"name" validate:"required,max=100"`
+	Position   int      `json:"position" validate:"min=0"`
+	EventType  string   `json:"event_type" validate:"max=50"`
+	EventName  string   `json:"event_name" validate:"max=100"`
+	AppVersion string   `json:"app_version" validate:"max=50"`
+	Property   string   `json:"property" validate:"max=500"`
+	Action     string   `json:"action" validate:"required,oneof=sample drop keep"`
+	KeepRate   *float64 `json:"keep_rate" validate:"omitempty,gte=0,lte=1"`
+	SampleBy   string   `json:"sample_by" validate:"omitempty,oneof=session_id user_id"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// ClientConfigOverride adjusts the client configuration served to SDKs of
+// matching app versions and platforms. Matching overrides are applied in
+// position order; empty targeting fields match any client.
+type ClientConfigOverride struct {
+	ID                   int64              `json:"id" db:"id"`
+	Name                 string             `json:"name" db:"name"`
+	Position             int                `json:"position" db:"position"`
+	AppVersion           string             `json:"app_version" db:"app_version"`
+	Platform             string             `json:"platform" db:"platform"`
+	SamplingRates        map[string]float64 `json:"sampling_rates" db:"sampling_rates"`
+	DisabledEvents       []string           `json:"disabled_events" db:"disabled_events"`
+	MaxBatchSize         *int               `json:"max_batch_size" db:"max_batch_size"`
+	FlushIntervalSeconds *int               `json:"flush_interval_seconds" db:"flush_interval_seconds"`
+	IsActive             bool               `json:"is_active" db:"is_active"`
+	CreatedAt            time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// ClientConfigOverrideRequest creates or replaces a client config override
+type ClientConfigOverrideRequest struct {
+	Name                 string             `json:"name" validate:"required,max=100"`
+	Position             int                `json:"position" validate:"min=0"`
+	AppVersion           string             `json:"app_version" validate:"max=50"`
+	Platform             string             `json:"platform" validate:"max=50"`
+	SamplingRates        map[string]float64 `json:"sampling_rates" validate:"dive,keys,required,max=100,endkeys,gte=0,lte=1"`
+	DisabledEvents       []string           `json:"disabled_events" validate:"dive,required,max=100"`
+	MaxBatchSize         *int               `json:"max_batch_size" validate:"omitempty,min=1"`
+	FlushIntervalSeconds *int               `json:"flush_interval_seconds" validate:"omitempty,min=1,max=86400"`
+	IsActive             *bool              `json:"is_active"`
+}
+
+// ClientConfig is the configuration document served to client SDKs. Version
+// identifies its content and changes whenever the document does.
+type ClientConfig struct {
+	Version                string             `json:"version"`
+	SamplingRates          map[string]float64 `json:"sampling_rates"`
+	DisabledEvents         []string           `json:"disabled_events"`
+	MaxBatchSize           int                `json:"max_batch_size"`
+	FlushIntervalSeconds   int                `json:"flush_interval_seconds"`
+	RefreshIntervalSeconds int                `json:"refresh_interval_seconds"`
+}
+
+// Transform operations
+const (
+	TransformRenameEvent    = "rename_event"
+	TransformMove           = "move"
+	TransformDrop           = "drop"
+	TransformCoerce         = "coerce"
+	TransformDeriveDuration = "derive_duration"
+)
+
+// TransformOperation is one step of a transform rule. Paths are dotted keys
+// into properties, e.g. "cart.total".
+type TransformOperation struct {
+	Op string `json:"op" validate:"required,oneof=rename_event move drop coerce derive_duration"`
+	// From and To are the source and destination paths of move; To is also
+	// the new event name of rename_event and the output path of
+	// derive_duration
+	From string `json:"from,omitempty" validate:"max=200"`
+	To   string `json:"to,omitempty" validate:"max=200"`
+	// Path is the key dropped or coerced
+	Path string `json:"path,omitempty" validate:"max=200"`
+	// Type is the coerce target: string, int, float or bool
+	Type string `json:"type,omitempty" validate:"omitempty,oneof=string int float bool"`
+	// Start and End are the timestamp paths of derive_duration, and Unit its
+	// output unit: ms (default) or s
+	Start string `json:"start,omitempty" validate:"max=200"`
+	End   string `json:"end,omitempty" validate:"max=200"`
+	Unit  string `json:"unit,omitempty" validate:"omitempty,oneof=ms s"`
+}
+
+// TransformRule rewrites matching logs at ingestion. Rules are applied in
+// position order, each seeing the output of the previous ones; empty match
+// fields match any log. The app version range is [MinAppVersion,
+// MaxAppVersion).
+type TransformRule struct {
+	ID            int64                `json:"id" db:"id"`
+	Name          string               `json:"name" db:"name"`
+	Position      int                  `json:"position" db:"position"`
+	EventType     string               `json:"event_type" db:"event_type"`
+	EventName     string               `json:"event_name" db:"event_name"`
+	MinAppVersion string               `json:"min_app_version" db:"min_app_version"`
+	MaxAppVersion string               `json:"max_app_version" db:"max_app_version"`
+	Operations    []TransformOperation `json:"operations" db:"operations"`
+	IsActive      bool                 `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// TransformRuleRequest creates or replaces a transform rule
+type TransformRuleRequest struct {
+	Name          string               `json:"name" validate:"required,max=100"`
+	Position      int                  `json:"position" validate:"min=0"`
+	EventType     string               `json:"event_type" validate:"max=50"`
+	EventName     string               `json:"event_name" validate:"max=100"`
+	MinAppVersion string               `json:"min_app_version" validate:"max=50"`
+	MaxAppVersion string               `json:"max_app_version" validate:"max=50"`
+	Operations    []TransformOperation `json:"operations" validate:"required,min=1,max=50,dive"`
+	IsActive      *bool                `json:"is_active"`
+}
+
+// TransformDryRunRequest runs transform rules against a sample log. Rules
+// lists unsaved rules to try instead of the active ones.
+type TransformDryRunRequest struct {
+	Log   AnalyticsLog           `json:"log" validate:"-"`
+	Rules []TransformRuleRequest `json:"rules" validate:"max=50,dive"`
+}
+
+// TransformDryRunResult shows a log before and after the transform rules
+type TransformDryRunResult struct {
+	Before  AnalyticsLog           `json:"before"`
+	After   AnalyticsLog           `json:"after"`
+	Applied []TransformApplication `json:"applied"`
+}
+
+// TransformApplication reports a rule that matched a log and the operations
+// that could not be applied, which are skipped
+type TransformApplication struct {
+	Rule   string   `json:"rule"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// RetentionPolicy sets how long matching logs are kept. Each log follows the
+// first matching policy in position order; empty match fields match any log,
+// and logs matching no policy are kept forever.
+type RetentionPolicy struct {
+	ID             int64     `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	Position       int       `json:"position" db:"position"`
+	EventType      string    `json:"event_type" db:"event_type"`
+	EventName      string    `json:"event_name" db:"event_name"`
+	Priority       string    `json:"priority" db:"priority"`
+	RetentionClass string    `json:"retention_class" db:"retention_class"`
+	RetentionDays  int       `json:"retention_days" db:"retention_days"`
+	IsActive       bool      `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RetentionPolicyRequest creates or replaces a retention policy
+type RetentionPolicyRequest struct {
+	Name           string `json:"name" validate:"required,max=100"`
+	Position       int    `json:"position" validate:"min=0"`
+	EventType      string `json:"event_type" validate:"max=50"`
+	EventName      string `json:"event_name" validate:"max=100"`
+	Priority       string `json:"priority" validate:"omitempty,oneof=normal high"`
+	RetentionClass string `json:"retention_class" validate:"omitempty,oneof=short standard long"`
+	RetentionDays  int    `json:"retention_days" validate:"required,min=1,max=36500"`
+	IsActive       *bool  `json:"is_active"`
+}
+
+// Retention run statuses
+const (
+	RetentionRunSucceeded   = "succeeded"
+	RetentionRunFailed      = "failed"
+	RetentionRunInterrupted = "interrupted"
+)
+
+// RetentionRun records one run of the retention job
+type RetentionRun struct {
+	ID                int64             `json:"id" db:"id"`
+	Instance          string            `json:"instance" db:"instance"`
+	Status            string            `json:"status" db:"status"`
+	StartedAt         time.Time         `json:"started_at" db:"started_at"`
+	FinishedAt        time.Time         `json:"finished_at" db:"finished_at"`
+	RowsDeleted       int64             `json:"rows_deleted" db:"rows_deleted"`
+	PartitionsDropped int               `json:"partitions_dropped" db:"partitions_dropped"`
+	Error             string            `json:"error,omitempty" db:"error"`
+	Results           []RetentionResult `json:"results" db:"results"`
+}
+
+// RetentionResult reports what one policy, or the server_metrics retention,
+// removed in a run
+type RetentionResult struct {
+	Policy            string    `json:"policy"`
+	Table             string    `json:"table"`
+	Cutoff            time.Time `json:"cutoff"`
+	RowsDeleted       int64     `json:"rows_deleted"`
+	PartitionsDropped int       `json:"partitions_dropped,omitempty"`
+}
+
+// ArchiveFile is a Parquet file of archived logs, as listed in
+// archive_manifest. Each file holds logs of one event type and UTC day.
+type ArchiveFile struct {
+	ID           int64     `json:"id"`
+	ObjectKey    string    `json:"object_key"`
+	LogDate      time.Time `json:"log_date"`
+	EventType    string    `json:"event_type"`
+	RowCount     int64     `json:"row_count"`
+	SizeBytes    int64     `json:"size_bytes"`
+	SHA256       string    `json:"sha256"`
+	MinTimestamp time.Time `json:"min_timestamp"`
+	MaxTimestamp time.Time `json:"max_timestamp"`
+	ArchivedAt   time.Time `json:"archived_at"`
+}
+
+// SequencedEvent is one received log of a session, in arrival order
+type SequencedEvent struct {
+	EventID        string
+	SequenceNumber int
+	AppVersion     *string
+	CreatedAt      time.Time
+}
+
+// SequenceRange is an inclusive range of sequence numbers
+type SequenceRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// SessionIntegrity reports missing, out-of-order and replayed sequence
+// numbers within one session
+type SessionIntegrity struct {
+	SessionID        string          `json:"session_id" db:"session_id"`
+	AppVersion       *string         `json:"app_version" db:"app_version"`
+	FirstSequence    int             `json:"first_sequence" db:"first_sequence"`
+	LastSequence     int             `json:"last_sequence" db:"last_sequence"`
+	ExpectedEvents   int             `json:"expected_events" db:"expected_events"`
+	ReceivedEvents   int             `json:"received_events" db:"received_events"`
+	MissingEvents    int             `json:"missing_events" db:"missing_events"`
+	MissingRanges    []SequenceRange `json:"missing_ranges" db:"missing_ranges"`
+	OutOfOrderEvents int             `json:"out_of_order_events" db:"out_of_order_events"`
+	ReplayedEvents   int             `json:"replayed_events" db:"replayed_events"`
+	LossRate         float64         `json:"loss_rate"`
+	LastEventAt      time.Time       `json:"last_event_at" db:"last_event_at"`
+	AnalyzedAt       time.Time       `json:"analyzed_at" db:"analyzed_at"`
+}
+
+// IntegrityAggregate sums session integrity results for one app version
+type IntegrityAggregate struct {
+	AppVersion       string
+	Sessions         int64
+	ExpectedEvents   int64
+	MissingEvents    int64
+	OutOfOrderEvents int64
+	ReplayedEvents   int64
+}
+
 // ServerMetric represents a server metric entry
 type ServerMetric struct {
 	ID          int64     `json:"id" db:"id"`
@@ -108,6 +514,7 @@ type HealthStatus struct {
 
 // MetricsResponse represents the metrics response
 type MetricsResponse struct {
+	// TotalLogs counts the stored logs, up to AsOf when read from the rollups
 	TotalLogs       int64   `json:"total_logs"`
 	LogsLastHour    int64   `json:"logs_last_hour"`
 	LogsLastDay     int64   `json:"logs_last_day"`
@@ -115,6 +522,8 @@ type MetricsResponse struct {
 	ErrorRate       float64 `json:"error_rate_percent"`
 	ActiveSessions  int64   `json:"active_sessions"`
 	TopEventTypes   []EventTypeCount `json:"top_event_types"`
+	// AsOf is the creation time up to which logs are counted
+	AsOf            *time.Time       `json:"as_of"`
 }
 
 // EventTypeCount represents event type counts
@@ -123,6 +532,54 @@ type EventTypeCount struct {
 	Count     int64  `json:"count"`
 }
 
+// Rollup granularities
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+)
+
+// RollupPoint is the number of logs created in one bucket, for one group
+// when the series is grouped
+type RollupPoint struct {
+	Bucket         time.Time `json:"bucket"`
+	Group          string    `json:"group,omitempty"`
+	Count          int64     `json:"count"`
+	EstimatedCount float64   `json:"estimated_count"`
+}
+
+// RollupSeries is a time series of log counts
+type RollupSeries struct {
+	Granularity string        `json:"granularity"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	GroupBy     string        `json:"group_by,omitempty"`
+	AsOf        *time.Time    `json:"as_of"`
+	Points      []RollupPoint `json:"points"`
+}
+
+// UniquesPoint is the estimated number of distinct users and sessions in one
+// bucket
+type UniquesPoint struct {
+	Bucket   time.Time `json:"bucket"`
+	Users    int64     `json:"users"`
+	Sessions int64     `json:"sessions"`
+}
+
+// UniquesSeries is a time series of distinct users and sessions. Users and
+// Sessions count distinct values over the whole range, not the sum of the
+// points.
+type UniquesSeries struct {
+	Granularity string         `json:"granularity"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	EventType   string         `json:"event_type,omitempty"`
+	AsOf        *time.Time     `json:"as_of"`
+	Users       int64          `json:"users"`
+	Sessions    int64          `json:"sessions"`
+	Points      []UniquesPoint `json:"points"`
+}
+
 // FilteredLogsResponse represents the response for filtered logs
 type FilteredLogsResponse struct {
 	Logs       []AnalyticsLog `json:"logs"`
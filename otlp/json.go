@@ -0,0 +1,319 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// JSON mirrors of the OTLP messages, following the protobuf JSON mapping:
+// lowerCamelCase names, 64-bit integers as strings or numbers, trace and
+// span IDs as hex and enums as names or numbers
+
+type jsonRequest struct {
+	ResourceLogs []jsonResourceLogs `json:"resourceLogs"`
+}
+
+type jsonResourceLogs struct {
+	Resource struct {
+		Attributes []jsonKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []jsonScopeLogs `json:"scopeLogs"`
+}
+
+type jsonScopeLogs struct {
+	Scope      Scope           `json:"scope"`
+	LogRecords []jsonLogRecord `json:"logRecords"`
+}
+
+type jsonLogRecord struct {
+	TimeUnixNano         jsonInt        `json:"timeUnixNano"`
+	ObservedTimeUnixNano jsonInt        `json:"observedTimeUnixNano"`
+	SeverityNumber       jsonSeverity   `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 *jsonAnyValue  `json:"body"`
+	Attributes           []jsonKeyValue `json:"attributes"`
+	Flags                uint32         `json:"flags"`
+	TraceID              string         `json:"traceId"`
+	SpanID               string         `json:"spanId"`
+	EventName            string         `json:"eventName"`
+}
+
+type jsonKeyValue struct {
+	Key   string        `json:"key"`
+	Value *jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string       `json:"stringValue"`
+	BoolValue   *bool         `json:"boolValue"`
+	IntValue    *jsonInt      `json:"intValue"`
+	DoubleValue *jsonDouble   `json:"doubleValue"`
+	BytesValue  *string       `json:"bytesValue"`
+	ArrayValue  *jsonValues   `json:"arrayValue"`
+	KvlistValue *jsonKVValues `json:"kvlistValue"`
+}
+
+type jsonValues struct {
+	Values []jsonAnyValue `json:"values"`
+}
+
+type jsonKVValues struct {
+	Values []jsonKeyValue `json:"values"`
+}
+
+// jsonInt accepts a 64-bit integer encoded as a number or a string
+type jsonInt struct {
+	value int64
+	bits  uint64
+}
+
+func (i *jsonInt) UnmarshalJSON(data []byte) error {
+	text := string(bytes.Trim(data, `"`))
+	if text == "null" {
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+		i.bits, i.value = u, int64(u)
+		return nil
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	i.bits, i.value = uint64(v), v
+	return nil
+}
+
+// jsonDouble accepts a number or one of the strings NaN, Infinity and -Infinity
+type jsonDouble struct {
+	value interface{}
+}
+
+func (d *jsonDouble) UnmarshalJSON(data []byte) error {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		d.value = number
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid double %s", data)
+	}
+	switch text {
+	case "NaN", "Infinity", "-Infinity":
+		d.value = text
+		return nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid double %q", text)
+	}
+	d.value = finiteDouble(v)
+	return nil
+}
+
+// jsonSeverity accepts a SeverityNumber as its enum name or its number
+type jsonSeverity int32
+
+func (s *jsonSeverity) UnmarshalJSON(data []byte) error {
+	var number int32
+	if err := json.Unmarshal(data, &number); err == nil {
+		*s = jsonSeverity(number)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("invalid severityNumber %s", data)
+	}
+	number, ok := severityNames[name]
+	if !ok {
+		return fmt.Errorf("unknown severityNumber %q", name)
+	}
+	*s = jsonSeverity(number)
+	return nil
+}
+
+// severityNames maps SeverityNumber enum names to their values
+var severityNames = func() map[string]int32 {
+	names := map[string]int32{"SEVERITY_NUMBER_UNSPECIFIED": 0}
+	for i, level := range []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"} {
+		for j := int32(0); j < 4; j++ {
+			name := "SEVERITY_NUMBER_" + level
+			if j > 0 {
+				name += strconv.Itoa(int(j + 1))
+			}
+			names[name] = int32(i)*4 + j + 1
+		}
+	}
+	return names
+}()
+
+// UnmarshalJSON decodes a JSON-encoded ExportLogsServiceRequest
+func UnmarshalJSON(data []byte) (*Request, error) {
+	var decoded jsonRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid ExportLogsServiceRequest: %w", err)
+	}
+
+	request := &Request{ResourceLogs: make([]ResourceLogs, 0, len(decoded.ResourceLogs))}
+	for _, jrl := range decoded.ResourceLogs {
+		rl := ResourceLogs{ScopeLogs: make([]ScopeLogs, 0, len(jrl.ScopeLogs))}
+
+		resource, err := jsonAttributes(jrl.Resource.Attributes, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource attributes: %w", err)
+		}
+		rl.Resource = resource
+
+		for _, jsl := range jrl.ScopeLogs {
+			sl := ScopeLogs{Scope: jsl.Scope, LogRecords: make([]LogRecord, 0, len(jsl.LogRecords))}
+			for _, jr := range jsl.LogRecords {
+				record, err := jr.record()
+				if err != nil {
+					return nil, fmt.Errorf("invalid log record: %w", err)
+				}
+				sl.LogRecords = append(sl.LogRecords, record)
+			}
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+
+		request.ResourceLogs = append(request.ResourceLogs, rl)
+	}
+
+	return request, nil
+}
+
+// record converts a JSON log record
+func (jr jsonLogRecord) record() (LogRecord, error) {
+	record := LogRecord{
+		TimeUnixNano:         jr.TimeUnixNano.bits,
+		ObservedTimeUnixNano: jr.ObservedTimeUnixNano.bits,
+		SeverityNumber:       int32(jr.SeverityNumber),
+		SeverityText:         jr.SeverityText,
+		Flags:                jr.Flags,
+		EventName:            jr.EventName,
+	}
+
+	var err error
+	if record.TraceID, err = hex.DecodeString(jr.TraceID); err != nil {
+		return record, fmt.Errorf("invalid traceId: %w", err)
+	}
+	if record.SpanID, err = hex.DecodeString(jr.SpanID); err != nil {
+		return record, fmt.Errorf("invalid spanId: %w", err)
+	}
+	if len(record.TraceID) == 0 {
+		record.TraceID = nil
+	}
+	if len(record.SpanID) == 0 {
+		record.SpanID = nil
+	}
+
+	if jr.Body != nil {
+		if record.Body, err = jr.Body.value(0); err != nil {
+			return record, err
+		}
+	}
+
+	if record.Attributes, err = jsonAttributes(jr.Attributes, 0); err != nil {
+		return record, err
+	}
+
+	return record, nil
+}
+
+// jsonAttributes converts a KeyValue list, returning nil for an empty list
+func jsonAttributes(kvs []jsonKeyValue, depth int) (map[string]interface{}, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	attributes := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		var value interface{}
+		if kv.Value != nil {
+			v, err := kv.Value.value(depth)
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		attributes[kv.Key] = value
+	}
+	return attributes, nil
+}
+
+// value converts an AnyValue into a JSON-compatible value
+func (v *jsonAnyValue) value(depth int) (interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, errTooDeep
+	}
+
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, nil
+	case v.BoolValue != nil:
+		return *v.BoolValue, nil
+	case v.IntValue != nil:
+		return v.IntValue.value, nil
+	case v.DoubleValue != nil:
+		return v.DoubleValue.value, nil
+	case v.BytesValue != nil:
+		return *v.BytesValue, nil
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			item, err := v.ArrayValue.Values[i].value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, item)
+		}
+		return values, nil
+	case v.KvlistValue != nil:
+		values, err := jsonAttributes(v.KvlistValue.Values, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+// MarshalJSONResponse encodes an ExportLogsServiceResponse
+func MarshalJSONResponse(rejected int64, message string) []byte {
+	type partialSuccess struct {
+		RejectedLogRecords string `json:"rejectedLogRecords,omitempty"`
+		ErrorMessage       string `json:"errorMessage,omitempty"`
+	}
+	var response struct {
+		PartialSuccess *partialSuccess `json:"partialSuccess,omitempty"`
+	}
+
+	if rejected != 0 || message != "" {
+		response.PartialSuccess = &partialSuccess{ErrorMessage: message}
+		if rejected != 0 {
+			response.PartialSuccess.RejectedLogRecords = strconv.FormatInt(rejected, 10)
+		}
+	}
+
+	body, _ := json.Marshal(response)
+	return body
+}
+
+// MarshalJSONStatus encodes a google.rpc.Status for error responses
+func MarshalJSONStatus(code int32, message string) []byte {
+	body, _ := json.Marshal(struct {
+		Code    int32  `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}{code, message})
+	return body
+}
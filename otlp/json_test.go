@@ -0,0 +1,158 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// testJSON is testLogsData in the OTLP JSON encoding, written the way the
+// specification's examples are
+const testJSON = `{
+  "resourceLogs": [
+    {
+      "resource": {
+        "attributes": [
+          {"key": "service.name", "value": {"stringValue": "checkout"}},
+          {"key": "service.version", "value": {"stringValue": "2.4.0"}},
+          {"key": "host.cpus", "value": {"intValue": 8}}
+        ]
+      },
+      "scopeLogs": [
+        {
+          "scope": {"name": "checkout.payments", "version": "1.2.0"},
+          "logRecords": [
+            {
+              "timeUnixNano": "1700000000000000000",
+              "observedTimeUnixNano": 1700000000500000000,
+              "severityNumber": 17,
+              "severityText": "ERROR",
+              "body": {"stringValue": "payment failed"},
+              "attributes": [
+                {"key": "user.id", "value": {"stringValue": "user-1"}},
+                {"key": "retry", "value": {"boolValue": true}},
+                {"key": "amount", "value": {"doubleValue": 12.5}},
+                {"key": "tags", "value": {"arrayValue": {"values": [{"stringValue": "card"}, {"intValue": "-2"}]}}},
+                {"key": "unset"}
+              ],
+              "flags": 1,
+              "traceId": "5B8EFBF798B45A33116A3C698F2E0C41",
+              "spanId": "eb2f1a8e773ca001",
+              "eventName": "payment.failed",
+              "droppedAttributesCount": 0
+            },
+            {
+              "severityNumber": "SEVERITY_NUMBER_INFO2",
+              "body": {"kvlistValue": {"values": [
+                {"key": "message", "value": {"stringValue": "ok"}},
+                {"key": "empty", "value": {"kvlistValue": {}}}
+              ]}}
+            }
+          ]
+        }
+      ]
+    },
+    {"scopeLogs": [{"logRecords": [{"timeUnixNano": "1"}]}]}
+  ]
+}`
+
+func TestUnmarshalJSON(t *testing.T) {
+	got, err := UnmarshalJSON([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if want := testRequest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalJSON =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestUnmarshalJSONFromGeneratedTypes decodes the request as the protobuf
+// JSON mapping encodes it, with the hex IDs OTLP uses instead of base64
+func TestUnmarshalJSONFromGeneratedTypes(t *testing.T) {
+	data, err := protojson.Marshal(testLogsData())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	encoded := string(data)
+	for _, id := range [][]byte{testTraceID, testSpanID} {
+		base64ID := `"` + base64.StdEncoding.EncodeToString(id) + `"`
+		if !strings.Contains(encoded, base64ID) {
+			t.Fatalf("%s not found in %s", base64ID, encoded)
+		}
+		encoded = strings.Replace(encoded, base64ID, `"`+hex.EncodeToString(id)+`"`, 1)
+	}
+
+	got, err := UnmarshalJSON([]byte(encoded))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if want := testRequest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalJSON =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUnmarshalJSONValues(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want interface{}
+	}{
+		{name: "int as string", body: `{"intValue": "-9223372036854775808"}`, want: int64(-9223372036854775808)},
+		{name: "int as number", body: `{"intValue": 42}`, want: int64(42)},
+		{name: "double", body: `{"doubleValue": -0.5}`, want: -0.5},
+		{name: "double as string", body: `{"doubleValue": "1.5"}`, want: 1.5},
+		{name: "NaN", body: `{"doubleValue": "NaN"}`, want: "NaN"},
+		{name: "infinity", body: `{"doubleValue": "-Infinity"}`, want: "-Infinity"},
+		{name: "bytes", body: `{"bytesValue": "AQID"}`, want: "AQID"},
+		{name: "empty array", body: `{"arrayValue": {}}`, want: []interface{}{}},
+		{name: "empty value", body: `{}`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := UnmarshalJSON([]byte(`{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"body": ` + tt.body + `}]}]}]}`))
+			if err != nil {
+				t.Fatalf("UnmarshalJSON: %v", err)
+			}
+			if got := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	deep := `{"stringValue": "leaf"}`
+	for i := 0; i <= maxValueDepth+1; i++ {
+		deep = `{"arrayValue": {"values": [` + deep + `]}}`
+	}
+
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{name: "trace ID not hex", record: `{"traceId": "W477+Ji0WjMRajxpjy4MQQ=="}`},
+		{name: "span ID not hex", record: `{"spanId": "xyz"}`},
+		{name: "unknown severity name", record: `{"severityNumber": "SEVERITY_NUMBER_LOUD"}`},
+		{name: "invalid timestamp", record: `{"timeUnixNano": "yesterday"}`},
+		{name: "invalid double", record: `{"body": {"doubleValue": "lots"}}`},
+		{name: "too deep", record: `{"body": ` + deep + `}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"resourceLogs": [{"scopeLogs": [{"logRecords": [` + tt.record + `]}]}]}`
+			if _, err := UnmarshalJSON([]byte(data)); err == nil {
+				t.Error("UnmarshalJSON accepted the request")
+			}
+		})
+	}
+
+	if _, err := UnmarshalJSON([]byte(`{"resourceLogs": [`)); err == nil {
+		t.Error("UnmarshalJSON accepted truncated JSON")
+	}
+}
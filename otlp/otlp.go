@@ -0,0 +1,214 @@
+// Package otlp decodes OpenTelemetry OTLP/HTTP log export requests, in both
+// the protobuf and JSON encodings, and maps their records onto analytics logs.
+package otlp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"time"
+)
+
+const (
+	// EventType is the event type given to every OTLP log record
+	EventType = "observability"
+
+	// defaultEventName is used for records without an event name
+	defaultEventName = "otel_log"
+
+	// severityError is the first SeverityNumber of the ERROR range
+	severityError = 17
+
+	// maxValueDepth bounds nesting of array and kvlist values
+	maxValueDepth = 32
+)
+
+// Request is a decoded ExportLogsServiceRequest
+type Request struct {
+	ResourceLogs []ResourceLogs
+}
+
+// ResourceLogs holds the logs emitted by one resource, such as a service instance
+type ResourceLogs struct {
+	Resource  map[string]interface{}
+	ScopeLogs []ScopeLogs
+}
+
+// ScopeLogs holds the logs emitted by one instrumentation scope
+type ScopeLogs struct {
+	Scope      Scope
+	LogRecords []LogRecord
+}
+
+// Scope identifies the instrumentation library that emitted a record
+type Scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// LogRecord is a single OTLP log record. AnyValue fields are held as plain
+// JSON-compatible Go values.
+type LogRecord struct {
+	TimeUnixNano         uint64                 `json:"time_unix_nano"`
+	ObservedTimeUnixNano uint64                 `json:"observed_time_unix_nano"`
+	SeverityNumber       int32                  `json:"severity_number"`
+	SeverityText         string                 `json:"severity_text"`
+	Body                 interface{}            `json:"body"`
+	Attributes           map[string]interface{} `json:"attributes"`
+	Flags                uint32                 `json:"flags"`
+	TraceID              []byte                 `json:"trace_id"`
+	SpanID               []byte                 `json:"span_id"`
+	EventName            string                 `json:"event_name"`
+}
+
+// RecordCount returns the number of log records in the request
+func (r *Request) RecordCount() int {
+	count := 0
+	for _, rl := range r.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			count += len(sl.LogRecords)
+		}
+	}
+	return count
+}
+
+// Logs maps every record in the request onto an analytics log:
+//
+//   - resource attributes become DeviceInfo, with service.version as AppVersion
+//   - body, severity, trace context, scope and attributes go into Properties
+//   - user.id / enduser.id and session.id attributes fill UserID and SessionID
+//   - records at ERROR severity or above get high priority
+//
+// The event ID is taken from the log.record.uid attribute when present and is
+// otherwise derived from the record's content, so exporter retries deduplicate.
+func (r *Request) Logs() []models.AnalyticsLog {
+	logs := make([]models.AnalyticsLog, 0, r.RecordCount())
+	for _, rl := range r.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			for _, record := range sl.LogRecords {
+				logs = append(logs, mapRecord(rl.Resource, sl.Scope, record))
+			}
+		}
+	}
+	return logs
+}
+
+// mapRecord converts one log record
+func mapRecord(resource map[string]interface{}, scope Scope, record LogRecord) models.AnalyticsLog {
+	log := models.AnalyticsLog{
+		EventID:    eventID(resource, scope, record),
+		Timestamp:  recordTime(record),
+		EventType:  EventType,
+		EventName:  eventName(record),
+		Properties: recordProperties(scope, record),
+		DeviceInfo: models.JSONB(resource),
+		Priority:   "normal",
+	}
+
+	if record.SeverityNumber >= severityError {
+		log.Priority = "high"
+	}
+
+	log.AppVersion = stringAttribute(resource, "service.version")
+	log.UserID = stringAttribute(record.Attributes, "user.id")
+	if log.UserID == nil {
+		log.UserID = stringAttribute(record.Attributes, "enduser.id")
+	}
+	log.SessionID = stringAttribute(record.Attributes, "session.id")
+
+	return log
+}
+
+// recordProperties collects the record's payload and context
+func recordProperties(scope Scope, record LogRecord) models.JSONB {
+	properties := models.JSONB{
+		"severity_number": record.SeverityNumber,
+	}
+
+	if record.SeverityText != "" {
+		properties["severity_text"] = record.SeverityText
+	}
+	if record.Body != nil {
+		properties["body"] = record.Body
+	}
+	if len(record.Attributes) > 0 {
+		properties["attributes"] = record.Attributes
+	}
+	if len(record.TraceID) > 0 {
+		properties["trace_id"] = hex.EncodeToString(record.TraceID)
+	}
+	if len(record.SpanID) > 0 {
+		properties["span_id"] = hex.EncodeToString(record.SpanID)
+	}
+	if record.Flags != 0 {
+		properties["flags"] = record.Flags
+	}
+	if scope.Name != "" || scope.Version != "" {
+		properties["scope"] = scope
+	}
+
+	return properties
+}
+
+// recordTime picks the event time, falling back to the observed time
+func recordTime(record LogRecord) time.Time {
+	switch {
+	case record.TimeUnixNano != 0:
+		return time.Unix(0, int64(record.TimeUnixNano)).UTC()
+	case record.ObservedTimeUnixNano != 0:
+		return time.Unix(0, int64(record.ObservedTimeUnixNano)).UTC()
+	default:
+		return time.Now().UTC()
+	}
+}
+
+// eventName prefers the record's event name, then the legacy event.name attribute
+func eventName(record LogRecord) string {
+	if record.EventName != "" {
+		return record.EventName
+	}
+	if name := stringAttribute(record.Attributes, "event.name"); name != nil && *name != "" {
+		return *name
+	}
+	return defaultEventName
+}
+
+// eventID returns a stable identifier for a record, so a re-exported record
+// is stored once. A record without either timestamp is stamped with the time
+// it arrives, so its content does not identify it: two identical messages
+// logged at different times would hash the same. Such records get a random ID.
+func eventID(resource map[string]interface{}, scope Scope, record LogRecord) string {
+	if uid := stringAttribute(record.Attributes, "log.record.uid"); uid != nil && *uid != "" {
+		return *uid
+	}
+
+	if record.TimeUnixNano == 0 && record.ObservedTimeUnixNano == 0 {
+		b := make([]byte, 16)
+		rand.Read(b)
+		return "otlp-" + hex.EncodeToString(b)
+	}
+
+	// encoding/json sorts map keys, so equal records always hash the same
+	content, err := json.Marshal(struct {
+		Resource map[string]interface{} `json:"resource"`
+		Scope    Scope                  `json:"scope"`
+		Record   LogRecord              `json:"record"`
+	}{resource, scope, record})
+	if err != nil {
+		content = []byte(fmt.Sprintf("%v|%v|%v", resource, scope, record))
+	}
+
+	sum := sha256.Sum256(content)
+	return "otlp-" + hex.EncodeToString(sum[:16])
+}
+
+// stringAttribute returns a string-valued attribute, if set
+func stringAttribute(attributes map[string]interface{}, key string) *string {
+	if value, ok := attributes[key].(string); ok {
+		return &value
+	}
+	return nil
+}
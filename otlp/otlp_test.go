@@ -0,0 +1,40 @@
+package otlp
+
+import "testing"
+
+func TestEventID(t *testing.T) {
+	resource := map[string]interface{}{"service.name": "checkout"}
+	scope := Scope{Name: "app"}
+	timed := LogRecord{TimeUnixNano: 1_700_000_000_000_000_000, Body: "payment failed"}
+	observed := LogRecord{ObservedTimeUnixNano: 1_700_000_000_000_000_000, Body: "payment failed"}
+	untimed := LogRecord{Body: "payment failed"}
+	withUID := LogRecord{Body: "payment failed", Attributes: map[string]interface{}{"log.record.uid": "uid-1"}}
+
+	tests := []struct {
+		name     string
+		record   LogRecord
+		wantSame bool
+	}{
+		{name: "timestamped record", record: timed, wantSame: true},
+		{name: "observed record", record: observed, wantSame: true},
+		{name: "record without timestamps", record: untimed, wantSame: false},
+		{name: "record with log.record.uid", record: withUID, wantSame: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := eventID(resource, scope, tt.record)
+			second := eventID(resource, scope, tt.record)
+			if (first == second) != tt.wantSame {
+				t.Errorf("IDs %s and %s for the same record, want equal %t", first, second, tt.wantSame)
+			}
+		})
+	}
+
+	if got := eventID(resource, scope, withUID); got != "uid-1" {
+		t.Errorf("eventID = %s, want the log.record.uid", got)
+	}
+	if eventID(resource, scope, timed) == eventID(resource, scope, LogRecord{TimeUnixNano: timed.TimeUnixNano + 1, Body: "payment failed"}) {
+		t.Error("records logged at different times got the same ID")
+	}
+}
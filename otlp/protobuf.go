@@ -0,0 +1,193 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// errTooDeep rejects values nested beyond maxValueDepth
+var errTooDeep = errors.New("otlp: value nesting too deep")
+
+// UnmarshalProtobuf decodes a protobuf-encoded ExportLogsServiceRequest.
+// Unknown fields are ignored.
+func UnmarshalProtobuf(b []byte) (*Request, error) {
+	// LogsData is encoded exactly like ExportLogsServiceRequest, and its
+	// package does not depend on gRPC
+	var data logspb.LogsData
+	if err := proto.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid ExportLogsServiceRequest: %w", err)
+	}
+
+	request := &Request{ResourceLogs: make([]ResourceLogs, 0, len(data.ResourceLogs))}
+	for _, prl := range data.ResourceLogs {
+		rl := ResourceLogs{ScopeLogs: make([]ScopeLogs, 0, len(prl.ScopeLogs))}
+
+		resource, err := protoAttributes(prl.GetResource().GetAttributes(), 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource attributes: %w", err)
+		}
+		rl.Resource = resource
+
+		for _, psl := range prl.ScopeLogs {
+			sl := ScopeLogs{
+				Scope:      Scope{Name: psl.GetScope().GetName(), Version: psl.GetScope().GetVersion()},
+				LogRecords: make([]LogRecord, 0, len(psl.LogRecords)),
+			}
+			for _, pr := range psl.LogRecords {
+				record, err := protoRecord(pr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid log record: %w", err)
+				}
+				sl.LogRecords = append(sl.LogRecords, record)
+			}
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+
+		request.ResourceLogs = append(request.ResourceLogs, rl)
+	}
+
+	return request, nil
+}
+
+// protoRecord converts a protobuf log record
+func protoRecord(pr *logspb.LogRecord) (LogRecord, error) {
+	record := LogRecord{
+		TimeUnixNano:         pr.TimeUnixNano,
+		ObservedTimeUnixNano: pr.ObservedTimeUnixNano,
+		SeverityNumber:       int32(pr.SeverityNumber),
+		SeverityText:         pr.SeverityText,
+		Flags:                pr.Flags,
+		EventName:            pr.EventName,
+	}
+	if len(pr.TraceId) > 0 {
+		record.TraceID = pr.TraceId
+	}
+	if len(pr.SpanId) > 0 {
+		record.SpanID = pr.SpanId
+	}
+
+	var err error
+	if record.Body, err = protoValue(pr.Body, 0); err != nil {
+		return record, err
+	}
+	if record.Attributes, err = protoAttributes(pr.Attributes, 0); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// protoAttributes converts a KeyValue list, returning nil for an empty list
+func protoAttributes(kvs []*commonpb.KeyValue, depth int) (map[string]interface{}, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	attributes := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		value, err := protoValue(kv.Value, depth)
+		if err != nil {
+			return nil, err
+		}
+		attributes[kv.Key] = value
+	}
+	return attributes, nil
+}
+
+// protoValue converts an AnyValue into a JSON-compatible value
+func protoValue(v *commonpb.AnyValue, depth int) (interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, errTooDeep
+	}
+
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue, nil
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue, nil
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue, nil
+	case *commonpb.AnyValue_DoubleValue:
+		return finiteDouble(value.DoubleValue), nil
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(value.BytesValue), nil
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(value.ArrayValue.GetValues()))
+		for _, item := range value.ArrayValue.GetValues() {
+			converted, err := protoValue(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, converted)
+		}
+		return values, nil
+	case *commonpb.AnyValue_KvlistValue:
+		values, err := protoAttributes(value.KvlistValue.GetValues(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+// finiteDouble keeps doubles JSON-encodable, spelling out NaN and infinities
+// the way the OTLP JSON encoding does
+func finiteDouble(v float64) interface{} {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return v
+}
+
+// MarshalProtobufResponse encodes an ExportLogsServiceResponse. A partial
+// success is only included when records were rejected.
+func MarshalProtobufResponse(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return []byte{}
+	}
+
+	var partial []byte
+	if rejected != 0 {
+		partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+		partial = protowire.AppendVarint(partial, uint64(rejected))
+	}
+	if message != "" {
+		partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+		partial = protowire.AppendString(partial, message)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, partial)
+	return b
+}
+
+// MarshalProtobufStatus encodes a google.rpc.Status, the body OTLP/HTTP uses
+// for error responses
+func MarshalProtobufStatus(code int32, message string) []byte {
+	var b []byte
+	if code != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(code)))
+	}
+	if message != "" {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, message)
+	}
+	return b
+}
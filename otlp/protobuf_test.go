@@ -0,0 +1,223 @@
+package otlp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	testTraceID = []byte{0x5b, 0x8e, 0xfb, 0xf7, 0x98, 0xb4, 0x5a, 0x33, 0x11, 0x6a, 0x3c, 0x69, 0x8f, 0x2e, 0x0c, 0x41}
+	testSpanID  = []byte{0xeb, 0x2f, 0x1a, 0x8e, 0x77, 0x3c, 0xa0, 0x01}
+)
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func intValue(v int64) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+}
+
+func arrayValue(values ...*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+func kvlistValue(kvs ...*commonpb.KeyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+}
+
+func keyValue(key string, value *commonpb.AnyValue) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: value}
+}
+
+// testLogsData is an export request built with the generated OTLP types,
+// using every field the decoders read. testRequest is its decoded form.
+func testLogsData() *logspb.LogsData {
+	return &logspb.LogsData{ResourceLogs: []*logspb.ResourceLogs{
+		{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				keyValue("service.name", stringValue("checkout")),
+				keyValue("service.version", stringValue("2.4.0")),
+				keyValue("host.cpus", intValue(8)),
+			}},
+			ScopeLogs: []*logspb.ScopeLogs{
+				{
+					Scope: &commonpb.InstrumentationScope{Name: "checkout.payments", Version: "1.2.0"},
+					LogRecords: []*logspb.LogRecord{
+						{
+							TimeUnixNano:         1_700_000_000_000_000_000,
+							ObservedTimeUnixNano: 1_700_000_000_500_000_000,
+							SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+							SeverityText:         "ERROR",
+							Body:                 stringValue("payment failed"),
+							Attributes: []*commonpb.KeyValue{
+								keyValue("user.id", stringValue("user-1")),
+								keyValue("retry", &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}),
+								keyValue("amount", &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 12.5}}),
+								keyValue("tags", arrayValue(stringValue("card"), intValue(-2))),
+								keyValue("unset", nil),
+							},
+							Flags:     1,
+							TraceId:   testTraceID,
+							SpanId:    testSpanID,
+							EventName: "payment.failed",
+						},
+						{
+							SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_INFO2,
+							Body:           kvlistValue(keyValue("message", stringValue("ok")), keyValue("empty", kvlistValue())),
+						},
+					},
+				},
+			},
+		},
+		{
+			// A resource without attributes and a scope without a name
+			ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{TimeUnixNano: 1}}}},
+		},
+	}}
+}
+
+func testRequest() *Request {
+	return &Request{ResourceLogs: []ResourceLogs{
+		{
+			Resource: map[string]interface{}{"service.name": "checkout", "service.version": "2.4.0", "host.cpus": int64(8)},
+			ScopeLogs: []ScopeLogs{
+				{
+					Scope: Scope{Name: "checkout.payments", Version: "1.2.0"},
+					LogRecords: []LogRecord{
+						{
+							TimeUnixNano:         1_700_000_000_000_000_000,
+							ObservedTimeUnixNano: 1_700_000_000_500_000_000,
+							SeverityNumber:       17,
+							SeverityText:         "ERROR",
+							Body:                 "payment failed",
+							Attributes: map[string]interface{}{
+								"user.id": "user-1",
+								"retry":   true,
+								"amount":  12.5,
+								"tags":    []interface{}{"card", int64(-2)},
+								"unset":   nil,
+							},
+							Flags:     1,
+							TraceID:   testTraceID,
+							SpanID:    testSpanID,
+							EventName: "payment.failed",
+						},
+						{
+							SeverityNumber: 10,
+							Body:           map[string]interface{}{"message": "ok", "empty": map[string]interface{}{}},
+						},
+					},
+				},
+			},
+		},
+		{
+			ScopeLogs: []ScopeLogs{{LogRecords: []LogRecord{{TimeUnixNano: 1}}}},
+		},
+	}}
+}
+
+func TestUnmarshalProtobuf(t *testing.T) {
+	data, err := proto.Marshal(testLogsData())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got, err := UnmarshalProtobuf(data)
+	if err != nil {
+		t.Fatalf("UnmarshalProtobuf: %v", err)
+	}
+	if want := testRequest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalProtobuf =\n%+v\nwant\n%+v", got, want)
+	}
+
+	logs := got.Logs()
+	if len(logs) != 3 {
+		t.Fatalf("%d logs, want 3", len(logs))
+	}
+	first := logs[0]
+	if first.Priority != "high" || first.EventName != "payment.failed" || *first.AppVersion != "2.4.0" || *first.UserID != "user-1" {
+		t.Errorf("first log mapped as %+v", first)
+	}
+	if first.Properties["trace_id"] != "5b8efbf798b45a33116a3c698f2e0c41" || first.Properties["span_id"] != "eb2f1a8e773ca001" {
+		t.Errorf("trace context mapped as %v / %v", first.Properties["trace_id"], first.Properties["span_id"])
+	}
+	if _, ok := logs[1].Properties["trace_id"]; ok || logs[1].Priority != "normal" {
+		t.Errorf("second log mapped as %+v", logs[1])
+	}
+}
+
+func TestUnmarshalProtobufBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body *commonpb.AnyValue
+		want interface{}
+	}{
+		{name: "none", body: nil, want: nil},
+		{name: "empty", body: &commonpb.AnyValue{}, want: nil},
+		{name: "string", body: stringValue("text"), want: "text"},
+		{name: "bool", body: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: false}}, want: false},
+		{name: "int", body: intValue(math.MinInt64), want: int64(math.MinInt64)},
+		{name: "double", body: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: -0.5}}, want: -0.5},
+		{name: "NaN", body: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: math.NaN()}}, want: "NaN"},
+		{name: "infinity", body: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: math.Inf(-1)}}, want: "-Infinity"},
+		{name: "bytes", body: &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: []byte{1, 2, 3}}}, want: "AQID"},
+		{name: "empty array", body: arrayValue(), want: []interface{}{}},
+		{name: "nested array", body: arrayValue(arrayValue(intValue(1)), stringValue("x")), want: []interface{}{[]interface{}{int64(1)}, "x"}},
+		{name: "kvlist", body: kvlistValue(keyValue("a", kvlistValue(keyValue("b", intValue(2))))), want: map[string]interface{}{"a": map[string]interface{}{"b": int64(2)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := proto.Marshal(&logspb.LogsData{ResourceLogs: []*logspb.ResourceLogs{{
+				ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{Body: tt.body}}}},
+			}}})
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			request, err := UnmarshalProtobuf(data)
+			if err != nil {
+				t.Fatalf("UnmarshalProtobuf: %v", err)
+			}
+			if got := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalProtobufErrors(t *testing.T) {
+	deep := stringValue("leaf")
+	for i := 0; i <= maxValueDepth+1; i++ {
+		deep = arrayValue(deep)
+	}
+	tooDeep, err := proto.Marshal(&logspb.LogsData{ResourceLogs: []*logspb.ResourceLogs{{
+		ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{Body: deep}}}},
+	}}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated", data: []byte{0x0a, 0x10, 0x12}},
+		{name: "invalid tag", data: []byte{0x00}},
+		{name: "too deep", data: tooDeep},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnmarshalProtobuf(tt.data); err == nil {
+				t.Error("UnmarshalProtobuf accepted the request")
+			}
+		})
+	}
+}
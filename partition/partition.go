@@ -0,0 +1,304 @@
+// Package partition keeps the time range partitions of analytics_logs ahead
+// of incoming logs: it creates partitions for upcoming periods, moves logs
+// that landed in the default partition into partitions of their own and
+// detaches partitions once they fall out of the retention window.
+package partition
+
+import (
+	"log-ingestion-server/database"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Partition intervals
+const (
+	Daily   = "daily"
+	Monthly = "monthly"
+)
+
+// partitionsPerRound bounds the partitions created in one round, so a large
+// backfill is spread over several rounds
+const partitionsPerRound = 100
+
+// maxBackfill is how far back logs are moved out of the default partition
+// when partitions are never detached
+const maxBackfill = 365 * 24 * time.Hour
+
+// Options configures the partition maintainer
+type Options struct {
+	// Interval is the period each partition covers, Daily or Monthly
+	Interval string
+	// Premake is the number of future partitions kept ahead of the current one
+	Premake int
+	// DetachAfter is how old a partition's whole range must be before it is
+	// detached; zero keeps every partition attached
+	DetachAfter time.Duration
+	// CheckInterval is the time between maintenance rounds
+	CheckInterval time.Duration
+}
+
+// Maintainer periodically creates and detaches partitions of analytics_logs.
+// Changes are made under an advisory lock, so several replicas can run it at
+// once.
+type Maintainer struct {
+	db      *database.DB
+	opts    Options
+	metrics *Metrics
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the partition maintainer
+type Metrics struct {
+	Partitions prometheus.Gauge
+	Created    prometheus.Counter
+	Detached   prometheus.Counter
+	RowsMoved  prometheus.Counter
+	Errors     prometheus.Counter
+}
+
+// NewMaintainer creates a partition maintainer
+func NewMaintainer(db *database.DB, opts Options) *Maintainer {
+	metrics := &Metrics{
+		Partitions: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "log_partitions",
+				Help: "Number of time range partitions attached to analytics_logs",
+			},
+		),
+		Created: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "log_partitions_created_total",
+				Help: "Total number of analytics_logs partitions created by this instance",
+			},
+		),
+		Detached: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "log_partitions_detached_total",
+				Help: "Total number of analytics_logs partitions detached by this instance",
+			},
+		),
+		RowsMoved: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "log_partition_rows_moved_total",
+				Help: "Total number of logs moved out of the default partition",
+			},
+		),
+		Errors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "log_partition_maintenance_errors_total",
+				Help: "Total number of failed partition maintenance operations",
+			},
+		),
+	}
+
+	prometheus.MustRegister(
+		metrics.Partitions,
+		metrics.Created,
+		metrics.Detached,
+		metrics.RowsMoved,
+		metrics.Errors,
+	)
+
+	return &Maintainer{
+		db:      db,
+		opts:    opts,
+		metrics: metrics,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs maintenance rounds in the background until Stop is called
+func (m *Maintainer) Start() {
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(m.opts.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			m.runRound()
+
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	logrus.Infof("Partition maintainer started (%s partitions, %d ahead, every %s)", m.opts.Interval, m.opts.Premake, m.opts.CheckInterval)
+}
+
+// Stop waits for the current round to finish and stops the maintainer
+func (m *Maintainer) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// runRound creates the missing partitions, then detaches expired ones
+func (m *Maintainer) runRound() {
+	partitions, err := m.db.ListLogPartitions()
+	if err != nil {
+		m.fail("Failed to list log partitions: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	horizon := now.Add(-maxBackfill)
+	if m.opts.DetachAfter > 0 {
+		horizon = now.Add(-m.opts.DetachAfter)
+	}
+
+	// The current period and those ahead of it, then the periods whose logs
+	// are waiting in the default partition
+	var periods []time.Time
+	start := m.truncate(now)
+	for i := 0; i <= m.opts.Premake; i++ {
+		periods = append(periods, start)
+		start = m.next(start)
+	}
+
+	backlog, err := m.db.DefaultLogPeriods(m.unit(), horizon, start)
+	if err != nil {
+		m.fail("Failed to inspect the default log partition: %v", err)
+		return
+	}
+	periods = append(periods, backlog...)
+
+	created := 0
+	for _, period := range periods {
+		for _, gap := range uncovered(period, m.next(period), partitions) {
+			if created == partitionsPerRound {
+				break
+			}
+
+			name := partitionName(gap.From, gap.To)
+			ok, moved, err := m.db.CreateLogPartition(name, gap.From, gap.To)
+			if err != nil {
+				m.fail("Failed to create log partition: %v", err)
+				return
+			}
+			if !ok {
+				logrus.Debug("Partition maintenance is running on another instance")
+				return
+			}
+
+			created++
+			m.metrics.Created.Inc()
+			m.metrics.RowsMoved.Add(float64(moved))
+			partitions = insert(partitions, database.LogPartition{Name: name, From: gap.From, To: gap.To})
+			logrus.Infof("Created log partition %s (%d logs moved from the default partition)", name, moved)
+		}
+	}
+
+	if m.opts.DetachAfter > 0 {
+		attached := partitions[:0]
+		for _, p := range partitions {
+			if p.To.After(horizon) {
+				attached = append(attached, p)
+				continue
+			}
+
+			ok, err := m.db.DetachLogPartition(p)
+			if err != nil {
+				m.fail("Failed to detach log partition: %v", err)
+				attached = append(attached, p)
+				continue
+			}
+			if !ok {
+				return
+			}
+
+			m.metrics.Detached.Inc()
+			logrus.Infof("Detached log partition %s", p.Name)
+		}
+		partitions = attached
+	}
+
+	m.metrics.Partitions.Set(float64(len(partitions)))
+}
+
+func (m *Maintainer) fail(format string, args ...interface{}) {
+	m.metrics.Errors.Inc()
+	logrus.Errorf(format, args...)
+}
+
+// truncate returns the start of the period containing t
+func (m *Maintainer) truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if m.opts.Interval == Monthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the period after the one starting at t
+func (m *Maintainer) next(t time.Time) time.Time {
+	if m.opts.Interval == Monthly {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// unit returns the date_trunc unit of the interval
+func (m *Maintainer) unit() string {
+	if m.opts.Interval == Monthly {
+		return "month"
+	}
+	return "day"
+}
+
+// uncovered returns the parts of [from, to) not covered by partitions, which
+// must be sorted by From. Ranges left over from a change of interval are
+// filled instead of overlapped.
+func uncovered(from, to time.Time, partitions []database.LogPartition) []database.LogPartition {
+	var gaps []database.LogPartition
+	for _, p := range partitions {
+		if !p.To.After(from) {
+			continue
+		}
+		if !p.From.Before(to) {
+			break
+		}
+		if p.From.After(from) {
+			gaps = append(gaps, database.LogPartition{From: from, To: p.From})
+		}
+		from = p.To
+		if !from.Before(to) {
+			return gaps
+		}
+	}
+	return append(gaps, database.LogPartition{From: from, To: to})
+}
+
+// partitionName names the partition for [from, to): analytics_logs_pYYYYMM
+// for a calendar month, analytics_logs_pYYYYMMDD for other ranges starting at
+// midnight UTC
+func partitionName(from, to time.Time) string {
+	from = from.UTC()
+	midnight := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case from.Day() == 1 && from.Equal(midnight) && to.Equal(from.AddDate(0, 1, 0)):
+		return "analytics_logs_p" + from.Format("200601")
+	case from.Equal(midnight):
+		return "analytics_logs_p" + from.Format("20060102")
+	}
+	return "analytics_logs_p" + from.Format("20060102_150405")
+}
+
+// insert adds a partition, keeping partitions sorted by From
+func insert(partitions []database.LogPartition, p database.LogPartition) []database.LogPartition {
+	i := len(partitions)
+	for i > 0 && partitions[i-1].From.After(p.From) {
+		i--
+	}
+	partitions = append(partitions, database.LogPartition{})
+	copy(partitions[i+1:], partitions[i:])
+	partitions[i] = p
+	return partitions
+}
@@ -0,0 +1,308 @@
+package partition
+
+import (
+	"database/sql"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// openTestDB connects to the database named by TEST_DB_NAME, using the DB_*
+// variables for the rest, and resets its schema to the latest migration.
+// The test is skipped when TEST_DB_NAME is unset; everything in the
+// database is dropped, so never point it at one that matters.
+func openTestDB(t *testing.T) (*database.DB, *sql.DB) {
+	t.Helper()
+
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME is not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("DB_PORT"))
+	if port == 0 {
+		port = 5432
+	}
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:           envOr("DB_HOST", "localhost"),
+		Port:           port,
+		Name:           name,
+		User:           envOr("DB_USER", "postgres"),
+		Password:       os.Getenv("DB_PASSWORD"),
+		SSLMode:        envOr("DB_SSL_MODE", "disable"),
+		MaxConnections: 5,
+	}}
+
+	conn, err := sql.Open("postgres", cfg.GetDatabaseURL())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := conn.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatalf("reset schema: %v", err)
+	}
+
+	db, err := database.NewDB(cfg)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+
+	return db, conn
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func useTestRegistry(t *testing.T) {
+	t.Helper()
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+}
+
+func insertLog(t *testing.T, db *database.DB, eventID string, timestamp time.Time) {
+	t.Helper()
+	log := &models.AnalyticsLog{
+		EventID:   eventID,
+		Timestamp: timestamp,
+		EventType: "app_event",
+		EventName: "app_open",
+		Priority:  "normal",
+	}
+	if err := db.InsertLog(log); err != nil {
+		t.Fatalf("insert %s: %v", eventID, err)
+	}
+}
+
+func countRows(t *testing.T, conn *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func tableExists(t *testing.T, conn *sql.DB, name string) bool {
+	t.Helper()
+	var exists bool
+	if err := conn.QueryRow("SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists); err != nil {
+		t.Fatalf("to_regclass(%s): %v", name, err)
+	}
+	return exists
+}
+
+func partitionNames(t *testing.T, db *database.DB) map[string]database.LogPartition {
+	t.Helper()
+	partitions, err := db.ListLogPartitions()
+	if err != nil {
+		t.Fatalf("ListLogPartitions: %v", err)
+	}
+	names := make(map[string]database.LogPartition, len(partitions))
+	for _, p := range partitions {
+		names[p.Name] = p
+	}
+	return names
+}
+
+// TestMaintainerAgainstPostgres runs maintenance rounds against a real
+// database: partitions are created ahead and for logs waiting in the default
+// partition, expired ones are detached, and detaching or dropping a partition
+// forgets the event IDs of its range
+func TestMaintainerAgainstPostgres(t *testing.T) {
+	db, conn := openTestDB(t)
+	useTestRegistry(t)
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	old := today.AddDate(0, 0, -3)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	// With no partitions yet, every log lands in the default partition
+	insertLog(t, db, "old", old.Add(time.Hour))
+	insertLog(t, db, "current", now)
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_logs_default"); n != 2 {
+		t.Fatalf("default partition holds %d logs, want 2", n)
+	}
+
+	m := NewMaintainer(db, Options{Interval: Daily, Premake: 1, DetachAfter: 5 * 24 * time.Hour})
+	m.runRound()
+
+	names := partitionNames(t, db)
+	for _, day := range []time.Time{old, today, tomorrow} {
+		name := partitionName(day, day.AddDate(0, 0, 1))
+		p, ok := names[name]
+		if !ok {
+			t.Fatalf("partitions = %v, want %s attached", names, name)
+		}
+		if !p.From.Equal(day) || !p.To.Equal(day.AddDate(0, 0, 1)) {
+			t.Errorf("%s covers [%s, %s), want the day from %s", name, p.From, p.To, day)
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("partitions = %v, want 3", names)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_logs_default"); n != 0 {
+		t.Errorf("default partition holds %d logs after the round, want 0", n)
+	}
+	oldTable := pq.QuoteIdentifier(partitionName(old, old.AddDate(0, 0, 1)))
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM "+oldTable); n != 1 {
+		t.Errorf("%s holds %d logs, want the old log moved into it", oldTable, n)
+	}
+
+	// A second round has nothing left to create
+	m.runRound()
+	if got := partitionNames(t, db); len(got) != 3 {
+		t.Errorf("partitions after a second round = %v, want 3", got)
+	}
+
+	// Shrinking the window detaches the old partition and forgets its event
+	// IDs, so the event can be stored again
+	m.opts.DetachAfter = 2 * 24 * time.Hour
+	m.runRound()
+
+	oldName := partitionName(old, old.AddDate(0, 0, 1))
+	if _, ok := partitionNames(t, db)[oldName]; ok {
+		t.Errorf("%s is still attached", oldName)
+	}
+	if !tableExists(t, conn, oldName) {
+		t.Errorf("detached partition %s was dropped, want it kept as a table", oldName)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_log_event_ids WHERE event_id = 'old'"); n != 0 {
+		t.Error("event ID of the detached partition was kept")
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_log_event_ids WHERE event_id = 'current'"); n != 1 {
+		t.Error("event ID of an attached partition was pruned")
+	}
+	insertLog(t, db, "old", old.Add(time.Hour))
+
+	// Dropping a partition removes the table and its event IDs
+	insertLog(t, db, "next", tomorrow.Add(time.Hour))
+	next := partitionNames(t, db)[partitionName(tomorrow, tomorrow.AddDate(0, 0, 1))]
+	ok, err := db.DropLogPartition(next)
+	if err != nil || !ok {
+		t.Fatalf("DropLogPartition = %t, %v", ok, err)
+	}
+	if tableExists(t, conn, next.Name) {
+		t.Errorf("%s still exists after the drop", next.Name)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_log_event_ids WHERE event_id = 'next'"); n != 0 {
+		t.Error("event ID of the dropped partition was kept")
+	}
+}
+
+// TestCreateLogPartitionAgainstPostgres checks that creating a partition
+// moves exactly the logs of its range out of the default partition and
+// backs off while another instance holds the maintenance lock
+func TestCreateLogPartitionAgainstPostgres(t *testing.T) {
+	db, conn := openTestDB(t)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	insertLog(t, db, "before", from.Add(-time.Second))
+	insertLog(t, db, "first", from)
+	insertLog(t, db, "last", to.Add(-time.Second))
+	insertLog(t, db, "after", to)
+
+	// Another instance running maintenance, holding the database package's
+	// partitionLockKey
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", int64(0x6c6f6770617274)); err != nil {
+		t.Fatal(err)
+	}
+	ok, _, err := db.CreateLogPartition("analytics_logs_p202601", from, to)
+	tx.Rollback()
+	if err != nil || ok {
+		t.Fatalf("CreateLogPartition under another instance's lock = %t, %v, want false", ok, err)
+	}
+
+	ok, moved, err := db.CreateLogPartition("analytics_logs_p202601", from, to)
+	if err != nil || !ok {
+		t.Fatalf("CreateLogPartition = %t, %v", ok, err)
+	}
+	if moved != 2 {
+		t.Errorf("moved %d logs, want 2", moved)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_logs_default"); n != 2 {
+		t.Errorf("default partition holds %d logs, want the 2 outside the range", n)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM analytics_logs"); n != 4 {
+		t.Errorf("analytics_logs holds %d logs, want 4", n)
+	}
+
+	// The partition enforces its bounds once attached
+	if _, err := conn.Exec(`INSERT INTO analytics_logs_p202601 (event_id, timestamp, event_type, event_name)
+		VALUES ('outside', $1, 'app_event', 'app_open')`, to); err == nil {
+		t.Error("partition accepted a log outside its range")
+	}
+}
+
+func TestUncovered(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	part := func(from, to int) database.LogPartition {
+		return database.LogPartition{From: day(from), To: day(to)}
+	}
+
+	tests := []struct {
+		name       string
+		partitions []database.LogPartition
+		want       []database.LogPartition
+	}{
+		{name: "no partitions", want: []database.LogPartition{part(10, 20)}},
+		{name: "covered", partitions: []database.LogPartition{part(1, 31)}},
+		{name: "before and after", partitions: []database.LogPartition{part(1, 10), part(20, 25)}, want: []database.LogPartition{part(10, 20)}},
+		{name: "head covered", partitions: []database.LogPartition{part(5, 15)}, want: []database.LogPartition{part(15, 20)}},
+		{name: "tail covered", partitions: []database.LogPartition{part(15, 25)}, want: []database.LogPartition{part(10, 15)}},
+		{name: "holes", partitions: []database.LogPartition{part(12, 14), part(16, 18)}, want: []database.LogPartition{part(10, 12), part(14, 16), part(18, 20)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uncovered(day(10), day(20), tt.partitions)
+			if len(got) != len(tt.want) {
+				t.Fatalf("uncovered = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].From.Equal(tt.want[i].From) || !got[i].To.Equal(tt.want[i].To) {
+					t.Errorf("gap %d = [%s, %s), want [%s, %s)", i, got[i].From, got[i].To, tt.want[i].From, tt.want[i].To)
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionName(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from, to time.Time
+		want     string
+	}{
+		{from, from.AddDate(0, 1, 0), "analytics_logs_p202603"},
+		{from, from.AddDate(0, 0, 1), "analytics_logs_p20260301"},
+		{from.AddDate(0, 0, 14), from.AddDate(0, 1, 0), "analytics_logs_p20260315"},
+		{from.Add(6 * time.Hour), from.AddDate(0, 0, 1), "analytics_logs_p20260301_060000"},
+	}
+
+	for _, tt := range tests {
+		if got := partitionName(tt.from, tt.to); got != tt.want {
+			t.Errorf("partitionName(%s, %s) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
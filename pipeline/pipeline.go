@@ -0,0 +1,514 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"log-ingestion-server/deadletter"
+	"log-ingestion-server/models"
+	"log-ingestion-server/wal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrQueueFull is returned when the ingest queue has no room for the logs
+	ErrQueueFull = errors.New("ingest queue is full")
+
+	// ErrClosed is returned when logs are enqueued after shutdown has started
+	ErrClosed = errors.New("ingest pipeline is shut down")
+)
+
+// entry is a queued log together with the WAL segment it was recorded in
+type entry struct {
+	log     models.AnalyticsLog
+	segment uint64
+}
+
+// Pipeline buffers accepted logs in memory and writes them to the database
+// in batches from a pool of workers. When a WAL is attached, logs are
+// recorded on disk before they are acknowledged and any batch that cannot
+// reach the database is replayed from the WAL once it is reachable again.
+type Pipeline struct {
+	db             database.Store
+	wal            *wal.WAL
+	deadLetter     *deadletter.Writer
+	queue          chan entry
+	workers        int
+	batchSize      int
+	batchTimeout   time.Duration
+	replayInterval time.Duration
+	metrics        *Metrics
+
+	mu        sync.Mutex // guards closed and reserved
+	closed    bool
+	reserved  int            // queue slots held by producers writing to the WAL
+	producers sync.WaitGroup // producers past the closed check
+	wg        sync.WaitGroup
+
+	replayMu   sync.Mutex // serializes replay rounds
+	stopReplay chan struct{}
+	replayDone chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the ingest pipeline
+type Metrics struct {
+	QueueDepth        prometheus.GaugeFunc
+	QueueCapacity     prometheus.Gauge
+	FlushDuration     prometheus.Histogram
+	LastFlushDuration prometheus.Gauge
+	FlushedLogs       *prometheus.CounterVec
+	RejectedLogs      *prometheus.CounterVec
+	ReplayedLogs      prometheus.Counter
+}
+
+// NewPipeline creates a new ingest pipeline sized from configuration.
+// The WAL is optional; pass nil to buffer in memory only. The dead-letter
+// writer is optional too; without one, logs the database refuses stay in the
+// WAL, or are dropped when there is no WAL either.
+func NewPipeline(db database.Store, w *wal.WAL, dl *deadletter.Writer, cfg *config.Config) *Pipeline {
+	p := &Pipeline{
+		db:             db,
+		wal:            w,
+		deadLetter:     dl,
+		queue:          make(chan entry, cfg.IngestQueueSize),
+		workers:        cfg.WorkerPoolSize,
+		batchSize:      cfg.MaxBatchSize,
+		batchTimeout:   cfg.BatchTimeout,
+		replayInterval: cfg.WAL.ReplayInterval,
+		stopReplay:     make(chan struct{}),
+		replayDone:     make(chan struct{}),
+	}
+
+	p.metrics = &Metrics{
+		QueueDepth: prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "ingest_queue_depth",
+				Help: "Number of accepted logs waiting to be written to the database",
+			},
+			func() float64 { return float64(len(p.queue)) },
+		),
+		QueueCapacity: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ingest_queue_capacity",
+				Help: "Maximum number of logs the ingest queue can hold",
+			},
+		),
+		FlushDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ingest_flush_duration_seconds",
+				Help:    "Time taken to write a batch of queued logs to the database",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+			},
+		),
+		LastFlushDuration: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ingest_last_flush_duration_seconds",
+				Help: "Duration of the most recent batch flush",
+			},
+		),
+		FlushedLogs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ingest_flushed_logs_total",
+				Help: "Total number of queued logs written to the database, deferred or dead-lettered",
+			},
+			[]string{"result"},
+		),
+		RejectedLogs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ingest_rejected_logs_total",
+				Help: "Total number of logs rejected before entering the ingest queue",
+			},
+			[]string{"reason"},
+		),
+		ReplayedLogs: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ingest_replayed_logs_total",
+				Help: "Total number of logs replayed from the WAL into the database",
+			},
+		),
+	}
+	p.metrics.QueueCapacity.Set(float64(cfg.IngestQueueSize))
+
+	prometheus.MustRegister(
+		p.metrics.QueueDepth,
+		p.metrics.QueueCapacity,
+		p.metrics.FlushDuration,
+		p.metrics.LastFlushDuration,
+		p.metrics.FlushedLogs,
+		p.metrics.RejectedLogs,
+		p.metrics.ReplayedLogs,
+	)
+
+	return p
+}
+
+// Start launches the worker pool and, when a WAL is attached, the replayer
+func (p *Pipeline) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	if p.wal != nil {
+		go p.replayLoop()
+	} else {
+		close(p.replayDone)
+	}
+
+	logrus.Infof("Ingest pipeline started with %d workers (batch size %d, flush interval %s, queue capacity %d)",
+		p.workers, p.batchSize, p.batchTimeout, cap(p.queue))
+}
+
+// Enqueue adds logs to the queue. Either all logs are accepted or none are,
+// so callers can safely report the whole request as failed. Queue space is
+// reserved before the logs are written to the WAL, so producers do not wait
+// for each other's disk writes.
+func (p *Pipeline) Enqueue(logs ...models.AnalyticsLog) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.metrics.RejectedLogs.WithLabelValues("closed").Add(float64(len(logs)))
+		return ErrClosed
+	}
+
+	// Workers only ever drain the queue and every other producer has
+	// reserved the slots it will fill, so the reserved slots stay free
+	if cap(p.queue)-len(p.queue)-p.reserved < len(logs) {
+		p.mu.Unlock()
+		p.metrics.RejectedLogs.WithLabelValues("queue_full").Add(float64(len(logs)))
+		return ErrQueueFull
+	}
+	p.reserved += len(logs)
+	p.producers.Add(1)
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.reserved -= len(logs)
+		p.mu.Unlock()
+		p.producers.Done()
+	}()
+
+	var segment uint64
+	if p.wal != nil {
+		var err error
+		if segment, err = p.wal.Append(logs); err != nil {
+			p.metrics.RejectedLogs.WithLabelValues("wal_error").Add(float64(len(logs)))
+			return err
+		}
+	}
+
+	for _, log := range logs {
+		p.queue <- entry{log: log, segment: segment}
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting new logs and waits for the workers to flush
+// everything already queued. Logs that could not be flushed remain in the
+// WAL and are replayed on the next start.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	closing := !p.closed
+	p.closed = true
+	p.mu.Unlock()
+
+	if closing {
+		// Producers already past the closed check still send to the queue
+		go func() {
+			p.producers.Wait()
+			close(p.queue)
+			close(p.stopReplay)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		<-p.replayDone
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("Ingest pipeline drained")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if p.wal != nil {
+		if err := p.wal.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// worker collects queued logs into batches and flushes them when the batch
+// is full or the batch timeout has elapsed since its first log arrived
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]entry, 0, p.batchSize)
+	timer := time.NewTimer(p.batchTimeout)
+	timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-p.queue:
+			if !ok {
+				timer.Stop()
+				p.flush(batch)
+				return
+			}
+
+			if len(batch) == 0 {
+				timer.Reset(p.batchTimeout)
+			}
+			batch = append(batch, e)
+
+			if len(batch) >= p.batchSize {
+				timer.Stop()
+				p.flush(batch)
+				batch = make([]entry, 0, p.batchSize)
+			}
+
+		case <-timer.C:
+			p.flush(batch)
+			batch = make([]entry, 0, p.batchSize)
+		}
+	}
+}
+
+// flush writes a batch to the database. If the database is unreachable the
+// batch is left in the WAL for replay; otherwise a failed bulk insert is
+// retried one log at a time so a single bad row does not discard the others,
+// and rows the database still refuses are moved to the dead-letter directory.
+func (p *Pipeline) flush(batch []entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		p.metrics.FlushDuration.Observe(duration)
+		p.metrics.LastFlushDuration.Set(duration)
+	}()
+
+	logs := make([]models.AnalyticsLog, len(batch))
+	for i := range batch {
+		logs[i] = batch[i].log
+	}
+
+	err := p.db.InsertLogsBatch(logs)
+	if err == nil {
+		p.metrics.FlushedLogs.WithLabelValues("inserted").Add(float64(len(batch)))
+		p.settle(batch, p.ack)
+		logrus.Debugf("Flushed batch of %d logs", len(batch))
+		return
+	}
+
+	if p.wal != nil && p.db.HealthCheck() != nil {
+		logrus.Warnf("Database unavailable, keeping batch of %d logs in WAL for replay: %v", len(batch), err)
+		p.metrics.FlushedLogs.WithLabelValues("deferred").Add(float64(len(batch)))
+		p.settle(batch, p.wal.Fail)
+		return
+	}
+
+	logrus.Warnf("Batch insert of %d logs failed, retrying individually: %v", len(batch), err)
+
+	stored := make([]entry, 0, len(batch))
+	var kept []entry
+	for i := range logs {
+		err := p.db.InsertLog(&logs[i])
+		switch {
+		case err == nil:
+			p.metrics.FlushedLogs.WithLabelValues("inserted").Inc()
+		case strings.Contains(err.Error(), "duplicate key"):
+			p.metrics.FlushedLogs.WithLabelValues("duplicate").Inc()
+			logrus.Debugf("Skipping duplicate log %s", logs[i].EventID)
+		case p.deadLetterLog("flush", &logs[i], err):
+			p.metrics.FlushedLogs.WithLabelValues("dead_letter").Inc()
+		case p.wal != nil:
+			// Leave it in the WAL; replay retries it and quarantines the
+			// segment if it still fails
+			p.metrics.FlushedLogs.WithLabelValues("deferred").Inc()
+			kept = append(kept, batch[i])
+			continue
+		default:
+			p.metrics.FlushedLogs.WithLabelValues("failed").Inc()
+			logrus.Errorf("Dropping log %s: %v", logs[i].EventID, err)
+		}
+		stored = append(stored, batch[i])
+	}
+
+	p.settle(stored, p.ack)
+	if len(kept) > 0 {
+		p.settle(kept, p.wal.Fail)
+	}
+}
+
+// deadLetterLog moves a log the database refused to the dead-letter
+// directory, reporting false when there is none or it cannot be written
+func (p *Pipeline) deadLetterLog(source string, log *models.AnalyticsLog, cause error) bool {
+	if p.deadLetter == nil {
+		return false
+	}
+
+	if err := p.deadLetter.Write(source, log, cause); err != nil {
+		logrus.Errorf("Failed to dead-letter log %s: %v (insert error: %v)", log.EventID, err, cause)
+		return false
+	}
+
+	logrus.Warnf("Moved log %s to the dead-letter directory: %v", log.EventID, cause)
+	return true
+}
+
+// settle reports the outcome of a flushed batch to the WAL, grouped by segment
+func (p *Pipeline) settle(batch []entry, report func(segment uint64, n int)) {
+	if p.wal == nil {
+		return
+	}
+
+	counts := make(map[uint64]int)
+	for _, e := range batch {
+		counts[e.segment]++
+	}
+	for segment, n := range counts {
+		report(segment, n)
+	}
+}
+
+func (p *Pipeline) ack(segment uint64, n int) {
+	p.wal.Ack(segment, n)
+}
+
+// Recover replays every WAL segment awaiting replay. It is called once at
+// startup, before the server accepts traffic, and then periodically. A
+// segment that cannot be fully stored does not hold back the others: logs
+// the database refuses are dead-lettered, and corrupt segments or segments
+// with logs that could not be dead-lettered are quarantined. Recovery stops
+// early only when the database becomes unreachable.
+func (p *Pipeline) Recover() error {
+	if p.wal == nil {
+		return nil
+	}
+
+	p.replayMu.Lock()
+	defer p.replayMu.Unlock()
+
+	var errs []error
+	for _, id := range p.wal.ReplayCandidates() {
+		if err := p.replaySegment(id); err != nil {
+			if errors.Is(err, errDatabaseUnavailable) {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// errDatabaseUnavailable stops a replay round until the database is back
+var errDatabaseUnavailable = errors.New("database unavailable")
+
+// replaySegment stores the logs of one WAL segment, skipping those already
+// stored, then removes or quarantines the segment
+func (p *Pipeline) replaySegment(id uint64) error {
+	logs, err := p.wal.ReadSegment(id)
+	corrupt := errors.Is(err, wal.ErrCorruptSegment)
+	if err != nil && !corrupt {
+		return fmt.Errorf("failed to read WAL segment %d: %w", id, err)
+	}
+
+	var inserted, deadLettered, kept int
+	for start := 0; start < len(logs); start += p.batchSize {
+		batch := logs[start:min(start+p.batchSize, len(logs))]
+
+		results, err := p.db.InsertLogsBatchSkipDuplicates(batch)
+		if err != nil {
+			if p.db.HealthCheck() != nil {
+				return fmt.Errorf("failed to replay WAL segment %d: %w: %w", id, errDatabaseUnavailable, err)
+			}
+
+			// Retry one log at a time so a single bad row does not hold
+			// back the rest of the segment
+			logrus.Warnf("Replaying %d logs from WAL segment %d failed, retrying individually: %v", len(batch), id, err)
+			results = make([]bool, len(batch))
+			for i := range batch {
+				ok, err := p.db.InsertLogsBatchSkipDuplicates(batch[i : i+1])
+				switch {
+				case err == nil:
+					results[i] = ok[0]
+				case p.db.HealthCheck() != nil:
+					return fmt.Errorf("failed to replay WAL segment %d: %w: %w", id, errDatabaseUnavailable, err)
+				case p.deadLetterLog("replay", &batch[i], err):
+					deadLettered++
+				default:
+					kept++
+				}
+			}
+		}
+
+		for _, ok := range results {
+			if ok {
+				inserted++
+			}
+		}
+	}
+	p.metrics.ReplayedLogs.Add(float64(inserted))
+
+	if corrupt || kept > 0 {
+		if err := p.wal.Quarantine(id); err != nil {
+			return err
+		}
+		logrus.Errorf("Quarantined WAL segment %d (corrupt: %t, logs not stored: %d) after inserting %d of %d readable logs",
+			id, corrupt, kept, inserted, len(logs))
+		return nil
+	}
+
+	if err := p.wal.Remove(id); err != nil {
+		return err
+	}
+
+	logrus.Infof("Replayed WAL segment %d: %d of %d logs inserted, %d dead-lettered, rest already stored",
+		id, inserted, len(logs), deadLettered)
+	return nil
+}
+
+// replayLoop retries WAL segments left behind by database outages
+func (p *Pipeline) replayLoop() {
+	defer close(p.replayDone)
+
+	ticker := time.NewTicker(p.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if len(p.wal.ReplayCandidates()) == 0 {
+				continue
+			}
+			if err := p.db.HealthCheck(); err != nil {
+				logrus.Debugf("Database still unavailable, postponing WAL replay: %v", err)
+				continue
+			}
+			if err := p.Recover(); err != nil {
+				logrus.Errorf("WAL replay failed: %v", err)
+			}
+		case <-p.stopReplay:
+			return
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/database"
+	"log-ingestion-server/deadletter"
+	"log-ingestion-server/models"
+	"log-ingestion-server/wal"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// useTestRegistry gives each test its own Prometheus registry, since the
+// pipeline, WAL and dead-letter writer register their metrics when created
+func useTestRegistry(t *testing.T) {
+	t.Helper()
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+}
+
+// rejectingStore is a memory store that refuses to insert some event IDs, the
+// way PostgreSQL refuses a row that violates a constraint
+type rejectingStore struct {
+	*database.MemoryStore
+	reject map[string]bool
+}
+
+var errRejected = errors.New("value too long for type character varying(100)")
+
+func (s *rejectingStore) rejects(logs []models.AnalyticsLog) bool {
+	for i := range logs {
+		if s.reject[logs[i].EventID] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *rejectingStore) InsertLog(log *models.AnalyticsLog) error {
+	if s.reject[log.EventID] {
+		return errRejected
+	}
+	return s.MemoryStore.InsertLog(log)
+}
+
+func (s *rejectingStore) InsertLogsBatch(logs []models.AnalyticsLog) error {
+	if s.rejects(logs) {
+		return errRejected
+	}
+	return s.MemoryStore.InsertLogsBatch(logs)
+}
+
+func (s *rejectingStore) InsertLogsBatchSkipDuplicates(logs []models.AnalyticsLog) ([]bool, error) {
+	if s.rejects(logs) {
+		return nil, errRejected
+	}
+	return s.MemoryStore.InsertLogsBatchSkipDuplicates(logs)
+}
+
+func newRejectingStore(eventIDs ...string) *rejectingStore {
+	s := &rejectingStore{MemoryStore: database.NewMemoryStore(), reject: make(map[string]bool)}
+	for _, eventID := range eventIDs {
+		s.reject[eventID] = true
+	}
+	return s
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		MaxBatchSize:    10,
+		WorkerPoolSize:  1,
+		IngestQueueSize: 100,
+		BatchTimeout:    10 * time.Millisecond,
+		WAL:             config.WALConfig{ReplayInterval: time.Hour},
+	}
+}
+
+func openTestWAL(t *testing.T, dir string) *wal.WAL {
+	t.Helper()
+	w, err := wal.Open(wal.Options{Dir: dir, SegmentSize: 1 << 20, Fsync: wal.FsyncAlways})
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	return w
+}
+
+// replayRecords reopens the WAL in dir, as the next start would, and returns
+// how many records it holds for replay
+func replayRecords(t *testing.T, dir string) int {
+	t.Helper()
+	useTestRegistry(t)
+	w := openTestWAL(t, dir)
+	defer w.Close()
+	return w.Stats().ReplayRecords
+}
+
+func openTestDeadLetter(t *testing.T) (*deadletter.Writer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	dl, err := deadletter.Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open dead-letter directory: %v", err)
+	}
+	return dl, dir
+}
+
+// readDeadLetters returns every record written below dir
+func readDeadLetters(t *testing.T, dir string) []deadletter.Record {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+
+	var records []deadletter.Record
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", path, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record deadletter.Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("invalid dead-letter record %q: %v", scanner.Text(), err)
+			}
+			records = append(records, record)
+		}
+		file.Close()
+	}
+	return records
+}
+
+func testLogs(eventIDs ...string) []models.AnalyticsLog {
+	logs := make([]models.AnalyticsLog, len(eventIDs))
+	for i, eventID := range eventIDs {
+		logs[i] = models.AnalyticsLog{EventID: eventID, EventType: "behavioral", EventName: "habit_completed"}
+	}
+	return logs
+}
+
+// ingest enqueues logs and shuts the pipeline down once they are flushed
+func ingest(t *testing.T, p *Pipeline, logs []models.AnalyticsLog) {
+	t.Helper()
+	p.Start()
+	if err := p.Enqueue(logs...); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestFlushDeadLettersRefusedLogs(t *testing.T) {
+	useTestRegistry(t)
+	store := newRejectingStore("bad")
+	dl, dlDir := openTestDeadLetter(t)
+	walDir := t.TempDir()
+
+	ingest(t, NewPipeline(store, openTestWAL(t, walDir), dl, testConfig()), testLogs("good", "bad", "also-good"))
+
+	if count, _ := store.GetLogCount(); count != 2 {
+		t.Errorf("stored %d logs, want 2", count)
+	}
+
+	records := readDeadLetters(t, dlDir)
+	if len(records) != 1 || records[0].Log.EventID != "bad" || records[0].Source != "flush" || records[0].Error != errRejected.Error() {
+		t.Fatalf("dead letters = %+v, want the bad log from flush", records)
+	}
+
+	// Every log is accounted for, so nothing is left to replay
+	if n := replayRecords(t, walDir); n != 0 {
+		t.Errorf("WAL holds %d records for replay, want 0", n)
+	}
+}
+
+func TestFlushKeepsRefusedLogsInWALWithoutDeadLetter(t *testing.T) {
+	useTestRegistry(t)
+	store := newRejectingStore("bad")
+	walDir := t.TempDir()
+
+	ingest(t, NewPipeline(store, openTestWAL(t, walDir), nil, testConfig()), testLogs("good", "bad"))
+
+	if count, _ := store.GetLogCount(); count != 1 {
+		t.Errorf("stored %d logs, want 1", count)
+	}
+
+	if n := replayRecords(t, walDir); n != 2 {
+		t.Errorf("WAL holds %d records for replay, want the 2 in the refused log's segment", n)
+	}
+}
+
+func TestFlushKeepsLogsInWALWhenDeadLetterFails(t *testing.T) {
+	useTestRegistry(t)
+	store := newRejectingStore("bad")
+	dl, dlDir := openTestDeadLetter(t)
+	walDir := t.TempDir()
+
+	// Replace the dead-letter directory with a file so writes fail
+	if err := os.RemoveAll(dlDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dlDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ingest(t, NewPipeline(store, openTestWAL(t, walDir), dl, testConfig()), testLogs("good", "bad"))
+
+	if n := replayRecords(t, walDir); n != 2 {
+		t.Errorf("WAL holds %d records for replay, want 2", n)
+	}
+}
+
+// writeSegments leaves one unreplayed WAL segment in dir for each group of
+// logs, as a crash would
+func writeSegments(t *testing.T, dir string, groups ...[]models.AnalyticsLog) {
+	t.Helper()
+	for _, logs := range groups {
+		useTestRegistry(t)
+		w := openTestWAL(t, dir)
+		if _, err := w.Append(logs); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+}
+
+// quarantined returns the paths of the quarantined segments in dir
+func quarantined(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, "quarantine", "*.wal"))
+	return paths
+}
+
+func TestRecoverDeadLettersRefusedLogs(t *testing.T) {
+	useTestRegistry(t)
+	store := newRejectingStore("bad")
+	dl, dlDir := openTestDeadLetter(t)
+	walDir := t.TempDir()
+	writeSegments(t, walDir, testLogs("a1", "bad", "a2"), testLogs("b1"))
+
+	useTestRegistry(t)
+	p := NewPipeline(store, openTestWAL(t, walDir), dl, testConfig())
+	if err := p.Recover(); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	if count, _ := store.GetLogCount(); count != 3 {
+		t.Errorf("stored %d logs, want 3", count)
+	}
+	if records := readDeadLetters(t, dlDir); len(records) != 1 || records[0].Log.EventID != "bad" || records[0].Source != "replay" {
+		t.Errorf("dead letters = %+v, want the bad log from replay", records)
+	}
+	if ids := p.wal.ReplayCandidates(); len(ids) != 0 {
+		t.Errorf("replay candidates = %v, want none", ids)
+	}
+	if paths := quarantined(t, walDir); len(paths) != 0 {
+		t.Errorf("quarantined %v, want nothing", paths)
+	}
+}
+
+func TestRecoverQuarantinesAndContinues(t *testing.T) {
+	useTestRegistry(t)
+	store := newRejectingStore("bad")
+	walDir := t.TempDir()
+	writeSegments(t, walDir, testLogs("a1", "a2"), testLogs("b1", "bad"), testLogs("c1"))
+
+	// Cut the first segment short, in the middle of its second record
+	paths, _ := filepath.Glob(filepath.Join(walDir, "*.wal"))
+	info, err := os.Stat(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(paths[0], info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a dead-letter directory, the segment holding the refused log is
+	// quarantined rather than replayed forever or deleted
+	useTestRegistry(t)
+	p := NewPipeline(store, openTestWAL(t, walDir), nil, testConfig())
+	if err := p.Recover(); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	if count, _ := store.GetLogCount(); count != 3 {
+		t.Errorf("stored %d logs, want a1, b1 and c1", count)
+	}
+	if ids := p.wal.ReplayCandidates(); len(ids) != 0 {
+		t.Errorf("replay candidates = %v, want none", ids)
+	}
+	if got := quarantined(t, walDir); len(got) != 2 || filepath.Base(got[0]) != filepath.Base(paths[0]) || filepath.Base(got[1]) != filepath.Base(paths[1]) {
+		t.Errorf("quarantined %v, want the first two segments", got)
+	}
+}
+
+func TestRecoverStopsWhileDatabaseIsDown(t *testing.T) {
+	useTestRegistry(t)
+	store := &unreachableStore{newRejectingStore("a1")}
+	walDir := t.TempDir()
+	writeSegments(t, walDir, testLogs("a1"), testLogs("b1"))
+
+	useTestRegistry(t)
+	p := NewPipeline(store, openTestWAL(t, walDir), nil, testConfig())
+	if err := p.Recover(); !errors.Is(err, errDatabaseUnavailable) {
+		t.Fatalf("Recover error = %v, want database unavailable", err)
+	}
+
+	// Nothing is quarantined because of an outage
+	if ids := p.wal.ReplayCandidates(); len(ids) != 2 {
+		t.Errorf("replay candidates = %v, want both segments", ids)
+	}
+	if paths := quarantined(t, walDir); len(paths) != 0 {
+		t.Errorf("quarantined %v, want nothing", paths)
+	}
+}
+
+// unreachableStore fails its health check, as when the database is down
+type unreachableStore struct {
+	*rejectingStore
+}
+
+func (unreachableStore) HealthCheck() error {
+	return errors.New("connection refused")
+}
+
+// TestEnqueueConcurrently checks that producers writing to the WAL at the
+// same time cannot overfill the queue: those that do not fit are rejected
+// instead of blocking
+func TestEnqueueConcurrently(t *testing.T) {
+	useTestRegistry(t)
+	store := database.NewMemoryStore()
+	w := openTestWAL(t, t.TempDir())
+	p := NewPipeline(store, w, nil, testConfig())
+
+	// The workers are not started yet, so nothing drains the queue
+	const producers, perProducer = 20, 10
+	errs := make(chan error, producers)
+	for i := 0; i < producers; i++ {
+		go func(i int) {
+			logs := make([]models.AnalyticsLog, perProducer)
+			for j := range logs {
+				logs[j] = models.AnalyticsLog{EventID: fmt.Sprintf("e%d-%d", i, j), EventType: "behavioral", EventName: "habit_completed"}
+			}
+			errs <- p.Enqueue(logs...)
+		}(i)
+	}
+
+	var accepted, full int
+	for i := 0; i < producers; i++ {
+		switch err := <-errs; {
+		case err == nil:
+			accepted++
+		case errors.Is(err, ErrQueueFull):
+			full++
+		default:
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	if want := testConfig().IngestQueueSize / perProducer; accepted != want || full != producers-want {
+		t.Fatalf("accepted %d and rejected %d requests, want %d accepted", accepted, full, want)
+	}
+
+	p.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if count, _ := store.GetLogCount(); count != int64(accepted*perProducer) {
+		t.Errorf("stored %d logs, want %d", count, accepted*perProducer)
+	}
+	if err := p.Enqueue(testLogs("late")...); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue after shutdown = %v, want ErrClosed", err)
+	}
+}
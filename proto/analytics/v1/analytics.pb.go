@@ -0,0 +1,328 @@
+// Binary ingestion schema for /api/v1/ingest and /api/v1/batch-ingest.
+//
+// Send requests with Content-Type: application/x-protobuf. The messages mirror
+// models.AnalyticsLog and models.BatchRequest field for field, and decoded
+// logs are validated exactly like their JSON form.
+//
+// Versioning: field numbers are never reused or renumbered. Additive changes
+// keep the analytics.v1 package; incompatible changes go in analytics.v2.
+//
+// Dart bindings:
+//   protoc --dart_out=lib/generated -Iproto proto/analytics/v1/analytics.proto \
+//     google/protobuf/struct.proto google/protobuf/timestamp.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: analytics/v1/analytics.proto
+
+package analyticsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AnalyticsLog is a single analytics event
+type AnalyticsLog struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Client-generated unique ID; required
+	EventId string `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Time the event occurred; defaults to the time it is received
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Name of an active event type, such as behavioral or error; see
+	// GET /api/v1/admin/event-types for the registered types; required
+	EventType string `protobuf:"bytes,3,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	// Event name such as habit_completed; required
+	EventName string `protobuf:"bytes,4,opt,name=event_name,json=eventName,proto3" json:"event_name,omitempty"`
+	// Free-form event payload
+	Properties *structpb.Struct `protobuf:"bytes,5,opt,name=properties,proto3" json:"properties,omitempty"`
+	UserId     *string          `protobuf:"bytes,6,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
+	SessionId  *string          `protobuf:"bytes,7,opt,name=session_id,json=sessionId,proto3,oneof" json:"session_id,omitempty"`
+	AppVersion *string          `protobuf:"bytes,8,opt,name=app_version,json=appVersion,proto3,oneof" json:"app_version,omitempty"`
+	// Device details such as platform and model
+	DeviceInfo *structpb.Struct `protobuf:"bytes,9,opt,name=device_info,json=deviceInfo,proto3" json:"device_info,omitempty"`
+	// Client-side ordering within a session
+	SequenceNumber *int64 `protobuf:"varint,10,opt,name=sequence_number,json=sequenceNumber,proto3,oneof" json:"sequence_number,omitempty"`
+	// normal (default) or high
+	Priority string `protobuf:"bytes,11,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Fraction of events the client kept when sampling, in (0, 1]; the server
+	// multiplies it by its own sampling rate. Unset or 0 means 1.
+	SampleRate    float64 `protobuf:"fixed64,12,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyticsLog) Reset() {
+	*x = AnalyticsLog{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyticsLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyticsLog) ProtoMessage() {}
+
+func (x *AnalyticsLog) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyticsLog.ProtoReflect.Descriptor instead.
+func (*AnalyticsLog) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnalyticsLog) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *AnalyticsLog) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetEventName() string {
+	if x != nil {
+		return x.EventName
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetProperties() *structpb.Struct {
+	if x != nil {
+		return x.Properties
+	}
+	return nil
+}
+
+func (x *AnalyticsLog) GetUserId() string {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetSessionId() string {
+	if x != nil && x.SessionId != nil {
+		return *x.SessionId
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetAppVersion() string {
+	if x != nil && x.AppVersion != nil {
+		return *x.AppVersion
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetDeviceInfo() *structpb.Struct {
+	if x != nil {
+		return x.DeviceInfo
+	}
+	return nil
+}
+
+func (x *AnalyticsLog) GetSequenceNumber() int64 {
+	if x != nil && x.SequenceNumber != nil {
+		return *x.SequenceNumber
+	}
+	return 0
+}
+
+func (x *AnalyticsLog) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *AnalyticsLog) GetSampleRate() float64 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+// BatchRequest carries up to MAX_BATCH_SIZE logs
+type BatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Logs  []*AnalyticsLog        `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
+	// When the client sent the batch, by its own clock; used to correct skew
+	SentAt        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchRequest) Reset() {
+	*x = BatchRequest{}
+	mi := &file_analytics_v1_analytics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchRequest) ProtoMessage() {}
+
+func (x *BatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analytics_v1_analytics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchRequest.ProtoReflect.Descriptor instead.
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return file_analytics_v1_analytics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BatchRequest) GetLogs() []*AnalyticsLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetSentAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SentAt
+	}
+	return nil
+}
+
+var File_analytics_v1_analytics_proto protoreflect.FileDescriptor
+
+const file_analytics_v1_analytics_proto_rawDesc = "" +
+	"\n" +
+	"\x1canalytics/v1/analytics.proto\x12\fanalytics.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa6\x04\n" +
+	"\fAnalyticsLog\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x03 \x01(\tR\teventType\x12\x1d\n" +
+	"\n" +
+	"event_name\x18\x04 \x01(\tR\teventName\x127\n" +
+	"\n" +
+	"properties\x18\x05 \x01(\v2\x17.google.protobuf.StructR\n" +
+	"properties\x12\x1c\n" +
+	"\auser_id\x18\x06 \x01(\tH\x00R\x06userId\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"session_id\x18\a \x01(\tH\x01R\tsessionId\x88\x01\x01\x12$\n" +
+	"\vapp_version\x18\b \x01(\tH\x02R\n" +
+	"appVersion\x88\x01\x01\x128\n" +
+	"\vdevice_info\x18\t \x01(\v2\x17.google.protobuf.StructR\n" +
+	"deviceInfo\x12,\n" +
+	"\x0fsequence_number\x18\n" +
+	" \x01(\x03H\x03R\x0esequenceNumber\x88\x01\x01\x12\x1a\n" +
+	"\bpriority\x18\v \x01(\tR\bpriority\x12\x1f\n" +
+	"\vsample_rate\x18\f \x01(\x01R\n" +
+	"sampleRateB\n" +
+	"\n" +
+	"\b_user_idB\r\n" +
+	"\v_session_idB\x0e\n" +
+	"\f_app_versionB\x12\n" +
+	"\x10_sequence_number\"s\n" +
+	"\fBatchRequest\x12.\n" +
+	"\x04logs\x18\x01 \x03(\v2\x1a.analytics.v1.AnalyticsLogR\x04logs\x123\n" +
+	"\asent_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x06sentAtB5Z3log-ingestion-server/proto/analytics/v1;analyticsv1b\x06proto3"
+
+var (
+	file_analytics_v1_analytics_proto_rawDescOnce sync.Once
+	file_analytics_v1_analytics_proto_rawDescData []byte
+)
+
+func file_analytics_v1_analytics_proto_rawDescGZIP() []byte {
+	file_analytics_v1_analytics_proto_rawDescOnce.Do(func() {
+		file_analytics_v1_analytics_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_analytics_v1_analytics_proto_rawDesc), len(file_analytics_v1_analytics_proto_rawDesc)))
+	})
+	return file_analytics_v1_analytics_proto_rawDescData
+}
+
+var file_analytics_v1_analytics_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_analytics_v1_analytics_proto_goTypes = []any{
+	(*AnalyticsLog)(nil),          // 0: analytics.v1.AnalyticsLog
+	(*BatchRequest)(nil),          // 1: analytics.v1.BatchRequest
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 3: google.protobuf.Struct
+}
+var file_analytics_v1_analytics_proto_depIdxs = []int32{
+	2, // 0: analytics.v1.AnalyticsLog.timestamp:type_name -> google.protobuf.Timestamp
+	3, // 1: analytics.v1.AnalyticsLog.properties:type_name -> google.protobuf.Struct
+	3, // 2: analytics.v1.AnalyticsLog.device_info:type_name -> google.protobuf.Struct
+	0, // 3: analytics.v1.BatchRequest.logs:type_name -> analytics.v1.AnalyticsLog
+	2, // 4: analytics.v1.BatchRequest.sent_at:type_name -> google.protobuf.Timestamp
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_analytics_v1_analytics_proto_init() }
+func file_analytics_v1_analytics_proto_init() {
+	if File_analytics_v1_analytics_proto != nil {
+		return
+	}
+	file_analytics_v1_analytics_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_analytics_v1_analytics_proto_rawDesc), len(file_analytics_v1_analytics_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_analytics_v1_analytics_proto_goTypes,
+		DependencyIndexes: file_analytics_v1_analytics_proto_depIdxs,
+		MessageInfos:      file_analytics_v1_analytics_proto_msgTypes,
+	}.Build()
+	File_analytics_v1_analytics_proto = out.File
+	file_analytics_v1_analytics_proto_goTypes = nil
+	file_analytics_v1_analytics_proto_depIdxs = nil
+}
@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// detector finds sensitive substrings in a string value. The regular
+// expression proposes candidates, which valid then confirms.
+type detector struct {
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// detectors holds the built-in detectors by name. The order they are applied
+// in is that of DetectorNames.
+var detectors = map[string]detector{
+	"jwt": {
+		pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	"email": {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		pattern: regexp.MustCompile(`\d(?:[ -]?\d){12,18}`),
+		valid:   validCardNumber,
+	},
+	"phone": {
+		pattern: regexp.MustCompile(`\+\d{8,15}|(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\d{2,4}[ .-])\d{3,4}[ .-]?\d{3,4}`),
+		valid:   validPhoneNumber,
+	},
+	"ipv4": {
+		pattern: regexp.MustCompile(`\d{1,3}(?:\.\d{1,3}){3}`),
+		valid:   func(match string) bool { return net.ParseIP(match) != nil },
+	},
+	"ipv6": {
+		// Addresses ending in an embedded IPv4 address are tried first, so the
+		// whole of ::ffff:192.0.2.1 is matched rather than ::ffff:192
+		pattern: regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,6}\d{1,3}(?:\.\d{1,3}){3}|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`),
+		valid:   validIPv6,
+	},
+}
+
+// DetectorNames lists the built-in detectors in the order BuiltinRules
+// applies them, so that tokens and card numbers are matched before the looser
+// phone and IP patterns see their digits
+var DetectorNames = []string{"jwt", "email", "credit_card", "phone", "ipv4", "ipv6"}
+
+// find returns the byte ranges of confirmed matches in s. Candidates embedded
+// in a longer run of letters or digits are ignored.
+func (d detector) find(s string) [][]int {
+	var matches [][]int
+	for _, loc := range d.pattern.FindAllStringIndex(s, -1) {
+		if !isBoundary(s, loc[0]-1) || !isBoundary(s, loc[1]) {
+			continue
+		}
+		if d.valid != nil && !d.valid(s[loc[0]:loc[1]]) {
+			continue
+		}
+		matches = append(matches, loc)
+	}
+	return matches
+}
+
+// isBoundary reports whether the byte at i is absent or not alphanumeric
+func isBoundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	c := s[i]
+	return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z')
+}
+
+// validCardNumber applies the Luhn checksum to a 13 to 19 digit number
+func validCardNumber(match string) bool {
+	digits := onlyDigits(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validPhoneNumber accepts the digit counts of E.164 numbers
+func validPhoneNumber(match string) bool {
+	n := len(onlyDigits(match))
+	return n >= 7 && n <= 15
+}
+
+// validIPv6 accepts IPv6 addresses, excluding IPv4 and the bare "::"
+func validIPv6(match string) bool {
+	if strings.Trim(match, ":") == "" {
+		return false
+	}
+	ip := net.ParseIP(match)
+	return ip != nil && strings.Contains(match, ":")
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,365 @@
+// Package redact removes personal data and credentials that clients log by
+// accident from analytics log properties and device info before they are
+// stored.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/config"
+	"log-ingestion-server/models"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Actions a rule can take on a match
+const (
+	ActionMask = "mask"
+	ActionHash = "hash"
+	ActionDrop = "drop"
+)
+
+// MarkerProperty is the properties key listing what was redacted from a log
+const MarkerProperty = "_redactions"
+
+// Rule matches sensitive data and says what to do with it. Exactly one of
+// Detector, Pattern or Key is set: Detector and Pattern match substrings of
+// string values, Key matches field names and applies to the whole value.
+type Rule struct {
+	Name     string `json:"name"`
+	Detector string `json:"detector,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Action   string `json:"action"`
+}
+
+// Redaction records one field a rule matched, without the matched value
+type Redaction struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+}
+
+// compiledRule is a rule ready to match
+type compiledRule struct {
+	name     string
+	action   string
+	detector detector
+	key      *regexp.Regexp
+}
+
+// Redactor applies a set of rules to logs
+type Redactor struct {
+	valueRules []compiledRule
+	keyRules   []compiledRule
+	hashKey    []byte
+}
+
+// New compiles rules into a redactor. hashKey keys the HMAC used by the hash
+// action and is required when any rule hashes.
+func New(rules []Rule, hashKey string) (*Redactor, error) {
+	r := &Redactor{hashKey: []byte(hashKey)}
+	seen := make(map[string]bool)
+
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		switch rule.Action {
+		case ActionMask, ActionDrop:
+		case ActionHash:
+			if hashKey == "" {
+				return nil, fmt.Errorf("rule %s: the hash action requires a hash key", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("rule %s: unknown action %q", rule.Name, rule.Action)
+		}
+
+		compiled := compiledRule{name: rule.Name, action: rule.Action}
+		set := 0
+		if rule.Detector != "" {
+			set++
+			d, ok := detectors[rule.Detector]
+			if !ok {
+				return nil, fmt.Errorf("rule %s: unknown detector %q", rule.Name, rule.Detector)
+			}
+			compiled.detector = d
+		}
+		if rule.Pattern != "" {
+			set++
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			compiled.detector = detector{pattern: pattern}
+		}
+		if rule.Key != "" {
+			set++
+			key, err := regexp.Compile("(?i)" + rule.Key)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			compiled.key = key
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("rule %s: exactly one of detector, pattern or key is required", rule.Name)
+		}
+
+		if compiled.key != nil {
+			r.keyRules = append(r.keyRules, compiled)
+		} else {
+			r.valueRules = append(r.valueRules, compiled)
+		}
+	}
+
+	return r, nil
+}
+
+// FromConfig builds the redactor described by configuration: the listed
+// built-in detectors (all of them when none are listed, none for "none")
+// followed by the rules in the rules file
+func FromConfig(cfg config.RedactionConfig) (*Redactor, error) {
+	specs := cfg.Detectors
+	if len(specs) == 0 {
+		specs = DetectorNames
+	}
+	if len(specs) == 1 && strings.TrimSpace(specs[0]) == "none" {
+		specs = nil
+	}
+
+	rules, err := BuiltinRules(specs, cfg.DefaultAction)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.RulesFile != "" {
+		custom, err := LoadRules(cfg.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, custom...)
+	}
+
+	return New(rules, cfg.HashKey)
+}
+
+// BuiltinRules returns a rule for each named built-in detector. Entries have
+// the form "detector" or "detector:action"; the action defaults to
+// defaultAction.
+func BuiltinRules(specs []string, defaultAction string) ([]Rule, error) {
+	var rules []Rule
+	for _, spec := range specs {
+		name, action := strings.TrimSpace(spec), defaultAction
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name, action = name[:i], name[i+1:]
+		}
+		if _, ok := detectors[name]; !ok {
+			return nil, fmt.Errorf("unknown detector %q (available: %s)", name, strings.Join(DetectorNames, ", "))
+		}
+		rules = append(rules, Rule{Name: name, Detector: name, Action: action})
+	}
+
+	// Apply the built-ins in their documented order regardless of how they were listed
+	order := make(map[string]int, len(DetectorNames))
+	for i, name := range DetectorNames {
+		order[name] = i
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return order[rules[i].Detector] < order[rules[j].Detector] })
+
+	return rules, nil
+}
+
+// LoadRules reads custom rules from a JSON file holding an array of rules
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Apply redacts a log's event name, user and session IDs, properties and
+// device info in place and returns what was redacted. When anything was, the
+// list is also stored on the log under MarkerProperty.
+func (r *Redactor) Apply(log *models.AnalyticsLog) []Redaction {
+	var redactions []Redaction
+
+	// event_name is required, so it is masked where a rule would drop it
+	if !r.redactField(&log.EventName, "event_name", &redactions) {
+		log.EventName = mask(redactions[len(redactions)-1].Rule)
+	}
+	log.UserID = r.redactOptionalField(log.UserID, "user_id", &redactions)
+	log.SessionID = r.redactOptionalField(log.SessionID, "session_id", &redactions)
+
+	r.redactMap(log.Properties, "properties", &redactions)
+	r.redactMap(log.DeviceInfo, "device_info", &redactions)
+
+	if len(redactions) > 0 {
+		if log.Properties == nil {
+			log.Properties = make(models.JSONB)
+		}
+		log.Properties[MarkerProperty] = redactions
+	}
+	return redactions
+}
+
+// redactField runs the value rules over a top-level field. A match replaces
+// the whole value, so it stays within its column and a hashed ID still
+// identifies one user or session. It returns false if the field must be
+// dropped.
+func (r *Redactor) redactField(value *string, field string, redactions *[]Redaction) bool {
+	for _, rule := range r.valueRules {
+		if len(rule.detector.find(*value)) == 0 {
+			continue
+		}
+
+		*redactions = append(*redactions, Redaction{Field: field, Rule: rule.name, Action: rule.action})
+		switch rule.action {
+		case ActionDrop:
+			return false
+		case ActionHash:
+			*value = r.hash(*value)
+		default:
+			*value = mask(rule.name)
+		}
+		return true
+	}
+	return true
+}
+
+// redactOptionalField redacts a copy of an optional top-level field, returning
+// nil if it is dropped
+func (r *Redactor) redactOptionalField(value *string, field string, redactions *[]Redaction) *string {
+	if value == nil {
+		return nil
+	}
+	redacted := *value
+	if !r.redactField(&redacted, field, redactions) {
+		return nil
+	}
+	return &redacted
+}
+
+// redactMap redacts the values of m in place
+func (r *Redactor) redactMap(m map[string]interface{}, path string, redactions *[]Redaction) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if strings.HasPrefix(key, "_") && path == "properties" {
+			// Skip server-written markers such as _schema_violations
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := path + "." + key
+
+		if rule := r.matchKey(key); rule != nil {
+			*redactions = append(*redactions, Redaction{Field: field, Rule: rule.name, Action: rule.action})
+			switch rule.action {
+			case ActionDrop:
+				delete(m, key)
+			case ActionHash:
+				m[key] = r.hash(fmt.Sprint(m[key]))
+			default:
+				m[key] = mask(rule.name)
+			}
+			continue
+		}
+
+		value, keep := r.redactValue(m[key], field, redactions)
+		if keep {
+			m[key] = value
+		} else {
+			delete(m, key)
+		}
+	}
+}
+
+// redactValue returns the redacted value, or false if it must be dropped
+func (r *Redactor) redactValue(value interface{}, field string, redactions *[]Redaction) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v, field, redactions)
+	case map[string]interface{}:
+		r.redactMap(v, field, redactions)
+	case models.JSONB:
+		r.redactMap(v, field, redactions)
+	case []interface{}:
+		kept := v[:0]
+		for i, item := range v {
+			if item, keep := r.redactValue(item, fmt.Sprintf("%s[%d]", field, i), redactions); keep {
+				kept = append(kept, item)
+			}
+		}
+		return kept, true
+	}
+	return value, true
+}
+
+// redactString runs the value rules over s in order
+func (r *Redactor) redactString(s, field string, redactions *[]Redaction) (interface{}, bool) {
+	for _, rule := range r.valueRules {
+		matches := rule.detector.find(s)
+		if len(matches) == 0 {
+			continue
+		}
+
+		*redactions = append(*redactions, Redaction{Field: field, Rule: rule.name, Action: rule.action})
+		if rule.action == ActionDrop {
+			return nil, false
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range matches {
+			b.WriteString(s[last:loc[0]])
+			if rule.action == ActionHash {
+				b.WriteString(r.hash(s[loc[0]:loc[1]]))
+			} else {
+				b.WriteString(mask(rule.name))
+			}
+			last = loc[1]
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s, true
+}
+
+func (r *Redactor) matchKey(key string) *compiledRule {
+	for i := range r.keyRules {
+		if r.keyRules[i].key.MatchString(key) {
+			return &r.keyRules[i]
+		}
+	}
+	return nil
+}
+
+// hash replaces a value with a keyed digest, so equal values can still be
+// correlated without being recoverable
+func (r *Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func mask(rule string) string {
+	return "[redacted:" + rule + "]"
+}
@@ -0,0 +1,276 @@
+package redact
+
+import (
+	"log-ingestion-server/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T, rules ...Rule) *Redactor {
+	t.Helper()
+	r, err := New(rules, "test-key")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestBuiltinRulesOrder(t *testing.T) {
+	rules, err := BuiltinRules([]string{"ipv4", "phone:hash", "jwt", "credit_card"}, ActionMask)
+	if err != nil {
+		t.Fatalf("BuiltinRules: %v", err)
+	}
+
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	if want := []string{"jwt", "credit_card", "phone", "ipv4"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("rules applied in order %v, want %v", names, want)
+	}
+	if rules[2].Action != ActionHash || rules[0].Action != ActionMask {
+		t.Errorf("actions = %+v, want phone hashed and the rest masked", rules)
+	}
+
+	if _, err := BuiltinRules([]string{"passport"}, ActionMask); err == nil {
+		t.Error("BuiltinRules accepted an unknown detector")
+	}
+}
+
+// TestApplyOrder checks that card numbers are claimed before the phone
+// detector sees their digits, however the detectors were listed
+func TestApplyOrder(t *testing.T) {
+	rules, err := BuiltinRules([]string{"phone", "credit_card"}, ActionMask)
+	if err != nil {
+		t.Fatalf("BuiltinRules: %v", err)
+	}
+	r := newTestRedactor(t, rules...)
+
+	log := &models.AnalyticsLog{EventName: "checkout", Properties: models.JSONB{"note": "paid with 4111 1111 1111 1111"}}
+	r.Apply(log)
+
+	if got := log.Properties["note"]; got != "paid with [redacted:credit_card]" {
+		t.Errorf("note = %q, want the card masked as a card", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	email := Rule{Name: "email", Detector: "email", Action: ActionMask}
+	hashEmail := Rule{Name: "email", Detector: "email", Action: ActionHash}
+	dropEmail := Rule{Name: "email", Detector: "email", Action: ActionDrop}
+	secrets := Rule{Name: "secrets", Key: "^(password|token)$", Action: ActionDrop}
+	member := Rule{Name: "member_id", Pattern: `MBR-[0-9]{8}`, Action: ActionMask}
+
+	tests := []struct {
+		name           string
+		rules          []Rule
+		log            models.AnalyticsLog
+		wantEventName  string
+		wantUserID     *string
+		wantSessionID  *string
+		wantProperties models.JSONB
+		wantDeviceInfo models.JSONB
+		wantFields     []string
+	}{
+		{
+			name:           "nothing to redact",
+			rules:          []Rule{email},
+			log:            models.AnalyticsLog{EventName: "app_open", UserID: stringPtr("u-1"), Properties: models.JSONB{"screen": "home"}},
+			wantEventName:  "app_open",
+			wantUserID:     stringPtr("u-1"),
+			wantProperties: models.JSONB{"screen": "home"},
+		},
+		{
+			name:  "nested values",
+			rules: []Rule{email, member},
+			log: models.AnalyticsLog{
+				EventName: "signup",
+				Properties: models.JSONB{
+					"contact": map[string]interface{}{"primary": "a@example.com", "ids": []interface{}{"MBR-12345678", 7}},
+					"count":   3.0,
+				},
+				DeviceInfo: models.JSONB{"owner": "owner is b@example.com"},
+			},
+			wantEventName: "signup",
+			wantProperties: models.JSONB{
+				"contact": map[string]interface{}{"primary": "[redacted:email]", "ids": []interface{}{"[redacted:member_id]", 7}},
+				"count":   3.0,
+			},
+			wantDeviceInfo: models.JSONB{"owner": "owner is [redacted:email]"},
+			wantFields:     []string{"properties.contact.ids[0]", "properties.contact.primary", "device_info.owner"},
+		},
+		{
+			name:  "key rules and dropped values",
+			rules: []Rule{secrets, dropEmail},
+			log: models.AnalyticsLog{
+				EventName:  "login",
+				Properties: models.JSONB{"password": "hunter2", "Token": "abc", "emails": []interface{}{"a@example.com", "kept"}},
+			},
+			wantEventName:  "login",
+			wantProperties: models.JSONB{"emails": []interface{}{"kept"}},
+			wantFields:     []string{"properties.Token", "properties.emails[0]", "properties.password"},
+		},
+		{
+			name:  "server markers skipped",
+			rules: []Rule{email},
+			log: models.AnalyticsLog{
+				EventName:  "purchase",
+				Properties: models.JSONB{"_schema_violations": []interface{}{"x@example.com"}},
+			},
+			wantEventName:  "purchase",
+			wantProperties: models.JSONB{"_schema_violations": []interface{}{"x@example.com"}},
+		},
+		{
+			name:  "top-level fields masked whole",
+			rules: []Rule{email},
+			log: models.AnalyticsLog{
+				EventName: "sent_to_ops@example.com",
+				UserID:    stringPtr("jane@example.com"),
+				SessionID: stringPtr("s-1"),
+			},
+			wantEventName: "[redacted:email]",
+			wantUserID:    stringPtr("[redacted:email]"),
+			wantSessionID: stringPtr("s-1"),
+			wantFields:    []string{"event_name", "user_id"},
+		},
+		{
+			name:  "top-level IDs hashed whole",
+			rules: []Rule{hashEmail},
+			log: models.AnalyticsLog{
+				EventName: "app_open",
+				UserID:    stringPtr("jane@example.com"),
+				SessionID: stringPtr("jane@example.com#1"),
+			},
+			wantEventName: "app_open",
+			wantUserID:    stringPtr(newTestRedactor(t, hashEmail).hash("jane@example.com")),
+			wantSessionID: stringPtr(newTestRedactor(t, hashEmail).hash("jane@example.com#1")),
+			wantFields:    []string{"user_id", "session_id"},
+		},
+		{
+			name:  "top-level fields dropped",
+			rules: []Rule{dropEmail},
+			log: models.AnalyticsLog{
+				EventName: "mail_ops@example.com",
+				UserID:    stringPtr("jane@example.com"),
+				SessionID: stringPtr("s-1"),
+			},
+			wantEventName: "[redacted:email]",
+			wantSessionID: stringPtr("s-1"),
+			wantFields:    []string{"event_name", "user_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedactor(t, tt.rules...)
+			log := tt.log
+			userID, original := log.UserID, derefString(log.UserID)
+
+			redactions := r.Apply(&log)
+
+			if log.EventName != tt.wantEventName {
+				t.Errorf("event_name = %q, want %q", log.EventName, tt.wantEventName)
+			}
+			if !reflect.DeepEqual(log.UserID, tt.wantUserID) {
+				t.Errorf("user_id = %v, want %v", derefString(log.UserID), derefString(tt.wantUserID))
+			}
+			if !reflect.DeepEqual(log.SessionID, tt.wantSessionID) {
+				t.Errorf("session_id = %v, want %v", derefString(log.SessionID), derefString(tt.wantSessionID))
+			}
+			if derefString(userID) != original {
+				t.Error("user_id was redacted through the caller's pointer")
+			}
+
+			var fields []string
+			for _, redaction := range redactions {
+				fields = append(fields, redaction.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("redacted fields = %v, want %v", fields, tt.wantFields)
+			}
+
+			// The marker lists the redactions, without the values
+			properties := log.Properties
+			if len(redactions) > 0 {
+				if marker := properties[MarkerProperty]; !reflect.DeepEqual(marker, redactions) {
+					t.Errorf("%s = %v, want %v", MarkerProperty, marker, redactions)
+				}
+				properties = copyWithout(properties, MarkerProperty)
+			}
+			if len(properties) != len(tt.wantProperties) || (len(properties) > 0 && !reflect.DeepEqual(properties, tt.wantProperties)) {
+				t.Errorf("properties = %v, want %v", properties, tt.wantProperties)
+			}
+			if !reflect.DeepEqual(log.DeviceInfo, tt.wantDeviceInfo) {
+				t.Errorf("device_info = %v, want %v", log.DeviceInfo, tt.wantDeviceInfo)
+			}
+		})
+	}
+}
+
+func TestHashIsKeyed(t *testing.T) {
+	a, err := New(nil, "key-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(nil, "key-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.hash("jane@example.com") != a.hash("jane@example.com") {
+		t.Error("equal values hash differently")
+	}
+	if a.hash("jane@example.com") == b.hash("jane@example.com") {
+		t.Error("different keys give the same hash")
+	}
+	if got := a.hash("jane@example.com"); !strings.HasPrefix(got, "hmac:") || len(got) != len("hmac:")+16 {
+		t.Errorf("hash = %q, want hmac: and 16 hex digits", got)
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		hashKey string
+	}{
+		{name: "missing name", rules: []Rule{{Detector: "email", Action: ActionMask}}},
+		{name: "duplicate name", rules: []Rule{{Name: "a", Detector: "email", Action: ActionMask}, {Name: "a", Detector: "jwt", Action: ActionMask}}},
+		{name: "unknown action", rules: []Rule{{Name: "a", Detector: "email", Action: "erase"}}},
+		{name: "hash without key", rules: []Rule{{Name: "a", Detector: "email", Action: ActionHash}}},
+		{name: "unknown detector", rules: []Rule{{Name: "a", Detector: "passport", Action: ActionMask}}},
+		{name: "invalid pattern", rules: []Rule{{Name: "a", Pattern: "(", Action: ActionMask}}},
+		{name: "no matcher", rules: []Rule{{Name: "a", Action: ActionMask}}},
+		{name: "two matchers", rules: []Rule{{Name: "a", Detector: "email", Key: "email", Action: ActionMask}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules, tt.hashKey); err == nil {
+				t.Error("New accepted the rules")
+			}
+		})
+	}
+}
+
+func derefString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func copyWithout(m models.JSONB, key string) models.JSONB {
+	out := make(models.JSONB, len(m))
+	for k, v := range m {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,392 @@
+// Package retention enforces the retention policies stored in the
+// retention_policies table, deleting expired logs from analytics_logs and
+// expired rows from server_metrics.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// runsKept is the number of run records kept in retention_runs
+const runsKept = 100
+
+// serverMetricsPolicy names the server_metrics retention in run results and
+// metrics
+const serverMetricsPolicy = "server_metrics"
+
+// Options configures the retention job
+type Options struct {
+	// Interval between runs
+	Interval time.Duration
+	// BatchSize is the number of rows removed per delete statement
+	BatchSize int
+	// ServerMetricsRetention is how long server_metrics rows are kept; zero
+	// keeps them forever
+	ServerMetricsRetention time.Duration
+	// ArchiveAfter is the archiver's ARCHIVE_AFTER, or zero when logs are not
+	// archived. Logs are then only deleted from days and event types the
+	// archiver has written files for.
+	ArchiveAfter time.Duration
+}
+
+// Validate checks that a policy's event name pattern can be matched in SQL,
+// where only the * and ? wildcards are supported
+func Validate(p models.RetentionPolicy) error {
+	if strings.ContainsAny(p.EventName, `[]\`) {
+		return fmt.Errorf("event_name patterns support only the * and ? wildcards")
+	}
+	return nil
+}
+
+// ValidateArchive checks that a policy keeps logs until they are archived.
+// The archiver moves a day once it closed archiveAfter ago, so a shorter
+// policy would delete logs the archiver never sees. A zero archiveAfter means
+// logs are not archived.
+func ValidateArchive(p models.RetentionPolicy, archiveAfter time.Duration) error {
+	if archiveAfter <= 0 || !p.IsActive {
+		return nil
+	}
+
+	const day = 24 * time.Hour
+	if time.Duration(p.RetentionDays)*day <= archiveAfter+day {
+		afterDays := int((archiveAfter + day - 1) / day)
+		return fmt.Errorf("retention_days must be more than %d while logs are archived after %d days, or logs are deleted before they are archived",
+			afterDays+1, afterDays)
+	}
+	return nil
+}
+
+// Purger periodically deletes the logs and server metrics that outlived their
+// retention. A run holds a PostgreSQL advisory lock, so when several replicas
+// run the purger only one of them works at a time.
+type Purger struct {
+	db       *database.DB
+	opts     Options
+	metrics  *Metrics
+	instance string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the retention job
+type Metrics struct {
+	RowsDeleted       *prometheus.CounterVec
+	PartitionsDropped prometheus.Counter
+	Runs              *prometheus.CounterVec
+	RunDuration       prometheus.Histogram
+	LastSuccess       prometheus.Gauge
+}
+
+// NewPurger creates a retention purger
+func NewPurger(db *database.DB, opts Options) *Purger {
+	metrics := &Metrics{
+		RowsDeleted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "retention_rows_deleted_total",
+				Help: "Total number of expired rows deleted, by retention policy",
+			},
+			[]string{"policy"},
+		),
+		PartitionsDropped: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "retention_partitions_dropped_total",
+				Help: "Total number of expired analytics_logs partitions dropped",
+			},
+		),
+		Runs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "retention_runs_total",
+				Help: "Total number of retention runs performed by this instance, by status",
+			},
+			[]string{"status"},
+		),
+		RunDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "retention_run_duration_seconds",
+				Help:    "Duration of retention runs",
+				Buckets: prometheus.ExponentialBuckets(0.1, 4, 10),
+			},
+		),
+		LastSuccess: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "retention_last_success_timestamp_seconds",
+				Help: "Unix time of the last successful retention run on this instance",
+			},
+		),
+	}
+
+	prometheus.MustRegister(
+		metrics.RowsDeleted,
+		metrics.PartitionsDropped,
+		metrics.Runs,
+		metrics.RunDuration,
+		metrics.LastSuccess,
+	)
+
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Purger{
+		db:       db,
+		opts:     opts,
+		metrics:  metrics,
+		instance: instance,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the purger in the background until Stop is called
+func (p *Purger) Start() {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.run()
+
+			select {
+			case <-ticker.C:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	logrus.Infof("Retention purger started (every %s, batches of %d)", p.opts.Interval, p.opts.BatchSize)
+}
+
+// Stop interrupts the current run between batches and stops the purger
+func (p *Purger) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+// run enforces every policy once, if no other instance is already doing so,
+// and records the outcome in retention_runs
+func (p *Purger) run() {
+	release, err := p.db.TryRetentionLock(p.ctx)
+	if err != nil {
+		if p.ctx.Err() == nil {
+			logrus.Errorf("Retention run failed: %v", err)
+			p.metrics.Runs.WithLabelValues(models.RetentionRunFailed).Inc()
+		}
+		return
+	}
+	if release == nil {
+		logrus.Debug("Retention run skipped: another instance holds the lock")
+		return
+	}
+	defer release()
+
+	run := &models.RetentionRun{
+		Instance:  p.instance,
+		StartedAt: time.Now(),
+		Results:   []models.RetentionResult{},
+	}
+
+	err = p.enforce(run)
+	run.FinishedAt = time.Now()
+
+	switch {
+	case err == nil:
+		run.Status = models.RetentionRunSucceeded
+		p.metrics.LastSuccess.SetToCurrentTime()
+	case p.ctx.Err() != nil:
+		run.Status = models.RetentionRunInterrupted
+	default:
+		run.Status = models.RetentionRunFailed
+		run.Error = err.Error()
+		logrus.Errorf("Retention run failed: %v", err)
+	}
+	p.metrics.Runs.WithLabelValues(run.Status).Inc()
+	p.metrics.RunDuration.Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+
+	for _, result := range run.Results {
+		run.RowsDeleted += result.RowsDeleted
+		run.PartitionsDropped += result.PartitionsDropped
+	}
+
+	if err := p.db.InsertRetentionRun(run, runsKept); err != nil {
+		logrus.Errorf("Failed to record retention run: %v", err)
+	}
+
+	if run.RowsDeleted > 0 || run.PartitionsDropped > 0 {
+		logrus.Infof("Retention run %s: %d rows deleted, %d partitions dropped in %s",
+			run.Status, run.RowsDeleted, run.PartitionsDropped, run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+	}
+}
+
+// enforce applies the active policies, then the server_metrics retention,
+// adding a result to run as each one completes
+func (p *Purger) enforce(run *models.RetentionRun) error {
+	stored, err := p.db.ListRetentionPolicies()
+	if err != nil {
+		return err
+	}
+
+	var policies []models.RetentionPolicy
+	for _, policy := range stored {
+		if !policy.IsActive {
+			continue
+		}
+		if err := Validate(policy); err != nil {
+			logrus.Errorf("Skipping retention policy %s: %v", policy.Name, err)
+			continue
+		}
+		if err := ValidateArchive(policy, p.opts.ArchiveAfter); err != nil {
+			logrus.Errorf("Skipping retention policy %s: %v", policy.Name, err)
+			continue
+		}
+		policies = append(policies, policy)
+	}
+
+	now := time.Now()
+
+	dropped, err := p.dropExpiredPartitions(policies, now)
+	if err != nil {
+		return err
+	}
+
+	// Dropped partitions are reported under the catch-all policy, which is
+	// what allows dropping them
+	last := catchAll(policies)
+	for i, policy := range policies {
+		result := models.RetentionResult{
+			Policy: policy.Name,
+			Table:  "analytics_logs",
+			Cutoff: now.AddDate(0, 0, -policy.RetentionDays),
+		}
+		if i == last {
+			result.PartitionsDropped = dropped
+		}
+
+		err := p.purge(&result, func(limit int) (int64, error) {
+			return p.db.PurgeExpiredLogs(p.ctx, policy, policies[:i], result.Cutoff, limit, p.opts.ArchiveAfter > 0)
+		})
+		run.Results = append(run.Results, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.opts.ServerMetricsRetention > 0 {
+		result := models.RetentionResult{
+			Policy: serverMetricsPolicy,
+			Table:  "server_metrics",
+			Cutoff: now.Add(-p.opts.ServerMetricsRetention),
+		}
+		err := p.purge(&result, func(limit int) (int64, error) {
+			return p.db.PurgeServerMetrics(p.ctx, result.Cutoff, limit)
+		})
+		run.Results = append(run.Results, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purge deletes batches until one comes back short, counting the rows
+func (p *Purger) purge(result *models.RetentionResult, deleteBatch func(limit int) (int64, error)) error {
+	for {
+		if err := p.ctx.Err(); err != nil {
+			return err
+		}
+
+		deleted, err := deleteBatch(p.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		result.RowsDeleted += deleted
+		p.metrics.RowsDeleted.WithLabelValues(result.Policy).Add(float64(deleted))
+
+		if deleted < int64(p.opts.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// dropExpiredPartitions drops the partitions of analytics_logs whose whole
+// range is past every policy that could apply to their logs. This is only
+// possible when a catch-all policy gives every log a retention; otherwise
+// expired logs are removed row by row. While logs are archived, partitions
+// still holding logs the archiver has not reached are kept.
+func (p *Purger) dropExpiredPartitions(policies []models.RetentionPolicy, now time.Time) (int, error) {
+	last := catchAll(policies)
+	if last < 0 {
+		return 0, nil
+	}
+
+	longest := 0
+	for _, policy := range policies[:last+1] {
+		longest = max(longest, policy.RetentionDays)
+	}
+	cutoff := now.AddDate(0, 0, -longest)
+
+	partitions, err := p.db.ListLogPartitions()
+	if err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	for _, partition := range partitions {
+		if partition.To.After(cutoff) {
+			break
+		}
+
+		if p.opts.ArchiveAfter > 0 {
+			pending, err := p.db.HasUnarchivedLogs(p.ctx, partition.From, partition.To)
+			if err != nil {
+				return dropped, err
+			}
+			if pending {
+				logrus.Warnf("Keeping expired log partition %s until its logs are archived", partition.Name)
+				break
+			}
+		}
+
+		ok, err := p.db.DropLogPartition(partition)
+		if err != nil {
+			return dropped, err
+		}
+		if !ok {
+			// The partition maintainer is busy; the rows are deleted instead
+			break
+		}
+
+		dropped++
+		p.metrics.PartitionsDropped.Inc()
+		logrus.Infof("Dropped expired log partition %s", partition.Name)
+	}
+
+	return dropped, nil
+}
+
+// catchAll returns the index of the first policy matching every log, or -1
+func catchAll(policies []models.RetentionPolicy) int {
+	for i, policy := range policies {
+		if policy.EventType == "" && policy.EventName == "" && policy.Priority == "" && policy.RetentionClass == "" {
+			return i
+		}
+	}
+	return -1
+}
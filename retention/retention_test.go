@@ -0,0 +1,36 @@
+package retention
+
+import (
+	"log-ingestion-server/models"
+	"testing"
+	"time"
+)
+
+func TestValidateArchive(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		days         int
+		inactive     bool
+		archiveAfter time.Duration
+		wantErr      bool
+	}{
+		{name: "archiving disabled", days: 1},
+		{name: "shorter than ARCHIVE_AFTER", days: 7, archiveAfter: 30 * day, wantErr: true},
+		{name: "equal to ARCHIVE_AFTER", days: 30, archiveAfter: 30 * day, wantErr: true},
+		{name: "within the day being archived", days: 31, archiveAfter: 30 * day, wantErr: true},
+		{name: "after the day is archived", days: 32, archiveAfter: 30 * day},
+		{name: "inactive policy", days: 7, inactive: true, archiveAfter: 30 * day},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := models.RetentionPolicy{Name: "p", RetentionDays: tt.days, IsActive: !tt.inactive}
+			err := ValidateArchive(policy, tt.archiveAfter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateArchive() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package rollup keeps the log_rollups and log_rollup_sketches tables up to
+// date: it adds newly created logs to the minute, hour and day rollups and
+// prunes fine-grained rollups once they are old.
+package rollup
+
+import (
+	"context"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// settleLag is how far behind the current time the rollups stay. Logs get
+// their created_at when their insert starts, so a log may become visible
+// shortly after a later one; waiting lets those inserts commit first.
+const settleLag = time.Minute
+
+// chunk is the span of creation times rolled up per transaction, so catching
+// up on a large backlog does not hold one long transaction
+const chunk = 15 * time.Minute
+
+// Options configures the rollup aggregator
+type Options struct {
+	// Interval between aggregation rounds
+	Interval time.Duration
+	// MinuteRetention is how long minute rollups are kept
+	MinuteRetention time.Duration
+	// HourRetention is how long hour rollups are kept; zero keeps them
+	// forever. Day rollups are always kept.
+	HourRetention time.Duration
+}
+
+// Aggregator periodically rolls up new logs. A round holds a PostgreSQL
+// advisory lock, so when several replicas run the aggregator only one of them
+// works at a time.
+type Aggregator struct {
+	db      *database.DB
+	opts    Options
+	metrics *Metrics
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Metrics holds Prometheus metrics for the rollup aggregator
+type Metrics struct {
+	LogsRolledUp  prometheus.Counter
+	Lag           prometheus.Gauge
+	ChunkDuration prometheus.Histogram
+	Errors        prometheus.Counter
+}
+
+// NewAggregator creates a rollup aggregator
+func NewAggregator(db *database.DB, opts Options) *Aggregator {
+	metrics := &Metrics{
+		LogsRolledUp: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "rollup_logs_aggregated_total",
+				Help: "Total number of logs added to the rollups by this instance",
+			},
+		),
+		Lag: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "rollup_lag_seconds",
+				Help: "Age of the newest creation time covered by the rollups",
+			},
+		),
+		ChunkDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "rollup_chunk_duration_seconds",
+				Help:    "Duration of rolling up one chunk of logs",
+				Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+			},
+		),
+		Errors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "rollup_errors_total",
+				Help: "Total number of failed rollup operations",
+			},
+		),
+	}
+
+	prometheus.MustRegister(
+		metrics.LogsRolledUp,
+		metrics.Lag,
+		metrics.ChunkDuration,
+		metrics.Errors,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Aggregator{
+		db:      db,
+		opts:    opts,
+		metrics: metrics,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs aggregation rounds in the background until Stop is called
+func (a *Aggregator) Start() {
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			a.runRound()
+
+			select {
+			case <-ticker.C:
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	logrus.Infof("Rollup aggregator started (every %s)", a.opts.Interval)
+}
+
+// Stop interrupts the current round between chunks and stops the aggregator
+func (a *Aggregator) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// runRound rolls up the logs created since the watermark, then prunes old
+// rollups
+func (a *Aggregator) runRound() {
+	release, err := a.db.TryRollupLock(a.ctx)
+	if err != nil {
+		if a.ctx.Err() == nil {
+			a.fail("Failed to take the rollup lock: %v", err)
+		}
+		return
+	}
+	if release == nil {
+		logrus.Debug("Rollup round skipped: another instance holds the lock")
+		a.updateLag()
+		return
+	}
+	defer release()
+
+	until := time.Now().Add(-settleLag)
+	if err := a.db.StartRollups(until); err != nil {
+		a.fail("Failed to start rollups: %v", err)
+		return
+	}
+
+	watermark, err := a.db.RollupWatermark()
+	if err != nil {
+		a.fail("Failed to get the rollup watermark: %v", err)
+		return
+	}
+
+	from := *watermark
+	for from.Before(until) && a.ctx.Err() == nil {
+		to := chunkEnd(from, until)
+
+		started := time.Now()
+		rolledUp, err := a.db.RollUpLogs(a.ctx, from, to)
+		if err != nil {
+			if a.ctx.Err() == nil {
+				a.fail("Failed to roll up logs: %v", err)
+			}
+			return
+		}
+		a.metrics.ChunkDuration.Observe(time.Since(started).Seconds())
+		a.metrics.LogsRolledUp.Add(float64(rolledUp))
+		a.metrics.Lag.Set(time.Since(to).Seconds())
+		from = to
+	}
+
+	a.prune(models.GranularityMinute, a.opts.MinuteRetention)
+	a.prune(models.GranularityHour, a.opts.HourRetention)
+}
+
+// chunkEnd returns the end of the chunk of creation times starting at from,
+// which is at most chunk long and never reaches past until
+func chunkEnd(from, until time.Time) time.Time {
+	to := from.Add(chunk)
+	if to.After(until) {
+		return until
+	}
+	return to
+}
+
+// prune deletes the rollups of a granularity older than retention
+func (a *Aggregator) prune(granularity string, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	pruned, err := a.db.PruneRollups(granularity, time.Now().Add(-retention))
+	if err != nil {
+		a.fail("Failed to prune rollups: %v", err)
+		return
+	}
+	if pruned > 0 {
+		logrus.Infof("Pruned %d %s rollups", pruned, granularity)
+	}
+}
+
+// updateLag reports the lag of the rollups maintained by another instance
+func (a *Aggregator) updateLag() {
+	watermark, err := a.db.RollupWatermark()
+	if err != nil || watermark == nil {
+		return
+	}
+	a.metrics.Lag.Set(time.Since(*watermark).Seconds())
+}
+
+func (a *Aggregator) fail(format string, args ...interface{}) {
+	a.metrics.Errors.Inc()
+	logrus.Errorf(format, args...)
+}
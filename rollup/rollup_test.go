@@ -0,0 +1,45 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkEnd(t *testing.T) {
+	watermark := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		until      time.Time
+		wantChunks int
+	}{
+		{name: "less than a chunk behind", until: watermark.Add(40 * time.Second), wantChunks: 1},
+		{name: "exactly one chunk behind", until: watermark.Add(chunk), wantChunks: 1},
+		{name: "backfill ending mid-chunk", until: watermark.Add(3*time.Hour + 7*time.Minute), wantChunks: 13},
+		{name: "caught up", until: watermark, wantChunks: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Walk the chunks the way a round does
+			var chunks int
+			from := watermark
+			for from.Before(tt.until) {
+				to := chunkEnd(from, tt.until)
+				if !to.After(from) || to.Sub(from) > chunk {
+					t.Fatalf("chunk [%s, %s) is empty or longer than %s", from, to, chunk)
+				}
+				chunks++
+				from = to
+			}
+
+			// The chunks cover the range exactly, without gaps or overlap
+			if tt.until.After(watermark) && !from.Equal(tt.until) {
+				t.Errorf("chunks end at %s, want %s", from, tt.until)
+			}
+			if chunks != tt.wantChunks {
+				t.Errorf("rolled up %d chunks, want %d", chunks, tt.wantChunks)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package sampling
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled property condition of the form
+//
+//	<path> exists
+//	<path> <op> <value>
+//
+// where path is a dotted key into properties (e.g. "screen" or "perf.fps"),
+// op is one of == != > >= < <= and value is a JSON literal. A bare word value
+// is read as a string, so `screen == home` and `screen == "home"` are equal;
+// values with spaces must be quoted.
+type Expression struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+// operators are the comparison operators
+var operators = map[string]bool{"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true}
+
+// operatorChars are the characters operators are made of; they end a path
+const operatorChars = "=!<>"
+
+// ParseExpression compiles a property expression. It reads the path up to
+// the first space or operator character, then the operator, then the value,
+// so operator characters inside a quoted or bare value are left alone.
+func ParseExpression(source string) (*Expression, error) {
+	rest := strings.TrimSpace(source)
+	if rest == "" {
+		return nil, fmt.Errorf("empty property expression")
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(operatorChars+`"`, r)
+	})
+	if end < 0 {
+		end = len(rest)
+	}
+	path := rest[:end]
+	if path == "" {
+		return nil, fmt.Errorf("property expression %q has no path", source)
+	}
+	rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+
+	if rest == "exists" {
+		return newExpression(path, "exists", nil)
+	}
+
+	end = strings.IndexFunc(rest, func(r rune) bool {
+		return !strings.ContainsRune(operatorChars, r)
+	})
+	if end < 0 {
+		end = len(rest)
+	}
+	op := rest[:end]
+	if !operators[op] {
+		if op == "" {
+			return nil, fmt.Errorf("property expression %q has no operator (use exists, ==, !=, >, >=, < or <=)", source)
+		}
+		return nil, fmt.Errorf("unknown operator %q in %q (use exists, ==, !=, >, >=, < or <=)", op, source)
+	}
+
+	value, err := parseValue(strings.TrimSpace(rest[end:]))
+	if err != nil {
+		return nil, fmt.Errorf("property expression %q: %w", source, err)
+	}
+	if _, isNumber := value.(float64); !isNumber && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("operator %s needs a number in %q", op, source)
+	}
+
+	return newExpression(path, op, value)
+}
+
+// parseValue reads the value of a comparison: a JSON string, number, boolean
+// or null, or a bare word read as a string
+func parseValue(literal string) (interface{}, error) {
+	if literal == "" {
+		return nil, fmt.Errorf("no value")
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(literal))
+	err := decoder.Decode(&value)
+	if err == nil && decoder.InputOffset() == int64(len(literal)) {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("the value must be a string, number, boolean or null")
+		}
+		return value, nil
+	}
+
+	if strings.HasPrefix(literal, `"`) {
+		if err == nil {
+			return nil, fmt.Errorf("unexpected text after the value")
+		}
+		return nil, fmt.Errorf("invalid string %s", literal)
+	}
+	if strings.ContainsFunc(literal, unicode.IsSpace) {
+		return nil, fmt.Errorf("quote values containing spaces")
+	}
+	return literal, nil
+}
+
+func newExpression(path, op string, value interface{}) (*Expression, error) {
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return nil, fmt.Errorf("invalid property path %q", path)
+		}
+	}
+	return &Expression{path: strings.Split(path, "."), op: op, value: value}, nil
+}
+
+// Match evaluates the expression against a log's properties. A missing key
+// only matches !=.
+func (e *Expression) Match(properties models.JSONB) bool {
+	actual, found := lookup(properties, e.path)
+
+	switch e.op {
+	case "exists":
+		return found
+	case "==":
+		return found && equal(actual, e.value)
+	case "!=":
+		return !found || !equal(actual, e.value)
+	}
+
+	if !found {
+		return false
+	}
+	a, ok := actual.(float64)
+	if !ok {
+		return false
+	}
+	b := e.value.(float64)
+
+	switch e.op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+func lookup(properties models.JSONB, path []string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(properties)
+	for _, key := range path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// equal compares decoded JSON values, treating numbers by value
+func equal(a, b interface{}) bool {
+	switch b := b.(type) {
+	case float64:
+		a, ok := a.(float64)
+		return ok && a == b
+	case string:
+		a, ok := a.(string)
+		return ok && a == b
+	case bool:
+		a, ok := a.(bool)
+		return ok && a == b
+	case nil:
+		return a == nil
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package sampling
+
+import (
+	"log-ingestion-server/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		source    string
+		wantPath  []string
+		wantOp    string
+		wantValue interface{}
+	}{
+		{source: "screen exists", wantPath: []string{"screen"}, wantOp: "exists"},
+		{source: "  perf.fps   exists ", wantPath: []string{"perf", "fps"}, wantOp: "exists"},
+		{source: "screen == home", wantPath: []string{"screen"}, wantOp: "==", wantValue: "home"},
+		{source: `screen == "home"`, wantPath: []string{"screen"}, wantOp: "==", wantValue: "home"},
+		{source: `url == "a=b"`, wantPath: []string{"url"}, wantOp: "==", wantValue: "a=b"},
+		{source: `url == a=b`, wantPath: []string{"url"}, wantOp: "==", wantValue: "a=b"},
+		{source: `url != "a==b"`, wantPath: []string{"url"}, wantOp: "!=", wantValue: "a==b"},
+		{source: `title != "x >= y"`, wantPath: []string{"title"}, wantOp: "!=", wantValue: "x >= y"},
+		{source: `label == "two words"`, wantPath: []string{"label"}, wantOp: "==", wantValue: "two words"},
+		{source: "fps>=30", wantPath: []string{"fps"}, wantOp: ">=", wantValue: 30.0},
+		{source: "fps >= 30", wantPath: []string{"fps"}, wantOp: ">=", wantValue: 30.0},
+		{source: "fps > 30", wantPath: []string{"fps"}, wantOp: ">", wantValue: 30.0},
+		{source: "fps <= -1.5", wantPath: []string{"fps"}, wantOp: "<=", wantValue: -1.5},
+		{source: "fps < 1e3", wantPath: []string{"fps"}, wantOp: "<", wantValue: 1000.0},
+		{source: "beta == true", wantPath: []string{"beta"}, wantOp: "==", wantValue: true},
+		{source: "referrer == null", wantPath: []string{"referrer"}, wantOp: "==", wantValue: nil},
+		{source: "build == 2abc", wantPath: []string{"build"}, wantOp: "==", wantValue: "2abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := ParseExpression(tt.source)
+			if err != nil {
+				t.Fatalf("ParseExpression: %v", err)
+			}
+			if !reflect.DeepEqual(e.path, tt.wantPath) || e.op != tt.wantOp || !reflect.DeepEqual(e.value, tt.wantValue) {
+				t.Errorf("ParseExpression = %q %s %#v, want %q %s %#v", e.path, e.op, e.value, tt.wantPath, tt.wantOp, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"   ",
+		"screen",
+		"== home",
+		"screen = home",
+		"screen => 5",
+		"screen === home",
+		"screen ==",
+		"fps > fast",
+		`fps >= "30"`,
+		`label == two words`,
+		`label == "unterminated`,
+		`label == "a" "b"`,
+		`tags == ["a"]`,
+		`perf == {"fps": 30}`,
+		"perf..fps exists",
+		".fps exists",
+		"screen exists now",
+	} {
+		t.Run(source, func(t *testing.T) {
+			if e, err := ParseExpression(source); err == nil {
+				t.Errorf("ParseExpression accepted %q as %q %s %#v", source, e.path, e.op, e.value)
+			}
+		})
+	}
+}
+
+func TestExpressionMatch(t *testing.T) {
+	properties := models.JSONB{
+		"screen": "home",
+		"url":    "a=b",
+		"fps":    30.0,
+		"beta":   true,
+		"none":   nil,
+		"perf":   map[string]interface{}{"fps": 24.0, "label": "slow"},
+	}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"screen exists", true},
+		{"missing exists", false},
+		{"none exists", true},
+		{"perf.fps exists", true},
+		{"screen.fps exists", false},
+		{"screen == home", true},
+		{`url == "a=b"`, true},
+		{"screen == away", false},
+		{"screen != away", true},
+		{"missing != away", true},
+		{"missing == away", false},
+		{"fps == 30", true},
+		{`fps == "30"`, false},
+		{"fps > 29.5", true},
+		{"fps >= 30", true},
+		{"fps > 30", false},
+		{"fps < 30", false},
+		{"fps <= 30", true},
+		{"perf.fps < 25", true},
+		{"screen > 1", false},
+		{"missing < 1", false},
+		{"beta == true", true},
+		{"beta == false", false},
+		{"none == null", true},
+		{"missing == null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := ParseExpression(tt.source)
+			if err != nil {
+				t.Fatalf("ParseExpression: %v", err)
+			}
+			if got := e.Match(properties); got != tt.want {
+				t.Errorf("Match = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package sampling applies the sampling and drop rules stored in the
+// sampling_rules table to ingested logs, so noisy events can be thinned out
+// or discarded without a redeploy.
+package sampling
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log-ingestion-server/models"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Decisions taken for a log
+const (
+	DecisionKept       = "kept"
+	DecisionSampledOut = "sampled_out"
+	DecisionDropped    = "dropped"
+)
+
+// Store loads the sampling rules
+type Store interface {
+	ListSamplingRules() ([]models.SamplingRule, error)
+}
+
+// Decision is the outcome of the sampling rules for one log
+type Decision struct {
+	// Keep reports whether the log should be stored
+	Keep bool
+	// Rate is the fraction of matching logs kept, to be recorded on the log
+	Rate float64
+	// Rule names the rule that matched, empty if none did
+	Rule string
+	// Outcome is one of the Decision constants
+	Outcome string
+}
+
+// rule is a sampling rule with its property expression compiled
+type rule struct {
+	models.SamplingRule
+	property *Expression
+}
+
+// Registry caches the active sampling rules in evaluation order
+type Registry struct {
+	store Store
+
+	mu    sync.RWMutex
+	rules []rule
+}
+
+// NewRegistry creates an empty registry backed by store
+func NewRegistry(store Store) *Registry {
+	return &Registry{store: store}
+}
+
+// Load replaces the cache with the active rules from the store. Rules with an
+// invalid property expression are skipped and logged.
+func (r *Registry) Load() error {
+	stored, err := r.store.ListSamplingRules()
+	if err != nil {
+		return err
+	}
+
+	rules := make([]rule, 0, len(stored))
+	for _, s := range stored {
+		if !s.IsActive {
+			continue
+		}
+		compiled, err := compile(s)
+		if err != nil {
+			logrus.Errorf("Skipping sampling rule %s: %v", s.Name, err)
+			continue
+		}
+		rules = append(rules, compiled)
+	}
+
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh periodically reloads the cache so rule changes made through
+// other server instances take effect
+func (r *Registry) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Load(); err != nil {
+				logrus.Errorf("Failed to refresh sampling rules: %v", err)
+			}
+		}
+	}()
+}
+
+// Validate checks a rule's patterns and property expression
+func Validate(s models.SamplingRule) error {
+	_, err := compile(s)
+	return err
+}
+
+func compile(s models.SamplingRule) (rule, error) {
+	compiled := rule{SamplingRule: s}
+
+	for field, pattern := range map[string]string{"event_name": s.EventName, "app_version": s.AppVersion} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule{}, fmt.Errorf("invalid %s pattern %q", field, pattern)
+		}
+	}
+
+	if s.Property != "" {
+		expression, err := ParseExpression(s.Property)
+		if err != nil {
+			return rule{}, err
+		}
+		compiled.property = expression
+	}
+
+	return compiled, nil
+}
+
+// Decide applies the first matching rule to a log. High priority logs and
+// errors are always kept, whatever the rules say.
+func (r *Registry) Decide(log *models.AnalyticsLog) Decision {
+	if log.Priority == "high" || log.EventType == "error" {
+		return Decision{Keep: true, Rate: 1, Outcome: DecisionKept}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !rule.matches(log) {
+			continue
+		}
+
+		switch rule.Action {
+		case models.SamplingActionDrop:
+			return Decision{Keep: false, Rate: 0, Rule: rule.Name, Outcome: DecisionDropped}
+		case models.SamplingActionSample:
+			if score(sampleKey(log, rule.SampleBy)) < rule.KeepRate {
+				return Decision{Keep: true, Rate: rule.KeepRate, Rule: rule.Name, Outcome: DecisionKept}
+			}
+			return Decision{Keep: false, Rate: rule.KeepRate, Rule: rule.Name, Outcome: DecisionSampledOut}
+		default:
+			return Decision{Keep: true, Rate: 1, Rule: rule.Name, Outcome: DecisionKept}
+		}
+	}
+
+	return Decision{Keep: true, Rate: 1, Outcome: DecisionKept}
+}
+
+func (r *rule) matches(log *models.AnalyticsLog) bool {
+	if r.EventType != "" && r.EventType != log.EventType {
+		return false
+	}
+	if r.EventName != "" && !globMatch(r.EventName, log.EventName) {
+		return false
+	}
+	if r.AppVersion != "" && (log.AppVersion == nil || !globMatch(r.AppVersion, *log.AppVersion)) {
+		return false
+	}
+	if r.property != nil && !r.property.Match(log.Properties) {
+		return false
+	}
+	return true
+}
+
+// globMatch matches a shell-style pattern such as "scroll_*" or "2.3.*"
+func globMatch(pattern, value string) bool {
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// sampleKey returns the value sampling is keyed on, so every log of a session
+// or user gets the same decision. Logs without it are sampled individually.
+func sampleKey(log *models.AnalyticsLog, sampleBy string) string {
+	var key *string
+	switch sampleBy {
+	case "user_id":
+		key = log.UserID
+	default:
+		key = log.SessionID
+	}
+	if key != nil && *key != "" {
+		return *key
+	}
+	return log.EventID
+}
+
+// score hashes a key onto [0, 1). Rules share the hash, so the sessions kept
+// at a low rate are also kept by any rule with a higher one.
+func score(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / math.Exp2(53)
+}
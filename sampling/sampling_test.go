@@ -0,0 +1,268 @@
+package sampling
+
+import (
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+	"math"
+	"testing"
+)
+
+type fakeStore struct {
+	rules []models.SamplingRule
+	err   error
+}
+
+func (s *fakeStore) ListSamplingRules() ([]models.SamplingRule, error) {
+	return s.rules, s.err
+}
+
+func newTestRegistry(t *testing.T, rules ...models.SamplingRule) *Registry {
+	t.Helper()
+	for i := range rules {
+		rules[i].IsActive = true
+	}
+	r := NewRegistry(&fakeStore{rules: rules})
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return r
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func sampleRule(name string, rate float64, sampleBy string) models.SamplingRule {
+	return models.SamplingRule{Name: name, EventName: "scroll_*", Action: models.SamplingActionSample, KeepRate: rate, SampleBy: sampleBy}
+}
+
+func scrollLog(eventID, sessionID, userID string) *models.AnalyticsLog {
+	log := &models.AnalyticsLog{EventID: eventID, EventType: "user_action", EventName: "scroll_feed", Priority: "normal"}
+	if sessionID != "" {
+		log.SessionID = stringPtr(sessionID)
+	}
+	if userID != "" {
+		log.UserID = stringPtr(userID)
+	}
+	return log
+}
+
+func TestDecideSamplesSessionsDeterministically(t *testing.T) {
+	const sessions = 4000
+	r := newTestRegistry(t, sampleRule("scroll", 0.25, "session_id"))
+
+	kept := 0
+	for i := 0; i < sessions; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		first := r.Decide(scrollLog(fmt.Sprintf("e-%d-0", i), session, ""))
+		if first.Rate != 0.25 || first.Rule != "scroll" {
+			t.Fatalf("decision = %+v, want rate 0.25 from rule scroll", first)
+		}
+		if first.Keep {
+			kept++
+			if first.Outcome != DecisionKept {
+				t.Fatalf("kept log has outcome %s", first.Outcome)
+			}
+		} else if first.Outcome != DecisionSampledOut {
+			t.Fatalf("sampled out log has outcome %s", first.Outcome)
+		}
+
+		// Every log of the session gets the same decision
+		for j := 1; j < 3; j++ {
+			if d := r.Decide(scrollLog(fmt.Sprintf("e-%d-%d", i, j), session, "")); d.Keep != first.Keep {
+				t.Fatalf("%s: log %d kept = %t, first log kept = %t", session, j, d.Keep, first.Keep)
+			}
+		}
+	}
+
+	if got := float64(kept) / sessions; math.Abs(got-0.25) > 0.03 {
+		t.Errorf("kept %.3f of sessions, want about 0.25", got)
+	}
+}
+
+func TestDecideSamplesUsers(t *testing.T) {
+	r := newTestRegistry(t, sampleRule("scroll", 0.5, "user_id"))
+
+	kept := 0
+	for i := 0; i < 200; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		first := r.Decide(scrollLog(fmt.Sprintf("e-%d-a", i), "session-a", user))
+		second := r.Decide(scrollLog(fmt.Sprintf("e-%d-b", i), "session-b", user))
+		if first.Keep != second.Keep {
+			t.Fatalf("%s: sessions of the same user decided differently", user)
+		}
+		if first.Keep {
+			kept++
+		}
+	}
+	if kept == 0 || kept == 200 {
+		t.Errorf("kept %d of 200 users at rate 0.5", kept)
+	}
+}
+
+func TestDecideWithoutSampleKeyUsesEventID(t *testing.T) {
+	r := newTestRegistry(t, sampleRule("scroll", 0.5, "user_id"))
+
+	kept := 0
+	for i := 0; i < 200; i++ {
+		log := scrollLog(fmt.Sprintf("e-%d", i), "", "")
+		d := r.Decide(log)
+		if d.Keep != (score(log.EventID) < 0.5) {
+			t.Fatalf("%s: decision %+v does not follow the event ID", log.EventID, d)
+		}
+		if d.Keep {
+			kept++
+		}
+	}
+	if kept == 0 || kept == 200 {
+		t.Errorf("kept %d of 200 logs sampled by event ID at rate 0.5", kept)
+	}
+}
+
+// TestDecideKeepsSessionsAcrossRates checks that raising a rule's rate only
+// adds sessions: every session kept at 10% is also kept at 50%
+func TestDecideKeepsSessionsAcrossRates(t *testing.T) {
+	low := newTestRegistry(t, sampleRule("scroll", 0.1, "session_id"))
+	high := newTestRegistry(t, sampleRule("scroll", 0.5, "session_id"))
+
+	for i := 0; i < 1000; i++ {
+		log := scrollLog(fmt.Sprintf("e-%d", i), fmt.Sprintf("session-%d", i), "")
+		if low.Decide(log).Keep && !high.Decide(log).Keep {
+			t.Fatalf("session-%d kept at 10%% but not at 50%%", i)
+		}
+	}
+}
+
+func TestDecideRules(t *testing.T) {
+	dropScroll := models.SamplingRule{Name: "drop-scroll", EventName: "scroll_*", Action: models.SamplingActionDrop}
+	keepBeta := models.SamplingRule{Name: "keep-beta", AppVersion: "3.*-beta", Action: models.SamplingActionKeep}
+	dropSlow := models.SamplingRule{Name: "drop-slow", EventType: "performance", Property: "perf.fps < 10", Action: models.SamplingActionDrop}
+
+	beta := scrollLog("e-1", "s-1", "")
+	beta.AppVersion = stringPtr("3.2-beta")
+	slow := &models.AnalyticsLog{EventID: "e-2", EventType: "performance", EventName: "frame", Priority: "normal",
+		Properties: models.JSONB{"perf": map[string]interface{}{"fps": 4.0}}}
+	fast := &models.AnalyticsLog{EventID: "e-3", EventType: "performance", EventName: "frame", Priority: "normal",
+		Properties: models.JSONB{"perf": map[string]interface{}{"fps": 60.0}}}
+	highPriority := scrollLog("e-4", "s-1", "")
+	highPriority.Priority = "high"
+	scrollError := scrollLog("e-5", "s-1", "")
+	scrollError.EventType = "error"
+
+	tests := []struct {
+		name  string
+		rules []models.SamplingRule
+		log   *models.AnalyticsLog
+		want  Decision
+	}{
+		{
+			name: "no rules",
+			log:  scrollLog("e-0", "s-1", ""),
+			want: Decision{Keep: true, Rate: 1, Outcome: DecisionKept},
+		},
+		{
+			name:  "drop rule",
+			rules: []models.SamplingRule{dropScroll},
+			log:   scrollLog("e-0", "s-1", ""),
+			want:  Decision{Keep: false, Rate: 0, Rule: "drop-scroll", Outcome: DecisionDropped},
+		},
+		{
+			name:  "no rule matches",
+			rules: []models.SamplingRule{dropScroll},
+			log:   fast,
+			want:  Decision{Keep: true, Rate: 1, Outcome: DecisionKept},
+		},
+		{
+			name:  "first matching rule applies",
+			rules: []models.SamplingRule{keepBeta, dropScroll},
+			log:   beta,
+			want:  Decision{Keep: true, Rate: 1, Rule: "keep-beta", Outcome: DecisionKept},
+		},
+		{
+			name:  "later rule when app version does not match",
+			rules: []models.SamplingRule{keepBeta, dropScroll},
+			log:   scrollLog("e-0", "s-1", ""),
+			want:  Decision{Keep: false, Rate: 0, Rule: "drop-scroll", Outcome: DecisionDropped},
+		},
+		{
+			name:  "property expression matches",
+			rules: []models.SamplingRule{dropSlow},
+			log:   slow,
+			want:  Decision{Keep: false, Rate: 0, Rule: "drop-slow", Outcome: DecisionDropped},
+		},
+		{
+			name:  "property expression does not match",
+			rules: []models.SamplingRule{dropSlow},
+			log:   fast,
+			want:  Decision{Keep: true, Rate: 1, Outcome: DecisionKept},
+		},
+		{
+			name:  "high priority always kept",
+			rules: []models.SamplingRule{dropScroll},
+			log:   highPriority,
+			want:  Decision{Keep: true, Rate: 1, Outcome: DecisionKept},
+		},
+		{
+			name:  "errors always kept",
+			rules: []models.SamplingRule{{Name: "drop-all", Action: models.SamplingActionDrop}},
+			log:   scrollError,
+			want:  Decision{Keep: true, Rate: 1, Outcome: DecisionKept},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t, tt.rules...)
+			if got := r.Decide(tt.log); got != tt.want {
+				t.Errorf("Decide = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSkipsInactiveAndInvalidRules(t *testing.T) {
+	store := &fakeStore{rules: []models.SamplingRule{
+		{Name: "inactive", Action: models.SamplingActionDrop},
+		{Name: "invalid", Property: "fps = 5", Action: models.SamplingActionDrop, IsActive: true},
+		{Name: "sample", Action: models.SamplingActionSample, KeepRate: 0, IsActive: true},
+	}}
+	r := NewRegistry(store)
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if d := r.Decide(scrollLog("e-1", "s-1", "")); d.Rule != "sample" || d.Keep {
+		t.Errorf("Decide = %+v, want sampled out by rule sample", d)
+	}
+
+	// A failed reload keeps the cached rules
+	store.err = errors.New("database unavailable")
+	if err := r.Load(); err == nil {
+		t.Error("Load succeeded with a failing store")
+	}
+	if d := r.Decide(scrollLog("e-1", "s-1", "")); d.Rule != "sample" {
+		t.Errorf("Decide after a failed reload = %+v, want the cached rule", d)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.SamplingRule
+		wantErr bool
+	}{
+		{name: "valid", rule: models.SamplingRule{EventName: "scroll_*", AppVersion: "2.3.*", Property: "fps < 10"}},
+		{name: "invalid event name pattern", rule: models.SamplingRule{EventName: "scroll_["}, wantErr: true},
+		{name: "invalid app version pattern", rule: models.SamplingRule{AppVersion: "[2"}, wantErr: true},
+		{name: "invalid property", rule: models.SamplingRule{Property: "fps >"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,559 @@
+// Package schema validates analytics log properties against JSON Schemas
+// registered per event name.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema. The supported subset covers the
+// validation keywords useful for flat event payloads:
+//
+//	type, enum, const
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, minItems, maxItems, uniqueItems
+//	minLength, maxLength, pattern, format (date-time, date, email, uuid)
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//	allOf, anyOf, oneOf, not
+//
+// Annotation keywords such as title and description are ignored. References
+// ($ref) are rejected at compile time rather than silently skipped.
+type Schema struct {
+	alwaysValid bool
+	neverValid  bool
+
+	types    []string
+	enum     []interface{}
+	constant *interface{}
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+// Violation is one way a value fails its schema
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Compile parses and checks a JSON Schema document
+func Compile(raw []byte) (*Schema, error) {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return compile(document, "#")
+}
+
+func compile(document interface{}, path string) (*Schema, error) {
+	if b, ok := document.(bool); ok {
+		return &Schema{alwaysValid: b, neverValid: !b}, nil
+	}
+
+	keywords, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", path)
+	}
+
+	s := &Schema{}
+	for keyword, value := range keywords {
+		at := path + "/" + keyword
+		var err error
+
+		switch keyword {
+		case "type":
+			s.types, err = compileTypes(value, at)
+		case "enum":
+			values, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an array", at)
+			}
+			s.enum = values
+		case "const":
+			constant := value
+			s.constant = &constant
+		case "properties":
+			members, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an object", at)
+			}
+			s.properties = make(map[string]*Schema, len(members))
+			for name, member := range members {
+				if s.properties[name], err = compile(member, at+"/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = compileStrings(value, at)
+		case "additionalProperties":
+			s.additionalProperties, err = compile(value, at)
+		case "items":
+			s.items, err = compile(value, at)
+		case "allOf":
+			s.allOf, err = compileList(value, at)
+		case "anyOf":
+			s.anyOf, err = compileList(value, at)
+		case "oneOf":
+			s.oneOf, err = compileList(value, at)
+		case "not":
+			s.not, err = compile(value, at)
+		case "minProperties":
+			s.minProperties, err = compileCount(value, at)
+		case "maxProperties":
+			s.maxProperties, err = compileCount(value, at)
+		case "minItems":
+			s.minItems, err = compileCount(value, at)
+		case "maxItems":
+			s.maxItems, err = compileCount(value, at)
+		case "minLength":
+			s.minLength, err = compileCount(value, at)
+		case "maxLength":
+			s.maxLength, err = compileCount(value, at)
+		case "uniqueItems":
+			s.uniqueItems, _ = value.(bool)
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", at)
+			}
+			if s.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s: %w", at, err)
+			}
+		case "format":
+			s.format, _ = value.(string)
+		case "minimum":
+			s.minimum, err = compileNumber(value, at)
+		case "maximum":
+			s.maximum, err = compileNumber(value, at)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = compileNumber(value, at)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = compileNumber(value, at)
+		case "multipleOf":
+			if s.multipleOf, err = compileNumber(value, at); err == nil && *s.multipleOf <= 0 {
+				err = fmt.Errorf("%s: must be greater than 0", at)
+			}
+		case "$ref", "$dynamicRef", "$recursiveRef", "patternProperties", "dependentSchemas",
+			"dependentRequired", "if", "then", "else", "prefixItems", "contains", "unevaluatedProperties",
+			"unevaluatedItems", "propertyNames":
+			return nil, fmt.Errorf("%s: keyword is not supported", at)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func compileTypes(value interface{}, at string) ([]string, error) {
+	var types []string
+	switch v := value.(type) {
+	case string:
+		types = []string{v}
+	case []interface{}:
+		var err error
+		if types, err = compileStrings(v, at); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a string or array of strings", at)
+	}
+
+	for _, t := range types {
+		if !validTypes[t] {
+			return nil, fmt.Errorf("%s: unknown type %q", at, t)
+		}
+	}
+	return types, nil
+}
+
+func compileStrings(value interface{}, at string) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an array of strings", at)
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an array of strings", at)
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+func compileList(value interface{}, at string) ([]*Schema, error) {
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array of schemas", at)
+	}
+
+	schemas := make([]*Schema, 0, len(values))
+	for i, v := range values {
+		s, err := compile(v, fmt.Sprintf("%s/%d", at, i))
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, nil
+}
+
+func compileCount(value interface{}, at string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", at)
+	}
+	count := int(n)
+	return &count, nil
+}
+
+func compileNumber(value interface{}, at string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &n, nil
+}
+
+// Validate checks a value, returning every violation found. Paths are
+// dotted from root, e.g. "properties.tags[0]".
+func (s *Schema) Validate(value interface{}, root string) []Violation {
+	var violations []Violation
+	s.validate(normalize(value), root, &violations)
+	return violations
+}
+
+func (s *Schema) validate(value interface{}, path string, violations *[]Violation) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.alwaysValid {
+		return
+	}
+	if s.neverValid {
+		fail("No value is allowed here")
+		return
+	}
+
+	if len(s.types) > 0 && !matchesType(value, s.types) {
+		fail("Must be of type %s, got %s", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+
+	if s.constant != nil && !reflect.DeepEqual(value, normalize(*s.constant)) {
+		fail("Must equal %s", formatValue(*s.constant))
+	}
+
+	if len(s.enum) > 0 {
+		found := false
+		for _, allowed := range s.enum {
+			if reflect.DeepEqual(value, normalize(allowed)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed := make([]string, len(s.enum))
+			for i, v := range s.enum {
+				allowed[i] = formatValue(v)
+			}
+			fail("Must be one of: %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, violations, fail)
+	case []interface{}:
+		s.validateArray(v, path, violations, fail)
+	case string:
+		s.validateString(v, fail)
+	case float64:
+		s.validateNumber(v, fail)
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(value, path, violations)
+	}
+
+	if len(s.anyOf) > 0 && s.countMatches(s.anyOf, value, path) == 0 {
+		fail("Must match at least one of the allowed schemas")
+	}
+
+	if len(s.oneOf) > 0 {
+		if matches := s.countMatches(s.oneOf, value, path); matches != 1 {
+			fail("Must match exactly one of the allowed schemas, matched %d", matches)
+		}
+	}
+
+	if s.not != nil && s.countMatches([]*Schema{s.not}, value, path) == 1 {
+		fail("Must not match the excluded schema")
+	}
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, path string, violations *[]Violation, fail func(string, ...interface{})) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			*violations = append(*violations, Violation{Path: joinPath(path, name), Message: "This field is required"})
+		}
+	}
+
+	if s.minProperties != nil && len(object) < *s.minProperties {
+		fail("Must have at least %d properties", *s.minProperties)
+	}
+	if s.maxProperties != nil && len(object) > *s.maxProperties {
+		fail("Must have at most %d properties", *s.maxProperties)
+	}
+
+	// Visit properties in order so violations are reported deterministically
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		at := joinPath(path, name)
+		if property, ok := s.properties[name]; ok {
+			property.validate(object[name], at, violations)
+			continue
+		}
+
+		if s.additionalProperties == nil {
+			continue
+		}
+		if s.additionalProperties.neverValid {
+			*violations = append(*violations, Violation{Path: at, Message: "Unknown property"})
+			continue
+		}
+		s.additionalProperties.validate(object[name], at, violations)
+	}
+}
+
+func (s *Schema) validateArray(array []interface{}, path string, violations *[]Violation, fail func(string, ...interface{})) {
+	if s.minItems != nil && len(array) < *s.minItems {
+		fail("Must have at least %d items", *s.minItems)
+	}
+	if s.maxItems != nil && len(array) > *s.maxItems {
+		fail("Must have at most %d items", *s.maxItems)
+	}
+
+	if s.uniqueItems {
+		for i := range array {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(array[i], array[j]) {
+					fail("Items must be unique; item %d repeats item %d", i, j)
+				}
+			}
+		}
+	}
+
+	if s.items != nil {
+		for i, item := range array {
+			s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+		}
+	}
+}
+
+func (s *Schema) validateString(value string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(value)
+	if s.minLength != nil && length < *s.minLength {
+		fail("Must be at least %d characters", *s.minLength)
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		fail("Must be at most %d characters", *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		fail("Must match pattern %s", s.pattern.String())
+	}
+
+	if !matchesFormat(value, s.format) {
+		fail("Must be a valid %s", s.format)
+	}
+}
+
+func (s *Schema) validateNumber(value float64, fail func(string, ...interface{})) {
+	if s.minimum != nil && value < *s.minimum {
+		fail("Must be at least %v", *s.minimum)
+	}
+	if s.maximum != nil && value > *s.maximum {
+		fail("Must be at most %v", *s.maximum)
+	}
+	if s.exclusiveMinimum != nil && value <= *s.exclusiveMinimum {
+		fail("Must be greater than %v", *s.exclusiveMinimum)
+	}
+	if s.exclusiveMaximum != nil && value >= *s.exclusiveMaximum {
+		fail("Must be less than %v", *s.exclusiveMaximum)
+	}
+	if s.multipleOf != nil {
+		quotient := value / *s.multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			fail("Must be a multiple of %v", *s.multipleOf)
+		}
+	}
+}
+
+// countMatches returns how many schemas accept the value
+func (s *Schema) countMatches(schemas []*Schema, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		var scratch []Violation
+		sub.validate(value, path, &scratch)
+		if len(scratch) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func matchesType(value interface{}, types []string) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func matchesFormat(value, format string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "email":
+		_, err := mail.ParseAddress(value)
+		return err == nil && !strings.ContainsAny(value, "<> ")
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	default:
+		// Unknown formats are annotations only
+		return true
+	}
+}
+
+// normalize converts a value to the types encoding/json produces, so logs
+// built in code (OTLP, syslog) validate the same as decoded JSON
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, float64:
+		return v
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = normalize(item)
+		}
+		return out
+	}
+
+	// Anything else takes a round trip through JSON
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+func formatValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
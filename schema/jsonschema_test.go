@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		// wantPaths lists the path of each expected violation, in order
+		wantPaths []string
+	}{
+		// type
+		{name: "type matches", schema: `{"type": "string"}`, value: `"a"`},
+		{name: "type mismatch", schema: `{"type": "string"}`, value: `1`, wantPaths: []string{"properties"}},
+		{name: "integer is a number", schema: `{"type": "number"}`, value: `3`},
+		{name: "fraction is not an integer", schema: `{"type": "integer"}`, value: `3.5`, wantPaths: []string{"properties"}},
+		{name: "whole float is an integer", schema: `{"type": "integer"}`, value: `3.0`},
+		{name: "type list", schema: `{"type": ["string", "null"]}`, value: `null`},
+		{name: "type list mismatch", schema: `{"type": ["string", "null"]}`, value: `false`, wantPaths: []string{"properties"}},
+		{name: "type mismatch skips other keywords", schema: `{"type": "string", "minLength": 5}`, value: `[]`, wantPaths: []string{"properties"}},
+
+		// enum and const
+		{name: "enum member", schema: `{"enum": ["a", 1, null]}`, value: `1`},
+		{name: "enum non-member", schema: `{"enum": ["a", 1, null]}`, value: `"b"`, wantPaths: []string{"properties"}},
+		{name: "enum compares objects deeply", schema: `{"enum": [{"a": [1, 2]}]}`, value: `{"a": [1, 2]}`},
+		{name: "const", schema: `{"const": "fixed"}`, value: `"fixed"`},
+		{name: "const mismatch", schema: `{"const": "fixed"}`, value: `"other"`, wantPaths: []string{"properties"}},
+
+		// objects
+		{
+			name:      "required",
+			schema:    `{"type": "object", "required": ["a", "b"]}`,
+			value:     `{"b": 1}`,
+			wantPaths: []string{"properties.a"},
+		},
+		{
+			name:      "property schemas",
+			schema:    `{"properties": {"a": {"type": "string"}, "b": {"type": "integer"}}}`,
+			value:     `{"a": 1, "b": 2, "c": true}`,
+			wantPaths: []string{"properties.a"},
+		},
+		{
+			name:      "additionalProperties false",
+			schema:    `{"properties": {"a": {}}, "additionalProperties": false}`,
+			value:     `{"a": 1, "z": 2, "y": 3}`,
+			wantPaths: []string{"properties.y", "properties.z"},
+		},
+		{
+			name:      "additionalProperties schema",
+			schema:    `{"properties": {"a": {}}, "additionalProperties": {"type": "number"}}`,
+			value:     `{"a": "x", "b": 1, "c": "x"}`,
+			wantPaths: []string{"properties.c"},
+		},
+		{name: "minProperties", schema: `{"minProperties": 2}`, value: `{"a": 1}`, wantPaths: []string{"properties"}},
+		{name: "maxProperties", schema: `{"maxProperties": 1}`, value: `{"a": 1, "b": 2}`, wantPaths: []string{"properties"}},
+		{
+			name: "nested objects",
+			schema: `{"properties": {"cart": {"type": "object", "required": ["total"],
+				"properties": {"total": {"type": "number", "minimum": 0}, "coupon": {"type": "object",
+					"properties": {"code": {"pattern": "^[A-Z]+$"}}}}}}}`,
+			value:     `{"cart": {"total": -1, "coupon": {"code": "abc"}}}`,
+			wantPaths: []string{"properties.cart.coupon.code", "properties.cart.total"},
+		},
+		{
+			name:      "nested required",
+			schema:    `{"properties": {"cart": {"required": ["total"]}}}`,
+			value:     `{"cart": {}}`,
+			wantPaths: []string{"properties.cart.total"},
+		},
+
+		// arrays
+		{name: "minItems", schema: `{"minItems": 2}`, value: `[1]`, wantPaths: []string{"properties"}},
+		{name: "maxItems", schema: `{"maxItems": 2}`, value: `[1, 2, 3]`, wantPaths: []string{"properties"}},
+		{name: "uniqueItems", schema: `{"uniqueItems": true}`, value: `[1, "1", {"a": 1}]`},
+		{name: "uniqueItems repeat", schema: `{"uniqueItems": true}`, value: `[{"a": 1}, 2, {"a": 1}]`, wantPaths: []string{"properties"}},
+		{
+			name:      "items",
+			schema:    `{"items": {"type": "string", "maxLength": 3}}`,
+			value:     `["ok", 5, "long"]`,
+			wantPaths: []string{"properties[1]", "properties[2]"},
+		},
+		{
+			name:      "arrays of objects",
+			schema:    `{"properties": {"lines": {"items": {"required": ["sku"], "properties": {"qty": {"type": "integer"}}}}}}`,
+			value:     `{"lines": [{"sku": "a", "qty": 1}, {"qty": 1.5}]}`,
+			wantPaths: []string{"properties.lines[1].sku", "properties.lines[1].qty"},
+		},
+
+		// strings
+		{name: "minLength counts characters", schema: `{"minLength": 3}`, value: `"héé"`},
+		{name: "minLength", schema: `{"minLength": 3}`, value: `"ab"`, wantPaths: []string{"properties"}},
+		{name: "maxLength counts characters", schema: `{"maxLength": 2}`, value: `"éé"`},
+		{name: "maxLength", schema: `{"maxLength": 2}`, value: `"abc"`, wantPaths: []string{"properties"}},
+		{name: "pattern is unanchored", schema: `{"pattern": "[0-9]+"}`, value: `"v12"`},
+		{name: "pattern", schema: `{"pattern": "^v[0-9]+$"}`, value: `"version"`, wantPaths: []string{"properties"}},
+		{name: "string keywords ignore other types", schema: `{"minLength": 3, "pattern": "^a"}`, value: `5`},
+
+		// formats
+		{name: "date-time", schema: `{"format": "date-time"}`, value: `"2026-10-16T09:30:00.5+02:00"`},
+		{name: "date-time without zone", schema: `{"format": "date-time"}`, value: `"2026-10-16T09:30:00"`, wantPaths: []string{"properties"}},
+		{name: "date", schema: `{"format": "date"}`, value: `"2026-02-30"`, wantPaths: []string{"properties"}},
+		{name: "email", schema: `{"format": "email"}`, value: `"ops@example.com"`},
+		{name: "email with display name", schema: `{"format": "email"}`, value: `"Ops <ops@example.com>"`, wantPaths: []string{"properties"}},
+		{name: "uuid", schema: `{"format": "uuid"}`, value: `"123e4567-e89b-12d3-a456-426614174000"`},
+		{name: "invalid uuid", schema: `{"format": "uuid"}`, value: `"123e4567e89b12d3a456426614174000"`, wantPaths: []string{"properties"}},
+		{name: "unknown format", schema: `{"format": "hostname"}`, value: `"not a host!"`},
+
+		// numbers
+		{name: "minimum is inclusive", schema: `{"minimum": 1}`, value: `1`},
+		{name: "minimum", schema: `{"minimum": 1}`, value: `0.5`, wantPaths: []string{"properties"}},
+		{name: "maximum is inclusive", schema: `{"maximum": 1}`, value: `1`},
+		{name: "maximum", schema: `{"maximum": 1}`, value: `1.5`, wantPaths: []string{"properties"}},
+		{name: "exclusiveMinimum", schema: `{"exclusiveMinimum": 1}`, value: `1`, wantPaths: []string{"properties"}},
+		{name: "exclusiveMaximum", schema: `{"exclusiveMaximum": 1}`, value: `1`, wantPaths: []string{"properties"}},
+		{name: "multipleOf", schema: `{"multipleOf": 0.1}`, value: `0.3`},
+		{name: "not a multiple", schema: `{"multipleOf": 5}`, value: `12`, wantPaths: []string{"properties"}},
+		{name: "every bound reported", schema: `{"minimum": 10, "multipleOf": 3}`, value: `4`, wantPaths: []string{"properties", "properties"}},
+
+		// combinators
+		{name: "allOf", schema: `{"allOf": [{"minimum": 0}, {"maximum": 10}]}`, value: `11`, wantPaths: []string{"properties"}},
+		{name: "anyOf", schema: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, value: `2`},
+		{name: "anyOf none", schema: `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, value: `2.5`, wantPaths: []string{"properties"}},
+		{name: "oneOf", schema: `{"oneOf": [{"type": "string"}, {"type": "number"}]}`, value: `"a"`},
+		{name: "oneOf both", schema: `{"oneOf": [{"type": "number"}, {"minimum": 5}]}`, value: `6`, wantPaths: []string{"properties"}},
+		{name: "not", schema: `{"not": {"type": "null"}}`, value: `null`, wantPaths: []string{"properties"}},
+
+		// boolean schemas
+		{name: "true", schema: `true`, value: `{"anything": [1]}`},
+		{name: "false", schema: `false`, value: `1`, wantPaths: []string{"properties"}},
+		{name: "annotations ignored", schema: `{"title": "Order", "description": "An order", "type": "object"}`, value: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("invalid test value: %v", err)
+			}
+
+			violations := s.Validate(value, "properties")
+			paths := make([]string, len(violations))
+			for i, v := range violations {
+				paths[i] = v.Path
+				if v.Message == "" {
+					t.Errorf("violation at %s has no message", v.Path)
+				}
+			}
+			if len(paths) != len(tt.wantPaths) || (len(paths) > 0 && !reflect.DeepEqual(paths, tt.wantPaths)) {
+				t.Errorf("violations = %+v, want paths %v", violations, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "not JSON", schema: `{"type":`, wantErr: "not valid JSON"},
+		{name: "not an object", schema: `"string"`, wantErr: "#: schema must be an object"},
+		{name: "unknown type", schema: `{"type": "float"}`, wantErr: `#/type: unknown type "float"`},
+		{name: "enum not an array", schema: `{"enum": "a"}`, wantErr: "#/enum: must be an array"},
+		{name: "required not strings", schema: `{"required": ["a", 1]}`, wantErr: "#/required: must be an array of strings"},
+		{name: "negative count", schema: `{"minLength": -1}`, wantErr: "#/minLength: must be a non-negative integer"},
+		{name: "fractional count", schema: `{"maxItems": 1.5}`, wantErr: "#/maxItems: must be a non-negative integer"},
+		{name: "bound not a number", schema: `{"minimum": "1"}`, wantErr: "#/minimum: must be a number"},
+		{name: "multipleOf zero", schema: `{"multipleOf": 0}`, wantErr: "#/multipleOf: must be greater than 0"},
+		{name: "invalid pattern", schema: `{"pattern": "("}`, wantErr: "#/pattern:"},
+		{name: "empty anyOf", schema: `{"anyOf": []}`, wantErr: "#/anyOf: must be a non-empty array"},
+		{name: "unsupported keyword", schema: `{"$ref": "#/definitions/a"}`, wantErr: "#/$ref: keyword is not supported"},
+		{
+			name:    "nested error path",
+			schema:  `{"properties": {"cart": {"items": {"type": 5}}}}`,
+			wantErr: "#/properties/cart/items/type:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidateNormalizesValues checks that values built in code, as OTLP and
+// syslog logs are, validate the same as decoded JSON
+func TestValidateNormalizesValues(t *testing.T) {
+	s, err := Compile([]byte(`{"properties": {
+		"count": {"type": "integer", "maximum": 10},
+		"ids": {"items": {"type": "integer"}},
+		"tags": {"type": "object", "additionalProperties": {"type": "string"}}
+	}}`))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	valid := map[string]interface{}{
+		"count": int64(3),
+		"ids":   []interface{}{int32(1), uint32(2), 3},
+		"tags":  map[string]string{"env": "prod"},
+	}
+	if violations := s.Validate(valid, "properties"); len(violations) != 0 {
+		t.Errorf("violations = %+v, want none", violations)
+	}
+
+	invalid := map[string]interface{}{"count": 11, "tags": map[string]int{"env": 1}}
+	violations := s.Validate(invalid, "properties")
+	if len(violations) != 2 || violations[0].Path != "properties.count" || violations[1].Path != "properties.tags.env" {
+		t.Errorf("violations = %+v, want count and tags.env", violations)
+	}
+}
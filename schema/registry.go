@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Store loads the enforced schema of each event name
+type Store interface {
+	ActiveEventSchemas() ([]models.EventSchema, error)
+}
+
+// Registry caches the compiled schema enforced for each event name
+type Registry struct {
+	store Store
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+// entry is one event name's enforced schema
+type entry struct {
+	version int
+	mode    string
+	schema  *Schema
+}
+
+// Result describes a log whose properties failed its event's schema
+type Result struct {
+	EventName  string
+	Version    int
+	Mode       string
+	Violations []Violation
+}
+
+// NewRegistry creates an empty registry backed by store
+func NewRegistry(store Store) *Registry {
+	return &Registry{
+		store:   store,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Load replaces the cache with the active schemas from the store. Stored
+// schemas that no longer compile are skipped and logged.
+func (r *Registry) Load() error {
+	schemas, err := r.store.ActiveEventSchemas()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*entry, len(schemas))
+	for _, s := range schemas {
+		compiled, err := Compile(s.Schema)
+		if err != nil {
+			logrus.Errorf("Skipping schema %s v%d: %v", s.EventName, s.Version, err)
+			continue
+		}
+		entries[s.EventName] = &entry{version: s.Version, mode: s.Mode, schema: compiled}
+	}
+
+	r.mu.Lock()
+	r.entries = entries
+	r.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh periodically reloads the cache so schema changes made through
+// other server instances take effect
+func (r *Registry) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Load(); err != nil {
+				logrus.Errorf("Failed to refresh event schemas: %v", err)
+			}
+		}
+	}()
+}
+
+// Has reports whether a schema is enforced for an event name
+func (r *Registry) Has(eventName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.entries[eventName]
+	return ok
+}
+
+// Check validates a log's properties against its event's schema. It returns
+// nil when no schema is registered or the properties are valid.
+func (r *Registry) Check(eventName string, properties map[string]interface{}) *Result {
+	r.mu.RLock()
+	e, ok := r.entries[eventName]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+
+	violations := e.schema.Validate(properties, "properties")
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &Result{
+		EventName:  eventName,
+		Version:    e.version,
+		Mode:       e.mode,
+		Violations: violations,
+	}
+}
+
+// Summary renders the violations in one line for logging
+func (r *Result) Summary() string {
+	if len(r.Violations) == 1 {
+		return fmt.Sprintf("%s: %s", r.Violations[0].Path, r.Violations[0].Message)
+	}
+	return fmt.Sprintf("%s: %s (and %d more)", r.Violations[0].Path, r.Violations[0].Message, len(r.Violations)-1)
+}
@@ -89,7 +89,7 @@ single_log='{
   "priority": "normal"
 }'
 
-test_endpoint "POST" "/api/v1/ingest" "$single_log" "201" "Single Log Ingestion"
+test_endpoint "POST" "/api/v1/ingest" "$single_log" "202" "Single Log Ingestion"
 
 # Test 5: Batch Log Ingestion
 batch_logs='{
@@ -127,7 +127,7 @@ batch_logs='{
   ]
 }'
 
-test_endpoint "POST" "/api/v1/batch-ingest" "$batch_logs" "201" "Batch Log Ingestion"
+test_endpoint "POST" "/api/v1/batch-ingest" "$batch_logs" "202" "Batch Log Ingestion"
 
 # Test 6: Service Status
 test_endpoint "GET" "/api/v1/status" "" "200" "Service Status"
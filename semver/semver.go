@@ -0,0 +1,163 @@
+// Package semver parses and orders the app versions reported by clients.
+// Besides strict semantic versions it accepts a leading "v", missing minor or
+// patch numbers ("2.3" is 2.3.0) and build metadata such as Flutter's
+// "2.3.1+45", which is ignored for ordering.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed app version
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+}
+
+// Parse reads a version such as "1.4.2", "v2.0", "3.1.0-beta.2" or "2.3.1+45"
+func Parse(s string) (Version, error) {
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v Version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if i == len(s)-1 {
+			return Version{}, fmt.Errorf("invalid version %q: empty prerelease", raw)
+		}
+		v.Prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.Prerelease {
+			if id == "" {
+				return Version{}, fmt.Errorf("invalid version %q: empty prerelease identifier", raw)
+			}
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q: too many components", raw)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", raw)
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// A prerelease is lower than its release, as in semantic versioning.
+func (v Version) Compare(o Version) int {
+	for _, pair := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInts(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifiers(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(v.Prerelease), len(o.Prerelease))
+}
+
+// String formats the version without build metadata
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	return s
+}
+
+// Range is a half-open version range [Min, Max); a nil bound is unbounded
+type Range struct {
+	Min *Version
+	Max *Version
+}
+
+// ParseRange reads the bounds of a range; an empty bound is unbounded
+func ParseRange(min, max string) (Range, error) {
+	var r Range
+	if min != "" {
+		v, err := Parse(min)
+		if err != nil {
+			return Range{}, err
+		}
+		r.Min = &v
+	}
+	if max != "" {
+		v, err := Parse(max)
+		if err != nil {
+			return Range{}, err
+		}
+		r.Max = &v
+	}
+	if r.Min != nil && r.Max != nil && r.Min.Compare(*r.Max) >= 0 {
+		return Range{}, fmt.Errorf("empty version range [%s, %s)", min, max)
+	}
+	return r, nil
+}
+
+// Unbounded reports whether the range matches every version
+func (r Range) Unbounded() bool {
+	return r.Min == nil && r.Max == nil
+}
+
+// Contains reports whether v is within the range
+func (r Range) Contains(v Version) bool {
+	if r.Min != nil && v.Compare(*r.Min) < 0 {
+		return false
+	}
+	if r.Max != nil && v.Compare(*r.Max) >= 0 {
+		return false
+	}
+	return true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers orders prerelease identifiers: numeric ones numerically
+// and below alphanumeric ones, which compare as strings
+func compareIdentifiers(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
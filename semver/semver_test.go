@@ -0,0 +1,217 @@
+package semver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"1.4.2", Version{Major: 1, Minor: 4, Patch: 2}},
+		{"v2.0", Version{Major: 2}},
+		{"3", Version{Major: 3}},
+		{" 2.3.1 ", Version{Major: 2, Minor: 3, Patch: 1}},
+		{"2.3.1+45", Version{Major: 2, Minor: 3, Patch: 1}},
+		{"3.1.0-beta.2", Version{Major: 3, Minor: 1, Prerelease: []string{"beta", "2"}}},
+		{"3.1.0-rc.1+build.7", Version{Major: 3, Minor: 1, Prerelease: []string{"rc", "1"}}},
+		{"1.0-alpha", Version{Major: 1, Prerelease: []string{"alpha"}}},
+		{"1.0.0-x-y", Version{Major: 1, Prerelease: []string{"x-y"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"v",
+		"1.2.3.4",
+		"1..2",
+		"1.2.",
+		"a.b.c",
+		"1.-2.3",
+		"1.2.3-",
+		"1.2.3-beta..1",
+		"1.2.3-beta.",
+		"-1",
+	} {
+		t.Run(input, func(t *testing.T) {
+			if v, err := Parse(input); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", input, v)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Each version is lower than the next, following the semantic versioning
+	// precedence example
+	ordered := []string{
+		"0.9.9",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, err := Parse(ordered[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(ordered[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := a.Compare(b); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareEquivalentForms(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"2.3", "2.3.0"},
+		{"v2.3.0", "2.3.0"},
+		{"2.3.1+45", "2.3.1+46"},
+		{"1.0.0-rc.1+a", "1.0.0-rc.1"},
+	} {
+		a, _ := Parse(pair[0])
+		b, _ := Parse(pair[1])
+		if a.Compare(b) != 0 {
+			t.Errorf("%s and %s compare as different", pair[0], pair[1])
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for input, want := range map[string]string{
+		"v2":                "2.0.0",
+		"2.3.1+45":          "2.3.1",
+		"3.1-beta.2+build9": "3.1.0-beta.2",
+	} {
+		v, err := Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max string
+		in       []string
+		out      []string
+	}{
+		{
+			name: "unbounded",
+			in:   []string{"0.0.1", "1.0.0-alpha", "99.0.0"},
+		},
+		{
+			name: "half-open",
+			min:  "2.0.0",
+			max:  "3.0.0",
+			in:   []string{"2.0.0", "2.9.9", "2.5.0-beta"},
+			out:  []string{"1.9.9", "3.0.0", "3.0.1", "2.0.0-rc.1"},
+		},
+		{
+			// A prerelease of the upper bound sorts below it
+			name: "prerelease of the upper bound",
+			min:  "2.0.0",
+			max:  "3.0.0",
+			in:   []string{"3.0.0-beta.1"},
+		},
+		{
+			name: "prerelease bounds",
+			min:  "3.0.0-beta",
+			max:  "3.0.0-rc.1",
+			in:   []string{"3.0.0-beta", "3.0.0-beta.5", "3.0.0-rc"},
+			out:  []string{"3.0.0-alpha", "3.0.0-rc.1", "3.0.0"},
+		},
+		{
+			name: "minimum only",
+			min:  "v2.3",
+			in:   []string{"2.3.0", "10.0.0"},
+			out:  []string{"2.2.99", "2.3.0-rc.1"},
+		},
+		{
+			name: "maximum only",
+			max:  "2.0",
+			in:   []string{"0.1.0", "1.99.0", "2.0.0-beta"},
+			out:  []string{"2.0.0", "2.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRange(tt.min, tt.max)
+			if err != nil {
+				t.Fatalf("ParseRange: %v", err)
+			}
+			if r.Unbounded() != (tt.min == "" && tt.max == "") {
+				t.Errorf("Unbounded = %t", r.Unbounded())
+			}
+			for _, s := range tt.in {
+				v, _ := Parse(s)
+				if !r.Contains(v) {
+					t.Errorf("[%s, %s) does not contain %s", tt.min, tt.max, s)
+				}
+			}
+			for _, s := range tt.out {
+				v, _ := Parse(s)
+				if r.Contains(v) {
+					t.Errorf("[%s, %s) contains %s", tt.min, tt.max, s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, bounds := range [][2]string{
+		{"2.0.0", "2.0.0"},
+		{"3.0.0", "2.0.0"},
+		{"2.0.0", "2.0.0-beta"},
+		{"not a version", ""},
+		{"", "1.x"},
+	} {
+		if _, err := ParseRange(bounds[0], bounds[1]); err == nil {
+			t.Errorf("ParseRange(%q, %q) accepted the range", bounds[0], bounds[1])
+		}
+	}
+}
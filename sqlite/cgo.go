@@ -0,0 +1,6 @@
+//go:build cgo
+
+package sqlite
+
+// driverAvailable reports whether the cgo SQLite driver is compiled in
+const driverAvailable = true
@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package sqlite
+
+// driverAvailable reports whether the cgo SQLite driver is compiled in; in
+// CGO_ENABLED=0 builds it is only a stub that fails every query
+const driverAvailable = false
@@ -0,0 +1,529 @@
+// Package sqlite implements database.Store on an embedded SQLite database, so
+// the server can run as a single binary for development. Only the tables
+// behind ingestion, queries and API keys exist; features that need
+// PostgreSQL are unavailable.
+//
+// The driver needs cgo: in binaries built with CGO_ENABLED=0, Open fails with
+// ErrNoCgo. The Docker image is built with cgo for this reason.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrNoCgo is returned by Open in binaries built without cgo
+var ErrNoCgo = errors.New("the sqlite storage backend needs a binary built with cgo (CGO_ENABLED=1); rebuild with cgo or use STORAGE_BACKEND=postgres or memory")
+
+// schema creates the tables on first use. Times are kept as UTC text, which
+// sorts chronologically.
+const schema = `
+CREATE TABLE IF NOT EXISTS analytics_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id TEXT NOT NULL UNIQUE,
+	timestamp TIMESTAMP NOT NULL,
+	event_type TEXT NOT NULL,
+	event_name TEXT NOT NULL,
+	properties TEXT,
+	user_id TEXT,
+	session_id TEXT,
+	app_version TEXT,
+	device_info TEXT,
+	sequence_number INTEGER,
+	priority TEXT NOT NULL DEFAULT 'normal',
+	created_at TIMESTAMP NOT NULL,
+	processed_at TIMESTAMP,
+	corrected_timestamp TIMESTAMP,
+	clock_skew_ms INTEGER,
+	clock_skew_flagged BOOLEAN NOT NULL DEFAULT 0,
+	sample_rate REAL NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_created_at ON analytics_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_event_type ON analytics_logs(event_type);
+CREATE INDEX IF NOT EXISTS idx_analytics_logs_session_id ON analytics_logs(session_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key_hash TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	is_active BOOLEAN NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	expires_at TIMESTAMP,
+	usage_count INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// logColumns lists the analytics_logs columns written on insert, in the
+// order returned by logValues
+const logColumns = `event_id, timestamp, event_type, event_name, properties,
+	user_id, session_id, app_version, device_info, sequence_number, priority, created_at,
+	corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate`
+
+// logSelectList lists the analytics_logs columns read by queryLogs
+const logSelectList = `id, event_id, timestamp, event_type, event_name, properties,
+	user_id, session_id, app_version, device_info, sequence_number,
+	priority, created_at, processed_at,
+	corrected_timestamp, clock_skew_ms, clock_skew_flagged, sample_rate`
+
+const insertLog = `INSERT INTO analytics_logs (` + logColumns + `)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Store keeps logs and API keys in a SQLite database file
+type Store struct {
+	conn *sql.DB
+}
+
+var _ database.Store = (*Store)(nil)
+
+// Open opens or creates the database at path and its tables. ":memory:"
+// opens a database that lives as long as the store.
+func Open(path string) (*Store, error) {
+	if !driverAvailable {
+		return nil, ErrNoCgo
+	}
+
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite has a single writer; one connection also keeps ":memory:" alive
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(schema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return &Store{conn: conn}, nil
+}
+
+// InsertLog inserts a single analytics log
+func (s *Store) InsertLog(log *models.AnalyticsLog) error {
+	values, err := logValues(log)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec(insertLog, values...)
+	if err != nil {
+		return insertError(log, err)
+	}
+
+	log.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to insert log: %w", err)
+	}
+	log.CreatedAt = values[11].(time.Time)
+	return nil
+}
+
+// InsertLogsBatch inserts multiple analytics logs in one transaction. The
+// whole batch fails if any event_id is already stored.
+func (s *Store) InsertLogsBatch(logs []models.AnalyticsLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insertLog)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range logs {
+		values, err := logValues(&logs[i])
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return insertError(&logs[i], err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// InsertLogsBatchSkipDuplicates inserts the logs whose event_id is new and
+// reports, per input index, whether the log was inserted
+func (s *Store) InsertLogsBatchSkipDuplicates(logs []models.AnalyticsLog) ([]bool, error) {
+	inserted := make([]bool, len(logs))
+	if len(logs) == 0 {
+		return inserted, nil
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insertLog + ` ON CONFLICT (event_id) DO NOTHING`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range logs {
+		values, err := logValues(&logs[i])
+		if err != nil {
+			return nil, err
+		}
+		result, err := stmt.Exec(values...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert log %s: %w", logs[i].EventID, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		inserted[i] = n == 1
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// GetFilteredLogs returns logs based on filter criteria
+func (s *Store) GetFilteredLogs(filter database.LogFilter) ([]models.AnalyticsLog, int64, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if filter.EventType != "" {
+		add("event_type = ?", filter.EventType)
+	}
+	if filter.EventName != "" {
+		add("event_name = ?", filter.EventName)
+	}
+	if filter.UserID != "" {
+		add("user_id = ?", filter.UserID)
+	}
+	if filter.SessionID != "" {
+		add("session_id = ?", filter.SessionID)
+	}
+	if filter.AppVersion != "" {
+		add("app_version = ?", filter.AppVersion)
+	}
+	if filter.Priority != "" {
+		add("priority = ?", filter.Priority)
+	}
+	if filter.ProviderName != "" {
+		add("json_extract(properties, '$.tags.provider') = ?", filter.ProviderName)
+	}
+	if filter.StartTime != nil {
+		add("created_at >= ?", filter.StartTime.UTC())
+	}
+	if filter.EndTime != nil {
+		add("created_at <= ?", filter.EndTime.UTC())
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalCount int64
+	err := s.conn.QueryRow("SELECT COUNT(*) FROM analytics_logs "+whereClause, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get filtered logs count: %w", err)
+	}
+
+	sortBy := "created_at"
+	if database.LogSortFields[filter.SortBy] {
+		sortBy = filter.SortBy
+	}
+
+	// Match PostgreSQL, where NULLs sort as the largest values
+	sortOrder := "DESC NULLS FIRST"
+	if filter.SortOrder == "ASC" || filter.SortOrder == "asc" {
+		sortOrder = "ASC NULLS LAST"
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	logs, err := s.queryLogs(fmt.Sprintf(`
+		SELECT %s
+		FROM analytics_logs
+		%s
+		ORDER BY %s %s
+		LIMIT ? OFFSET ?`, logSelectList, whereClause, sortBy, sortOrder), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get filtered logs: %w", err)
+	}
+
+	return logs, totalCount, nil
+}
+
+// GetRecentLogs returns recent logs for debugging
+func (s *Store) GetRecentLogs(limit int) ([]models.AnalyticsLog, error) {
+	logs, err := s.queryLogs(`
+		SELECT `+logSelectList+`
+		FROM analytics_logs
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent logs: %w", err)
+	}
+	return logs, nil
+}
+
+// GetMetrics counts the stored logs directly, so AsOf is always now
+func (s *Store) GetMetrics() (*models.MetricsResponse, error) {
+	now := time.Now().UTC()
+	metrics := &models.MetricsResponse{AsOf: &now}
+
+	err := s.conn.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE created_at >= ?),
+			COUNT(*) FILTER (WHERE created_at >= ?),
+			COUNT(DISTINCT session_id) FILTER (WHERE created_at >= ?)
+		FROM analytics_logs`,
+		now.Add(-time.Hour), now.Add(-24*time.Hour), now.Add(-30*time.Minute),
+	).Scan(&metrics.TotalLogs, &metrics.LogsLastHour, &metrics.LogsLastDay, &metrics.ActiveSessions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log counts: %w", err)
+	}
+
+	rows, err := s.conn.Query(`
+		SELECT event_type, COUNT(*) AS count
+		FROM analytics_logs
+		WHERE created_at >= ?
+		GROUP BY event_type
+		ORDER BY count DESC, event_type
+		LIMIT 10`, now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top event types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventType models.EventTypeCount
+		if err := rows.Scan(&eventType.EventType, &eventType.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan event type: %w", err)
+		}
+		metrics.TopEventTypes = append(metrics.TopEventTypes, eventType)
+	}
+
+	return metrics, rows.Err()
+}
+
+// GetLogCount returns the number of stored logs
+func (s *Store) GetLogCount() (int64, error) {
+	var count int64
+	if err := s.conn.QueryRow(`SELECT COUNT(*) FROM analytics_logs`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return count, nil
+}
+
+// GetAPIKey retrieves an active API key by hash
+func (s *Store) GetAPIKey(keyHash string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := s.conn.QueryRow(`
+		SELECT id, key_hash, name, is_active, created_at, last_used_at, expires_at, usage_count
+		FROM api_keys
+		WHERE key_hash = ? AND is_active`, keyHash).Scan(
+		&apiKey.ID,
+		&apiKey.KeyHash,
+		&apiKey.Name,
+		&apiKey.IsActive,
+		&apiKey.CreatedAt,
+		&apiKey.LastUsedAt,
+		&apiKey.ExpiresAt,
+		&apiKey.UsageCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return &apiKey, nil
+}
+
+// InsertAPIKey inserts a new API key
+func (s *Store) InsertAPIKey(apiKey *models.APIKey) error {
+	createdAt := time.Now().UTC()
+	result, err := s.conn.Exec(`
+		INSERT INTO api_keys (key_hash, name, is_active, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		apiKey.KeyHash, apiKey.Name, apiKey.IsActive, createdAt, utc(apiKey.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	apiKey.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+	apiKey.CreatedAt = createdAt
+	return nil
+}
+
+// UpdateAPIKeyUsage updates the API key usage statistics
+func (s *Store) UpdateAPIKeyUsage(keyHash string) error {
+	_, err := s.conn.Exec(`
+		UPDATE api_keys
+		SET last_used_at = ?, usage_count = usage_count + 1
+		WHERE key_hash = ?`, time.Now().UTC(), keyHash)
+	if err != nil {
+		return fmt.Errorf("failed to update API key usage: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck checks that the database file is readable
+func (s *Store) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.conn.PingContext(ctx)
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.conn.Close()
+}
+
+// queryLogs runs a query selecting logSelectList
+func (s *Store) queryLogs(query string, args ...interface{}) ([]models.AnalyticsLog, error) {
+	rows, err := s.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.AnalyticsLog
+	for rows.Next() {
+		var log models.AnalyticsLog
+		err := rows.Scan(
+			&log.ID,
+			&log.EventID,
+			&log.Timestamp,
+			&log.EventType,
+			&log.EventName,
+			&log.Properties,
+			&log.UserID,
+			&log.SessionID,
+			&log.AppVersion,
+			&log.DeviceInfo,
+			&log.SequenceNumber,
+			&log.Priority,
+			&log.CreatedAt,
+			&log.ProcessedAt,
+			&log.CorrectedTimestamp,
+			&log.ClockSkewMs,
+			&log.ClockSkewFlagged,
+			&log.SampleRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan log: %w", err)
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+// logValues returns the insert arguments for a log, matching logColumns
+func logValues(log *models.AnalyticsLog) ([]interface{}, error) {
+	properties, err := jsonText(log.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties of log %s: %w", log.EventID, err)
+	}
+	deviceInfo, err := jsonText(log.DeviceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device_info of log %s: %w", log.EventID, err)
+	}
+
+	priority := log.Priority
+	if priority == "" {
+		priority = "normal"
+	}
+	sampleRate := log.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 1
+	}
+
+	return []interface{}{
+		log.EventID,
+		log.Timestamp.UTC(),
+		log.EventType,
+		log.EventName,
+		properties,
+		log.UserID,
+		log.SessionID,
+		log.AppVersion,
+		deviceInfo,
+		log.SequenceNumber,
+		priority,
+		time.Now().UTC(),
+		utc(log.CorrectedTimestamp),
+		log.ClockSkewMs,
+		log.ClockSkewFlagged,
+		sampleRate,
+	}, nil
+}
+
+// insertError reports a unique violation on event_id as a duplicate
+func insertError(log *models.AnalyticsLog, err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("failed to insert log %s: %w", log.EventID, database.ErrDuplicateEvent)
+	}
+	return fmt.Errorf("failed to insert log %s: %w", log.EventID, err)
+}
+
+// jsonText encodes a JSON column as TEXT, which SQLite's JSON functions read
+func jsonText(m models.JSONB) (interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func utc(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
@@ -0,0 +1,284 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Message formats
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatRFC3164 = "rfc3164"
+)
+
+// nilValue is the RFC 5424 placeholder for an absent header field
+const nilValue = "-"
+
+var (
+	errNoPriority      = errors.New("missing or invalid PRI")
+	errBadHeader       = errors.New("malformed RFC 5424 header")
+	errBadStructured   = errors.New("malformed RFC 5424 structured data")
+	errBadTimestamp    = errors.New("invalid RFC 5424 timestamp")
+	utf8BOM            = []byte{0xEF, 0xBB, 0xBF}
+	rfc3164TimeLayouts = []string{time.Stamp, "Jan _2 15:04:05.000000", time.RFC3339Nano}
+)
+
+// Message is a parsed syslog message. Fields absent from the message are empty.
+type Message struct {
+	Format         string
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Text           string
+}
+
+// Parse parses an RFC 5424 or RFC 3164 message, detected by the version
+// number following PRI. Timestamps missing from the message, or an RFC 3164
+// timestamp that cannot be read, default to received.
+func Parse(data []byte, received time.Time) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	priority, rest, err := parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Facility:  priority / 8,
+		Severity:  priority % 8,
+		Timestamp: received,
+	}
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		msg.Format = FormatRFC5424
+		if err := parseRFC5424(msg, rest[2:]); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	msg.Format = FormatRFC3164
+	parseRFC3164(msg, rest, received)
+	return msg, nil
+}
+
+// parsePriority reads "<PRI>", returning the remainder
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errNoPriority
+	}
+
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, errNoPriority
+	}
+
+	priority, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, nil, errNoPriority
+	}
+
+	return priority, data[end+1:], nil
+}
+
+// parseRFC5424 parses the header after "<PRI>1 ":
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parseRFC5424(msg *Message, data []byte) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		end := bytes.IndexByte(data, ' ')
+		if end <= 0 {
+			return errBadHeader
+		}
+		fields[i] = string(data[:end])
+		data = data[end+1:]
+	}
+
+	if fields[0] != nilValue {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return errBadTimestamp
+		}
+		msg.Timestamp = timestamp
+	}
+
+	msg.Hostname = headerValue(fields[1])
+	msg.AppName = headerValue(fields[2])
+	msg.ProcID = headerValue(fields[3])
+	msg.MsgID = headerValue(fields[4])
+
+	structuredData, rest, err := parseStructuredData(data)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = structuredData
+
+	if len(rest) > 0 {
+		if rest[0] != ' ' {
+			return errBadStructured
+		}
+		msg.Text = string(bytes.TrimPrefix(rest[1:], utf8BOM))
+	}
+
+	return nil
+}
+
+// parseStructuredData reads "-" or one or more [SD-ID PARAM="VALUE" ...] elements
+func parseStructuredData(data []byte) (map[string]map[string]string, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errBadStructured
+	}
+	if data[0] == '-' {
+		return nil, data[1:], nil
+	}
+
+	elements := make(map[string]map[string]string)
+	for len(data) > 0 && data[0] == '[' {
+		data = data[1:]
+
+		idEnd := bytes.IndexAny(data, " ]")
+		if idEnd <= 0 {
+			return nil, nil, errBadStructured
+		}
+		id := string(data[:idEnd])
+		data = data[idEnd:]
+
+		params := elements[id]
+		if params == nil {
+			params = make(map[string]string)
+			elements[id] = params
+		}
+
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+
+			nameEnd := bytes.IndexByte(data, '=')
+			if nameEnd <= 0 || len(data) < nameEnd+2 || data[nameEnd+1] != '"' {
+				return nil, nil, errBadStructured
+			}
+			name := string(data[:nameEnd])
+
+			value, rest, err := parseParamValue(data[nameEnd+2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			params[name] = value
+			data = rest
+		}
+
+		if len(data) == 0 || data[0] != ']' {
+			return nil, nil, errBadStructured
+		}
+		data = data[1:]
+	}
+
+	return elements, data, nil
+}
+
+// parseParamValue reads a quoted PARAM-VALUE up to its closing quote,
+// unescaping \", \\ and \]
+func parseParamValue(data []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+				i++
+			}
+			value = append(value, data[i])
+		case '"':
+			return string(value), data[i+1:], nil
+		default:
+			value = append(value, data[i])
+		}
+	}
+	return "", nil, errBadStructured
+}
+
+// parseRFC3164 parses the BSD format after "<PRI>":
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+// The format is loosely specified, so anything unrecognized is kept as text.
+func parseRFC3164(msg *Message, data []byte, received time.Time) {
+	data = bytes.TrimLeft(data, " ")
+
+	if timestamp, rest, ok := parseRFC3164Timestamp(data, received); ok {
+		msg.Timestamp = timestamp
+		data = rest
+
+		// The hostname is the next word unless that word is already the tag
+		if end := bytes.IndexByte(data, ' '); end > 0 && !isTag(data[:end]) {
+			msg.Hostname = string(data[:end])
+			data = data[end+1:]
+		}
+	}
+
+	if end := bytes.IndexByte(data, ':'); end > 0 && isTag(data[:end+1]) {
+		tag := data[:end]
+		if open := bytes.IndexByte(tag, '['); open > 0 && tag[len(tag)-1] == ']' {
+			msg.ProcID = string(tag[open+1 : len(tag)-1])
+			tag = tag[:open]
+		}
+		msg.AppName = string(tag)
+		data = bytes.TrimPrefix(data[end+1:], []byte(" "))
+	}
+
+	msg.Text = string(data)
+}
+
+// parseRFC3164Timestamp reads a leading timestamp. RFC 3164 timestamps carry
+// no year or zone, so they are taken in local time in the year of received,
+// stepping back a year when that would put them more than a day ahead.
+func parseRFC3164Timestamp(data []byte, received time.Time) (time.Time, []byte, bool) {
+	for _, layout := range rfc3164TimeLayouts {
+		end := len(layout)
+		if layout == time.RFC3339Nano {
+			end = bytes.IndexByte(data, ' ')
+		}
+		if end <= 0 || end >= len(data) || data[end] != ' ' {
+			continue
+		}
+
+		timestamp, err := time.ParseInLocation(layout, string(data[:end]), time.Local)
+		if err != nil {
+			continue
+		}
+
+		if timestamp.Year() == 0 {
+			timestamp = timestamp.AddDate(received.Year(), 0, 0)
+			if timestamp.After(received.Add(24 * time.Hour)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+		}
+		return timestamp, data[end+1:], true
+	}
+	return time.Time{}, data, false
+}
+
+// isTag reports whether word looks like an RFC 3164 TAG, optionally with a
+// [PID] suffix, terminated by a colon
+func isTag(word []byte) bool {
+	if len(word) < 2 || word[len(word)-1] != ':' {
+		return false
+	}
+	for _, c := range word[:len(word)-1] {
+		if c == ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// headerValue converts the RFC 5424 nil value to an empty string
+func headerValue(field string) string {
+	if field == nilValue {
+		return ""
+	}
+	return field
+}
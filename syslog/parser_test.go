@@ -0,0 +1,243 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	received := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  Message
+	}{
+		{
+			name:  "full header",
+			input: "<165>1 2026-10-16T09:42:00.003Z app01.example.com checkout 8710 ORDER_PLACED - Order 42 placed",
+			want: Message{
+				Format: FormatRFC5424, Facility: 20, Severity: 5,
+				Timestamp: time.Date(2026, 10, 16, 9, 42, 0, 3000000, time.UTC),
+				Hostname:  "app01.example.com", AppName: "checkout", ProcID: "8710", MsgID: "ORDER_PLACED",
+				Text: "Order 42 placed",
+			},
+		},
+		{
+			name:  "NILVALUE header fields",
+			input: "<14>1 - - - - - - hello",
+			want:  Message{Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Text: "hello"},
+		},
+		{
+			name:  "no message",
+			input: "<14>1 - host app - - -",
+			want:  Message{Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app"},
+		},
+		{
+			name:  "BOM before the message",
+			input: "<14>1 - host app - - - \xEF\xBB\xBFgrüße",
+			want:  Message{Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app", Text: "grüße"},
+		},
+		{
+			name:  "timestamp with offset",
+			input: "<14>1 2026-10-16T11:42:00+02:00 host app - - - up",
+			want: Message{
+				Format: FormatRFC5424, Facility: 1, Severity: 6,
+				Timestamp: time.Date(2026, 10, 16, 9, 42, 0, 0, time.UTC),
+				Hostname:  "host", AppName: "app", Text: "up",
+			},
+		},
+		{
+			name:  "structured data",
+			input: `<14>1 - host app - - [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="192.0.2.1"] started`,
+			want: Message{
+				Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": "Application"},
+					"origin":            {"ip": "192.0.2.1"},
+				},
+				Text: "started",
+			},
+		},
+		{
+			name:  "structured data escapes",
+			input: `<14>1 - host app - - [meta quote="say \"hi\"" slash="a\\b" bracket="[x\]" other="\n"]`,
+			want: Message{
+				Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app",
+				StructuredData: map[string]map[string]string{
+					"meta": {"quote": `say "hi"`, "slash": `a\b`, "bracket": "[x]", "other": `\n`},
+				},
+			},
+		},
+		{
+			name:  "structured data element without params",
+			input: "<14>1 - host app - - [heartbeat] ok",
+			want: Message{
+				Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app",
+				StructuredData: map[string]map[string]string{"heartbeat": {}},
+				Text:           "ok",
+			},
+		},
+		{
+			name:  "trailing newline and NULs trimmed",
+			input: "<14>1 - host app - - - bye\r\n\x00",
+			want:  Message{Format: FormatRFC5424, Facility: 1, Severity: 6, Timestamp: received, Hostname: "host", AppName: "app", Text: "bye"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input), received)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("timestamp = %s, want %s", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{name: "empty", input: "", want: errNoPriority},
+		{name: "no PRI", input: "hello", want: errNoPriority},
+		{name: "empty PRI", input: "<>1 - - - - - -", want: errNoPriority},
+		{name: "PRI out of range", input: "<192>1 - - - - - -", want: errNoPriority},
+		{name: "PRI not a number", input: "<1a>1 - - - - - -", want: errNoPriority},
+		{name: "PRI too long", input: "<0014>1 - - - - - -", want: errNoPriority},
+		{name: "truncated header", input: "<14>1 - host app", want: errBadHeader},
+		{name: "double space in header", input: "<14>1 -  host app - - -", want: errBadHeader},
+		{name: "bad timestamp", input: "<14>1 16/10/2026 host app - - - x", want: errBadTimestamp},
+		{name: "missing structured data", input: "<14>1 - host app - - ", want: errBadStructured},
+		{name: "unterminated element", input: `<14>1 - host app - - [meta a="1" x`, want: errBadStructured},
+		{name: "unquoted param", input: "<14>1 - host app - - [meta a=1] x", want: errBadStructured},
+		{name: "unterminated param", input: `<14>1 - host app - - [meta a="1] x`, want: errBadStructured},
+		{name: "no space before message", input: "<14>1 - host app - - -x", want: errBadStructured},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg, err := Parse([]byte(tt.input), time.Now()); err != tt.want {
+				t.Errorf("Parse = %+v, %v, want %v", msg, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRFC3164(t *testing.T) {
+	received := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		input    string
+		received time.Time
+		want     Message
+	}{
+		{
+			name:  "full message",
+			input: "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			want: Message{
+				Format: FormatRFC3164, Facility: 4, Severity: 2,
+				Timestamp: time.Date(2026, 10, 11, 22, 14, 15, 0, time.Local),
+				Hostname:  "mymachine", AppName: "su", ProcID: "230",
+				Text: "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name:  "space padded day",
+			input: "<13>Oct  6 08:00:00 host cron: run",
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2026, 10, 6, 8, 0, 0, 0, time.Local),
+				Hostname:  "host", AppName: "cron", Text: "run",
+			},
+		},
+		{
+			name:  "fractional seconds",
+			input: "<13>Oct 16 11:59:59.250000 host app: tick",
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2026, 10, 16, 11, 59, 59, 250000000, time.Local),
+				Hostname:  "host", AppName: "app", Text: "tick",
+			},
+		},
+		{
+			name:     "December message received in January is last year's",
+			input:    "<13>Dec 31 23:59:58 host app: late",
+			received: time.Date(2026, 1, 1, 0, 0, 5, 0, time.Local),
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2025, 12, 31, 23, 59, 58, 0, time.Local),
+				Hostname:  "host", AppName: "app", Text: "late",
+			},
+		},
+		{
+			name:     "clock slightly ahead stays in the current year",
+			input:    "<13>Jan  1 10:00:00 host app: early",
+			received: time.Date(2026, 1, 1, 0, 0, 5, 0, time.Local),
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2026, 1, 1, 10, 0, 0, 0, time.Local),
+				Hostname:  "host", AppName: "app", Text: "early",
+			},
+		},
+		{
+			name:  "RFC 3339 timestamp",
+			input: "<13>2026-10-16T09:42:00Z host app: iso",
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2026, 10, 16, 9, 42, 0, 0, time.UTC),
+				Hostname:  "host", AppName: "app", Text: "iso",
+			},
+		},
+		{
+			name:  "no hostname",
+			input: "<13>Oct 16 08:00:00 app[7]: no host",
+			want: Message{
+				Format: FormatRFC3164, Facility: 1, Severity: 5,
+				Timestamp: time.Date(2026, 10, 16, 8, 0, 0, 0, time.Local),
+				AppName:   "app", ProcID: "7", Text: "no host",
+			},
+		},
+		{
+			name:  "no timestamp",
+			input: "<13>app: just a tag",
+			want:  Message{Format: FormatRFC3164, Facility: 1, Severity: 5, Timestamp: received, AppName: "app", Text: "just a tag"},
+		},
+		{
+			name:  "unstructured text",
+			input: "<13>something happened: maybe",
+			want:  Message{Format: FormatRFC3164, Facility: 1, Severity: 5, Timestamp: received, Text: "something happened: maybe"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := tt.received
+			if at.IsZero() {
+				at = received
+			}
+			got, err := Parse([]byte(tt.input), at)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("timestamp = %s, want %s", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
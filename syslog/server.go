@@ -0,0 +1,428 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log-ingestion-server/models"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// flushInterval bounds how long a received message waits to be enqueued
+	flushInterval = 250 * time.Millisecond
+
+	// bufferSize is the number of parsed messages waiting to be batched
+	bufferSize = 4096
+
+	// disabledAddr turns off a transport
+	disabledAddr = "none"
+)
+
+var errMessageTooLong = errors.New("message exceeds maximum size")
+
+// Sink prepares and stores converted logs. The ingest handler implements it,
+// so syslog messages are validated, transformed, sampled and redacted like
+// HTTP requests. It returns how many logs failed validation.
+type Sink interface {
+	IngestSyslog(logs []models.AnalyticsLog) (int, error)
+}
+
+// Options configures a Server. An empty or "none" address disables that transport.
+type Options struct {
+	UDPAddr        string
+	TCPAddr        string
+	MaxMessageSize int
+	BatchSize      int
+}
+
+// Server listens for syslog messages and hands them to a Sink in batches
+type Server struct {
+	opts    Options
+	sink    Sink
+	metrics *serverMetrics
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+
+	logs        chan models.AnalyticsLog
+	readers     sync.WaitGroup
+	batcherDone chan struct{}
+}
+
+// serverMetrics holds Prometheus metrics for the listener
+type serverMetrics struct {
+	Received    *prometheus.CounterVec
+	ParseErrors *prometheus.CounterVec
+	Dropped     *prometheus.CounterVec
+}
+
+// NewServer creates a syslog server feeding sink
+func NewServer(opts Options, sink Sink) *Server {
+	metrics := &serverMetrics{
+		Received: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "syslog_messages_received_total",
+				Help: "Total number of syslog messages received",
+			},
+			[]string{"transport", "format"},
+		),
+		ParseErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "syslog_parse_errors_total",
+				Help: "Total number of syslog messages that could not be parsed",
+			},
+			[]string{"transport", "reason"},
+		),
+		Dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "syslog_dropped_messages_total",
+				Help: "Total number of parsed syslog messages that could not be enqueued",
+			},
+			[]string{"reason"},
+		),
+	}
+
+	prometheus.MustRegister(metrics.Received, metrics.ParseErrors, metrics.Dropped)
+
+	return &Server{
+		opts:        opts,
+		sink:        sink,
+		metrics:     metrics,
+		conns:       make(map[net.Conn]struct{}),
+		logs:        make(chan models.AnalyticsLog, bufferSize),
+		batcherDone: make(chan struct{}),
+	}
+}
+
+// Start opens the configured listeners and begins receiving
+func (s *Server) Start() error {
+	if enabled(s.opts.UDPAddr) {
+		conn, err := net.ListenPacket("udp", s.opts.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for syslog on udp %s: %w", s.opts.UDPAddr, err)
+		}
+		s.udp = conn
+	}
+
+	if enabled(s.opts.TCPAddr) {
+		listener, err := net.Listen("tcp", s.opts.TCPAddr)
+		if err != nil {
+			if s.udp != nil {
+				s.udp.Close()
+			}
+			return fmt.Errorf("failed to listen for syslog on tcp %s: %w", s.opts.TCPAddr, err)
+		}
+		s.tcp = listener
+	}
+
+	go s.batcher()
+
+	if s.udp != nil {
+		s.readers.Add(1)
+		go s.serveUDP()
+		logrus.Infof("Syslog listening on udp %s", s.udp.LocalAddr())
+	}
+	if s.tcp != nil {
+		s.readers.Add(1)
+		go s.serveTCP()
+		logrus.Infof("Syslog listening on tcp %s", s.tcp.Addr())
+	}
+
+	return nil
+}
+
+// Shutdown stops the listeners, closes open connections and enqueues
+// every message already received
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.udp != nil {
+		s.udp.Close()
+	}
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.readers.Wait()
+		close(s.logs)
+		<-s.batcherDone
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveUDP treats every datagram as one message
+func (s *Server) serveUDP() {
+	defer s.readers.Done()
+
+	buf := make([]byte, s.opts.MaxMessageSize+1)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			logrus.Errorf("Syslog UDP read failed: %v", err)
+			continue
+		}
+
+		if n > s.opts.MaxMessageSize {
+			s.metrics.ParseErrors.WithLabelValues("udp", "too_long").Inc()
+			continue
+		}
+
+		log, ok := s.parse(buf[:n], hostOf(addr), "udp")
+		if !ok {
+			continue
+		}
+
+		select {
+		case s.logs <- log:
+		default:
+			// UDP senders cannot be slowed down, so shed load instead
+			s.metrics.Dropped.WithLabelValues("buffer_full").Inc()
+		}
+	}
+}
+
+// serveTCP accepts connections until the listener is closed
+func (s *Server) serveTCP() {
+	defer s.readers.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			logrus.Errorf("Syslog TCP accept failed: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.readers.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads messages framed by octet counting or by newlines (RFC 6587)
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.readers.Done()
+	}()
+
+	source := hostOf(conn.RemoteAddr())
+	reader := bufio.NewReaderSize(conn, 64*1024)
+
+	for {
+		frame, err := s.readFrame(reader)
+		if err == errMessageTooLong {
+			s.metrics.ParseErrors.WithLabelValues("tcp", "too_long").Inc()
+			continue
+		}
+		if err != nil {
+			if err != io.EOF && !s.isClosed() {
+				logrus.Debugf("Syslog TCP connection from %s closed: %v", source, err)
+			}
+			return
+		}
+
+		if len(bytes.TrimSpace(frame)) == 0 {
+			continue
+		}
+
+		if log, ok := s.parse(frame, source, "tcp"); ok {
+			// Blocking here pushes back on the sender through TCP flow control
+			s.logs <- log
+		}
+	}
+}
+
+// readFrame reads one message. A frame starting with a digit is octet
+// counted ("LEN SP MSG"); anything else ends at a newline.
+func (s *Server) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		prefix, err := reader.ReadSlice(' ')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(string(prefix[:len(prefix)-1]))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid octet count %q", prefix)
+		}
+		if length > s.opts.MaxMessageSize {
+			if _, err := reader.Discard(length); err != nil {
+				return nil, err
+			}
+			return nil, errMessageTooLong
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	var frame []byte
+	tooLong := false
+	for {
+		line, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(frame)+len(line) > s.opts.MaxMessageSize+1 {
+				tooLong = true
+				frame = nil
+			} else {
+				frame = append(frame, line...)
+			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(frame) > 0 && !tooLong:
+			return frame, nil
+		case err != nil:
+			return nil, err
+		case tooLong:
+			return nil, errMessageTooLong
+		default:
+			return frame, nil
+		}
+	}
+}
+
+// parse converts a raw message, counting failures
+func (s *Server) parse(data []byte, source, transport string) (models.AnalyticsLog, bool) {
+	msg, err := Parse(data, time.Now())
+	if err != nil {
+		s.metrics.ParseErrors.WithLabelValues(transport, parseErrorReason(err)).Inc()
+		logrus.Debugf("Dropped unparseable syslog message from %s: %v", source, err)
+		return models.AnalyticsLog{}, false
+	}
+
+	s.metrics.Received.WithLabelValues(transport, msg.Format).Inc()
+	return msg.ToLog(source, transport), true
+}
+
+// batcher groups received logs and enqueues them, flushing on size or interval
+func (s *Server) batcher() {
+	defer close(s.batcherDone)
+
+	batch := make([]models.AnalyticsLog, 0, s.opts.BatchSize)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		rejected, err := s.sink.IngestSyslog(batch)
+		if rejected > 0 {
+			s.metrics.Dropped.WithLabelValues("invalid").Add(float64(rejected))
+		}
+		if err != nil {
+			s.metrics.Dropped.WithLabelValues("enqueue_failed").Add(float64(len(batch) - rejected))
+			logrus.Warnf("Dropped %d syslog messages: %v", len(batch)-rejected, err)
+		}
+		batch = make([]models.AnalyticsLog, 0, s.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case log, ok := <-s.logs:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// parseErrorReason labels a parse error for metrics
+func parseErrorReason(err error) string {
+	switch err {
+	case errNoPriority:
+		return "invalid_priority"
+	case errBadHeader:
+		return "invalid_header"
+	case errBadStructured:
+		return "invalid_structured_data"
+	case errBadTimestamp:
+		return "invalid_timestamp"
+	default:
+		return "other"
+	}
+}
+
+// hostOf returns the host part of a network address
+func hostOf(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func enabled(addr string) bool {
+	return addr != "" && addr != disabledAddr
+}
@@ -0,0 +1,183 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log-ingestion-server/models"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestReadFrame(t *testing.T) {
+	long := "<14>" + strings.Repeat("x", 60)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "newline framing",
+			input: "<14>first\n<14>second\n",
+			want:  []string{"<14>first\n", "<14>second\n"},
+		},
+		{
+			name:  "last newline frame ends at EOF",
+			input: "<14>first\n<14>second",
+			want:  []string{"<14>first\n", "<14>second"},
+		},
+		{
+			name:  "octet counting",
+			input: "9 <14>first10 <14>second",
+			want:  []string{"<14>first", "<14>second"},
+		},
+		{
+			name:  "octet counted frame keeps newlines",
+			input: "11 <14>a\nb\nc\nd",
+			want:  []string{"<14>a\nb\nc\nd"},
+		},
+		{
+			name:  "both framings on one connection",
+			input: "9 <14>first<14>second\n9 <14>third",
+			want:  []string{"<14>first", "<14>second\n", "<14>third"},
+		},
+		{
+			name:    "oversized octet counted frame skipped",
+			input:   "64 " + long + "9 <14>after",
+			want:    []string{"", "<14>after"},
+			wantErr: errMessageTooLong,
+		},
+		{
+			name:    "oversized line skipped",
+			input:   long + "\n<14>after\n",
+			want:    []string{"", "<14>after\n"},
+			wantErr: errMessageTooLong,
+		},
+		{
+			name:    "truncated octet counted frame",
+			input:   "20 <14>short",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{opts: Options{MaxMessageSize: 32}}
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+
+			var got []string
+			var gotErr error
+			for {
+				frame, err := s.readFrame(reader)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					gotErr = err
+					if err != errMessageTooLong {
+						break
+					}
+				}
+				got = append(got, string(frame))
+			}
+
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("error = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFrameInvalidOctetCount(t *testing.T) {
+	s := &Server{opts: Options{MaxMessageSize: 32}}
+	if _, err := s.readFrame(bufio.NewReader(strings.NewReader("1x <14>a"))); err == nil {
+		t.Error("readFrame accepted an invalid octet count")
+	}
+}
+
+// TestReadFrameLongLine reads a line longer than the connection buffer
+func TestReadFrameLongLine(t *testing.T) {
+	s := &Server{opts: Options{MaxMessageSize: 100}}
+	line := "<14>" + strings.Repeat("y", 80) + "\n"
+	frame, err := s.readFrame(bufio.NewReaderSize(strings.NewReader(line), 16))
+	if err != nil || string(frame) != line {
+		t.Errorf("readFrame = %q, %v, want the whole line", frame, err)
+	}
+}
+
+type recordingSink struct {
+	mu   sync.Mutex
+	logs []models.AnalyticsLog
+}
+
+func (s *recordingSink) IngestSyslog(logs []models.AnalyticsLog) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, logs...)
+	return 0, nil
+}
+
+// TestServerTCP sends both framings over one connection and checks every
+// parseable message reaches the sink
+func TestServerTCP(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = registerer }()
+
+	sink := &recordingSink{}
+	s := NewServer(Options{TCPAddr: "127.0.0.1:0", MaxMessageSize: 1024, BatchSize: 10}, sink)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", s.tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := "<14>1 - host app - first - newline framed\n" +
+		"39 <14>1 - host app - second - octet\ncount" +
+		"not syslog\n" +
+		"<13>Oct 16 08:00:00 host app: bsd\n"
+	if _, err := conn.Write([]byte(messages)); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// Wait for the batcher to flush the messages before shutting down, which
+	// closes connections still being read
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		sink.mu.Lock()
+		received := len(sink.logs)
+		sink.mu.Unlock()
+		if received == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	var names []string
+	for _, log := range sink.logs {
+		names = append(names, log.EventName)
+	}
+	if got, want := strings.Join(names, ","), "first,second,app"; got != want {
+		t.Errorf("event names = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+// Package syslog receives RFC 5424 and RFC 3164 syslog messages over UDP and
+// TCP and converts them into analytics logs for the ingest pipeline.
+package syslog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log-ingestion-server/models"
+	"strconv"
+)
+
+// Severities from RFC 5424 section 6.2.1
+const (
+	SeverityEmergency = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInformational
+	SeverityDebug
+)
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// telemetryFacilities are the facilities used by the operating system and its
+// daemons, as opposed to applications (user, local0-7) and security logging
+var telemetryFacilities = map[int]bool{
+	0: true, 2: true, 3: true, 5: true, 6: true, 7: true,
+	8: true, 9: true, 11: true, 12: true, 15: true,
+}
+
+// defaultEventName is used for messages with neither MSGID nor APP-NAME
+const defaultEventName = "syslog_message"
+
+// FacilityName returns the conventional keyword for a facility code
+func FacilityName(facility int) string {
+	if facility >= 0 && facility < len(facilityNames) {
+		return facilityNames[facility]
+	}
+	return strconv.Itoa(facility)
+}
+
+// SeverityName returns the conventional keyword for a severity code
+func SeverityName(severity int) string {
+	if severity >= 0 && severity < len(severityNames) {
+		return severityNames[severity]
+	}
+	return strconv.Itoa(severity)
+}
+
+// EventType maps a message's facility and severity to an event type.
+// Messages at err severity or worse are errors; otherwise system facilities
+// are telemetry, and application and security facilities are observability.
+func EventType(facility, severity int) string {
+	switch {
+	case severity <= SeverityError:
+		return "error"
+	case telemetryFacilities[facility]:
+		return "telemetry"
+	default:
+		return "observability"
+	}
+}
+
+// Priority maps a severity to a log priority
+func Priority(severity int) string {
+	if severity <= SeverityError {
+		return "high"
+	}
+	return "normal"
+}
+
+// ToLog converts a parsed message into an analytics log. The message text,
+// header fields and structured data go into Properties; the sending host and
+// transport go into DeviceInfo. Syslog has no message identifier, so each
+// message gets a random event ID.
+func (m *Message) ToLog(source, transport string) models.AnalyticsLog {
+	properties := models.JSONB{
+		"message":  m.Text,
+		"facility": FacilityName(m.Facility),
+		"severity": SeverityName(m.Severity),
+		"format":   m.Format,
+	}
+	if m.AppName != "" {
+		properties["app_name"] = m.AppName
+	}
+	if m.ProcID != "" {
+		properties["proc_id"] = m.ProcID
+	}
+	if m.MsgID != "" {
+		properties["msg_id"] = m.MsgID
+	}
+	if len(m.StructuredData) > 0 {
+		properties["structured_data"] = m.StructuredData
+	}
+
+	deviceInfo := models.JSONB{
+		"source":    source,
+		"transport": transport,
+	}
+	if m.Hostname != "" {
+		deviceInfo["hostname"] = m.Hostname
+	}
+
+	eventName := defaultEventName
+	switch {
+	case m.MsgID != "":
+		eventName = m.MsgID
+	case m.AppName != "":
+		eventName = m.AppName
+	}
+
+	return models.AnalyticsLog{
+		EventID:    newEventID(),
+		Timestamp:  m.Timestamp,
+		EventType:  EventType(m.Facility, m.Severity),
+		EventName:  eventName,
+		Properties: properties,
+		DeviceInfo: deviceInfo,
+		Priority:   Priority(m.Severity),
+	}
+}
+
+// newEventID returns a random event ID
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "syslog-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,134 @@
+// Package taxonomy caches the event type taxonomy stored in the event_types
+// table, so event types can be added or retired without a redeploy.
+package taxonomy
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Store loads the event type taxonomy
+type Store interface {
+	ListEventTypes() ([]models.EventType, error)
+}
+
+// Registry caches the event types by name
+type Registry struct {
+	store Store
+
+	mu    sync.RWMutex
+	types map[string]models.EventType
+}
+
+// NewRegistry creates an empty registry backed by store
+func NewRegistry(store Store) *Registry {
+	return &Registry{
+		store: store,
+		types: make(map[string]models.EventType),
+	}
+}
+
+// Load replaces the cache with the event types from the store. An empty
+// taxonomy is refused, since it would reject every log.
+func (r *Registry) Load() error {
+	eventTypes, err := r.store.ListEventTypes()
+	if err != nil {
+		return err
+	}
+
+	types := make(map[string]models.EventType, len(eventTypes))
+	active := 0
+	for _, t := range eventTypes {
+		types[t.Name] = t
+		if t.IsActive {
+			active++
+		}
+	}
+	if active == 0 {
+		return fmt.Errorf("event type taxonomy has no active types")
+	}
+
+	r.mu.Lock()
+	r.types = types
+	r.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh periodically reloads the cache so taxonomy changes made through
+// other server instances take effect
+func (r *Registry) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Load(); err != nil {
+				logrus.Errorf("Failed to refresh event types: %v", err)
+			}
+		}
+	}()
+}
+
+// Get returns an event type, active or not
+func (r *Registry) Get(name string) (models.EventType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Accepts reports whether logs of an event type may be ingested
+func (r *Registry) Accepts(name string) bool {
+	t, ok := r.Get(name)
+	return ok && t.IsActive
+}
+
+// Known reports whether an event type exists, including retired types that
+// still have stored logs
+func (r *Registry) Known(name string) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+// DefaultPriority returns the priority given to logs of an event type that do
+// not set one
+func (r *Registry) DefaultPriority(name string) string {
+	if t, ok := r.Get(name); ok && t.DefaultPriority != "" {
+		return t.DefaultPriority
+	}
+	return "normal"
+}
+
+// ActiveNames returns the accepted event types in sorted order
+func (r *Registry) ActiveNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.types))
+	for name, t := range r.types {
+		if t.IsActive {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns every event type in sorted order
+func (r *Registry) All() []models.EventType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]models.EventType, 0, len(r.types))
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
@@ -0,0 +1,140 @@
+package taxonomy
+
+import (
+	"errors"
+	"log-ingestion-server/database"
+	"log-ingestion-server/models"
+	"reflect"
+	"testing"
+)
+
+type fakeStore struct {
+	types []models.EventType
+	err   error
+}
+
+func (s *fakeStore) ListEventTypes() ([]models.EventType, error) {
+	return s.types, s.err
+}
+
+func newTestRegistry(t *testing.T, types ...models.EventType) (*Registry, *fakeStore) {
+	t.Helper()
+	store := &fakeStore{types: types}
+	r := NewRegistry(store)
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return r, store
+}
+
+func TestRegistry(t *testing.T) {
+	r, _ := newTestRegistry(t,
+		models.EventType{Name: "user_action", DefaultPriority: "normal", IsActive: true},
+		models.EventType{Name: "error", DefaultPriority: "high", IsActive: true},
+		models.EventType{Name: "legacy_ping", IsActive: false},
+		models.EventType{Name: "checkout", IsActive: true},
+	)
+
+	tests := []struct {
+		name         string
+		wantAccepts  bool
+		wantKnown    bool
+		wantPriority string
+	}{
+		{name: "user_action", wantAccepts: true, wantKnown: true, wantPriority: "normal"},
+		{name: "error", wantAccepts: true, wantKnown: true, wantPriority: "high"},
+		{name: "checkout", wantAccepts: true, wantKnown: true, wantPriority: "normal"},
+		{name: "legacy_ping", wantAccepts: false, wantKnown: true, wantPriority: "normal"},
+		{name: "unknown", wantAccepts: false, wantKnown: false, wantPriority: "normal"},
+		{name: "User_Action", wantAccepts: false, wantKnown: false, wantPriority: "normal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Accepts(tt.name); got != tt.wantAccepts {
+				t.Errorf("Accepts = %t, want %t", got, tt.wantAccepts)
+			}
+			if got := r.Known(tt.name); got != tt.wantKnown {
+				t.Errorf("Known = %t, want %t", got, tt.wantKnown)
+			}
+			if got := r.DefaultPriority(tt.name); got != tt.wantPriority {
+				t.Errorf("DefaultPriority = %q, want %q", got, tt.wantPriority)
+			}
+		})
+	}
+
+	if want := []string{"checkout", "error", "user_action"}; !reflect.DeepEqual(r.ActiveNames(), want) {
+		t.Errorf("ActiveNames = %v, want %v", r.ActiveNames(), want)
+	}
+
+	var all []string
+	for _, eventType := range r.All() {
+		all = append(all, eventType.Name)
+	}
+	if want := []string{"checkout", "error", "legacy_ping", "user_action"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("All = %v, want %v", all, want)
+	}
+}
+
+func TestLoadKeepsTaxonomyOnError(t *testing.T) {
+	r, store := newTestRegistry(t, models.EventType{Name: "user_action", IsActive: true})
+
+	tests := []struct {
+		name  string
+		types []models.EventType
+		err   error
+	}{
+		{name: "store error", err: errors.New("database unavailable")},
+		{name: "empty taxonomy"},
+		{name: "every type retired", types: []models.EventType{{Name: "user_action"}, {Name: "error"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.types, store.err = tt.types, tt.err
+			if err := r.Load(); err == nil {
+				t.Error("Load succeeded")
+			}
+			if !r.Accepts("user_action") || r.Known("error") {
+				t.Errorf("taxonomy after a failed load = %v, want the previous one", r.All())
+			}
+		})
+	}
+}
+
+func TestLoadReplacesTaxonomy(t *testing.T) {
+	r, store := newTestRegistry(t, models.EventType{Name: "user_action", IsActive: true})
+
+	store.types = []models.EventType{{Name: "user_action"}, {Name: "interaction", IsActive: true}}
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if r.Accepts("user_action") || !r.Known("user_action") {
+		t.Error("retired type still accepted, or forgotten")
+	}
+	if !r.Accepts("interaction") {
+		t.Error("new type not accepted")
+	}
+}
+
+func TestEmptyRegistry(t *testing.T) {
+	r := NewRegistry(&fakeStore{})
+	if r.Accepts("user_action") || r.Known("user_action") || len(r.ActiveNames()) != 0 || len(r.All()) != 0 {
+		t.Error("a registry that was never loaded knows event types")
+	}
+}
+
+func TestBuiltinTaxonomy(t *testing.T) {
+	r := NewRegistry(database.Builtins{})
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(r.ActiveNames()) == 0 {
+		t.Fatal("built-in taxonomy has no active types")
+	}
+	for _, eventType := range r.All() {
+		if eventType.DefaultPriority != "normal" && eventType.DefaultPriority != "high" {
+			t.Errorf("%s has default priority %q", eventType.Name, eventType.DefaultPriority)
+		}
+	}
+}
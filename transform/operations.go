@@ -0,0 +1,275 @@
+package transform
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operation is a compiled transform operation
+type operation struct {
+	models.TransformOperation
+	from, to, path, start, end []string
+}
+
+func compileOperation(op models.TransformOperation) (operation, error) {
+	compiled := operation{TransformOperation: op}
+
+	var err error
+	field := func(name, value string) []string {
+		if err != nil {
+			return nil
+		}
+		var path []string
+		path, err = parsePath(name, value)
+		return path
+	}
+
+	switch op.Op {
+	case models.TransformRenameEvent:
+		if op.To == "" {
+			return operation{}, fmt.Errorf("rename_event needs to")
+		}
+	case models.TransformMove:
+		compiled.from = field("from", op.From)
+		compiled.to = field("to", op.To)
+		if err == nil && (hasPrefix(compiled.from, compiled.to) || hasPrefix(compiled.to, compiled.from)) {
+			err = fmt.Errorf("move from %q to %q overlaps", op.From, op.To)
+		}
+	case models.TransformDrop:
+		compiled.path = field("path", op.Path)
+	case models.TransformCoerce:
+		compiled.path = field("path", op.Path)
+		if err == nil && op.Type == "" {
+			err = fmt.Errorf("coerce needs type")
+		}
+	case models.TransformDeriveDuration:
+		compiled.start = field("start", op.Start)
+		compiled.end = field("end", op.End)
+		compiled.to = field("to", op.To)
+	default:
+		err = fmt.Errorf("unknown operation %q", op.Op)
+	}
+	if err != nil {
+		return operation{}, err
+	}
+
+	return compiled, nil
+}
+
+// apply runs the operation on a log. Operations whose source keys are absent
+// do nothing; an error leaves the log as it was.
+func (op *operation) apply(log *models.AnalyticsLog) error {
+	if log.Properties == nil {
+		log.Properties = make(models.JSONB)
+	}
+	properties := map[string]interface{}(log.Properties)
+
+	switch op.Op {
+	case models.TransformRenameEvent:
+		log.EventName = op.To
+
+	case models.TransformMove:
+		value, ok := get(properties, op.from)
+		if !ok {
+			return nil
+		}
+		// A payload carrying both shapes keeps the new one
+		if _, exists := get(properties, op.to); !exists {
+			if err := set(properties, op.to, value); err != nil {
+				return err
+			}
+		}
+		remove(properties, op.from)
+
+	case models.TransformDrop:
+		remove(properties, op.path)
+
+	case models.TransformCoerce:
+		value, ok := get(properties, op.path)
+		if !ok {
+			return nil
+		}
+		coerced, err := coerce(value, op.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op.Path, err)
+		}
+		return set(properties, op.path, coerced)
+
+	case models.TransformDeriveDuration:
+		startValue, okStart := get(properties, op.start)
+		endValue, okEnd := get(properties, op.end)
+		if !okStart || !okEnd {
+			return nil
+		}
+		start, err := parseTime(startValue)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op.Start, err)
+		}
+		end, err := parseTime(endValue)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op.End, err)
+		}
+
+		var duration interface{} = end.Sub(start).Milliseconds()
+		if op.Unit == "s" {
+			duration = end.Sub(start).Seconds()
+		}
+		return set(properties, op.to, duration)
+	}
+
+	return nil
+}
+
+func parsePath(name, value string) ([]string, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s is required", name)
+	}
+	path := strings.Split(value, ".")
+	for _, key := range path {
+		if key == "" {
+			return nil, fmt.Errorf("invalid %s path %q", name, value)
+		}
+	}
+	return path, nil
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func get(properties map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = properties
+	for _, key := range path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// set stores a value, creating intermediate objects as needed
+func set(properties map[string]interface{}, path []string, value interface{}) error {
+	object := properties
+	for i, key := range path[:len(path)-1] {
+		next, exists := object[key]
+		if !exists {
+			created := make(map[string]interface{})
+			object[key] = created
+			object = created
+			continue
+		}
+		nested, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an object", strings.Join(path[:i+1], "."))
+		}
+		object = nested
+	}
+	object[path[len(path)-1]] = value
+	return nil
+}
+
+func remove(properties map[string]interface{}, path []string) {
+	object := properties
+	for _, key := range path[:len(path)-1] {
+		nested, ok := object[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		object = nested
+	}
+	delete(object, path[len(path)-1])
+}
+
+// coerce converts a decoded JSON value to string, int, float or bool
+func coerce(value interface{}, target string) (interface{}, error) {
+	switch target {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+
+	case "int":
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, fmt.Errorf("%v is not an integer", value)
+		}
+		return int64(f), nil
+
+	case "float":
+		return toFloat(value)
+
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			return v != 0, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", v)
+			}
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to %s", value, target)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to a number", value)
+}
+
+// parseTime reads an RFC 3339 timestamp or Unix milliseconds
+func parseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp", v)
+		}
+		return t, nil
+	case float64:
+		return time.UnixMilli(int64(v)), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot read a timestamp from %T", value)
+}
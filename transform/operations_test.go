@@ -0,0 +1,220 @@
+package transform
+
+import (
+	"log-ingestion-server/models"
+	"reflect"
+	"testing"
+)
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		name           string
+		op             models.TransformOperation
+		eventName      string
+		properties     models.JSONB
+		wantEventName  string
+		wantProperties models.JSONB
+		wantErr        bool
+	}{
+		{
+			name:           "rename event",
+			op:             models.TransformOperation{Op: models.TransformRenameEvent, To: "screen_view"},
+			eventName:      "page_view",
+			properties:     models.JSONB{"screen": "home"},
+			wantEventName:  "screen_view",
+			wantProperties: models.JSONB{"screen": "home"},
+		},
+		{
+			name:           "move top-level key",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "scr", To: "screen"},
+			properties:     models.JSONB{"scr": "home"},
+			wantProperties: models.JSONB{"screen": "home"},
+		},
+		{
+			name:           "move into a new object",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "fps", To: "perf.fps"},
+			properties:     models.JSONB{"fps": 60.0},
+			wantProperties: models.JSONB{"perf": map[string]interface{}{"fps": 60.0}},
+		},
+		{
+			name:           "move out of a nested object",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "perf.fps", To: "fps"},
+			properties:     models.JSONB{"perf": map[string]interface{}{"fps": 60.0, "jank": 2.0}},
+			wantProperties: models.JSONB{"fps": 60.0, "perf": map[string]interface{}{"jank": 2.0}},
+		},
+		{
+			name:           "move keeps an existing destination",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "scr", To: "screen"},
+			properties:     models.JSONB{"scr": "old", "screen": "new"},
+			wantProperties: models.JSONB{"screen": "new"},
+		},
+		{
+			name:           "move of a missing key does nothing",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "scr", To: "screen"},
+			properties:     models.JSONB{"other": 1.0},
+			wantProperties: models.JSONB{"other": 1.0},
+		},
+		{
+			name:           "move through a non-object fails",
+			op:             models.TransformOperation{Op: models.TransformMove, From: "fps", To: "perf.fps"},
+			properties:     models.JSONB{"fps": 60.0, "perf": "fast"},
+			wantProperties: models.JSONB{"fps": 60.0, "perf": "fast"},
+			wantErr:        true,
+		},
+		{
+			name:           "drop",
+			op:             models.TransformOperation{Op: models.TransformDrop, Path: "debug.trace"},
+			properties:     models.JSONB{"debug": map[string]interface{}{"trace": "x", "level": 1.0}},
+			wantProperties: models.JSONB{"debug": map[string]interface{}{"level": 1.0}},
+		},
+		{
+			name:           "drop of a missing key does nothing",
+			op:             models.TransformOperation{Op: models.TransformDrop, Path: "debug.trace"},
+			properties:     models.JSONB{"debug": "off"},
+			wantProperties: models.JSONB{"debug": "off"},
+		},
+		{
+			name:           "coerce string to int",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "count", Type: "int"},
+			properties:     models.JSONB{"count": " 42 "},
+			wantProperties: models.JSONB{"count": int64(42)},
+		},
+		{
+			name:           "coerce fraction to int fails",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "count", Type: "int"},
+			properties:     models.JSONB{"count": 4.5},
+			wantProperties: models.JSONB{"count": 4.5},
+			wantErr:        true,
+		},
+		{
+			name:           "coerce number to string",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "build", Type: "string"},
+			properties:     models.JSONB{"build": 1042.0},
+			wantProperties: models.JSONB{"build": "1042"},
+		},
+		{
+			name:           "coerce bool to string",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "beta", Type: "string"},
+			properties:     models.JSONB{"beta": true},
+			wantProperties: models.JSONB{"beta": "true"},
+		},
+		{
+			name:           "coerce string to float",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "perf.fps", Type: "float"},
+			properties:     models.JSONB{"perf": map[string]interface{}{"fps": "59.9"}},
+			wantProperties: models.JSONB{"perf": map[string]interface{}{"fps": 59.9}},
+		},
+		{
+			name:           "coerce text to float fails",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "fps", Type: "float"},
+			properties:     models.JSONB{"fps": "fast"},
+			wantProperties: models.JSONB{"fps": "fast"},
+			wantErr:        true,
+		},
+		{
+			name:           "coerce string to bool",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "beta", Type: "bool"},
+			properties:     models.JSONB{"beta": "TRUE"},
+			wantProperties: models.JSONB{"beta": true},
+		},
+		{
+			name:           "coerce number to bool",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "beta", Type: "bool"},
+			properties:     models.JSONB{"beta": 0.0},
+			wantProperties: models.JSONB{"beta": false},
+		},
+		{
+			name:           "coerce object fails",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "perf", Type: "string"},
+			properties:     models.JSONB{"perf": map[string]interface{}{}},
+			wantProperties: models.JSONB{"perf": map[string]interface{}{}},
+			wantErr:        true,
+		},
+		{
+			name:           "coerce of a missing key does nothing",
+			op:             models.TransformOperation{Op: models.TransformCoerce, Path: "count", Type: "int"},
+			wantProperties: models.JSONB{},
+		},
+		{
+			name:           "derive duration in milliseconds",
+			op:             models.TransformOperation{Op: models.TransformDeriveDuration, Start: "started_at", End: "ended_at", To: "duration_ms"},
+			properties:     models.JSONB{"started_at": "2026-10-16T09:00:00Z", "ended_at": "2026-10-16T09:00:01.5Z"},
+			wantProperties: models.JSONB{"started_at": "2026-10-16T09:00:00Z", "ended_at": "2026-10-16T09:00:01.5Z", "duration_ms": int64(1500)},
+		},
+		{
+			name:           "derive duration in seconds from Unix milliseconds",
+			op:             models.TransformOperation{Op: models.TransformDeriveDuration, Start: "t.start", End: "t.end", To: "t.seconds", Unit: "s"},
+			properties:     models.JSONB{"t": map[string]interface{}{"start": 1000.0, "end": 3500.0}},
+			wantProperties: models.JSONB{"t": map[string]interface{}{"start": 1000.0, "end": 3500.0, "seconds": 2.5}},
+		},
+		{
+			name:           "derive duration across formats",
+			op:             models.TransformOperation{Op: models.TransformDeriveDuration, Start: "start", End: "end", To: "ms"},
+			properties:     models.JSONB{"start": 1792141200000.0, "end": "2026-10-16T09:00:00.25Z"},
+			wantProperties: models.JSONB{"start": 1792141200000.0, "end": "2026-10-16T09:00:00.25Z", "ms": int64(250)},
+		},
+		{
+			name:           "derive duration without an end does nothing",
+			op:             models.TransformOperation{Op: models.TransformDeriveDuration, Start: "start", End: "end", To: "ms"},
+			properties:     models.JSONB{"start": 1000.0},
+			wantProperties: models.JSONB{"start": 1000.0},
+		},
+		{
+			name:           "derive duration from an invalid timestamp fails",
+			op:             models.TransformOperation{Op: models.TransformDeriveDuration, Start: "start", End: "end", To: "ms"},
+			properties:     models.JSONB{"start": "yesterday", "end": 1000.0},
+			wantProperties: models.JSONB{"start": "yesterday", "end": 1000.0},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := compileOperation(tt.op)
+			if err != nil {
+				t.Fatalf("compileOperation: %v", err)
+			}
+
+			log := &models.AnalyticsLog{EventName: tt.eventName, Properties: tt.properties}
+			err = op.apply(log)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("apply error = %v, want error %t", err, tt.wantErr)
+			}
+			if log.EventName != tt.wantEventName {
+				t.Errorf("event name = %q, want %q", log.EventName, tt.wantEventName)
+			}
+			if !reflect.DeepEqual(log.Properties, tt.wantProperties) {
+				t.Errorf("properties = %#v, want %#v", log.Properties, tt.wantProperties)
+			}
+		})
+	}
+}
+
+func TestCompileOperationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		op   models.TransformOperation
+	}{
+		{name: "unknown op", op: models.TransformOperation{Op: "upcase", Path: "x"}},
+		{name: "rename without to", op: models.TransformOperation{Op: models.TransformRenameEvent}},
+		{name: "move without from", op: models.TransformOperation{Op: models.TransformMove, To: "b"}},
+		{name: "move without to", op: models.TransformOperation{Op: models.TransformMove, From: "a"}},
+		{name: "move into itself", op: models.TransformOperation{Op: models.TransformMove, From: "a", To: "a.b"}},
+		{name: "move onto its parent", op: models.TransformOperation{Op: models.TransformMove, From: "a.b", To: "a"}},
+		{name: "move to the same key", op: models.TransformOperation{Op: models.TransformMove, From: "a", To: "a"}},
+		{name: "drop without path", op: models.TransformOperation{Op: models.TransformDrop}},
+		{name: "drop with an empty key", op: models.TransformOperation{Op: models.TransformDrop, Path: "a..b"}},
+		{name: "coerce without type", op: models.TransformOperation{Op: models.TransformCoerce, Path: "a"}},
+		{name: "coerce without path", op: models.TransformOperation{Op: models.TransformCoerce, Type: "int"}},
+		{name: "derive duration without end", op: models.TransformOperation{Op: models.TransformDeriveDuration, Start: "s", To: "d"}},
+		{name: "derive duration without to", op: models.TransformOperation{Op: models.TransformDeriveDuration, Start: "s", End: "e"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileOperation(tt.op); err == nil {
+				t.Error("compileOperation accepted the operation")
+			}
+		})
+	}
+}
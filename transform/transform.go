@@ -0,0 +1,192 @@
+// Package transform rewrites ingested logs with the rules stored in the
+// transform_rules table, so payloads from older app versions are brought to
+// the current shape before they are stored.
+package transform
+
+import (
+	"fmt"
+	"log-ingestion-server/models"
+	"log-ingestion-server/semver"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Store loads the transform rules
+type Store interface {
+	ListTransformRules() ([]models.TransformRule, error)
+}
+
+// rule is a transform rule with its version range and operations compiled
+type rule struct {
+	models.TransformRule
+	versions   semver.Range
+	operations []operation
+}
+
+// RuleSet is a compiled, ordered list of transform rules
+type RuleSet struct {
+	rules []rule
+}
+
+// Compile checks and compiles rules, keeping their order
+func Compile(rules []models.TransformRule) (*RuleSet, error) {
+	set := &RuleSet{rules: make([]rule, 0, len(rules))}
+	for _, r := range rules {
+		compiled, err := compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("transform rule %s: %w", r.Name, err)
+		}
+		set.rules = append(set.rules, compiled)
+	}
+	return set, nil
+}
+
+// Validate checks a rule's patterns, version range and operations
+func Validate(r models.TransformRule) error {
+	_, err := compile(r)
+	return err
+}
+
+func compile(r models.TransformRule) (rule, error) {
+	compiled := rule{TransformRule: r}
+
+	if _, err := path.Match(r.EventName, ""); err != nil {
+		return rule{}, fmt.Errorf("invalid event_name pattern %q", r.EventName)
+	}
+
+	versions, err := semver.ParseRange(r.MinAppVersion, r.MaxAppVersion)
+	if err != nil {
+		return rule{}, err
+	}
+	compiled.versions = versions
+
+	if len(r.Operations) == 0 {
+		return rule{}, fmt.Errorf("no operations")
+	}
+	for i, op := range r.Operations {
+		compiledOp, err := compileOperation(op)
+		if err != nil {
+			return rule{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+		compiled.operations = append(compiled.operations, compiledOp)
+	}
+
+	return compiled, nil
+}
+
+// Apply runs the matching rules on a log in order, returning the rules that
+// matched. Operations that fail are skipped and reported.
+func (s *RuleSet) Apply(log *models.AnalyticsLog) []models.TransformApplication {
+	if s == nil {
+		return nil
+	}
+
+	var applied []models.TransformApplication
+	for i := range s.rules {
+		r := &s.rules[i]
+		if !r.matches(log) {
+			continue
+		}
+
+		application := models.TransformApplication{Rule: r.Name}
+		for j := range r.operations {
+			if err := r.operations[j].apply(log); err != nil {
+				application.Errors = append(application.Errors, fmt.Sprintf("operation %d (%s): %v", j, r.operations[j].Op, err))
+			}
+		}
+		applied = append(applied, application)
+	}
+	return applied
+}
+
+func (r *rule) matches(log *models.AnalyticsLog) bool {
+	if r.EventType != "" && r.EventType != log.EventType {
+		return false
+	}
+	if r.EventName != "" {
+		if ok, _ := path.Match(r.EventName, log.EventName); !ok {
+			return false
+		}
+	}
+	if !r.versions.Unbounded() {
+		if log.AppVersion == nil {
+			return false
+		}
+		version, err := semver.Parse(*log.AppVersion)
+		if err != nil || !r.versions.Contains(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry caches the active transform rules
+type Registry struct {
+	store Store
+
+	mu    sync.RWMutex
+	rules *RuleSet
+}
+
+// NewRegistry creates an empty registry backed by store
+func NewRegistry(store Store) *Registry {
+	return &Registry{store: store, rules: &RuleSet{}}
+}
+
+// Load replaces the cache with the active rules from the store. Invalid rules
+// are skipped and logged.
+func (r *Registry) Load() error {
+	stored, err := r.store.ListTransformRules()
+	if err != nil {
+		return err
+	}
+
+	rules := &RuleSet{rules: make([]rule, 0, len(stored))}
+	for _, s := range stored {
+		if !s.IsActive {
+			continue
+		}
+		compiled, err := compile(s)
+		if err != nil {
+			logrus.Errorf("Skipping transform rule %s: %v", s.Name, err)
+			continue
+		}
+		rules.rules = append(rules.rules, compiled)
+	}
+
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh periodically reloads the cache so rule changes made through
+// other server instances take effect
+func (r *Registry) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Load(); err != nil {
+				logrus.Errorf("Failed to refresh transform rules: %v", err)
+			}
+		}
+	}()
+}
+
+// Rules returns the active rules
+func (r *Registry) Rules() *RuleSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rules
+}
+
+// Apply runs the active rules on a log
+func (r *Registry) Apply(log *models.AnalyticsLog) []models.TransformApplication {
+	return r.Rules().Apply(log)
+}
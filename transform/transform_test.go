@@ -0,0 +1,162 @@
+package transform
+
+import (
+	"log-ingestion-server/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeStore struct {
+	rules []models.TransformRule
+}
+
+func (s *fakeStore) ListTransformRules() ([]models.TransformRule, error) {
+	return s.rules, nil
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestRuleMatching(t *testing.T) {
+	rename := []models.TransformOperation{{Op: models.TransformRenameEvent, To: "renamed"}}
+
+	tests := []struct {
+		name       string
+		rule       models.TransformRule
+		eventType  string
+		eventName  string
+		appVersion *string
+		want       bool
+	}{
+		{name: "match everything", rule: models.TransformRule{}, eventName: "tap", want: true},
+		{name: "event type", rule: models.TransformRule{EventType: "user_action"}, eventType: "user_action", want: true},
+		{name: "other event type", rule: models.TransformRule{EventType: "user_action"}, eventType: "error"},
+		{name: "event name pattern", rule: models.TransformRule{EventName: "checkout_*"}, eventName: "checkout_started", want: true},
+		{name: "other event name", rule: models.TransformRule{EventName: "checkout_*"}, eventName: "cart_opened"},
+		{name: "within version range", rule: models.TransformRule{MinAppVersion: "2.0", MaxAppVersion: "3.0"}, appVersion: stringPtr("2.4.1+88"), want: true},
+		{name: "at the upper bound", rule: models.TransformRule{MinAppVersion: "2.0", MaxAppVersion: "3.0"}, appVersion: stringPtr("3.0.0")},
+		{name: "prerelease of the upper bound", rule: models.TransformRule{MaxAppVersion: "3.0"}, appVersion: stringPtr("3.0.0-beta.1"), want: true},
+		{name: "prerelease of the lower bound", rule: models.TransformRule{MinAppVersion: "2.0"}, appVersion: stringPtr("2.0.0-rc.1")},
+		{name: "no app version", rule: models.TransformRule{MinAppVersion: "2.0"}},
+		{name: "unparseable app version", rule: models.TransformRule{MinAppVersion: "2.0"}, appVersion: stringPtr("nightly")},
+		{name: "no range ignores app version", rule: models.TransformRule{}, appVersion: stringPtr("nightly"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name = "rule"
+			tt.rule.Operations = rename
+			set, err := Compile([]models.TransformRule{tt.rule})
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+
+			log := &models.AnalyticsLog{EventType: tt.eventType, EventName: tt.eventName, AppVersion: tt.appVersion}
+			applied := set.Apply(log)
+			if got := len(applied) == 1; got != tt.want {
+				t.Errorf("rule applied = %t, want %t", got, tt.want)
+			}
+			if tt.want && log.EventName != "renamed" {
+				t.Errorf("event name = %q, want renamed", log.EventName)
+			}
+		})
+	}
+}
+
+// TestApplyChainsRules checks that each rule sees the output of the previous
+// ones and that failed operations are reported without stopping the rule
+func TestApplyChainsRules(t *testing.T) {
+	set, err := Compile([]models.TransformRule{
+		{Name: "v1-names", EventName: "page_view", Operations: []models.TransformOperation{
+			{Op: models.TransformRenameEvent, To: "screen_view"},
+			{Op: models.TransformMove, From: "page", To: "screen"},
+		}},
+		{Name: "screen-shape", EventName: "screen_view", Operations: []models.TransformOperation{
+			{Op: models.TransformCoerce, Path: "load_ms", Type: "int"},
+			{Op: models.TransformDrop, Path: "legacy"},
+		}},
+		{Name: "unrelated", EventName: "purchase", Operations: []models.TransformOperation{
+			{Op: models.TransformDrop, Path: "screen"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	log := &models.AnalyticsLog{EventName: "page_view", Properties: models.JSONB{"page": "home", "load_ms": "slow", "legacy": true}}
+	applied := set.Apply(log)
+
+	if log.EventName != "screen_view" {
+		t.Errorf("event name = %q, want screen_view", log.EventName)
+	}
+	if want := (models.JSONB{"screen": "home", "load_ms": "slow"}); !reflect.DeepEqual(log.Properties, want) {
+		t.Errorf("properties = %v, want %v", log.Properties, want)
+	}
+
+	if len(applied) != 2 || applied[0].Rule != "v1-names" || applied[1].Rule != "screen-shape" {
+		t.Fatalf("applied = %+v, want v1-names then screen-shape", applied)
+	}
+	if applied[0].Errors != nil {
+		t.Errorf("v1-names errors = %v, want none", applied[0].Errors)
+	}
+	if len(applied[1].Errors) != 1 || !strings.Contains(applied[1].Errors[0], "operation 0 (coerce)") {
+		t.Errorf("screen-shape errors = %v, want the failed coerce", applied[1].Errors)
+	}
+}
+
+func TestApplyNilRuleSet(t *testing.T) {
+	var set *RuleSet
+	log := &models.AnalyticsLog{EventName: "tap"}
+	if applied := set.Apply(log); applied != nil || log.EventName != "tap" {
+		t.Errorf("Apply on a nil rule set = %v, %q", applied, log.EventName)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	drop := []models.TransformOperation{{Op: models.TransformDrop, Path: "x"}}
+
+	tests := []struct {
+		name    string
+		rule    models.TransformRule
+		wantErr bool
+	}{
+		{name: "valid", rule: models.TransformRule{EventName: "tap_*", MinAppVersion: "1.0", MaxAppVersion: "2.0-beta", Operations: drop}},
+		{name: "invalid event name pattern", rule: models.TransformRule{EventName: "tap_[", Operations: drop}, wantErr: true},
+		{name: "invalid version", rule: models.TransformRule{MinAppVersion: "one", Operations: drop}, wantErr: true},
+		{name: "empty version range", rule: models.TransformRule{MinAppVersion: "2.0", MaxAppVersion: "2.0-beta", Operations: drop}, wantErr: true},
+		{name: "no operations", rule: models.TransformRule{}, wantErr: true},
+		{name: "invalid operation", rule: models.TransformRule{Operations: []models.TransformOperation{{Op: models.TransformDrop}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryLoadSkipsInactiveAndInvalidRules(t *testing.T) {
+	registry := NewRegistry(&fakeStore{rules: []models.TransformRule{
+		{Name: "inactive", Operations: []models.TransformOperation{{Op: models.TransformRenameEvent, To: "inactive"}}},
+		{Name: "invalid", IsActive: true},
+		{Name: "active", IsActive: true, Operations: []models.TransformOperation{{Op: models.TransformRenameEvent, To: "active"}}},
+	}})
+
+	// Before the first load no rules apply
+	log := &models.AnalyticsLog{EventName: "tap"}
+	if applied := registry.Apply(log); applied != nil {
+		t.Errorf("applied before Load = %v", applied)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	applied := registry.Apply(log)
+	if len(applied) != 1 || applied[0].Rule != "active" || log.EventName != "active" {
+		t.Errorf("applied = %+v, event name %q, want only the active rule", applied, log.EventName)
+	}
+}
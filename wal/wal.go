@@ -0,0 +1,800 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log-ingestion-server/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Segment files start with this magic header so stray files are never replayed
+const segmentMagic = "LOGWAL01"
+
+const (
+	segmentExt      = ".wal"
+	quarantineDir   = "quarantine"
+	recordHeaderLen = 8 // uint32 payload length + uint32 CRC-32C
+	maxRecordLen    = 16 << 20
+)
+
+// FsyncPolicy controls when appended records are flushed to stable storage
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the segment before every append returns
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs the active segment periodically in the background
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system
+	FsyncNever FsyncPolicy = "never"
+)
+
+var (
+	// ErrFull is returned when the WAL has reached its configured size limit
+	ErrFull = errors.New("write-ahead log is full")
+
+	// ErrClosed is returned when appending to a closed WAL
+	ErrClosed = errors.New("write-ahead log is closed")
+
+	// ErrCorruptSegment is returned with the records read before a segment's
+	// first torn or corrupt record
+	ErrCorruptSegment = errors.New("corrupt WAL segment")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Options configures a write-ahead log
+type Options struct {
+	Dir           string
+	SegmentSize   int64
+	MaxSize       int64
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+// ParseFsyncPolicy validates a policy name from configuration
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch policy := FsyncPolicy(strings.ToLower(s)); policy {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid fsync policy %q (must be always, interval or never)", s)
+	}
+}
+
+// segment tracks one segment file and how many of its records are still in flight
+type segment struct {
+	id        uint64
+	path      string
+	size      int64
+	records   int
+	pending   int  // records appended but not yet acknowledged or failed
+	replay    bool // some records failed to reach the database
+	sealed    bool
+	createdAt time.Time
+}
+
+// WAL is a segmented, checksummed write-ahead log of accepted analytics logs.
+// Records are appended before a request is acknowledged; a segment is deleted
+// once every record in it has been stored in the database, and segments with
+// failed records are kept until they are replayed.
+type WAL struct {
+	opts Options
+
+	mu       sync.Mutex
+	segments map[uint64]*segment
+	active   *segment
+	file     *os.File
+	writer   *bufio.Writer
+	dirty    bool
+	nextID   uint64
+	closed   bool
+	stop     chan struct{}
+	done     chan struct{}
+	metrics  *Metrics
+
+	// Appends are numbered so concurrent appends can share an fsync
+	appended   uint64     // number of the last append written to a segment
+	synced     uint64     // appends up to this one are on stable storage
+	syncFailed uint64     // appends up to this one failed their fsync
+	syncErr    error      // error of the last failed fsync
+	syncing    bool       // an fsync is in progress without mu held
+	syncDone   *sync.Cond // signalled when an fsync finishes
+}
+
+// Metrics holds Prometheus metrics for the write-ahead log
+type Metrics struct {
+	AppendedRecords     prometheus.Counter
+	CorruptRecords      prometheus.Counter
+	QuarantinedSegments prometheus.Counter
+	FsyncDuration       prometheus.Histogram
+	Segments            prometheus.GaugeFunc
+	Bytes               prometheus.GaugeFunc
+}
+
+// Open opens the WAL directory, creating it if needed. Segments left behind
+// by a previous run are validated and marked for replay.
+func Open(opts Options) (*WAL, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+
+	w := &WAL{
+		opts:     opts,
+		segments: make(map[uint64]*segment),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.syncDone = sync.NewCond(&w.mu)
+	w.registerMetrics()
+
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		go w.syncLoop()
+	} else {
+		close(w.done)
+	}
+
+	return w, nil
+}
+
+func (w *WAL) registerMetrics() {
+	w.metrics = &Metrics{
+		AppendedRecords: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "wal_appended_records_total",
+				Help: "Total number of records appended to the write-ahead log",
+			},
+		),
+		CorruptRecords: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "wal_corrupt_records_total",
+				Help: "Total number of torn or corrupt records skipped while reading segments",
+			},
+		),
+		QuarantinedSegments: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "wal_quarantined_segments_total",
+				Help: "Total number of segments moved to the quarantine directory instead of being deleted",
+			},
+		),
+		FsyncDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "wal_fsync_duration_seconds",
+				Help:    "Time taken to fsync the active WAL segment",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+			},
+		),
+		Segments: prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "wal_segments",
+				Help: "Number of WAL segment files on disk",
+			},
+			func() float64 { return float64(w.Stats().Segments) },
+		),
+		Bytes: prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "wal_bytes",
+				Help: "Total size of WAL segment files on disk",
+			},
+			func() float64 { return float64(w.Stats().Bytes) },
+		),
+	}
+
+	prometheus.MustRegister(
+		w.metrics.AppendedRecords,
+		w.metrics.CorruptRecords,
+		w.metrics.QuarantinedSegments,
+		w.metrics.FsyncDuration,
+		w.metrics.Segments,
+		w.metrics.Bytes,
+	)
+}
+
+// recover scans existing segment files and marks them for replay
+func (w *WAL) recover() error {
+	entries, err := os.ReadDir(w.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			logrus.Warnf("Ignoring unexpected file in WAL directory: %s", name)
+			continue
+		}
+
+		// A corrupt segment is still replayed up to its first bad record
+		path := filepath.Join(w.opts.Dir, name)
+		logs, err := w.readSegmentFile(path)
+		if err != nil && !errors.Is(err, ErrCorruptSegment) {
+			return fmt.Errorf("failed to recover WAL segment %s: %w", name, err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat WAL segment %s: %w", name, err)
+		}
+
+		w.segments[id] = &segment{
+			id:        id,
+			path:      path,
+			size:      info.Size(),
+			records:   len(logs),
+			replay:    true,
+			sealed:    true,
+			createdAt: info.ModTime(),
+		}
+
+		if id >= w.nextID {
+			w.nextID = id + 1
+		}
+	}
+
+	if len(w.segments) > 0 {
+		logrus.Infof("Recovered %d WAL segments awaiting replay", len(w.segments))
+	}
+
+	return nil
+}
+
+// openSegment creates a new active segment. Callers must hold mu or be in Open.
+func (w *WAL) openSegment() error {
+	id := w.nextID
+	w.nextID++
+
+	path := filepath.Join(w.opts.Dir, fmt.Sprintf("%020d%s", id, segmentExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create WAL segment: %w", err)
+	}
+
+	// The header is written through at once, so the file always ends with
+	// the last complete append and a failed one can be cut off
+	writer := bufio.NewWriterSize(file, 64*1024)
+	_, err = writer.WriteString(segmentMagic)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write WAL segment header: %w", err)
+	}
+
+	seg := &segment{
+		id:        id,
+		path:      path,
+		size:      int64(len(segmentMagic)),
+		createdAt: time.Now(),
+	}
+
+	w.segments[id] = seg
+	w.active = seg
+	w.file = file
+	w.writer = writer
+	w.dirty = true
+
+	return nil
+}
+
+// rotate seals the active segment and opens a new one. Callers must hold mu.
+func (w *WAL) rotate() error {
+	if err := w.closeActive(); err != nil {
+		return err
+	}
+	return w.openSegment()
+}
+
+// closeActive flushes, syncs and seals the active segment. Callers must hold mu.
+func (w *WAL) closeActive() error {
+	if w.active == nil {
+		return nil
+	}
+
+	if err := w.syncLocked(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close WAL segment: %w", err)
+	}
+
+	seg := w.active
+	seg.sealed = true
+	w.active = nil
+	w.file = nil
+	w.writer = nil
+
+	w.maybeRemoveLocked(seg)
+	return nil
+}
+
+// Append writes logs to the active segment and returns the segment ID that
+// must later be passed to Ack or Fail. All logs land in the same segment.
+// With the always fsync policy, concurrent appends share their fsyncs.
+func (w *WAL) Append(logs []models.AnalyticsLog) (uint64, error) {
+	payloads := make([][]byte, len(logs))
+	var total int64
+	for i := range logs {
+		payload, err := json.Marshal(&logs[i])
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode WAL record: %w", err)
+		}
+		payloads[i] = payload
+		total += int64(recordHeaderLen + len(payload))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.opts.MaxSize > 0 && w.totalSizeLocked()+total > w.opts.MaxSize {
+		return 0, ErrFull
+	}
+
+	if w.active == nil {
+		// A previous rotation failed to open its replacement segment, or
+		// the segment was abandoned after a failed write
+		if err := w.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.active.records > 0 && w.active.size+total > w.opts.SegmentSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	// Hand the data to the OS so a process crash does not lose it; the
+	// fsync policy only decides how much an OS crash can lose
+	seg := w.active
+	var err error
+	var header [recordHeaderLen]byte
+	for _, payload := range payloads {
+		binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+		if _, err = w.writer.Write(header[:]); err != nil {
+			break
+		}
+		if _, err = w.writer.Write(payload); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.writer.Flush()
+	}
+	if err != nil {
+		w.truncateLocked(seg.size)
+		return 0, fmt.Errorf("failed to write WAL record: %w", err)
+	}
+
+	seg.size += total
+	seg.records += len(logs)
+	seg.pending += len(logs)
+	w.dirty = true
+	w.appended++
+
+	if w.opts.Fsync == FsyncAlways {
+		if err := w.waitSyncedLocked(w.appended); err != nil {
+			// The records stay in the abandoned segment, which is deleted
+			// once the logs appended before them are stored
+			seg.pending -= len(logs)
+			w.maybeRemoveLocked(seg)
+			return 0, err
+		}
+	}
+
+	w.metrics.AppendedRecords.Add(float64(len(logs)))
+	return seg.id, nil
+}
+
+// waitSyncedLocked returns once append number seq is on stable storage.
+// Concurrent appends share fsyncs: one caller syncs the active segment with
+// mu released, covering every append written before it started, while the
+// others wait for it. A failed fsync may have lost any data written since
+// the last successful one, so every append since then fails and the segment
+// is abandoned rather than synced again. Callers must hold mu.
+func (w *WAL) waitSyncedLocked(seq uint64) error {
+	for {
+		switch {
+		case w.synced >= seq:
+			return nil
+		case w.syncFailed >= seq:
+			return fmt.Errorf("failed to sync WAL segment: %w", w.syncErr)
+		case w.syncing:
+			w.syncDone.Wait()
+			continue
+		}
+
+		w.syncing = true
+		target, file := w.appended, w.file
+		w.mu.Unlock()
+		start := time.Now()
+		err := file.Sync()
+		w.mu.Lock()
+		w.syncing = false
+		w.syncDone.Broadcast()
+
+		switch {
+		case w.synced >= target:
+			// A rotation synced and closed the segment in the meantime
+		case w.file != file:
+			// The segment was abandoned in the meantime, failing the appends
+		case err != nil:
+			logrus.Errorf("Failed to sync WAL segment, abandoning it: %v", err)
+			w.abandonLocked(err)
+		default:
+			w.synced = target
+			w.metrics.FsyncDuration.Observe(time.Since(start).Seconds())
+		}
+	}
+}
+
+// truncateLocked cuts a failed append off the end of the active segment, so
+// later appends do not follow a torn record. If that fails too, the segment
+// is abandoned with the torn record at its end, where replay stops anyway.
+// Callers must hold mu.
+func (w *WAL) truncateLocked(size int64) {
+	w.writer.Reset(w.file)
+	err := w.file.Truncate(size)
+	if err == nil {
+		_, err = w.file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to truncate WAL segment after a failed write, abandoning it: %v", err)
+		w.abandonLocked(fmt.Errorf("segment abandoned after a failed write: %w", err))
+	}
+}
+
+// abandonLocked seals the active segment without flushing or syncing it, and
+// fails the appends still waiting for it to be synced with cause. The next
+// append opens a new segment. Callers must hold mu.
+func (w *WAL) abandonLocked(cause error) {
+	if w.synced < w.appended {
+		w.syncFailed, w.syncErr = w.appended, cause
+	}
+
+	seg := w.active
+	w.file.Close()
+	seg.sealed = true
+	w.active = nil
+	w.file = nil
+	w.writer = nil
+
+	w.maybeRemoveLocked(seg)
+}
+
+// Ack records that n logs from a segment were stored in the database
+func (w *WAL) Ack(id uint64, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seg, ok := w.segments[id]
+	if !ok {
+		return
+	}
+
+	seg.pending -= n
+	w.maybeRemoveLocked(seg)
+}
+
+// Fail records that n logs from a segment could not be stored. The segment is
+// sealed and kept on disk until it has been replayed.
+func (w *WAL) Fail(id uint64, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seg, ok := w.segments[id]
+	if !ok {
+		return
+	}
+
+	seg.pending -= n
+	seg.replay = true
+
+	if seg == w.active && !w.closed {
+		if err := w.rotate(); err != nil {
+			logrus.Errorf("Failed to rotate WAL segment: %v", err)
+		}
+	}
+}
+
+// maybeRemoveLocked deletes a sealed segment whose records are all stored
+func (w *WAL) maybeRemoveLocked(seg *segment) {
+	if !seg.sealed || seg.pending > 0 || seg.replay {
+		return
+	}
+
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		logrus.Errorf("Failed to remove WAL segment %s: %v", seg.path, err)
+		return
+	}
+	delete(w.segments, seg.id)
+}
+
+// ReplayCandidates returns sealed segments with failed records and nothing
+// still in flight, oldest first
+func (w *WAL) ReplayCandidates() []uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var ids []uint64
+	for id, seg := range w.segments {
+		if seg.sealed && seg.replay && seg.pending <= 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// ReadSegment returns every valid record in a sealed segment. For a corrupt
+// segment it returns the records before the corruption and ErrCorruptSegment.
+func (w *WAL) ReadSegment(id uint64) ([]models.AnalyticsLog, error) {
+	w.mu.Lock()
+	seg, ok := w.segments[id]
+	w.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("WAL segment %d not found", id)
+	}
+	if !seg.sealed {
+		return nil, fmt.Errorf("WAL segment %d is still active", id)
+	}
+
+	return w.readSegmentFile(seg.path)
+}
+
+// Remove deletes a replayed segment
+func (w *WAL) Remove(id uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seg, ok := w.segments[id]
+	if !ok {
+		return nil
+	}
+	if !seg.sealed {
+		return fmt.Errorf("WAL segment %d is still active", id)
+	}
+
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove WAL segment: %w", err)
+	}
+	delete(w.segments, id)
+	return nil
+}
+
+// Quarantine moves a replayed segment that is corrupt, or that still holds
+// records the database did not store, into the quarantine subdirectory. It
+// is kept there for inspection and never replayed again.
+func (w *WAL) Quarantine(id uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seg, ok := w.segments[id]
+	if !ok {
+		return nil
+	}
+	if !seg.sealed {
+		return fmt.Errorf("WAL segment %d is still active", id)
+	}
+
+	dir := filepath.Join(w.opts.Dir, quarantineDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create WAL quarantine directory: %w", err)
+	}
+	if err := os.Rename(seg.path, filepath.Join(dir, filepath.Base(seg.path))); err != nil {
+		return fmt.Errorf("failed to quarantine WAL segment: %w", err)
+	}
+	delete(w.segments, id)
+	w.metrics.QuarantinedSegments.Inc()
+	return nil
+}
+
+// readSegmentFile decodes records until the end of the file or the first torn
+// or corrupt record, usually the tail of an interrupted write. Records after
+// a corrupt one cannot be located, so the caller also gets ErrCorruptSegment.
+func (w *WAL) readSegmentFile(path string) ([]models.AnalyticsLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+
+	magic := make([]byte, len(segmentMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		// A crash right after creating the segment leaves it empty
+		return nil, nil
+	}
+	if string(magic) != segmentMagic {
+		return nil, w.corruption(path, 0, "invalid segment header")
+	}
+
+	var logs []models.AnalyticsLog
+	var header [recordHeaderLen]byte
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			if err != io.EOF {
+				return logs, w.corruption(path, len(logs), "truncated record header")
+			}
+			return logs, nil
+		}
+
+		length := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		if length > maxRecordLen {
+			return logs, w.corruption(path, len(logs), "record length out of range")
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return logs, w.corruption(path, len(logs), "truncated record payload")
+		}
+
+		if crc32.Checksum(payload, crcTable) != checksum {
+			return logs, w.corruption(path, len(logs), "checksum mismatch")
+		}
+
+		var log models.AnalyticsLog
+		if err := json.Unmarshal(payload, &log); err != nil {
+			return logs, w.corruption(path, len(logs), "undecodable record")
+		}
+		logs = append(logs, log)
+	}
+}
+
+// corruption counts and logs a corrupt record and returns the error for it
+func (w *WAL) corruption(path string, valid int, reason string) error {
+	w.metrics.CorruptRecords.Inc()
+	logrus.Warnf("WAL segment %s: %s after %d valid records, ignoring the rest", path, reason, valid)
+	return fmt.Errorf("%w %s: %s after %d valid records", ErrCorruptSegment, filepath.Base(path), reason, valid)
+}
+
+// syncLoop periodically syncs the active segment for the interval policy
+func (w *WAL) syncLoop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed {
+				if err := w.syncLocked(); err != nil {
+					logrus.Errorf("Failed to sync WAL segment: %v", err)
+				}
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// syncLocked flushes buffered records and fsyncs the active segment
+func (w *WAL) syncLocked() error {
+	if w.active == nil || !w.dirty {
+		return nil
+	}
+
+	start := time.Now()
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush WAL segment: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL segment: %w", err)
+	}
+	w.metrics.FsyncDuration.Observe(time.Since(start).Seconds())
+	w.dirty = false
+	w.synced = w.appended
+
+	return nil
+}
+
+func (w *WAL) totalSizeLocked() int64 {
+	var total int64
+	for _, seg := range w.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// Close syncs and seals the active segment. Segments that still hold
+// unstored records stay on disk and are replayed on the next start.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.stop)
+	err := w.closeActive()
+	w.mu.Unlock()
+
+	<-w.done
+	return err
+}
+
+// Stats describes the WAL backlog
+type Stats struct {
+	Segments        int        `json:"segments"`
+	Bytes           int64      `json:"bytes"`
+	Records         int        `json:"records"`
+	PendingRecords  int        `json:"pending_records"`
+	ReplaySegments  int        `json:"replay_segments"`
+	ReplayRecords   int        `json:"replay_records"`
+	OldestSegmentAt *time.Time `json:"oldest_segment_at,omitempty"`
+	ActiveSegmentID uint64     `json:"active_segment_id"`
+	FsyncPolicy     string     `json:"fsync_policy"`
+	MaxBytes        int64      `json:"max_bytes"`
+}
+
+// Stats returns a snapshot of the WAL backlog
+func (w *WAL) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := Stats{
+		Segments:    len(w.segments),
+		FsyncPolicy: string(w.opts.Fsync),
+		MaxBytes:    w.opts.MaxSize,
+	}
+	if w.active != nil {
+		stats.ActiveSegmentID = w.active.id
+	}
+
+	for _, seg := range w.segments {
+		stats.Bytes += seg.size
+		stats.Records += seg.records
+		if seg.pending > 0 {
+			stats.PendingRecords += seg.pending
+		}
+		if seg.replay {
+			stats.ReplaySegments++
+			stats.ReplayRecords += seg.records
+		}
+		if stats.OldestSegmentAt == nil || seg.createdAt.Before(*stats.OldestSegmentAt) {
+			createdAt := seg.createdAt
+			stats.OldestSegmentAt = &createdAt
+		}
+	}
+
+	return stats
+}
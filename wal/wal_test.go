@@ -0,0 +1,314 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-ingestion-server/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// useTestRegistry gives each WAL its own Prometheus registry, since Open
+// registers the WAL metrics
+func useTestRegistry(t *testing.T) {
+	t.Helper()
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+}
+
+func openTestWAL(t *testing.T, dir string) *WAL {
+	t.Helper()
+	useTestRegistry(t)
+	w, err := Open(Options{Dir: dir, SegmentSize: 1 << 20, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return w
+}
+
+func testLogs(eventIDs ...string) []models.AnalyticsLog {
+	logs := make([]models.AnalyticsLog, len(eventIDs))
+	for i, eventID := range eventIDs {
+		logs[i] = models.AnalyticsLog{EventID: eventID, EventType: "behavioral", EventName: "habit_completed"}
+	}
+	return logs
+}
+
+// writeSegment appends logs to a fresh WAL in dir and closes it without
+// acknowledging them, as a crash would, returning the segment path and the
+// offset each record starts at
+func writeSegment(t *testing.T, dir string, logs []models.AnalyticsLog) (string, []int64) {
+	t.Helper()
+	w := openTestWAL(t, dir)
+	if _, err := w.Append(logs); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(paths) != 1 {
+		t.Fatalf("found segments %v, want one", paths)
+	}
+
+	offsets := make([]int64, len(logs))
+	offset := int64(len(segmentMagic))
+	for i := range logs {
+		offsets[i] = offset
+		payload, _ := json.Marshal(&logs[i])
+		offset += int64(recordHeaderLen + len(payload))
+	}
+	return paths[0], offsets
+}
+
+func TestReadSegment(t *testing.T) {
+	logs := testLogs("e1", "e2", "e3")
+
+	tests := []struct {
+		name        string
+		damage      func(t *testing.T, path string, offsets []int64)
+		wantLogs    int
+		wantCorrupt bool
+	}{
+		{
+			name:     "intact",
+			damage:   func(t *testing.T, path string, offsets []int64) {},
+			wantLogs: 3,
+		},
+		{
+			name: "truncated payload of the last record",
+			damage: func(t *testing.T, path string, offsets []int64) {
+				truncate(t, path, offsets[2]+recordHeaderLen+5)
+			},
+			wantLogs:    2,
+			wantCorrupt: true,
+		},
+		{
+			name: "truncated record header",
+			damage: func(t *testing.T, path string, offsets []int64) {
+				truncate(t, path, offsets[2]+3)
+			},
+			wantLogs:    2,
+			wantCorrupt: true,
+		},
+		{
+			name: "checksum mismatch in the middle",
+			damage: func(t *testing.T, path string, offsets []int64) {
+				flipByte(t, path, offsets[1]+recordHeaderLen+2)
+			},
+			wantLogs:    1,
+			wantCorrupt: true,
+		},
+		{
+			name: "length out of range",
+			damage: func(t *testing.T, path string, offsets []int64) {
+				writeAt(t, path, offsets[0], []byte{0xff, 0xff, 0xff, 0xff})
+			},
+			wantLogs:    0,
+			wantCorrupt: true,
+		},
+		{
+			name: "invalid segment header",
+			damage: func(t *testing.T, path string, offsets []int64) {
+				writeAt(t, path, 0, []byte("NOTAWAL!"))
+			},
+			wantLogs:    0,
+			wantCorrupt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path, offsets := writeSegment(t, dir, logs)
+			tt.damage(t, path, offsets)
+
+			// Damaged segments must not stop the WAL from opening
+			w := openTestWAL(t, dir)
+			defer w.Close()
+
+			ids := w.ReplayCandidates()
+			if len(ids) != 1 {
+				t.Fatalf("replay candidates = %v, want one segment", ids)
+			}
+
+			got, err := w.ReadSegment(ids[0])
+			if errors.Is(err, ErrCorruptSegment) != tt.wantCorrupt {
+				t.Fatalf("ReadSegment error = %v, want corrupt %t", err, tt.wantCorrupt)
+			}
+			if !tt.wantCorrupt && err != nil {
+				t.Fatalf("ReadSegment: %v", err)
+			}
+			if len(got) != tt.wantLogs {
+				t.Fatalf("read %d logs, want %d", len(got), tt.wantLogs)
+			}
+			for i := range got {
+				if got[i].EventID != logs[i].EventID {
+					t.Errorf("log %d = %s, want %s", i, got[i].EventID, logs[i].EventID)
+				}
+			}
+		})
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	path, offsets := writeSegment(t, dir, testLogs("e1", "e2"))
+	truncate(t, path, offsets[1]+1)
+
+	w := openTestWAL(t, dir)
+	id := w.ReplayCandidates()[0]
+	if err := w.Quarantine(id); err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if ids := w.ReplayCandidates(); len(ids) != 0 {
+		t.Errorf("replay candidates after quarantine = %v, want none", ids)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir, filepath.Base(path))); err != nil {
+		t.Errorf("segment not in quarantine directory: %v", err)
+	}
+
+	// The quarantined segment is not picked up again on the next start
+	w = openTestWAL(t, dir)
+	defer w.Close()
+	if ids := w.ReplayCandidates(); len(ids) != 0 {
+		t.Errorf("replay candidates after reopening = %v, want none", ids)
+	}
+}
+
+// TestConcurrentAppends checks that appends sharing fsyncs all land intact
+func TestConcurrentAppends(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir)
+
+	const appenders, perAppender = 16, 20
+	errs := make(chan error, appenders)
+	for i := 0; i < appenders; i++ {
+		go func(i int) {
+			for j := 0; j < perAppender; j++ {
+				if _, err := w.Append(testLogs(fmt.Sprintf("e%d-%d", i, j))); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(i)
+	}
+	for i := 0; i < appenders; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if w.synced != w.appended {
+		t.Errorf("synced through append %d of %d", w.synced, w.appended)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	w = openTestWAL(t, dir)
+	defer w.Close()
+	logs, err := w.ReadSegment(w.ReplayCandidates()[0])
+	if err != nil {
+		t.Fatalf("ReadSegment: %v", err)
+	}
+	if len(logs) != appenders*perAppender {
+		t.Errorf("read %d logs, want %d", len(logs), appenders*perAppender)
+	}
+}
+
+// failingWriter writes the first n bytes it is given, then fails
+type failingWriter struct {
+	file *os.File
+	n    int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.n {
+		written, _ := f.file.Write(p[:f.n])
+		f.n -= written
+		return written, errors.New("no space left on device")
+	}
+	f.n -= len(p)
+	return f.file.Write(p)
+}
+
+// TestAppendTruncatesFailedWrite checks that a partly written append is cut
+// off, so the appends after it are not hidden behind a torn record
+func TestAppendTruncatesFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir)
+
+	if _, err := w.Append(testLogs("e1")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	w.writer = bufio.NewWriter(&failingWriter{file: w.file, n: recordHeaderLen + 5})
+	if _, err := w.Append(testLogs("e2", "e3")); err == nil {
+		t.Fatal("Append succeeded through a failing writer")
+	}
+	if _, err := w.Append(testLogs("e4")); err != nil {
+		t.Fatalf("Append after a failed write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	w = openTestWAL(t, dir)
+	defer w.Close()
+	ids := w.ReplayCandidates()
+	if len(ids) != 1 {
+		t.Fatalf("replay candidates = %v, want one segment", ids)
+	}
+	logs, err := w.ReadSegment(ids[0])
+	if err != nil {
+		t.Fatalf("ReadSegment: %v", err)
+	}
+	var eventIDs []string
+	for _, log := range logs {
+		eventIDs = append(eventIDs, log.EventID)
+	}
+	if want := []string{"e1", "e4"}; !reflect.DeepEqual(eventIDs, want) {
+		t.Errorf("segment holds %v, want %v", eventIDs, want)
+	}
+}
+
+func truncate(t *testing.T, path string, size int64) {
+	t.Helper()
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatalf("failed to truncate segment: %v", err)
+	}
+}
+
+func writeAt(t *testing.T, path string, offset int64, data []byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(data, offset); err != nil {
+		t.Fatalf("failed to damage segment: %v", err)
+	}
+}
+
+func flipByte(t *testing.T, path string, offset int64) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read segment: %v", err)
+	}
+	writeAt(t, path, offset, []byte{data[offset] ^ 0xff})
+}
@@ -0,0 +1,143 @@
+// Package wireformat decodes ingest requests sent as Protocol Buffers (using
+// proto/analytics/v1/analytics.proto) or MessagePack into the same models the
+// JSON endpoints bind, so every format is validated and stored identically.
+package wireformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-ingestion-server/models"
+	analyticsv1 "log-ingestion-server/proto/analytics/v1"
+	"mime"
+	"reflect"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Format is a request body encoding
+type Format string
+
+// Supported formats
+const (
+	JSON     Format = "json"
+	Protobuf Format = "protobuf"
+	MsgPack  Format = "msgpack"
+)
+
+// FromContentType picks the format for a Content-Type header. Anything that
+// is not Protocol Buffers or MessagePack is treated as JSON, as before.
+func FromContentType(contentType string) Format {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+		return Protobuf
+	case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+		return MsgPack
+	default:
+		return JSON
+	}
+}
+
+// UnmarshalLog decodes a protobuf AnalyticsLog
+func UnmarshalLog(data []byte, log *models.AnalyticsLog) error {
+	var message analyticsv1.AnalyticsLog
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	return convertLog(&message, log)
+}
+
+// UnmarshalBatch decodes a protobuf BatchRequest
+func UnmarshalBatch(data []byte, batch *models.BatchRequest) error {
+	var message analyticsv1.BatchRequest
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+
+	if message.SentAt != nil {
+		sentAt, err := convertTimestamp(message.SentAt)
+		if err != nil {
+			return fmt.Errorf("sent_at: %w", err)
+		}
+		batch.SentAt = &sentAt
+	}
+
+	for i, m := range message.Logs {
+		var log models.AnalyticsLog
+		if err := convertLog(m, &log); err != nil {
+			return fmt.Errorf("logs[%d]: %w", i, err)
+		}
+		batch.Logs = append(batch.Logs, log)
+	}
+
+	return nil
+}
+
+// convertLog copies a decoded AnalyticsLog into its model
+func convertLog(m *analyticsv1.AnalyticsLog, log *models.AnalyticsLog) error {
+	log.EventID = m.EventId
+	log.EventType = m.EventType
+	log.EventName = m.EventName
+	log.UserID = m.UserId
+	log.SessionID = m.SessionId
+	log.AppVersion = m.AppVersion
+	log.Priority = m.Priority
+	log.SampleRate = m.SampleRate
+
+	if m.SequenceNumber != nil {
+		sequence := int(*m.SequenceNumber)
+		log.SequenceNumber = &sequence
+	}
+	if m.Timestamp != nil {
+		timestamp, err := convertTimestamp(m.Timestamp)
+		if err != nil {
+			return fmt.Errorf("timestamp: %w", err)
+		}
+		log.Timestamp = timestamp
+	}
+	if m.Properties != nil {
+		log.Properties = models.JSONB(m.Properties.AsMap())
+	}
+	if m.DeviceInfo != nil {
+		log.DeviceInfo = models.JSONB(m.DeviceInfo.AsMap())
+	}
+
+	return nil
+}
+
+// convertTimestamp checks a google.protobuf.Timestamp is in range
+func convertTimestamp(timestamp *timestamppb.Timestamp) (time.Time, error) {
+	if err := timestamp.CheckValid(); err != nil {
+		return time.Time{}, err
+	}
+	return timestamp.AsTime(), nil
+}
+
+// msgpackHandle decodes MessagePack into the generic values encoding/json produces
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}()
+
+// UnmarshalMsgPack decodes a MessagePack document into v. The document is
+// converted to JSON and decoded with encoding/json, so field names, types and
+// defaults behave exactly as for a JSON request. MessagePack timestamps are
+// accepted wherever JSON expects an RFC 3339 string.
+func UnmarshalMsgPack(data []byte, v interface{}) error {
+	var document interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&document); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, v)
+}
@@ -0,0 +1,315 @@
+package wireformat
+
+import (
+	"log-ingestion-server/models"
+	analyticsv1 "log-ingestion-server/proto/analytics/v1"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func mustStruct(t *testing.T, m map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	return s
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return data
+}
+
+func TestUnmarshalLog(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 123456789, time.UTC)
+	userID, sessionID, appVersion := "user-1", "session-1", "2.4.0"
+	sequence := 7
+
+	tests := []struct {
+		name    string
+		message *analyticsv1.AnalyticsLog
+		want    models.AnalyticsLog
+	}{
+		{
+			name: "every field",
+			message: &analyticsv1.AnalyticsLog{
+				EventId:   "e1",
+				Timestamp: timestamppb.New(at),
+				EventType: "behavioral",
+				EventName: "habit_completed",
+				Properties: mustStruct(t, map[string]interface{}{
+					"habit":  "run",
+					"streak": 3,
+					"done":   true,
+					"note":   nil,
+					"tags":   []interface{}{"a", 1.5},
+					"goal":   map[string]interface{}{"days": 30},
+				}),
+				UserId:         &userID,
+				SessionId:      &sessionID,
+				AppVersion:     &appVersion,
+				DeviceInfo:     mustStruct(t, map[string]interface{}{"platform": "ios"}),
+				SequenceNumber: proto.Int64(7),
+				Priority:       "high",
+				SampleRate:     0.25,
+			},
+			want: models.AnalyticsLog{
+				EventID:   "e1",
+				Timestamp: at,
+				EventType: "behavioral",
+				EventName: "habit_completed",
+				// Struct numbers are doubles, as in JSON
+				Properties: models.JSONB{
+					"habit":  "run",
+					"streak": 3.0,
+					"done":   true,
+					"note":   nil,
+					"tags":   []interface{}{"a", 1.5},
+					"goal":   map[string]interface{}{"days": 30.0},
+				},
+				UserID:         &userID,
+				SessionID:      &sessionID,
+				AppVersion:     &appVersion,
+				DeviceInfo:     models.JSONB{"platform": "ios"},
+				SequenceNumber: &sequence,
+				Priority:       "high",
+				SampleRate:     0.25,
+			},
+		},
+		{
+			name:    "required fields only",
+			message: &analyticsv1.AnalyticsLog{EventId: "e1", EventType: "system", EventName: "app_open"},
+			want:    models.AnalyticsLog{EventID: "e1", EventType: "system", EventName: "app_open"},
+		},
+		{
+			// Explicitly set optional fields are distinguishable from unset ones
+			name:    "zero optional values",
+			message: &analyticsv1.AnalyticsLog{EventId: "e1", UserId: proto.String(""), SequenceNumber: proto.Int64(0), Properties: &structpb.Struct{}},
+			want:    models.AnalyticsLog{EventID: "e1", UserID: proto.String(""), SequenceNumber: new(int), Properties: models.JSONB{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log models.AnalyticsLog
+			if err := UnmarshalLog(mustMarshal(t, tt.message), &log); err != nil {
+				t.Fatalf("UnmarshalLog: %v", err)
+			}
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("UnmarshalLog =\n%+v\nwant\n%+v", log, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalLogSkipsUnknownFields(t *testing.T) {
+	data := mustMarshal(t, &analyticsv1.AnalyticsLog{EventId: "e1", SampleRate: 0.5})
+	// Fields added by newer clients
+	data = protowire.AppendTag(data, 99, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 1)
+	data = protowire.AppendTag(data, 100, protowire.BytesType)
+	data = protowire.AppendString(data, "future")
+
+	var log models.AnalyticsLog
+	if err := UnmarshalLog(data, &log); err != nil {
+		t.Fatalf("UnmarshalLog: %v", err)
+	}
+	if log.EventID != "e1" || log.SampleRate != 0.5 {
+		t.Errorf("decoded %+v", log)
+	}
+}
+
+func TestUnmarshalLogErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:    "timestamp out of range",
+			data:    mustMarshal(t, &analyticsv1.AnalyticsLog{Timestamp: &timestamppb.Timestamp{Seconds: 1, Nanos: 1e9}}),
+			wantErr: "timestamp",
+		},
+		{
+			name: "invalid tag",
+			data: []byte{0x00},
+		},
+		{
+			name: "truncated",
+			data: mustMarshal(t, &analyticsv1.AnalyticsLog{EventId: "e1"})[:2],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log models.AnalyticsLog
+			err := UnmarshalLog(tt.data, &log)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UnmarshalLog error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalBatch(t *testing.T) {
+	sentAt := time.Date(2026, 10, 16, 9, 31, 0, 0, time.UTC)
+	data := mustMarshal(t, &analyticsv1.BatchRequest{
+		Logs: []*analyticsv1.AnalyticsLog{
+			{EventId: "e1", EventName: "first"},
+			{EventId: "e2", EventName: "second", SequenceNumber: proto.Int64(2)},
+		},
+		SentAt: timestamppb.New(sentAt),
+	})
+
+	var batch models.BatchRequest
+	if err := UnmarshalBatch(data, &batch); err != nil {
+		t.Fatalf("UnmarshalBatch: %v", err)
+	}
+
+	if batch.SentAt == nil || !batch.SentAt.Equal(sentAt) {
+		t.Errorf("sent_at = %v, want %v", batch.SentAt, sentAt)
+	}
+	if len(batch.Logs) != 2 || batch.Logs[0].EventID != "e1" || batch.Logs[1].EventName != "second" {
+		t.Fatalf("logs = %+v", batch.Logs)
+	}
+	if batch.Logs[1].SequenceNumber == nil || *batch.Logs[1].SequenceNumber != 2 {
+		t.Errorf("sequence_number = %v, want 2", batch.Logs[1].SequenceNumber)
+	}
+}
+
+func TestUnmarshalBatchErrors(t *testing.T) {
+	invalid := &timestamppb.Timestamp{Seconds: -62135596801}
+	tests := []struct {
+		name    string
+		message *analyticsv1.BatchRequest
+		wantErr string
+	}{
+		{
+			name:    "invalid sent_at",
+			message: &analyticsv1.BatchRequest{SentAt: invalid},
+			wantErr: "sent_at",
+		},
+		{
+			name:    "invalid log",
+			message: &analyticsv1.BatchRequest{Logs: []*analyticsv1.AnalyticsLog{{EventId: "e1"}, {EventId: "e2", Timestamp: invalid}}},
+			wantErr: "logs[1]: timestamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batch models.BatchRequest
+			err := UnmarshalBatch(mustMarshal(t, tt.message), &batch)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UnmarshalBatch error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMsgPack(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		timestamp interface{}
+	}{
+		{name: "RFC 3339 timestamp", timestamp: at.Format(time.RFC3339)},
+		{name: "timestamp extension", timestamp: at},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data []byte
+			document := map[string]interface{}{
+				"event_id":        "e1",
+				"timestamp":       tt.timestamp,
+				"event_type":      "behavioral",
+				"event_name":      "habit_completed",
+				"properties":      map[string]interface{}{"streak": 3, "tags": []interface{}{"a"}},
+				"user_id":         "user-1",
+				"sequence_number": 7,
+				"sample_rate":     0.5,
+			}
+			// WriteExt writes time.Time as the MessagePack timestamp extension
+			handle := &codec.MsgpackHandle{WriteExt: true}
+			if err := codec.NewEncoderBytes(&data, handle).Encode(document); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			var log models.AnalyticsLog
+			if err := UnmarshalMsgPack(data, &log); err != nil {
+				t.Fatalf("UnmarshalMsgPack: %v", err)
+			}
+
+			if log.EventID != "e1" || log.EventType != "behavioral" || log.EventName != "habit_completed" {
+				t.Errorf("decoded %s/%s/%s", log.EventID, log.EventType, log.EventName)
+			}
+			if !log.Timestamp.Equal(at) {
+				t.Errorf("timestamp = %v, want %v", log.Timestamp, at)
+			}
+			if want := (models.JSONB{"streak": 3.0, "tags": []interface{}{"a"}}); !reflect.DeepEqual(log.Properties, want) {
+				t.Errorf("properties = %#v, want %#v", log.Properties, want)
+			}
+			if log.UserID == nil || *log.UserID != "user-1" || log.SequenceNumber == nil || *log.SequenceNumber != 7 || log.SampleRate != 0.5 {
+				t.Errorf("decoded %+v", log)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMsgPackBatch(t *testing.T) {
+	var data []byte
+	document := map[string]interface{}{
+		"logs": []interface{}{
+			map[string]interface{}{"event_id": "e1"},
+			map[string]interface{}{"event_id": "e2"},
+		},
+		"sent_at": "2026-10-16T09:31:00Z",
+	}
+	if err := codec.NewEncoderBytes(&data, &codec.MsgpackHandle{}).Encode(document); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var batch models.BatchRequest
+	if err := UnmarshalMsgPack(data, &batch); err != nil {
+		t.Fatalf("UnmarshalMsgPack: %v", err)
+	}
+	if len(batch.Logs) != 2 || batch.Logs[1].EventID != "e2" || batch.SentAt == nil {
+		t.Errorf("decoded %+v", batch)
+	}
+
+	if err := UnmarshalMsgPack([]byte{0xc1}, &batch); err == nil {
+		t.Error("UnmarshalMsgPack accepted an invalid document")
+	}
+}
+
+func TestFromContentType(t *testing.T) {
+	tests := map[string]Format{
+		"application/x-protobuf":          Protobuf,
+		"application/protobuf":            Protobuf,
+		"application/msgpack":             MsgPack,
+		"application/x-msgpack":           MsgPack,
+		"application/json":                JSON,
+		"application/json; charset=utf-8": JSON,
+		"":                                JSON,
+		"text/plain":                      JSON,
+	}
+	for contentType, want := range tests {
+		if got := FromContentType(contentType); got != want {
+			t.Errorf("FromContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}